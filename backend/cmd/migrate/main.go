@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
+	"workbench/internal/config"
+	"workbench/internal/database"
+	"workbench/internal/logging"
+)
+
+const usage = `Usage: migrate <command> [argument]
+
+Commands:
+  up [version]   apply pending migrations, up to version when given
+  down [steps]   roll back the latest applied migrations (default 1)
+  status         list migrations and whether they are applied`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	argument := ""
+	if len(os.Args) > 2 {
+		argument = os.Args[2]
+	}
+
+	// Load configuration
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Fatal("Failed to load configuration: ", err)
+	}
+	logging.Setup(os.Stderr, cfg.App.LogLevel, cfg.App.LogFormat)
+
+	db, err := database.Connect(&cfg.Database)
+	if err != nil {
+		slog.Error("❌ Failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	switch command {
+	case "up":
+		var target int64
+		if argument != "" {
+			if target, err = strconv.ParseInt(argument, 10, 64); err != nil || target <= 0 {
+				slog.Error("❌ Invalid version", "version", argument)
+				os.Exit(2)
+			}
+		}
+		applied, err := database.MigrateUp(db, target)
+		if err != nil {
+			slog.Error("❌ Migration failed", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("✅ Migrations applied", "count", len(applied))
+
+	case "down":
+		steps := 1
+		if argument != "" {
+			if steps, err = strconv.Atoi(argument); err != nil || steps <= 0 {
+				slog.Error("❌ Invalid number of steps", "steps", argument)
+				os.Exit(2)
+			}
+		}
+		rolledBack, err := database.MigrateDown(db, steps)
+		if err != nil {
+			slog.Error("❌ Migration failed", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("✅ Migrations rolled back", "count", len(rolledBack))
+
+	case "status":
+		states, err := database.MigrationStatus(db)
+		if err != nil {
+			slog.Error("❌ Failed to read migration status", "error", err)
+			os.Exit(1)
+		}
+		pending := 0
+		for _, state := range states {
+			status := "pending"
+			switch {
+			case state.Missing:
+				status = "applied " + state.AppliedAt.Format("2006-01-02 15:04:05") + " (not in this build)"
+			case state.Applied:
+				status = "applied " + state.AppliedAt.Format("2006-01-02 15:04:05")
+			default:
+				pending++
+			}
+			fmt.Printf("%6d  %-40s %s\n", state.Version, state.Name, status)
+		}
+		fmt.Printf("%d pending\n", pending)
+
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
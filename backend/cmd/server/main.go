@@ -1,72 +1,142 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+
+	"workbench/internal/cache"
 	"workbench/internal/config"
+	"workbench/internal/core/handlers"
 	"workbench/internal/database"
+	"workbench/internal/logging"
+	"workbench/internal/queue"
 	"workbench/internal/router"
+	"workbench/internal/storage"
 
-	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
-	fmt.Println("🚀 PDF Extraction Platform Starting...")
+	var options config.Options
+	options.RegisterFlags(flag.CommandLine)
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, secrets masked, and exit")
+	flag.Parse()
 
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.Load(&options)
 	if err != nil {
-		log.Fatal("Failed to load configuration:", err)
+		log.Fatal("Failed to load configuration: ", err)
+	}
+	if *printConfig {
+		cfg.Dump(os.Stdout)
+		return
+	}
+
+	// Structured logging from here on; the standard log package writes through it too
+	logging.Setup(os.Stderr, cfg.App.LogLevel, cfg.App.LogFormat)
+
+	slog.Info("🚀 PDF Extraction Platform Starting...", "environment", cfg.App.Environment)
+	cfg.LogEffective()
+	for _, warning := range cfg.Warnings() {
+		slog.Warn("⚠️ " + warning)
 	}
+	slog.Info("🔍 Connecting to database", "dsn", cfg.Database.RedactedDSN())
 
 	// Initialize database
 	_, err = database.Initialize(&cfg.Database)
 	if err != nil {
-		log.Fatal("Failed to initialize database:", err)
+		slog.Error("❌ Failed to initialize database", "error", err)
+		os.Exit(1)
+	}
+
+	// Share the extraction queue and lookup cache between replicas through Redis when enabled
+	var redisClient *redis.Client
+	if cfg.Redis.Enabled {
+		redisClient, err = database.ConnectRedis(&cfg.Redis)
+		if err != nil {
+			slog.Error("❌ Failed to connect to Redis", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("✅ Redis connected", "address", cfg.Redis.Addr())
+		cache.SetDefault(cache.NewRedis(redisClient, cfg.Redis.KeyPrefix, cfg.Redis.CacheTTL))
+		queue.SetDefault(queue.NewRedis(redisClient, cfg.Redis.KeyPrefix, handlers.WorkerName()))
+	} else {
+		cache.SetDefault(cache.NewMemory(cfg.Redis.CacheTTL))
+	}
+
+	// Uploaded PDFs and the extractor's output live in storage, on local disk or in an S3 bucket
+	storageCtx, cancelStorage := context.WithTimeout(context.Background(), 10*time.Second)
+	blobs, err := storage.New(storageCtx, cfg.Storage, cfg.Server.BackendURL)
+	cancelStorage()
+	if err != nil {
+		slog.Error("❌ Failed to open storage", "backend", cfg.Storage.Backend, "error", err)
+		os.Exit(1)
 	}
-	defer database.Close()
+	storage.SetDefault(blobs)
+	slog.Info("✅ Storage ready", "backend", cfg.Storage.Backend)
 
 	// Setup router
-	e := router.Setup()
+	e := router.Setup(cfg)
+
+	// Fail the extractions a previous run left unfinished
+	if err := e.RecoverJobs(context.Background()); err != nil {
+		slog.Error("❌ Failed to recover interrupted jobs", "error", err)
+	}
 
 	e.HideBanner = true
+	e.HidePort = true
 	e.Validator = nil
 
-	// Global middleware
-	e.Use(middleware.Logger())
-	e.Use(middleware.Recover())
-	e.Use(middleware.RequestID())
-	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins:     cfg.Server.CORSOrigins,
-		AllowMethods:     []string{echo.GET, echo.POST, echo.PUT, echo.DELETE, echo.OPTIONS},
-		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
-		AllowCredentials: true,
-	}))
-
 	// Start server
+	failed := make(chan error, 1)
 	go func() {
 		address := fmt.Sprintf(":%s", cfg.Server.Port)
-		log.Printf("✅ Server starting on %s", address)
-		if err := e.Start(address); err != nil {
-			log.Printf("Server error: %v", err)
+		slog.Info("✅ Server starting", "address", address)
+		if err := e.Start(address); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			failed <- err
 		}
 	}()
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal, or for the server to fail
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	exitCode := 0
+	select {
+	case sig := <-quit:
+		slog.Info("Shutting down server...", "signal", sig.String(), "timeout", cfg.Server.ShutdownTimeout)
+	case err := <-failed:
+		slog.Error("❌ Server error", "error", err)
+		exitCode = 1
+	}
+	// A second signal kills the process without waiting
+	signal.Stop(quit)
 
-	log.Println("Shutting down server...")
+	// Drain requests and jobs, then close the database last
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	if err := e.Shutdown(ctx); err != nil {
+		slog.Error("❌ Shutdown did not complete cleanly", "error", err)
+		exitCode = 1
+	}
+	cancel()
 
-	// Cleanup
+	if redisClient != nil {
+		if err := redisClient.Close(); err != nil {
+			slog.Error("❌ Error closing Redis", "error", err)
+		}
+	}
 	if err := database.Close(); err != nil {
-		log.Printf("Error closing database: %v", err)
+		slog.Error("❌ Error closing database", "error", err)
 	}
 
-	log.Println("Server stopped")
+	slog.Info("Server stopped")
+	os.Exit(exitCode)
 }
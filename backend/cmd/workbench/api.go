@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"workbench/internal/cache"
+	"workbench/internal/config"
+	"workbench/internal/core/handlers"
+	"workbench/internal/queue"
+	"workbench/internal/storage"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// api serves requests with the HTTP handlers in-process, so commands share their validation,
+// mapping and import logic without a running server
+type api struct {
+	e *echo.Echo
+}
+
+// newAPI extracts PDFs in this process, with its own queue and workers, even when the server shares Redis.
+// blobs may be nil for commands that store no PDFs or spreadsheets.
+func newAPI(db *gorm.DB, cfg *config.Config, blobs storage.Storage) *api {
+	e := echo.New()
+	g := e.Group("/api/v1")
+	handlers.NewExtractionHandler(db, queue.NewMemory(), cache.NewMemory(cfg.Redis.CacheTTL), blobs, cfg.Extraction, cfg.Upload, cfg.Storage).ExtractionRoutes(g)
+	handlers.NewPetrographyCarbonateHandler(db).PetrographyCarbonateRoutes(g)
+	handlers.NewPetrographyClasticHandler(db).PetrographyClasticRoutes(g)
+	return &api{e: e}
+}
+
+// apiError is an error response of a handler
+type apiError struct {
+	Status  int
+	Message string
+	// Body is the decoded JSON response, which may carry details such as unmapped headers
+	Body map[string]interface{}
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// responseWriter streams a successful response body to w and keeps error responses for decoding
+type responseWriter struct {
+	header http.Header
+	status int
+	w      io.Writer
+	errors bytes.Buffer
+	err    error
+}
+
+func (r *responseWriter) Header() http.Header {
+	return r.header
+}
+
+func (r *responseWriter) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *responseWriter) Write(p []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	if r.status >= http.StatusBadRequest {
+		return r.errors.Write(p)
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.w.Write(p)
+	if err != nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// call serves req and streams a successful response body to w
+func (a *api) call(req *http.Request, w io.Writer) error {
+	res := &responseWriter{header: http.Header{}, w: w}
+	a.e.ServeHTTP(res, req)
+	if res.err != nil {
+		return res.err
+	}
+	if res.status < http.StatusBadRequest {
+		return nil
+	}
+
+	apiErr := &apiError{Status: res.status, Message: http.StatusText(res.status)}
+	if json.Unmarshal(res.errors.Bytes(), &apiErr.Body) == nil {
+		// Handlers answer {"error": ...}; Echo's own errors use {"message": ...}
+		for _, key := range []string{"error", "message"} {
+			if message, ok := apiErr.Body[key].(string); ok && message != "" {
+				apiErr.Message = message
+				break
+			}
+		}
+	}
+	return apiErr
+}
+
+// callJSON serves req and decodes its JSON response into v
+func (a *api) callJSON(req *http.Request, v interface{}) error {
+	var body bytes.Buffer
+	if err := a.call(req, &body); err != nil {
+		return err
+	}
+	return json.Unmarshal(body.Bytes(), v)
+}
+
+// jsonRequest builds a request with a JSON body
+func jsonRequest(method, target string, body interface{}) (*http.Request, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, target, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	return req, nil
+}
+
+// formRequest builds a POST request with a URL-encoded form body
+func formRequest(target string, fields url.Values) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, target, strings.NewReader(fields.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	return req, nil
+}
+
+// uploadRequest builds a multipart POST request uploading path as the "file" field. The file is
+// streamed, so large spreadsheets are not read into memory first.
+func uploadRequest(target, path string, fields url.Values) (*http.Request, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	body, pipe := io.Pipe()
+	form := multipart.NewWriter(pipe)
+	go func() {
+		defer file.Close()
+		for name, values := range fields {
+			for _, value := range values {
+				if err := form.WriteField(name, value); err != nil {
+					pipe.CloseWithError(err)
+					return
+				}
+			}
+		}
+		part, err := form.CreateFormFile("file", filepath.Base(path))
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		pipe.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, target, body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+	return req, nil
+}
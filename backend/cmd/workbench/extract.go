@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"workbench/internal/cache"
+	"workbench/internal/core/handlers"
+	"workbench/internal/queue"
+	"workbench/internal/storage"
+)
+
+// saveResult is the response of the save-to-db endpoint
+type saveResult struct {
+	DryRun       bool                 `json:"dry_run"`
+	SavedTables  int                  `json:"saved_tables"`
+	TotalRecords int                  `json:"total_records"`
+	Summary      map[string]int       `json:"summary"`
+	Rows         []handlers.RowResult `json:"rows"`
+}
+
+// extractResult reports one extracted PDF
+type extractResult struct {
+	File       string `json:"file"`
+	DocumentID string `json:"document_id,omitempty"`
+	// Duplicate is set when the PDF was uploaded before and its earlier extraction was reused
+	Duplicate bool        `json:"duplicate,omitempty"`
+	Tables    int         `json:"tables"`
+	Saved     *saveResult `json:"saved,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+func runExtract(a *app, fs *flag.FlagSet, args []string) error {
+	save := fs.Bool("save", true, "save the extracted tables to the database")
+	crs := fs.String("crs", "", "EPSG code of coordinates in the reports that do not name one")
+	reextract := fs.Bool("reextract", false, "extract PDFs uploaded before again instead of reusing their extraction")
+	allowDuplicates := fs.Bool("allow-duplicates", false, "save the tables of PDFs whose records were saved before")
+	positional, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError("expected one PDF file or directory")
+	}
+	files, err := findFiles(positional[0], ".pdf")
+	if err != nil {
+		return err
+	}
+
+	db, err := a.connect(true)
+	if err != nil {
+		return err
+	}
+	blobs, err := a.openStorage()
+	if err != nil {
+		return err
+	}
+	client := newAPI(db, a.cfg, blobs)
+
+	results := make([]extractResult, 0, len(files))
+	failed := 0
+	for _, file := range files {
+		result := extractResult{File: file}
+		opts := extractOptions{Save: *save, DryRun: a.dryRun, CRS: *crs, Reextract: *reextract, AllowDuplicates: *allowDuplicates}
+		if err := extractFile(client, file, opts, &result); err != nil {
+			result.Error = err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	err = a.emit(results, func(w io.Writer) {
+		for _, result := range results {
+			if result.Error != "" {
+				fmt.Fprintf(w, "❌ %s: %s\n", result.File, result.Error)
+				continue
+			}
+			fmt.Fprintf(w, "📄 %s: %d tables", result.File, result.Tables)
+			if result.Duplicate {
+				fmt.Fprint(w, " (uploaded before, earlier extraction reused)")
+			}
+			if result.Saved != nil {
+				fmt.Fprintf(w, ", %d records in %d tables", result.Saved.TotalRecords, result.Saved.SavedTables)
+				printSummary(w, result.Saved.Summary)
+			}
+			fmt.Fprintln(w)
+			if result.Saved != nil {
+				printRowProblems(w, result.Saved.Rows)
+			}
+		}
+		a.dryRunNote(w)
+	})
+	if err == nil && failed > 0 {
+		err = fmt.Errorf("%d of %d files failed", failed, len(files))
+	}
+	return err
+}
+
+// extractOptions are the flags of the extract command
+type extractOptions struct {
+	Save            bool
+	DryRun          bool
+	CRS             string
+	Reextract       bool
+	AllowDuplicates bool
+}
+
+// extractFile runs the extractor on one PDF and saves its tables unless opts.Save is off. A PDF uploaded
+// before reuses its earlier extraction unless opts.Reextract is set.
+func extractFile(client *api, file string, opts extractOptions, result *extractResult) error {
+	fields := url.Values{}
+	if opts.Reextract {
+		fields.Set("reextract", "true")
+	}
+	req, err := uploadRequest("/api/v1/extraction/process-pdf", file, fields)
+	if err != nil {
+		return err
+	}
+	var extraction struct {
+		DocumentID string `json:"document_id"`
+		Duplicate  bool   `json:"duplicate"`
+		Results    struct {
+			JSONFiles []struct {
+				Data struct {
+					Tables []map[string]interface{} `json:"tables"`
+				} `json:"data"`
+			} `json:"json_files"`
+		} `json:"results"`
+	}
+	if err := client.callJSON(req, &extraction); err != nil {
+		return err
+	}
+	result.DocumentID, result.Duplicate = extraction.DocumentID, extraction.Duplicate
+
+	var tables []map[string]interface{}
+	for _, jsonFile := range extraction.Results.JSONFiles {
+		tables = append(tables, jsonFile.Data.Tables...)
+	}
+	result.Tables = len(tables)
+	if !opts.Save || len(tables) == 0 {
+		return nil
+	}
+
+	result.Saved = &saveResult{}
+	return saveTables(client, tables, saveRequest{
+		Filename:        filepath.Base(file),
+		DocumentID:      extraction.DocumentID,
+		DryRun:          opts.DryRun,
+		CRS:             opts.CRS,
+		AllowDuplicates: opts.AllowDuplicates,
+	}, result.Saved)
+}
+
+// saveRequest describes tables to save besides the tables themselves
+type saveRequest struct {
+	Filename        string
+	DocumentID      string
+	DryRun          bool
+	CRS             string
+	AllowDuplicates bool
+}
+
+// saveTables sends tables through the save-to-db endpoint
+func saveTables(client *api, tables []map[string]interface{}, save saveRequest, result *saveResult) error {
+	req, err := jsonRequest(http.MethodPost, "/api/v1/extraction/save-to-db", map[string]interface{}{
+		"tables":           tables,
+		"filename":         save.Filename,
+		"document_id":      save.DocumentID,
+		"dry_run":          save.DryRun,
+		"crs":              save.CRS,
+		"allow_duplicates": save.AllowDuplicates,
+	})
+	if err != nil {
+		return err
+	}
+	return client.callJSON(req, result)
+}
+
+// remapTable is the mapping of one stored table's headers
+type remapTable struct {
+	Table    int               `json:"table"`
+	Rows     int               `json:"rows"`
+	Mapping  map[string]string `json:"mapping"`
+	Unmapped []string          `json:"unmapped"`
+}
+
+// remapResult reports the mapping of one stored extraction file
+type remapResult struct {
+	File   string       `json:"file"`
+	Tables []remapTable `json:"tables"`
+	Saved  *saveResult  `json:"saved,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+func runRemap(a *app, fs *flag.FlagSet, args []string) error {
+	save := fs.Bool("save", false, "save the remapped tables to the database; rows already saved are inserted again")
+	crs := fs.String("crs", "", "EPSG code of coordinates in the reports that do not name one")
+	positional, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return usageError("expected at most one JSON file or directory")
+	}
+	cfg, err := a.config()
+	if err != nil {
+		return err
+	}
+	// Without a path, the extractor output kept in storage is remapped
+	var files []string
+	readFile := os.ReadFile
+	if len(positional) == 1 {
+		if files, err = findFiles(positional[0], ".json"); err != nil {
+			return err
+		}
+	} else {
+		blobs, err := a.openStorage()
+		if err != nil {
+			return err
+		}
+		objects, err := blobs.List(context.Background(), storage.Outputs)
+		if err != nil {
+			return err
+		}
+		for _, object := range objects {
+			if strings.HasSuffix(object.Key, ".json") {
+				files = append(files, object.Key)
+			}
+		}
+		readFile = func(key string) ([]byte, error) {
+			reader, _, err := blobs.Get(context.Background(), key)
+			if err != nil {
+				return nil, err
+			}
+			defer reader.Close()
+			return io.ReadAll(reader)
+		}
+	}
+
+	// Mapping needs no database; saving does
+	extraction := handlers.NewExtractionHandler(nil, queue.NewMemory(), cache.NewMemory(cfg.Redis.CacheTTL), nil, cfg.Extraction, cfg.Upload, cfg.Storage)
+	var client *api
+	if *save {
+		db, err := a.connect(true)
+		if err != nil {
+			return err
+		}
+		client = newAPI(db, cfg, nil)
+	}
+
+	results := make([]remapResult, 0, len(files))
+	failed := 0
+	for _, file := range files {
+		result := remapResult{File: file, Tables: []remapTable{}}
+		err := func() error {
+			content, err := readFile(file)
+			if err != nil {
+				return err
+			}
+			var stored struct {
+				Tables []map[string]interface{} `json:"tables"`
+			}
+			if err := json.Unmarshal(content, &stored); err != nil {
+				return fmt.Errorf("not an extraction output: %w", err)
+			}
+
+			for i, table := range stored.Tables {
+				headers, _ := table["headers"].([]interface{})
+				rows, _ := table["rows"].([]interface{})
+				headerRow := make([]string, len(headers))
+				for j, header := range headers {
+					headerRow[j] = fmt.Sprintf("%v", header)
+				}
+				_, mapping, unmapped := extraction.MapHeaders(context.Background(), headerRow)
+				result.Tables = append(result.Tables, remapTable{Table: i + 1, Rows: len(rows), Mapping: mapping, Unmapped: unmapped})
+			}
+
+			if client == nil || len(stored.Tables) == 0 {
+				return nil
+			}
+			result.Saved = &saveResult{}
+			return saveTables(client, stored.Tables, saveRequest{Filename: filepath.Base(file), DryRun: a.dryRun, CRS: *crs}, result.Saved)
+		}()
+		if err != nil {
+			result.Error = err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	err = a.emit(results, func(w io.Writer) {
+		for _, result := range results {
+			if result.Error != "" {
+				fmt.Fprintf(w, "❌ %s: %s\n", result.File, result.Error)
+				continue
+			}
+			fmt.Fprintf(w, "📄 %s: %d tables\n", result.File, len(result.Tables))
+			for _, table := range result.Tables {
+				fmt.Fprintf(w, "  table %d (%d rows): %d headers mapped", table.Table, table.Rows, len(table.Mapping))
+				if len(table.Unmapped) > 0 {
+					fmt.Fprintf(w, ", unmapped: %s", strings.Join(table.Unmapped, ", "))
+				}
+				fmt.Fprintln(w)
+			}
+			if result.Saved != nil {
+				fmt.Fprintf(w, "  %d records in %d tables", result.Saved.TotalRecords, result.Saved.SavedTables)
+				printSummary(w, result.Saved.Summary)
+				fmt.Fprintln(w)
+				printRowProblems(w, result.Saved.Rows)
+			}
+		}
+		if *save {
+			a.dryRunNote(w)
+		}
+	})
+	if err == nil && failed > 0 {
+		err = fmt.Errorf("%d of %d files failed", failed, len(files))
+	}
+	return err
+}
+
+// findFiles returns path when it is a file, or the files with ext under it when it is a directory
+func findFiles(path, ext string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(file string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(file), ext) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no %s files in %s", ext, path)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// printSummary appends row counts by status to a line
+func printSummary(w io.Writer, summary map[string]int) {
+	for _, status := range []string{handlers.RowStatusInserted, handlers.RowStatusValid, handlers.RowStatusFailed, handlers.RowStatusSkipped} {
+		if summary[status] > 0 {
+			fmt.Fprintf(w, ", %d %s", summary[status], status)
+		}
+	}
+}
+
+// printRowProblems lists failed rows and rows with warnings
+func printRowProblems(w io.Writer, rows []handlers.RowResult) {
+	for _, row := range rows {
+		location := fmt.Sprintf("row %d", row.Row)
+		if row.Table > 0 {
+			location = fmt.Sprintf("table %d row %d", row.Table, row.Row)
+		}
+		if row.Status == handlers.RowStatusFailed {
+			fmt.Fprintf(w, "  ❌ %s (%s): %s\n", location, row.Target, row.Error)
+		}
+		for _, warning := range row.Warnings {
+			fmt.Fprintf(w, "  ⚠️ %s (%s): %s\n", location, row.Target, warning)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"workbench/internal/cache"
+	"workbench/internal/config"
+	"workbench/internal/database"
+	"workbench/internal/storage"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// command is a workbench subcommand. run defines its own flags on fs and calls app.parse.
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(a *app, fs *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{"migrate", "up [version] | down [steps] | status", "apply, roll back or list schema migrations", runMigrate},
+	{"seed", "[vocabularies]", "create the built-in reference data that is missing", runSeed},
+	{"extract", "<file.pdf|directory>", "extract the tables of local PDFs and save them", runExtract},
+	{"remap", "[file.json|directory]", "re-run header mapping on stored extraction output", runRemap},
+	{"import", "<file.csv|file.xlsx>", "import an ePBE spreadsheet", runImport},
+	{"export", "carbonate|clastic", "export samples as an ePBE spreadsheet", runExport},
+	{"user", "create | reset-password", "create users and reset passwords", runUser},
+	{"purge", "", "permanently delete soft-deleted records", runPurge},
+	{"config", "", "validate and print the effective configuration, secrets masked", runConfig},
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: workbench <command> [arguments] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %-38s %s\n", cmd.name, cmd.args, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Every command accepts --dry-run, to report what would change without writing,")
+	fmt.Fprintln(w, "and --json, to print the result as JSON. --config and --set select the configuration as")
+	fmt.Fprintln(w, "for the server. Run `workbench <command> -h` for a command's flags.")
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		usage(os.Stdout)
+		os.Exit(2)
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == os.Args[1] {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", os.Args[1])
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	// Handlers print progress with fmt; keep stdout for results so --json output stays parseable
+	a := &app{stdout: os.Stdout}
+	os.Stdout = os.Stderr
+	defer database.Close()
+
+	fs := flag.NewFlagSet("workbench "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: workbench %s [flags]\n\n%s\n\nFlags:\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.summary)
+		fs.PrintDefaults()
+	}
+
+	err := cmd.run(a, fs, os.Args[2:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	var usageErr usageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintf(os.Stderr, "%s\n\n", err)
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		a.fail(err)
+		os.Exit(1)
+	}
+}
+
+// usageError is a command line mistake; it is reported with the command's usage
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// app holds the flags shared by every command and the real stdout results are written to
+type app struct {
+	stdout  io.Writer
+	dryRun  bool
+	json    bool
+	options config.Options
+	cfg     *config.Config
+}
+
+// parse registers the shared flags and parses args. Flags may follow positional arguments,
+// as in `workbench import samples.xlsx --dry-run`.
+func (a *app) parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.BoolVar(&a.dryRun, "dry-run", false, "report what would change without writing anything")
+	fs.BoolVar(&a.json, "json", false, "print the result as JSON")
+	a.options.RegisterFlags(fs)
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// config loads the configuration once
+func (a *app) config() (*config.Config, error) {
+	if a.cfg == nil {
+		cfg, err := config.Load(&a.options)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+		a.cfg = cfg
+	}
+	return a.cfg, nil
+}
+
+// connect opens the database. Commands other than migrate refuse to run on an outdated schema.
+func (a *app) connect(checkSchema bool) (*gorm.DB, error) {
+	cfg, err := a.config()
+	if err != nil {
+		return nil, err
+	}
+	db, err := database.Connect(&cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQL is logged to stderr, and only when slow or failing; bulk imports would drown the output otherwise
+	db = db.Session(&gorm.Session{Logger: logger.New(log.New(os.Stderr, "\r\n", log.LstdFlags), logger.Config{
+		SlowThreshold:             time.Second,
+		LogLevel:                  logger.Warn,
+		IgnoreRecordNotFoundError: true,
+	})})
+
+	if checkSchema {
+		if err := database.CheckSchema(db); err != nil {
+			return nil, err
+		}
+	}
+
+	// Writes drop the cached lookups of running servers through the Redis they share
+	if cfg.Redis.Enabled {
+		client, err := database.ConnectRedis(&cfg.Redis)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️ %s; server caches expire after %s instead of being invalidated\n", err, cfg.Redis.CacheTTL)
+		} else {
+			cache.SetDefault(cache.NewRedis(client, cfg.Redis.KeyPrefix, cfg.Redis.CacheTTL))
+		}
+	}
+	return db, nil
+}
+
+// openStorage opens the storage holding the uploaded PDFs and the extractor's output
+func (a *app) openStorage() (storage.Storage, error) {
+	cfg, err := a.config()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	blobs, err := storage.New(ctx, cfg.Storage, cfg.Server.BackendURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+	return blobs, nil
+}
+
+// emit prints a command result, as JSON with --json and with text otherwise
+func (a *app) emit(result interface{}, text func(w io.Writer)) error {
+	if a.json {
+		encoder := json.NewEncoder(a.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	text(a.stdout)
+	return nil
+}
+
+// fail reports a command error; with --json it is also written to stdout for automation
+func (a *app) fail(err error) {
+	if a.json {
+		body := map[string]interface{}{"error": err.Error()}
+		var apiErr *apiError
+		if errors.As(err, &apiErr) {
+			for key, value := range apiErr.Body {
+				if key != "error" {
+					body[key] = value
+				}
+			}
+		}
+		a.emit(body, nil)
+	}
+	log.Printf("❌ %v", err)
+}
+
+// dryRunNote is appended to text output of dry runs
+func (a *app) dryRunNote(w io.Writer) {
+	if a.dryRun {
+		fmt.Fprintln(w, "Dry run: nothing was written")
+	}
+}
+
+// subcommand splits the first positional argument from the rest
+func subcommand(positional []string, allowed ...string) (string, []string, error) {
+	if len(positional) == 0 {
+		return "", nil, usageError("missing subcommand, expected " + strings.Join(allowed, ", "))
+	}
+	for _, name := range allowed {
+		if positional[0] == name {
+			return name, positional[1:], nil
+		}
+	}
+	return "", nil, usageError(fmt.Sprintf("unknown subcommand %q, expected %s", positional[0], strings.Join(allowed, ", ")))
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func runConfig(a *app, fs *flag.FlagSet, args []string) error {
+	positional, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError("config takes no arguments")
+	}
+	cfg, err := a.config()
+	if err != nil {
+		return err
+	}
+
+	result := map[string]interface{}{
+		"file":     cfg.File,
+		"settings": cfg.Effective(),
+		"warnings": cfg.Warnings(),
+	}
+	return a.emit(result, func(w io.Writer) {
+		if cfg.File != "" {
+			fmt.Fprintf(w, "Config file: %s\n", cfg.File)
+		}
+		cfg.Dump(w)
+		for _, warning := range cfg.Warnings() {
+			fmt.Fprintf(w, "⚠️ %s\n", warning)
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+
+	"workbench/internal/core/models"
+	"workbench/internal/database"
+
+	"gorm.io/gorm"
+)
+
+// errDryRun rolls back the transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+// migrationResult reports the migrations a migrate command applied, rolled back or would have
+type migrationResult struct {
+	Command    string                    `json:"command"`
+	DryRun     bool                      `json:"dry_run"`
+	Migrations []database.MigrationState `json:"migrations"`
+	Pending    int                       `json:"pending,omitempty"`
+}
+
+func runMigrate(a *app, fs *flag.FlagSet, args []string) error {
+	positional, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	name, rest, err := subcommand(positional, "up", "down", "status")
+	if err != nil {
+		return err
+	}
+	if len(rest) > 1 {
+		return usageError("too many arguments")
+	}
+
+	db, err := a.connect(false)
+	if err != nil {
+		return err
+	}
+	result := migrationResult{Command: name, DryRun: a.dryRun, Migrations: []database.MigrationState{}}
+
+	switch name {
+	case "up":
+		var target int64
+		if len(rest) > 0 {
+			if target, err = strconv.ParseInt(rest[0], 10, 64); err != nil || target <= 0 {
+				return usageError(fmt.Sprintf("invalid version %q", rest[0]))
+			}
+		}
+		var migrations []database.Migration
+		if a.dryRun {
+			pending, err := database.PendingMigrations(db)
+			if err != nil {
+				return err
+			}
+			for _, migration := range pending {
+				if target == 0 || migration.Version <= target {
+					migrations = append(migrations, migration)
+				}
+			}
+		} else if migrations, err = database.MigrateUp(db, target); err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			result.Migrations = append(result.Migrations, database.MigrationState{Version: migration.Version, Name: migration.Name, Applied: !a.dryRun})
+		}
+
+	case "down":
+		steps := 1
+		if len(rest) > 0 {
+			if steps, err = strconv.Atoi(rest[0]); err != nil || steps <= 0 {
+				return usageError(fmt.Sprintf("invalid number of steps %q", rest[0]))
+			}
+		}
+		if a.dryRun {
+			states, err := database.MigrationStatus(db)
+			if err != nil {
+				return err
+			}
+			for i := len(states) - 1; i >= 0 && len(result.Migrations) < steps; i-- {
+				if states[i].Applied {
+					result.Migrations = append(result.Migrations, states[i])
+				}
+			}
+		} else {
+			migrations, err := database.MigrateDown(db, steps)
+			if err != nil {
+				return err
+			}
+			for _, migration := range migrations {
+				result.Migrations = append(result.Migrations, database.MigrationState{Version: migration.Version, Name: migration.Name})
+			}
+		}
+
+	case "status":
+		if result.Migrations, err = database.MigrationStatus(db); err != nil {
+			return err
+		}
+		for _, state := range result.Migrations {
+			if !state.Applied {
+				result.Pending++
+			}
+		}
+	}
+
+	return a.emit(result, func(w io.Writer) {
+		for _, state := range result.Migrations {
+			status := "pending"
+			switch {
+			case name == "up" && a.dryRun:
+				status = "would be applied"
+			case name == "up":
+				status = "applied"
+			case name == "down" && a.dryRun:
+				status = "would be rolled back"
+			case name == "down":
+				status = "rolled back"
+			case state.Missing:
+				status = "applied " + state.AppliedAt.Format("2006-01-02 15:04:05") + " (not in this build)"
+			case state.Applied:
+				status = "applied " + state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%6d  %-40s %s\n", state.Version, state.Name, status)
+		}
+		switch name {
+		case "status":
+			fmt.Fprintf(w, "%d pending\n", result.Pending)
+		case "up":
+			fmt.Fprintf(w, "✅ %d migrations applied\n", len(result.Migrations))
+		case "down":
+			fmt.Fprintf(w, "✅ %d migrations rolled back\n", len(result.Migrations))
+		}
+		a.dryRunNote(w)
+	})
+}
+
+// seedResult reports the reference data a seed command created
+type seedResult struct {
+	DryRun  bool             `json:"dry_run"`
+	Created map[string]int64 `json:"created"`
+}
+
+func runSeed(a *app, fs *flag.FlagSet, args []string) error {
+	positional, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		positional = []string{"vocabularies"}
+	}
+	if _, _, err := subcommand(positional, "vocabularies"); err != nil {
+		return err
+	}
+
+	db, err := a.connect(true)
+	if err != nil {
+		return err
+	}
+
+	result := seedResult{DryRun: a.dryRun, Created: map[string]int64{}}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var before, after int64
+		if err := tx.Model(&models.VocabularyTerm{}).Count(&before).Error; err != nil {
+			return err
+		}
+		if err := database.SeedVocabularies(tx); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.VocabularyTerm{}).Count(&after).Error; err != nil {
+			return err
+		}
+		result.Created["vocabulary_terms"] = after - before
+		if a.dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return err
+	}
+
+	return a.emit(result, func(w io.Writer) {
+		fmt.Fprintf(w, "✅ %d vocabulary terms created\n", result.Created["vocabulary_terms"])
+		a.dryRunNote(w)
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"workbench/internal/core/handlers"
+	"workbench/internal/core/models"
+
+	"gorm.io/gorm"
+)
+
+// purgeTargets are the soft-deleted models, in deletion order
+var purgeTargets = []struct {
+	name  string
+	table string
+	model interface{}
+}{
+	{handlers.TargetCarbonate, "petrography_carbonate", &models.EPBEPetrographyCarbonate{}},
+	{handlers.TargetClastic, "petrography_clastic", &models.EPBEPetrographyClastic{}},
+	{"users", "users", &models.User{}},
+}
+
+// purgeResult reports the rows a purge deleted or would delete
+type purgeResult struct {
+	DryRun        bool             `json:"dry_run"`
+	DeletedBefore *time.Time       `json:"deleted_before,omitempty"`
+	Purged        map[string]int64 `json:"purged"`
+}
+
+func runPurge(a *app, fs *flag.FlagSet, args []string) error {
+	olderThan := fs.String("older-than", "", "only purge records deleted longer ago than this, e.g. 30d or 12h")
+	var only stringList
+	fs.Var(&only, "only", "purge only carbonate, clastic or users (repeatable)")
+	positional, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError("purge takes no arguments")
+	}
+
+	result := purgeResult{DryRun: a.dryRun, Purged: map[string]int64{}}
+	if *olderThan != "" {
+		age, err := parseAge(*olderThan)
+		if err != nil {
+			return usageError(err.Error())
+		}
+		cutoff := time.Now().UTC().Add(-age)
+		result.DeletedBefore = &cutoff
+	}
+
+	selected := map[string]bool{}
+	for _, name := range only {
+		found := false
+		for _, target := range purgeTargets {
+			found = found || target.name == name
+		}
+		if !found {
+			return usageError(fmt.Sprintf("unknown --only %q, expected carbonate, clastic or users", name))
+		}
+		selected[name] = true
+	}
+
+	db, err := a.connect(true)
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, target := range purgeTargets {
+			if len(selected) > 0 && !selected[target.name] {
+				continue
+			}
+			query := tx.Unscoped().Where("deleted_at IS NOT NULL")
+			if result.DeletedBefore != nil {
+				query = query.Where("deleted_at < ?", *result.DeletedBefore)
+			}
+
+			var count int64
+			if a.dryRun {
+				if err := query.Model(target.model).Count(&count).Error; err != nil {
+					return fmt.Errorf("failed to count %s: %w", target.table, err)
+				}
+			} else {
+				deleted := query.Delete(target.model)
+				if deleted.Error != nil {
+					return fmt.Errorf("failed to purge %s: %w", target.table, deleted.Error)
+				}
+				count = deleted.RowsAffected
+			}
+			result.Purged[target.table] = count
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.emit(result, func(w io.Writer) {
+		verb := "purged"
+		if a.dryRun {
+			verb = "would be purged"
+		}
+		for _, target := range purgeTargets {
+			if count, ok := result.Purged[target.table]; ok {
+				fmt.Fprintf(w, "🗑️ %s: %d records %s\n", target.table, count, verb)
+			}
+		}
+		a.dryRunNote(w)
+	})
+}
+
+// parseAge reads a duration, also accepting whole days such as 30d
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %q, expected e.g. 30d or 12h", value)
+	}
+	return age, nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"workbench/internal/core/handlers"
+	"workbench/internal/core/models"
+)
+
+// importResult is the response of the import endpoints
+type importResult struct {
+	DryRun   bool                 `json:"dry_run"`
+	Import   models.ImportJob     `json:"import"`
+	Mapping  map[string]string    `json:"mapping"`
+	Unmapped []string             `json:"unmapped"`
+	Summary  map[string]int       `json:"summary"`
+	Rows     []handlers.RowResult `json:"rows"`
+	Done     bool                 `json:"done"`
+	NextRow  int                  `json:"next_row,omitempty"`
+}
+
+func runImport(a *app, fs *flag.FlagSet, args []string) error {
+	target := fs.String("target", "", "save rows to carbonate or clastic only; default picks from the mapped headers")
+	crs := fs.String("crs", "", "EPSG code of coordinates that do not name one")
+	sheet := fs.String("sheet", "", "XLSX sheet to read; default is the first")
+	limit := fs.Int("limit", 0, "process at most this many rows, to resume later")
+	resume := fs.String("resume", "", "resume the import job with this ID instead of uploading a file")
+	positional, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *resume == "" && len(positional) != 1 {
+		return usageError("expected one CSV or XLSX file, or --resume")
+	}
+	if *resume != "" && len(positional) > 0 {
+		return usageError("--resume takes no file")
+	}
+
+	db, err := a.connect(true)
+	if err != nil {
+		return err
+	}
+	// Spreadsheets wait in the configured storage, where the server can resume them too
+	blobs, err := a.openStorage()
+	if err != nil {
+		return err
+	}
+	client := newAPI(db, a.cfg, blobs)
+
+	fields := url.Values{}
+	fields.Set("dry_run", strconv.FormatBool(a.dryRun))
+	if *limit > 0 {
+		fields.Set("limit", strconv.Itoa(*limit))
+	}
+
+	var req *http.Request
+	if *resume != "" {
+		req, err = formRequest("/api/v1/extraction/import/"+url.PathEscape(*resume)+"/resume", fields)
+	} else {
+		fields.Set("target", *target)
+		fields.Set("crs", *crs)
+		fields.Set("sheet", *sheet)
+		req, err = uploadRequest("/api/v1/extraction/import", positional[0], fields)
+	}
+	if err != nil {
+		return err
+	}
+
+	var result importResult
+	if err := client.callJSON(req, &result); err != nil {
+		return err
+	}
+
+	return a.emit(result, func(w io.Writer) {
+		job := result.Import
+		fmt.Fprintf(w, "📥 Import %s (%s): %s, %d rows processed, %d records inserted", job.ID, job.Filename, job.Status, job.ProcessedRows, job.InsertedRecords)
+		printSummary(w, result.Summary)
+		fmt.Fprintln(w)
+		if len(result.Unmapped) > 0 {
+			fmt.Fprintf(w, "  unmapped headers: %s\n", strings.Join(result.Unmapped, ", "))
+		}
+		printRowProblems(w, result.Rows)
+		if !result.Done {
+			fmt.Fprintf(w, "Continue from sheet row %d with: workbench import --resume %s\n", result.NextRow, job.ID)
+		}
+		a.dryRunNote(w)
+	})
+}
+
+// exportResult reports an export
+type exportResult struct {
+	DryRun  bool   `json:"dry_run"`
+	Table   string `json:"table"`
+	Format  string `json:"format"`
+	Records int64  `json:"records"`
+	Output  string `json:"output"`
+	Bytes   int64  `json:"bytes,omitempty"`
+}
+
+func runExport(a *app, fs *flag.FlagSet, args []string) error {
+	output := fs.String("o", "", "file to write; default is <table>_<timestamp>.<format>")
+	format := fs.String("format", handlers.ExportFormatCSV, "csv or xlsx")
+	units := fs.String("units", handlers.UnitsBoth, "metric, imperial or both")
+	sort := fs.String("sort", "", "sort order, as the list endpoints take it")
+	fields := fs.String("fields", "", "comma-separated columns to export")
+	search := fs.String("q", "", "full-text search")
+	var filters, params stringList
+	fs.Var(&filters, "filter", "filter expression, as the list endpoints take it (repeatable)")
+	fs.Var(&params, "param", "any other list parameter as key=value, e.g. interval=Miocene (repeatable)")
+	positional, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	table, _, err := subcommand(positional, handlers.TargetCarbonate, handlers.TargetClastic)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return usageError("too many arguments")
+	}
+	*format = strings.ToLower(*format)
+	if *format != handlers.ExportFormatCSV && *format != handlers.ExportFormatXLSX {
+		return usageError(fmt.Sprintf("unsupported export format %q, expected csv or xlsx", *format))
+	}
+
+	query := url.Values{}
+	for _, filter := range filters {
+		query.Add("filter", filter)
+	}
+	for _, param := range params {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok || key == "" {
+			return usageError(fmt.Sprintf("invalid --param %q, expected key=value", param))
+		}
+		query.Add(key, value)
+	}
+	if *sort != "" {
+		query.Set("sort", *sort)
+	}
+	if *fields != "" {
+		query.Set("fields", *fields)
+	}
+	if *search != "" {
+		query.Set("q", *search)
+	}
+
+	db, err := a.connect(true)
+	if err != nil {
+		return err
+	}
+	client := newAPI(db, a.cfg, nil)
+	resource := "/api/v1/petrography-" + table
+
+	result := exportResult{DryRun: a.dryRun, Table: "petrography_" + table, Format: *format, Output: *output}
+	if result.Output == "" {
+		result.Output = fmt.Sprintf("%s_%s.%s", result.Table, time.Now().UTC().Format("20060102_150405"), *format)
+	}
+
+	// Count the matching records with the list endpoint, which takes the same filters
+	countQuery := url.Values{}
+	for key, values := range query {
+		countQuery[key] = values
+	}
+	countQuery.Set("limit", "1")
+	countQuery.Set("include_total", "true")
+	countPath := resource
+	if *search != "" {
+		countPath += "/search"
+	}
+	countReq, err := http.NewRequest(http.MethodGet, countPath+"?"+countQuery.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	var list struct {
+		Pagination struct {
+			Total int64 `json:"total"`
+		} `json:"pagination"`
+	}
+	if err := client.callJSON(countReq, &list); err != nil {
+		return err
+	}
+	result.Records = list.Pagination.Total
+
+	if !a.dryRun {
+		query.Set("format", *format)
+		query.Set("units", *units)
+		req, err := http.NewRequest(http.MethodGet, resource+"/export?"+query.Encode(), nil)
+		if err != nil {
+			return err
+		}
+		if result.Bytes, err = writeExport(client, req, result.Output); err != nil {
+			return err
+		}
+	}
+
+	return a.emit(result, func(w io.Writer) {
+		if a.dryRun {
+			fmt.Fprintf(w, "📤 %d %s records would be exported to %s\n", result.Records, result.Table, result.Output)
+			a.dryRunNote(w)
+			return
+		}
+		fmt.Fprintf(w, "📤 %d %s records exported to %s (%d bytes)\n", result.Records, result.Table, result.Output, result.Bytes)
+	})
+}
+
+// writeExport streams an export response to a temporary file and renames it to path once complete,
+// so a failed export never leaves a partial file behind
+func writeExport(client *api, req *http.Request, path string) (int64, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(file.Name())
+
+	counter := &countingWriter{w: file}
+	err = client.call(req, counter)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return counter.n, os.Rename(file.Name(), path)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
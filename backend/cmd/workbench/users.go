@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"workbench/internal/core/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// userResult reports a created user or a password reset
+type userResult struct {
+	DryRun bool         `json:"dry_run"`
+	User   *models.User `json:"user"`
+	// Password is only returned when it was generated
+	Password string `json:"password,omitempty"`
+}
+
+func runUser(a *app, fs *flag.FlagSet, args []string) error {
+	email := fs.String("email", "", "email address of the user")
+	firstName := fs.String("first-name", "", "first name, for create")
+	lastName := fs.String("last-name", "", "last name, for create")
+	inactive := fs.Bool("inactive", false, "create the user deactivated")
+	password := fs.String("password", "", "new password; prefer --password-stdin, flags show up in the process list")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from the first line of stdin")
+	positional, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	name, rest, err := subcommand(positional, "create", "reset-password")
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return usageError("too many arguments")
+	}
+
+	*email = strings.TrimSpace(*email)
+	if *email == "" {
+		return usageError("--email is required")
+	}
+	if name == "create" && (strings.TrimSpace(*firstName) == "" || strings.TrimSpace(*lastName) == "") {
+		return usageError("--first-name and --last-name are required")
+	}
+	if *password != "" && *passwordStdin {
+		return usageError("use --password or --password-stdin, not both")
+	}
+
+	cfg, err := a.config()
+	if err != nil {
+		return err
+	}
+
+	result := userResult{DryRun: a.dryRun}
+	secret := *password
+	if *passwordStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read password: %w", err)
+		}
+		secret = strings.TrimRight(line, "\r\n")
+		if secret == "" {
+			return errors.New("empty password on stdin")
+		}
+	}
+	if secret == "" {
+		if secret, err = generatePassword(); err != nil {
+			return err
+		}
+		result.Password = secret
+	}
+	if len(secret) < cfg.Auth.PasswordMinLength {
+		return fmt.Errorf("password must be at least %d characters (auth.password_min_length)", cfg.Auth.PasswordMinLength)
+	}
+
+	db, err := a.connect(true)
+	if err != nil {
+		return err
+	}
+
+	var user models.User
+	err = db.Unscoped().Where("email = ?", *email).First(&user).Error
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("failed to look up user: %w", err)
+
+	case name == "create":
+		if err == nil {
+			if user.DeletedAt.Valid {
+				return fmt.Errorf("user %s was deleted but still holds the email; run `workbench purge --only users` first", *email)
+			}
+			return fmt.Errorf("user with email %s already exists", *email)
+		}
+		user = models.User{
+			ID:        uuid.New(),
+			Email:     *email,
+			Password:  secret,
+			FirstName: strings.TrimSpace(*firstName),
+			LastName:  strings.TrimSpace(*lastName),
+			IsActive:  !*inactive,
+		}
+		if !a.dryRun {
+			// Select writes IsActive even when false, which the column default would otherwise override
+			if err := db.Select("*").Omit("DeletedAt").Create(&user).Error; err != nil {
+				return fmt.Errorf("failed to create user: %w", err)
+			}
+		}
+
+	default:
+		if err != nil || user.DeletedAt.Valid {
+			return fmt.Errorf("user %s not found", *email)
+		}
+		if !a.dryRun {
+			if err := db.Model(&user).Update("password", secret).Error; err != nil {
+				return fmt.Errorf("failed to reset password: %w", err)
+			}
+		}
+	}
+	result.User = &user
+
+	return a.emit(result, func(w io.Writer) {
+		switch {
+		case name == "create" && a.dryRun:
+			fmt.Fprintf(w, "User %s can be created\n", user.Email)
+		case name == "create":
+			fmt.Fprintf(w, "✅ User %s created (%s)\n", user.Email, user.ID)
+		case a.dryRun:
+			fmt.Fprintf(w, "Password of %s can be reset\n", user.Email)
+		default:
+			fmt.Fprintf(w, "✅ Password of %s reset\n", user.Email)
+		}
+		if result.Password != "" {
+			fmt.Fprintf(w, "Generated password: %s\n", result.Password)
+		}
+		a.dryRunNote(w)
+	})
+}
+
+// generatePassword returns a random password of 24 URL-safe characters
+func generatePassword() (string, error) {
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
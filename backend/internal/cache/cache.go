@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Generations name the groups of cached lookups that are invalidated together
+const (
+	// Vocabularies covers the vocabulary terms and synonyms used to normalize records
+	Vocabularies = "vocabularies"
+	// Wells covers the well summaries, which aggregate the wells and their samples
+	Wells = "wells"
+)
+
+// ErrNotFound is returned by Get for missing and expired keys
+var ErrNotFound = errors.New("cache: key not found")
+
+// Cache stores JSON-encoded values under string keys. A ttl of zero uses the cache's default.
+type Cache interface {
+	Get(ctx context.Context, key string, dst interface{}) error
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	// Incr atomically increments the counter stored at key and returns its new value
+	Incr(ctx context.Context, key string) (int64, error)
+	// Ping checks that the backing store is reachable
+	Ping(ctx context.Context) error
+}
+
+var (
+	defaultMu sync.RWMutex
+	current   Cache = NewMemory(5 * time.Minute)
+)
+
+// Default returns the process-wide cache, in memory unless SetDefault installed another
+func Default() Cache {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return current
+}
+
+// SetDefault replaces the process-wide cache
+func SetDefault(c Cache) {
+	defaultMu.Lock()
+	current = c
+	defaultMu.Unlock()
+}
+
+// generationKey holds the counter bumped when a group of lookups goes stale
+func generationKey(name string) string {
+	return "generation:" + name
+}
+
+// Generation returns the current generation of a group of cached lookups, or -1 when it cannot be read
+func Generation(ctx context.Context, c Cache, name string) int64 {
+	var generation int64
+	err := c.Get(ctx, generationKey(name), &generation)
+	switch {
+	case err == nil:
+		return generation
+	case errors.Is(err, ErrNotFound):
+		return 0
+	default:
+		slog.WarnContext(ctx, "⚠️ Failed to read cache generation", "generation", name, "error", err)
+		return -1
+	}
+}
+
+// Invalidate drops every cached lookup of the named groups by moving them to a new generation
+func Invalidate(ctx context.Context, c Cache, names ...string) {
+	for _, name := range names {
+		if _, err := c.Incr(ctx, generationKey(name)); err != nil {
+			slog.WarnContext(ctx, "⚠️ Failed to invalidate cache", "generation", name, "error", err)
+		}
+	}
+}
+
+// Remember returns the value cached under key in the current generation of the named group, calling
+// load and caching its result on a miss. Lookups are not cached while the generation cannot be read.
+func Remember[T any](ctx context.Context, c Cache, name, key string, load func() (T, error)) (T, error) {
+	generation := Generation(ctx, c, name)
+	if generation < 0 {
+		return load()
+	}
+	key = name + ":" + strconv.FormatInt(generation, 10) + ":" + key
+
+	var value T
+	err := c.Get(ctx, key, &value)
+	if err == nil {
+		return value, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		slog.WarnContext(ctx, "⚠️ Failed to read cache", "key", key, "error", err)
+	}
+
+	value, err = load()
+	if err != nil {
+		return value, err
+	}
+	if err := c.Set(ctx, key, value, 0); err != nil {
+		slog.WarnContext(ctx, "⚠️ Failed to write cache", "key", key, "error", err)
+	}
+	return value, nil
+}
+
+// memoryEntry is a value of the memory cache
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// memoryCache keeps values in this process; it is not shared between replicas
+type memoryCache struct {
+	mu         sync.Mutex
+	entries    map[string]memoryEntry
+	ttl        time.Duration
+	writes     int
+	generation map[string]int64
+}
+
+// memorySweepInterval is the number of writes between removals of expired entries
+const memorySweepInterval = 256
+
+// NewMemory returns a cache held in process memory with the given default ttl
+func NewMemory(ttl time.Duration) Cache {
+	return &memoryCache{entries: map[string]memoryEntry{}, ttl: ttl, generation: map[string]int64{}}
+}
+
+func (m *memoryCache) Get(_ context.Context, key string, dst interface{}) error {
+	m.mu.Lock()
+	if counter, ok := m.generation[key]; ok {
+		m.mu.Unlock()
+		return json.Unmarshal([]byte(strconv.FormatInt(counter, 10)), dst)
+	}
+	entry, ok := m.entries[key]
+	m.mu.Unlock()
+	if !ok || time.Now().After(entry.expires) {
+		return ErrNotFound
+	}
+	return json.Unmarshal(entry.value, dst)
+}
+
+func (m *memoryCache) Set(_ context.Context, key string, value interface{}, ttl time.Duration) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("cache: failed to encode %s: %w", key, err)
+	}
+	if ttl <= 0 {
+		ttl = m.ttl
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = memoryEntry{value: encoded, expires: time.Now().Add(ttl)}
+	m.writes++
+	if m.writes%memorySweepInterval == 0 {
+		now := time.Now()
+		for key, entry := range m.entries {
+			if now.After(entry.expires) {
+				delete(m.entries, key)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *memoryCache) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.entries, key)
+		delete(m.generation, key)
+	}
+	return nil
+}
+
+// Incr keeps counters apart from the entries so they never expire
+func (m *memoryCache) Incr(_ context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.generation[key]++
+	return m.generation[key], nil
+}
+
+func (m *memoryCache) Ping(context.Context) error {
+	return nil
+}
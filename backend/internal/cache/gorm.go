@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// GormPlugin invalidates the cached lookups derived from a table once GORM's writes to it are committed.
+// Invalidating earlier would let a concurrent lookup read the old rows and cache them under the new
+// generation.
+type GormPlugin struct {
+	// Tables maps a table name to the generations its writes invalidate
+	Tables map[string][]string
+}
+
+// Name implements gorm.Plugin
+func (GormPlugin) Name() string {
+	return "cache"
+}
+
+// Initialize registers a callback after each of GORM's write processors have committed, and wraps the
+// connection pool so the transactions it begins invalidate on commit what their writes queued
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	if _, ok := db.ConnPool.(*txPool); !ok {
+		beginner, ok := db.ConnPool.(gorm.TxBeginner)
+		if !ok {
+			return errors.New("cache plugin: connection pool cannot begin transactions")
+		}
+		pool := &txPool{ConnPool: db.ConnPool, beginner: beginner}
+		db.ConnPool = pool
+		db.Statement.ConnPool = pool
+	}
+
+	invalidate := func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement.DryRun || tx.RowsAffected == 0 {
+			return
+		}
+		names := p.Tables[tx.Statement.Table]
+		if len(names) == 0 {
+			return
+		}
+		// Inside a transaction of the caller's, wait for its commit; otherwise the write is committed
+		if pending, ok := tx.Statement.ConnPool.(*pendingTx); ok {
+			pending.queue(names)
+			return
+		}
+		Invalidate(tx.Statement.Context, Default(), names...)
+	}
+
+	const committed = "gorm:commit_or_rollback_transaction"
+	if err := db.Callback().Create().After(committed).Register("cache:invalidate_create", invalidate); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After(committed).Register("cache:invalidate_update", invalidate); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After(committed).Register("cache:invalidate_delete", invalidate)
+}
+
+// txPool is the connection pool of GORM, beginning transactions that invalidate on commit
+type txPool struct {
+	gorm.ConnPool
+	beginner gorm.TxBeginner
+}
+
+// BeginTx implements gorm.ConnPoolBeginner
+func (p *txPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	tx, err := p.beginner.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	// Invalidation runs after the commit, when the context of the request may be done
+	return &pendingTx{Tx: tx, pool: p, ctx: context.WithoutCancel(ctx)}, nil
+}
+
+// GetDBConn implements gorm.GetDBConnector, so db.DB() still finds the pool
+func (p *txPool) GetDBConn() (*sql.DB, error) {
+	if sqlDB, ok := p.ConnPool.(*sql.DB); ok {
+		return sqlDB, nil
+	}
+	if connector, ok := p.ConnPool.(gorm.GetDBConnector); ok {
+		return connector.GetDBConn()
+	}
+	return nil, gorm.ErrInvalidDB
+}
+
+// pendingTx is a transaction holding the generations its writes invalidate until it commits
+type pendingTx struct {
+	*sql.Tx
+	pool *txPool
+	ctx  context.Context
+
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+func (t *pendingTx) queue(names []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.names == nil {
+		t.names = make(map[string]bool)
+	}
+	for _, name := range names {
+		t.names[name] = true
+	}
+}
+
+// Commit commits the transaction, then invalidates what its writes queued
+func (t *pendingTx) Commit() error {
+	if err := t.Tx.Commit(); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	names := make([]string, 0, len(t.names))
+	for name := range t.names {
+		names = append(names, name)
+	}
+	t.names = nil
+	t.mu.Unlock()
+	if len(names) > 0 {
+		Invalidate(t.ctx, Default(), names...)
+	}
+	return nil
+}
+
+// GetDBConn implements gorm.GetDBConnector, so tx.DB() still finds the pool
+func (t *pendingTx) GetDBConn() (*sql.DB, error) {
+	return t.pool.GetDBConn()
+}
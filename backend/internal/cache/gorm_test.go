@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// fakeDriver accepts every statement, reporting one row affected, and counts the generations of the
+// wells lookups seen as its transactions commit
+type fakeDriver struct {
+	mu       sync.Mutex
+	commits  []int64
+	rollback int
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{driver: d}, nil }
+
+type fakeConn struct{ driver *fakeDriver }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return &fakeTx{driver: c.driver}, nil }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct{ driver *fakeDriver }
+
+func (t *fakeTx) Commit() error {
+	t.driver.mu.Lock()
+	defer t.driver.mu.Unlock()
+	t.driver.commits = append(t.driver.commits, Generation(context.Background(), Default(), Wells))
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.driver.mu.Lock()
+	defer t.driver.mu.Unlock()
+	t.driver.rollback++
+	return nil
+}
+
+func openFakeDB(t *testing.T) (*gorm.DB, *fakeDriver) {
+	t.Helper()
+	fake := &fakeDriver{}
+	conn := sql.OpenDB(fakeConnector{fake})
+	t.Cleanup(func() { conn.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	if err := db.Use(GormPlugin{Tables: map[string][]string{"wells": {Wells}}}); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+
+	previous := Default()
+	SetDefault(NewMemory(time.Minute))
+	t.Cleanup(func() { SetDefault(previous) })
+	return db, fake
+}
+
+type fakeConnector struct{ driver *fakeDriver }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open("") }
+func (c fakeConnector) Driver() driver.Driver                        { return c.driver }
+
+func TestGormPluginInvalidatesAfterCommit(t *testing.T) {
+	ctx := context.Background()
+	generation := func() int64 { return Generation(ctx, Default(), Wells) }
+
+	t.Run("single write", func(t *testing.T) {
+		db, fake := openFakeDB(t)
+		if err := db.Table("wells").Where("id = ?", 1).Update("name", "A-1").Error; err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if got := generation(); got != 1 {
+			t.Errorf("generation = %d, want 1", got)
+		}
+		// The write's own transaction committed before the generation moved
+		if len(fake.commits) != 1 || fake.commits[0] != 0 {
+			t.Errorf("generations at commit = %v, want [0]", fake.commits)
+		}
+	})
+
+	t.Run("transaction", func(t *testing.T) {
+		db, fake := openFakeDB(t)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Table("wells").Where("id = ?", 1).Update("name", "A-1").Error; err != nil {
+				return err
+			}
+			if err := tx.Table("wells").Where("id = ?", 2).Delete(nil).Error; err != nil {
+				return err
+			}
+			if got := generation(); got != 0 {
+				t.Errorf("generation before commit = %d, want 0", got)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Transaction: %v", err)
+		}
+		// Both writes are invalidated once, after the commit
+		if got := generation(); got != 1 {
+			t.Errorf("generation = %d, want 1", got)
+		}
+		if len(fake.commits) != 1 || fake.commits[0] != 0 {
+			t.Errorf("generations at commit = %v, want [0]", fake.commits)
+		}
+	})
+
+	t.Run("rolled back transaction", func(t *testing.T) {
+		db, fake := openFakeDB(t)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Table("wells").Where("id = ?", 1).Update("name", "A-1").Error; err != nil {
+				return err
+			}
+			return errors.New("abort")
+		})
+		if err == nil {
+			t.Fatal("Transaction did not fail")
+		}
+		if got := generation(); got != 0 {
+			t.Errorf("generation = %d, want 0", got)
+		}
+		if fake.rollback != 1 {
+			t.Errorf("rollbacks = %d, want 1", fake.rollback)
+		}
+	})
+
+	t.Run("other table", func(t *testing.T) {
+		db, _ := openFakeDB(t)
+		if err := db.Table("users").Where("id = ?", 1).Update("name", "x").Error; err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if got := generation(); got != 0 {
+			t.Errorf("generation = %d, want 0", got)
+		}
+	})
+
+	t.Run("pool still reachable", func(t *testing.T) {
+		db, _ := openFakeDB(t)
+		if _, err := db.DB(); err != nil {
+			t.Errorf("DB: %v", err)
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			_, err := tx.DB()
+			return err
+		})
+		if err != nil {
+			t.Errorf("DB in a transaction: %v", err)
+		}
+	})
+}
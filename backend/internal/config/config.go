@@ -2,110 +2,262 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"net"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
-// Config holds all configuration for our application
+// Config holds all configuration for our application. Every setting has a YAML key, the dotted path
+// of its yaml tags, and most have an environment variable; see Load for how the layers combine.
 type Config struct {
-	App      AppConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	Server   ServerConfig
+	App        AppConfig        `yaml:"app"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Redis      RedisConfig      `yaml:"redis"`
+	Server     ServerConfig     `yaml:"server"`
+	Extraction ExtractionConfig `yaml:"extraction"`
+	Upload     UploadConfig     `yaml:"upload"`
+	Storage    StorageConfig    `yaml:"storage"`
+	Auth       AuthConfig       `yaml:"auth"`
+
+	// File is the YAML file that was read, if any
+	File string `yaml:"-"`
+	// sources records where each setting that is not a default came from, by key
+	sources map[string]string
 }
 
 // AppConfig holds application configuration
 type AppConfig struct {
-	Name        string
-	Environment string
-	Debug       bool
-	LogLevel    string
+	Name        string `yaml:"name" env:"APP_NAME"`
+	Environment string `yaml:"environment" env:"APP_ENV"`
+	Debug       bool   `yaml:"debug" env:"APP_DEBUG"`
+	LogLevel    string `yaml:"log_level" env:"LOG_LEVEL"`
+	// LogFormat is json, for log collectors, or text
+	LogFormat string `yaml:"log_format" env:"LOG_FORMAT"`
 }
 
 // DatabaseConfig holds database configuration
 type DatabaseConfig struct {
-	Host            string
-	Port            int
-	User            string
-	Password        string
-	Name            string
-	SSLMode         string
-	MaxOpenConns    int
-	MaxIdleConns    int
-	ConnMaxLifetime time.Duration
-}
-
-// RedisConfig holds Redis configuration
+	Host            string        `yaml:"host" env:"DB_HOST"`
+	Port            int           `yaml:"port" env:"DB_PORT"`
+	User            string        `yaml:"user" env:"DB_USER"`
+	Password        string        `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name            string        `yaml:"name" env:"DB_NAME"`
+	SSLMode         string        `yaml:"ssl_mode" env:"DB_SSL_MODE"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	// AutoMigrate applies pending migrations at server start instead of refusing to start
+	AutoMigrate bool `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+}
+
+// RedisConfig holds Redis configuration. When enabled, the extraction queue and the lookup cache
+// are kept in Redis and shared by every replica; otherwise they live in each process's memory.
 type RedisConfig struct {
-	Host     string
-	Port     int
-	Password string
-	DB       int
+	Enabled  bool   `yaml:"enabled" env:"REDIS_ENABLED"`
+	Host     string `yaml:"host" env:"REDIS_HOST"`
+	Port     int    `yaml:"port" env:"REDIS_PORT"`
+	Password string `yaml:"password" env:"REDIS_PASSWORD" secret:"true"`
+	DB       int    `yaml:"db" env:"REDIS_DB"`
+	// KeyPrefix namespaces the keys, so several deployments can share a Redis
+	KeyPrefix string `yaml:"key_prefix" env:"REDIS_KEY_PREFIX"`
+	// CacheTTL bounds how long a cached lookup is kept, in Redis or in memory
+	CacheTTL time.Duration `yaml:"cache_ttl" env:"REDIS_CACHE_TTL"`
+}
+
+// Addr returns the host:port address of the Redis server
+func (r *RedisConfig) Addr() string {
+	return net.JoinHostPort(r.Host, strconv.Itoa(r.Port))
 }
 
 // ServerConfig holds server configuration
 type ServerConfig struct {
-	Port        string
-	BackendURL  string
-	FrontendURL string
-	CORSOrigins []string
-}
-
-// Load loads configuration from environment variables
-func Load() (*Config, error) {
-	// Load .env file if it exists
-	if err := godotenv.Load(".env"); err != nil {
-		// Try parent directory if .env not found in current directory
-		if err := godotenv.Load("../.env"); err != nil {
-			log.Printf("Warning: .env file not found in current or parent directory: %v", err)
+	Port        string `yaml:"port" env:"BACKEND_PORT"`
+	BackendURL  string `yaml:"backend_url" env:"BACKEND_URL"`
+	FrontendURL string `yaml:"frontend_url" env:"FRONTEND_URL"`
+	// CORSOrigins defaults to the frontend URL
+	CORSOrigins []string `yaml:"cors_origins" env:"CORS_ORIGINS"`
+	// ShutdownTimeout bounds how long a shutdown waits for requests and extractions to finish
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+// ExtractionConfig locates the Python table extractor. Relative paths are resolved against the
+// working directory of the process, except the scripts, which are inside Dir.
+type ExtractionConfig struct {
+	Dir    string `yaml:"dir" env:"EXTRACTION_DIR"`
+	Python string `yaml:"python" env:"EXTRACTION_PYTHON"`
+	Script string `yaml:"script" env:"EXTRACTION_SCRIPT"`
+	// PageScript writes single pages of a PDF, as a PDF or an image, for the viewer
+	PageScript string `yaml:"page_script" env:"EXTRACTION_PAGE_SCRIPT"`
+	// InputDir and OutputDir are no longer used: each extraction runs in a scratch directory and the
+	// files are kept in storage. They are still accepted so existing configuration files load.
+	InputDir  string `yaml:"input_dir" env:"EXTRACTION_INPUT_DIR"`
+	OutputDir string `yaml:"output_dir" env:"EXTRACTION_OUTPUT_DIR"`
+	// Workers is the number of extractor processes allowed to run at once
+	Workers int           `yaml:"workers" env:"EXTRACTION_WORKERS"`
+	Timeout time.Duration `yaml:"timeout" env:"EXTRACTION_TIMEOUT"`
+}
+
+// UploadConfig limits uploads and locates the files kept while they are processed
+type UploadConfig struct {
+	Dir                string   `yaml:"dir" env:"UPLOAD_DIR"`
+	MaxPDFSize         ByteSize `yaml:"max_pdf_size" env:"UPLOAD_MAX_PDF_SIZE"`
+	MaxPDFPages        int      `yaml:"max_pdf_pages" env:"UPLOAD_MAX_PDF_PAGES"`
+	MaxSpreadsheetSize ByteSize `yaml:"max_spreadsheet_size" env:"UPLOAD_MAX_SPREADSHEET_SIZE"`
+}
+
+// ByteSize is a size in bytes, written as a number with an optional KB, MB or GB suffix
+type ByteSize int64
+
+// Byte size units
+const (
+	KB ByteSize = 1 << (10 * (iota + 1))
+	MB
+	GB
+)
+
+// UnmarshalText parses sizes such as 512KB, 100MB, 1.5GB or 1048576
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	raw := strings.ToUpper(strings.TrimSpace(string(text)))
+	unit := ByteSize(1)
+	for _, suffix := range []struct {
+		name string
+		size ByteSize
+	}{{"GB", GB}, {"MB", MB}, {"KB", KB}, {"B", 1}} {
+		if strings.HasSuffix(raw, suffix.name) {
+			raw, unit = strings.TrimSpace(strings.TrimSuffix(raw, suffix.name)), suffix.size
+			break
 		}
 	}
+	n, err := strconv.ParseFloat(raw, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q, expected e.g. 50MB", string(text))
+	}
+	*b = ByteSize(n * float64(unit))
+	return nil
+}
+
+// UnmarshalYAML accepts sizes written as numbers as well as strings
+func (b *ByteSize) UnmarshalYAML(node *yaml.Node) error {
+	return b.UnmarshalText([]byte(node.Value))
+}
+
+func (b ByteSize) String() string {
+	switch {
+	case b >= GB && b%GB == 0:
+		return fmt.Sprintf("%dGB", b/GB)
+	case b >= MB && b%MB == 0:
+		return fmt.Sprintf("%dMB", b/MB)
+	case b >= KB && b%KB == 0:
+		return fmt.Sprintf("%dKB", b/KB)
+	}
+	return fmt.Sprintf("%dB", int64(b))
+}
+
+// Storage backends
+const (
+	StorageLocal = "local"
+	StorageS3    = "s3"
+)
 
-	config := &Config{
+// StorageConfig selects where stored documents are kept
+type StorageConfig struct {
+	Backend  string   `yaml:"backend" env:"STORAGE_BACKEND"`
+	LocalDir string   `yaml:"local_dir" env:"STORAGE_LOCAL_DIR"`
+	S3       S3Config `yaml:"s3"`
+	// SigningKey signs the local backend's short-lived URLs; replicas must share it
+	SigningKey string `yaml:"signing_key" env:"STORAGE_SIGNING_KEY" secret:"true"`
+	// URLTTL is how long a signed URL handed to the viewer stays valid
+	URLTTL time.Duration `yaml:"url_ttl" env:"STORAGE_URL_TTL"`
+	// MinFreeSpace is the free disk space below which the server reports itself not ready
+	MinFreeSpace ByteSize `yaml:"min_free_space" env:"STORAGE_MIN_FREE_SPACE"`
+}
+
+// S3Config holds the settings of an S3-compatible object store
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint" env:"S3_ENDPOINT"`
+	Region    string `yaml:"region" env:"S3_REGION"`
+	Bucket    string `yaml:"bucket" env:"S3_BUCKET"`
+	AccessKey string `yaml:"access_key" env:"S3_ACCESS_KEY" secret:"true"`
+	SecretKey string `yaml:"secret_key" env:"S3_SECRET_KEY" secret:"true"`
+	UseSSL    bool   `yaml:"use_ssl" env:"S3_USE_SSL"`
+}
+
+// AuthConfig holds authentication settings
+type AuthConfig struct {
+	Enabled           bool          `yaml:"enabled" env:"AUTH_ENABLED"`
+	JWTSecret         string        `yaml:"jwt_secret" env:"AUTH_JWT_SECRET" secret:"true"`
+	TokenTTL          time.Duration `yaml:"token_ttl" env:"AUTH_TOKEN_TTL"`
+	PasswordMinLength int           `yaml:"password_min_length" env:"AUTH_PASSWORD_MIN_LENGTH"`
+}
+
+// Default returns the configuration used for settings no layer sets
+func Default() *Config {
+	return &Config{
 		App: AppConfig{
-			Name:        getEnv("APP_NAME", "Workbench"),
-			Environment: getEnv("APP_ENV", "development"),
-			Debug:       getEnvAsBool("APP_DEBUG", true),
-			LogLevel:    getEnv("LOG_LEVEL", "debug"),
+			Name:        "Workbench",
+			Environment: "development",
+			Debug:       true,
+			LogLevel:    "debug",
+			LogFormat:   "json",
 		},
 		Database: DatabaseConfig{
-			Host:            getEnv("DB_HOST", "localhost"),
-			Port:            getEnvAsInt("DB_PORT", 5432),
-			User:            getEnv("DB_USER", "postgres"),
-			Password:        getEnv("DB_PASSWORD", ""),
-			Name:            getEnv("DB_NAME", "pdf_extraction"),
-			SSLMode:         getEnv("DB_SSL_MODE", "disable"),
-			MaxOpenConns:    getEnvAsInt("DB_MAX_OPEN_CONNS", 25),
-			MaxIdleConns:    getEnvAsInt("DB_MAX_IDLE_CONNS", 25),
-			ConnMaxLifetime: getEnvAsDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+			Host:            "localhost",
+			Port:            5432,
+			User:            "postgres",
+			Name:            "pdf_extraction",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		Redis: RedisConfig{
+			Host:      "localhost",
+			Port:      6379,
+			KeyPrefix: "workbench:",
+			CacheTTL:  5 * time.Minute,
 		},
 		Server: ServerConfig{
-			Port:        getEnv("BACKEND_PORT", "8081"),
-			BackendURL:  getEnv("BACKEND_URL", "http://localhost:8081"),
-			FrontendURL: getEnv("FRONTEND_URL", "http://localhost:3000"),
-			CORSOrigins: []string{getEnv("FRONTEND_URL", "http://localhost:3000")},
+			Port:            "8081",
+			BackendURL:      "http://localhost:8081",
+			FrontendURL:     "http://localhost:3000",
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Extraction: ExtractionConfig{
+			Dir:        "../final_extraction_system",
+			Python:     "../temp_env/bin/python",
+			Script:     "better_markdown_extractor.py",
+			PageScript: "pdf_pages.py",
+			Workers:    1,
+			Timeout:    15 * time.Minute,
+		},
+		Upload: UploadConfig{
+			Dir:                "./uploads",
+			MaxPDFSize:         100 * MB,
+			MaxPDFPages:        2000,
+			MaxSpreadsheetSize: 50 * MB,
+		},
+		Storage: StorageConfig{
+			Backend:      StorageLocal,
+			LocalDir:     "./storage",
+			URLTTL:       5 * time.Minute,
+			MinFreeSpace: 1 * GB,
+			S3: S3Config{
+				UseSSL: true,
+			},
+		},
+		Auth: AuthConfig{
+			TokenTTL:          24 * time.Hour,
+			PasswordMinLength: 12,
 		},
 	}
-
-	// Debug: Print the actual database configuration being used
-	log.Printf("🔍 Database Config: Host=%s, Port=%d, User=%s, Name=%s, Password=%s, SSLMode=%s",
-		config.Database.Host,
-		config.Database.Port,
-		config.Database.User,
-		config.Database.Name,
-		config.Database.Password,
-		config.Database.SSLMode)
-
-	log.Printf("🔍 Database DSN: %s", config.Database.GetDSN())
-
-	return config, nil
 }
 
-// GetDSN returns PostgreSQL connection string
+// GetDSN returns PostgreSQL connection string. It contains the password; log RedactedDSN instead.
 func (c *DatabaseConfig) GetDSN() string {
 	return fmt.Sprintf(
 		"postgresql://%s:%s@%s:%d/%s?sslmode=%s",
@@ -113,34 +265,39 @@ func (c *DatabaseConfig) GetDSN() string {
 	)
 }
 
-// Helper functions
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
+// RedactedDSN returns the connection string with the password masked
+func (c *DatabaseConfig) RedactedDSN() string {
+	return fmt.Sprintf(
+		"postgresql://%s:%s@%s:%d/%s?sslmode=%s",
+		c.User, mask(c.Password), c.Host, c.Port, c.Name, c.SSLMode,
+	)
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	valueStr := getEnv(key, "")
-	if value, err := strconv.Atoi(valueStr); err == nil {
-		return value
-	}
-	return defaultValue
+// ScriptPath returns the path of the extractor script
+func (c *ExtractionConfig) ScriptPath() string {
+	return c.inDir(c.Script)
 }
 
-func getEnvAsBool(key string, defaultValue bool) bool {
-	valueStr := getEnv(key, "")
-	if value, err := strconv.ParseBool(valueStr); err == nil {
-		return value
+// PageScriptPath returns the path of the page script
+func (c *ExtractionConfig) PageScriptPath() string {
+	return c.inDir(c.PageScript)
+}
+
+// PythonCommand returns the interpreter to run; a bare name such as python3 is looked up in PATH,
+// anything else is made absolute because the extractor runs inside Dir
+func (c *ExtractionConfig) PythonCommand() string {
+	if !strings.ContainsRune(c.Python, os.PathSeparator) {
+		return c.Python
+	}
+	if abs, err := filepath.Abs(c.Python); err == nil {
+		return abs
 	}
-	return defaultValue
+	return c.Python
 }
 
-func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
-	valueStr := getEnv(key, "")
-	if value, err := time.ParseDuration(valueStr); err == nil {
-		return value
+func (c *ExtractionConfig) inDir(path string) string {
+	if filepath.IsAbs(path) {
+		return path
 	}
-	return defaultValue
+	return filepath.Join(c.Dir, path)
 }
@@ -0,0 +1,324 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Options are the command line layer of the configuration
+type Options struct {
+	// File is the YAML file to read; empty falls back to $CONFIG_FILE, then config.yaml when present
+	File string
+	// Set holds key=value overrides such as server.port=9090
+	Set []string
+}
+
+// RegisterFlags adds --config and --set to a flag set
+func (o *Options) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.File, "config", "", "YAML configuration file (default $CONFIG_FILE, else config.yaml when present)")
+	fs.Func("set", "override a setting by its YAML key, e.g. --set server.port=9090 (repeatable)", func(value string) error {
+		o.Set = append(o.Set, value)
+		return nil
+	})
+}
+
+// Load builds the configuration from, in increasing precedence: the defaults, the YAML file, the
+// environment (including .env), and the --set flags of opts, which may be nil. The result is
+// validated; errors name the setting and where its value came from.
+func Load(opts *Options) (*Config, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	config := Default()
+	config.sources = map[string]string{}
+	settings := config.settings()
+
+	// YAML file
+	file, explicit := opts.File, opts.File != ""
+	if file == "" {
+		file, explicit = os.Getenv("CONFIG_FILE"), os.Getenv("CONFIG_FILE") != ""
+	}
+	if file == "" {
+		for _, candidate := range []string{"config.yaml", "../config.yaml"} {
+			if _, err := os.Stat(candidate); err == nil {
+				file = candidate
+				break
+			}
+		}
+	}
+	if file != "" {
+		if err := config.loadFile(file, settings); err != nil {
+			if explicit || !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+		}
+	}
+
+	// Environment; variables already set take precedence over .env
+	if err := godotenv.Load(".env"); err != nil {
+		// Try parent directory if .env not found in current directory
+		if err := godotenv.Load("../.env"); err != nil {
+			log.Printf("Warning: .env file not found in current or parent directory: %v", err)
+		}
+	}
+	var problems []string
+	for _, setting := range settings {
+		if setting.Env == "" {
+			continue
+		}
+		if value := os.Getenv(setting.Env); value != "" {
+			source := "env " + setting.Env
+			if err := setting.set(value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s (%s): %v", setting.Key, source, err))
+				continue
+			}
+			config.sources[setting.Key] = source
+		}
+	}
+
+	// Flags
+	byKey := map[string]setting{}
+	for _, setting := range settings {
+		byKey[setting.Key] = setting
+	}
+	for _, override := range opts.Set {
+		key, value, ok := strings.Cut(override, "=")
+		key = strings.TrimSpace(key)
+		setting, known := byKey[key]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("--set %s: expected key=value", override))
+		case !known:
+			problems = append(problems, fmt.Sprintf("--set %s: unknown setting %q", override, key))
+		default:
+			if err := setting.set(value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s (flag --set): %v", key, err))
+				continue
+			}
+			config.sources[key] = "flag --set"
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	if len(config.Server.CORSOrigins) == 0 {
+		config.Server.CORSOrigins = []string{config.Server.FrontendURL}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// loadFile applies a YAML file. Unknown keys are errors, so a misspelt setting is not silently ignored.
+func (c *Config) loadFile(path string, settings []setting) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	c.File = path
+
+	// Record which keys the file set
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(content, &tree); err == nil {
+		for _, setting := range settings {
+			if hasKey(tree, strings.Split(setting.Key, ".")) {
+				c.sources[setting.Key] = "file " + path
+			}
+		}
+	}
+	return nil
+}
+
+func hasKey(tree map[string]interface{}, path []string) bool {
+	value, ok := tree[path[0]]
+	if !ok || len(path) == 1 {
+		return ok
+	}
+	child, ok := value.(map[string]interface{})
+	return ok && hasKey(child, path[1:])
+}
+
+// setting is one leaf of the configuration
+type setting struct {
+	Key    string
+	Env    string
+	Secret bool
+	value  reflect.Value
+}
+
+// settings lists the leaves of the configuration in declaration order
+func (c *Config) settings() []setting {
+	var settings []setting
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			value := v.Field(i)
+			if _, ok := value.Addr().Interface().(encoding.TextUnmarshaler); !ok && field.Type.Kind() == reflect.Struct {
+				walk(value, prefix+name+".")
+				continue
+			}
+			settings = append(settings, setting{
+				Key:    prefix + name,
+				Env:    field.Tag.Get("env"),
+				Secret: field.Tag.Get("secret") == "true",
+				value:  value,
+			})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+	return settings
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses raw into the setting
+func (s setting) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if unmarshaler, ok := s.value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(raw))
+	}
+
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, expected e.g. 30s or 5m", raw)
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(raw)
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q, expected true or false", raw)
+		}
+		s.value.SetBool(b)
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Slice && s.value.Type().Elem().Kind() == reflect.String:
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+	return nil
+}
+
+// display formats the setting's value, masking secrets
+func (s setting) display() string {
+	value := s.value.Interface()
+	if s.Secret {
+		return mask(s.value.String())
+	}
+	switch v := value.(type) {
+	case []string:
+		return strings.Join(v, ",")
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// mask hides a secret while still showing whether it is set
+func mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "********"
+}
+
+// EffectiveSetting is one setting of the effective configuration, with secrets masked
+type EffectiveSetting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Env    string `json:"env,omitempty"`
+}
+
+// Effective lists every setting with its value, secrets masked, and where the value came from
+func (c *Config) Effective() []EffectiveSetting {
+	settings := c.settings()
+	effective := make([]EffectiveSetting, 0, len(settings))
+	for _, setting := range settings {
+		source := c.sources[setting.Key]
+		if source == "" {
+			source = "default"
+		}
+		effective = append(effective, EffectiveSetting{Key: setting.Key, Value: setting.display(), Source: source, Env: setting.Env})
+	}
+	return effective
+}
+
+// Dump writes the effective configuration, one setting per line, with secrets masked
+func (c *Config) Dump(w io.Writer) {
+	effective := c.Effective()
+	width := 0
+	for _, setting := range effective {
+		if len(setting.Key) > width {
+			width = len(setting.Key)
+		}
+	}
+	for _, setting := range effective {
+		fmt.Fprintf(w, "  %-*s = %-30s (%s)\n", width, setting.Key, setting.Value, setting.Source)
+	}
+}
+
+// LogEffective logs the effective configuration with secrets masked, as one record whose
+// settings attribute maps each key to its value
+func (c *Config) LogEffective() {
+	effective := c.Effective()
+	settings := make([]any, 0, len(effective))
+	for _, setting := range effective {
+		settings = append(settings, slog.String(setting.Key, setting.Value))
+	}
+	slog.Info("🔧 Effective configuration", "file", c.File, slog.Group("settings", settings...))
+}
+
+// ValidationError lists every invalid setting
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	sort.Strings(e.Problems)
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
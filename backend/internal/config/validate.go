@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Validate checks every setting and reports all invalid ones at once
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, key, format string, args ...interface{}) {
+		if ok {
+			return
+		}
+		where := key
+		if source := c.sources[key]; source != "" {
+			where += " (" + source + ")"
+		}
+		problems = append(problems, where+": "+fmt.Sprintf(format, args...))
+	}
+	oneOf := func(value, key string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		check(false, key, "%q must be one of %s", value, strings.Join(allowed, ", "))
+	}
+
+	oneOf(c.App.Environment, "app.environment", "development", "test", "staging", "production")
+	oneOf(strings.ToLower(c.App.LogLevel), "app.log_level", "debug", "info", "warn", "error")
+	oneOf(c.App.LogFormat, "app.log_format", "json", "text")
+
+	check(c.Database.Host != "", "database.host", "is required")
+	check(validPort(c.Database.Port), "database.port", "%d is not a port number", c.Database.Port)
+	check(c.Database.User != "", "database.user", "is required")
+	check(c.Database.Name != "", "database.name", "is required")
+	oneOf(c.Database.SSLMode, "database.ssl_mode", "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	check(c.Database.MaxOpenConns >= 1, "database.max_open_conns", "must be at least 1")
+	check(c.Database.MaxIdleConns >= 0 && c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns", "must be between 0 and database.max_open_conns (%d)", c.Database.MaxOpenConns)
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime", "must not be negative")
+
+	check(validPort(c.Redis.Port), "redis.port", "%d is not a port number", c.Redis.Port)
+	check(c.Redis.DB >= 0, "redis.db", "must not be negative")
+	check(!c.Redis.Enabled || c.Redis.Host != "", "redis.host", "is required when redis is enabled")
+	check(c.Redis.CacheTTL > 0, "redis.cache_ttl", "must be positive")
+
+	port, err := strconv.Atoi(c.Server.Port)
+	check(err == nil && validPort(port), "server.port", "%q is not a port number", c.Server.Port)
+	check(validURL(c.Server.BackendURL), "server.backend_url", "%q is not an http(s) URL", c.Server.BackendURL)
+	check(validURL(c.Server.FrontendURL), "server.frontend_url", "%q is not an http(s) URL", c.Server.FrontendURL)
+	for _, origin := range c.Server.CORSOrigins {
+		check(origin == "*" || validURL(origin), "server.cors_origins", "%q is not an http(s) origin", origin)
+	}
+
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout", "must be positive")
+
+	check(c.Extraction.Dir != "", "extraction.dir", "is required")
+	check(c.Extraction.Python != "", "extraction.python", "is required")
+	check(c.Extraction.Script != "", "extraction.script", "is required")
+	check(c.Extraction.PageScript != "", "extraction.page_script", "is required")
+	check(c.Extraction.Workers >= 1, "extraction.workers", "must be at least 1")
+	check(c.Extraction.Timeout > 0, "extraction.timeout", "must be positive")
+
+	check(c.Upload.Dir != "", "upload.dir", "is required")
+	check(c.Upload.MaxPDFSize > 0, "upload.max_pdf_size", "must be positive")
+	check(c.Upload.MaxPDFPages > 0, "upload.max_pdf_pages", "must be positive")
+	check(c.Upload.MaxSpreadsheetSize > 0, "upload.max_spreadsheet_size", "must be positive")
+
+	oneOf(c.Storage.Backend, "storage.backend", StorageLocal, StorageS3)
+	check(c.Storage.MinFreeSpace >= 0, "storage.min_free_space", "must not be negative")
+	check(c.Storage.URLTTL > 0, "storage.url_ttl", "must be positive")
+	switch c.Storage.Backend {
+	case StorageLocal:
+		check(c.Storage.LocalDir != "", "storage.local_dir", "is required for the local backend")
+	case StorageS3:
+		check(c.Storage.S3.Endpoint != "", "storage.s3.endpoint", "is required for the s3 backend")
+		check(c.Storage.S3.Bucket != "", "storage.s3.bucket", "is required for the s3 backend")
+		check(c.Storage.S3.AccessKey != "", "storage.s3.access_key", "is required for the s3 backend")
+		check(c.Storage.S3.SecretKey != "", "storage.s3.secret_key", "is required for the s3 backend")
+	}
+
+	check(!c.Auth.Enabled || len(c.Auth.JWTSecret) >= 32, "auth.jwt_secret", "must be at least 32 characters when auth is enabled")
+	check(c.Auth.TokenTTL > 0, "auth.token_ttl", "must be positive")
+	check(c.Auth.PasswordMinLength >= 8, "auth.password_min_length", "must be at least 8")
+
+	if c.App.Environment == "production" {
+		check(!c.App.Debug, "app.debug", "must be false in production")
+		check(c.Database.Password != "", "database.password", "is required in production")
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Warnings reports settings that are valid but point at files that do not exist. They do not stop
+// startup, since commands that never extract PDFs do not need the extractor.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if _, err := os.Stat(c.Extraction.Dir); err != nil {
+		warnings = append(warnings, fmt.Sprintf("extraction.dir: %s not found; PDF extraction will fail", c.Extraction.Dir))
+	} else if _, err := os.Stat(c.Extraction.ScriptPath()); err != nil {
+		warnings = append(warnings, fmt.Sprintf("extraction.script: %s not found; PDF extraction will fail", c.Extraction.ScriptPath()))
+	} else if _, err := os.Stat(c.Extraction.PageScriptPath()); err != nil {
+		warnings = append(warnings, fmt.Sprintf("extraction.page_script: %s not found; page images will fail", c.Extraction.PageScriptPath()))
+	}
+	if _, err := exec.LookPath(c.Extraction.PythonCommand()); err != nil {
+		warnings = append(warnings, fmt.Sprintf("extraction.python: %s not found; PDF extraction will fail", c.Extraction.Python))
+	}
+	if c.Storage.Backend == StorageLocal && c.Storage.SigningKey == "" {
+		warnings = append(warnings, "storage.signing_key: not set; signed URLs use a random key, so they break on restart and other replicas refuse them")
+	}
+	return warnings
+}
+
+func validPort(port int) bool {
+	return port >= 1 && port <= 65535
+}
+
+func validURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
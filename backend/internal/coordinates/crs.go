@@ -0,0 +1,178 @@
+package coordinates
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrCRSRequired is returned for projected coordinates when no CRS was given
+	ErrCRSRequired = errors.New("coordinates look projected; a CRS (EPSG code) is required")
+	// ErrUnsupportedCRS is returned for EPSG codes that cannot be converted
+	ErrUnsupportedCRS = errors.New("unsupported CRS")
+)
+
+// CRS is a coordinate reference system that can be converted to WGS84
+type CRS struct {
+	Code      int    `json:"code"`
+	Name      string `json:"name"`
+	Projected bool   `json:"projected"`
+
+	toWGS84 func(x, y float64) (lon, lat float64)
+}
+
+// String returns the CRS as an EPSG identifier, e.g. "EPSG:32650"
+func (c *CRS) String() string {
+	return "EPSG:" + strconv.Itoa(c.Code)
+}
+
+// geographicCRS are datums that agree with WGS84 to a couple of metres, well within the precision of
+// reported well locations, so their latitudes and longitudes are used unchanged
+var geographicCRS = map[int]string{
+	4326: "WGS 84",
+	4258: "ETRS89",
+	4269: "NAD83",
+	4283: "GDA94",
+	4742: "GDM2000",
+}
+
+// SupportedCRS describes the EPSG codes Lookup accepts
+var SupportedCRS = []string{
+	"EPSG:4326 WGS 84, and EPSG:4258, 4269, 4283, 4742 (ETRS89, NAD83, GDA94, GDM2000) as WGS 84",
+	"EPSG:32601-32660 WGS 84 / UTM zones 1N-60N",
+	"EPSG:32701-32760 WGS 84 / UTM zones 1S-60S",
+	"EPSG:25828-25838 ETRS89 / UTM zones 28N-38N",
+	"EPSG:26901-26923 NAD83 / UTM zones 1N-23N",
+	"EPSG:28348-28358 GDA94 / MGA zones 48-58",
+	"EPSG:3857 WGS 84 / Pseudo-Mercator",
+}
+
+// Lookup finds a CRS by EPSG code, written as "32650", "EPSG:32650" or "urn:ogc:def:crs:EPSG::32650"
+func Lookup(code string) (*CRS, error) {
+	normalized := strings.ToUpper(strings.TrimSpace(code))
+	switch normalized {
+	case "WGS84", "WGS 84", "WGS-84":
+		normalized = "4326"
+	}
+	normalized = strings.TrimPrefix(normalized, "URN:OGC:DEF:CRS:EPSG::")
+	normalized = strings.TrimPrefix(normalized, "EPSG:")
+	normalized = strings.TrimSpace(strings.TrimPrefix(normalized, "EPSG"))
+
+	epsg, err := strconv.Atoi(normalized)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q is not an EPSG code", ErrUnsupportedCRS, code)
+	}
+
+	if name, ok := geographicCRS[epsg]; ok {
+		return &CRS{Code: epsg, Name: name, toWGS84: func(x, y float64) (float64, float64) { return x, y }}, nil
+	}
+
+	projected := func(name string, tm transverseMercator) *CRS {
+		return &CRS{Code: epsg, Name: name, Projected: true, toWGS84: tm.inverse}
+	}
+	switch {
+	case epsg == 3857:
+		return &CRS{Code: epsg, Name: "WGS 84 / Pseudo-Mercator", Projected: true, toWGS84: webMercatorInverse}, nil
+	case epsg >= 32601 && epsg <= 32660:
+		zone := epsg - 32600
+		return projected(fmt.Sprintf("WGS 84 / UTM zone %dN", zone), utm(wgs84, zone, false)), nil
+	case epsg >= 32701 && epsg <= 32760:
+		zone := epsg - 32700
+		return projected(fmt.Sprintf("WGS 84 / UTM zone %dS", zone), utm(wgs84, zone, true)), nil
+	case epsg >= 25828 && epsg <= 25838:
+		zone := epsg - 25800
+		return projected(fmt.Sprintf("ETRS89 / UTM zone %dN", zone), utm(grs80, zone, false)), nil
+	case epsg >= 26901 && epsg <= 26923:
+		zone := epsg - 26900
+		return projected(fmt.Sprintf("NAD83 / UTM zone %dN", zone), utm(grs80, zone, false)), nil
+	case epsg >= 28348 && epsg <= 28358:
+		zone := epsg - 28300
+		return projected(fmt.Sprintf("GDA94 / MGA zone %d", zone), utm(grs80, zone, true)), nil
+	}
+
+	return nil, fmt.Errorf("%w: EPSG:%d", ErrUnsupportedCRS, epsg)
+}
+
+// Position is a location converted to WGS84 and the CRS it was read in
+type Position struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	CRS       string  `json:"crs"`
+}
+
+// Resolve converts raw source coordinates to WGS84. x is the longitude or easting and y the latitude or
+// northing; when only one is given it may hold both. Without a CRS, values that parse as latitude and
+// longitude are taken as WGS84, and numbers outside the range of degrees return ErrCRSRequired.
+func Resolve(rawX, rawY, crsCode string) (*Position, error) {
+	x, y := strings.TrimSpace(rawX), strings.TrimSpace(rawY)
+	if x == "" && y != "" {
+		if lat, lon, ok := SplitPair(y); ok {
+			x, y = lon, lat
+		}
+	} else if y == "" && x != "" {
+		if lat, lon, ok := SplitPair(x); ok {
+			x, y = lon, lat
+		}
+	}
+	if x == "" || y == "" {
+		return nil, errors.New("both coordinates are required")
+	}
+
+	crs := &CRS{Code: 4326, Name: geographicCRS[4326]}
+	if strings.TrimSpace(crsCode) != "" {
+		var err error
+		if crs, err = Lookup(crsCode); err != nil {
+			return nil, err
+		}
+	}
+
+	if !crs.Projected {
+		lat, latErr := ParseAngle(y, Latitude)
+		lon, lonErr := ParseAngle(x, Longitude)
+		if latErr == nil && lonErr == nil {
+			return &Position{Latitude: lat, Longitude: lon, CRS: crs.String()}, nil
+		}
+		// Plain numbers too large for degrees are metres in some projection
+		if east, err := ParseNumber(x); err == nil {
+			if north, err := ParseNumber(y); err == nil && (math.Abs(east) > 180 || math.Abs(north) > 90) {
+				if strings.TrimSpace(crsCode) == "" {
+					return nil, ErrCRSRequired
+				}
+				return nil, fmt.Errorf("coordinates look projected but %s is geographic", crs)
+			}
+		}
+		if latErr != nil {
+			return nil, latErr
+		}
+		return nil, lonErr
+	}
+
+	east, eastErr := ParseNumber(x)
+	north, northErr := ParseNumber(y)
+	if eastErr != nil || northErr != nil {
+		// Degrees written with marks or hemispheres cannot be metres, whatever the report's CRS
+		lat, latErr := ParseAngle(y, Latitude)
+		lon, lonErr := ParseAngle(x, Longitude)
+		if latErr == nil && lonErr == nil {
+			return &Position{Latitude: lat, Longitude: lon, CRS: "EPSG:4326"}, nil
+		}
+		if eastErr != nil {
+			return nil, fmt.Errorf("easting: %w", eastErr)
+		}
+		return nil, fmt.Errorf("northing: %w", northErr)
+	}
+	lon, lat := crs.toWGS84(east, north)
+	if math.IsNaN(lon) || math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return nil, fmt.Errorf("easting %v, northing %v are outside %s", east, north, crs)
+	}
+	// Keep longitudes in [-180, 180] for zones next to the antimeridian
+	if lon > 180 {
+		lon -= 360
+	} else if lon < -180 {
+		lon += 360
+	}
+	return &Position{Latitude: lat, Longitude: lon, CRS: crs.String()}, nil
+}
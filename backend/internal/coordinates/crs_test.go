@@ -0,0 +1,147 @@
+package coordinates
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		code          string
+		wantCode      int
+		wantName      string
+		wantProjected bool
+		wantErr       error
+	}{
+		{code: "4326", wantCode: 4326, wantName: "WGS 84"},
+		{code: "WGS84", wantCode: 4326, wantName: "WGS 84"},
+		{code: "epsg:4742", wantCode: 4742, wantName: "GDM2000"},
+		{code: "EPSG 32650", wantCode: 32650, wantName: "WGS 84 / UTM zone 50N", wantProjected: true},
+		{code: "urn:ogc:def:crs:EPSG::32749", wantCode: 32749, wantName: "WGS 84 / UTM zone 49S", wantProjected: true},
+		{code: "25831", wantCode: 25831, wantName: "ETRS89 / UTM zone 31N", wantProjected: true},
+		{code: "26915", wantCode: 26915, wantName: "NAD83 / UTM zone 15N", wantProjected: true},
+		{code: "28350", wantCode: 28350, wantName: "GDA94 / MGA zone 50", wantProjected: true},
+		{code: "3857", wantCode: 3857, wantName: "WGS 84 / Pseudo-Mercator", wantProjected: true},
+		{code: "32600", wantErr: ErrUnsupportedCRS},
+		{code: "32661", wantErr: ErrUnsupportedCRS},
+		{code: "27700", wantErr: ErrUnsupportedCRS},
+		{code: "UTM 50", wantErr: ErrUnsupportedCRS},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			crs, err := Lookup(tt.code)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Lookup(%q) error = %v, want %v", tt.code, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Lookup(%q): %v", tt.code, err)
+			}
+			if crs.Code != tt.wantCode || crs.Name != tt.wantName || crs.Projected != tt.wantProjected {
+				t.Errorf("Lookup(%q) = %d %q projected=%v, want %d %q projected=%v",
+					tt.code, crs.Code, crs.Name, crs.Projected, tt.wantCode, tt.wantName, tt.wantProjected)
+			}
+		})
+	}
+}
+
+func TestLookupZones(t *testing.T) {
+	// The central meridian of every zone must come back as the easting 500000 line
+	tests := []struct {
+		code     string
+		northing float64
+		lon, lat float64
+	}{
+		{"EPSG:32601", 0, -177, 0},
+		{"EPSG:32660", 0, 177, 0},
+		{"EPSG:32750", 10000000, 117, 0},
+		{"EPSG:25838", 0, 45, 0},
+		{"EPSG:26901", 0, -177, 0},
+		{"EPSG:28358", 10000000, 165, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			crs, err := Lookup(tt.code)
+			if err != nil {
+				t.Fatalf("Lookup(%q): %v", tt.code, err)
+			}
+			lon, lat := crs.toWGS84(500000, tt.northing)
+			if math.Abs(lon-tt.lon) > 1e-12 || math.Abs(lat-tt.lat) > 1e-12 {
+				t.Errorf("%s central meridian = %v, %v, want %v, %v", tt.code, lon, lat, tt.lon, tt.lat)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	cnTowerLat := 43 + 38/60.0 + 33.24/3600
+	cnTowerLon := -(79 + 23/60.0 + 13.7/3600)
+
+	tests := []struct {
+		name       string
+		rawX, rawY string
+		crs        string
+		wantLat    float64
+		wantLon    float64
+		wantCRS    string
+		tolerance  float64
+		wantErr    string
+		wantErrIs  error
+	}{
+		{name: "decimal degrees default to WGS 84", rawX: "114.04", rawY: "4.58", wantLat: 4.58, wantLon: 114.04, wantCRS: "EPSG:4326"},
+		{name: "DMS", rawX: `79°23'13.7"W`, rawY: `43°38'33.24"N`, wantLat: cnTowerLat, wantLon: cnTowerLon, wantCRS: "EPSG:4326"},
+		{name: "pair in the y cell", rawY: `43°38'33.24"N 79°23'13.7"W`, wantLat: cnTowerLat, wantLon: cnTowerLon, wantCRS: "EPSG:4326"},
+		{name: "pair in the x cell", rawX: "4.58, 114.04", wantLat: 4.58, wantLon: 114.04, wantCRS: "EPSG:4326"},
+		{name: "geographic datum", rawX: "101.69", rawY: "3.14", crs: "EPSG:4742", wantLat: 3.14, wantLon: 101.69, wantCRS: "EPSG:4742"},
+		{name: "UTM", rawX: "630084", rawY: "4833438", crs: "EPSG:32617", wantLat: cnTowerLat, wantLon: cnTowerLon, wantCRS: "EPSG:32617", tolerance: 1e-5},
+		{name: "UTM with units and separators", rawX: "630,084.0 m", rawY: "4,833,438.0 m", crs: "32617", wantLat: cnTowerLat, wantLon: cnTowerLon, wantCRS: "EPSG:32617", tolerance: 1e-5},
+		{name: "degrees under a projected CRS", rawX: "114.04E", rawY: "4.58N", crs: "EPSG:32650", wantLat: 4.58, wantLon: 114.04, wantCRS: "EPSG:4326"},
+		// 1000 km east of 177°E on the equator is about 9° further, past the antimeridian
+		{name: "antimeridian wrap", rawX: "1500000", rawY: "0", crs: "EPSG:32660", wantLon: -174.05, wantCRS: "EPSG:32660", tolerance: 0.01},
+		{name: "missing coordinate", rawX: "114.04", wantErr: "both coordinates are required"},
+		{name: "projected without CRS", rawX: "630084", rawY: "4833438", wantErrIs: ErrCRSRequired},
+		{name: "projected under a geographic CRS", rawX: "630084", rawY: "4833438", crs: "EPSG:4326", wantErr: "EPSG:4326 is geographic"},
+		{name: "unsupported CRS", rawX: "1", rawY: "1", crs: "EPSG:27700", wantErrIs: ErrUnsupportedCRS},
+		{name: "latitude out of range", rawX: "114E", rawY: "95N", wantErr: "out of range"},
+		{name: "bad easting", rawX: "east", rawY: "4833438", crs: "EPSG:32617", wantErr: "easting"},
+		{name: "outside the projection", rawX: "500000", rawY: "10500000", crs: "EPSG:32617", wantErr: "outside EPSG:32617"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position, err := Resolve(tt.rawX, tt.rawY, tt.crs)
+			if tt.wantErr != "" || tt.wantErrIs != nil {
+				if err == nil {
+					t.Fatalf("Resolve = %+v, want an error", position)
+				}
+				if tt.wantErrIs != nil && !errors.Is(err, tt.wantErrIs) {
+					t.Errorf("error = %v, want %v", err, tt.wantErrIs)
+				}
+				if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+
+			tolerance := tt.tolerance
+			if tolerance == 0 {
+				tolerance = 1e-12
+			}
+			if math.Abs(position.Latitude-tt.wantLat) > tolerance || math.Abs(position.Longitude-tt.wantLon) > tolerance {
+				t.Errorf("Resolve = %.9f, %.9f, want %.9f, %.9f", position.Latitude, position.Longitude, tt.wantLat, tt.wantLon)
+			}
+			if position.CRS != tt.wantCRS {
+				t.Errorf("CRS = %q, want %q", position.CRS, tt.wantCRS)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package coordinates
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Axis tells ParseAngle which hemisphere letters and range apply
+type Axis int
+
+const (
+	Latitude Axis = iota
+	Longitude
+)
+
+var errEmpty = errors.New("empty value")
+
+// ParseAngle reads an angle in decimal degrees, degrees and decimal minutes, or degrees, minutes and
+// seconds, with an optional sign or hemisphere letter before or after it:
+//
+//	4.5867   -4.5867   4.5867N   4°35.2'N   4°35'12"N   N 4 35 12   4:35:12 S
+func ParseAngle(raw string, axis Axis) (float64, error) {
+	value := strings.ToUpper(strings.TrimSpace(raw))
+	if value == "" {
+		return 0, errEmpty
+	}
+	// The ordinal indicator is often typed for the degree sign, and is a letter to unicode
+	value = strings.ReplaceAll(value, "º", "°")
+
+	// Hemisphere letter, either leading or trailing
+	negative := false
+	hemisphere := rune(0)
+	if r := rune(value[0]); strings.ContainsRune("NSEW", r) {
+		hemisphere = r
+		value = strings.TrimSpace(value[1:])
+	} else if r := rune(value[len(value)-1]); strings.ContainsRune("NSEW", r) {
+		hemisphere = r
+		value = strings.TrimSpace(value[:len(value)-1])
+	}
+	if hemisphere != 0 {
+		valid := "NS"
+		if axis == Longitude {
+			valid = "EW"
+		}
+		if !strings.ContainsRune(valid, hemisphere) {
+			return 0, fmt.Errorf("hemisphere %c does not apply to %s", hemisphere, axis)
+		}
+		negative = hemisphere == 'S' || hemisphere == 'W'
+	}
+
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+") {
+		if hemisphere != 0 && value[0] == '-' {
+			return 0, fmt.Errorf("%q has both a sign and a hemisphere", raw)
+		}
+		negative = negative || value[0] == '-'
+		value = strings.TrimSpace(value[1:])
+	}
+
+	// A lone decimal comma is a decimal point
+	if !strings.Contains(value, ".") && strings.Count(value, ",") == 1 && isNumber(strings.Replace(value, ",", ".", 1)) {
+		value = strings.Replace(value, ",", ".", 1)
+	}
+
+	// Degree, minute and second marks and any spacing all separate components
+	parts := strings.FieldsFunc(value, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	if len(parts) == 0 || len(parts) > 3 || strings.IndexFunc(value, unicode.IsLetter) >= 0 {
+		return 0, fmt.Errorf("%q is not a coordinate", raw)
+	}
+
+	components := make([]float64, len(parts))
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a coordinate", raw)
+		}
+		// Only the last component may have a fraction
+		if i < len(parts)-1 && number != float64(int64(number)) {
+			return 0, fmt.Errorf("%q has a fraction before its last component", raw)
+		}
+		if i > 0 && number >= 60 {
+			return 0, fmt.Errorf("%q has minutes or seconds of 60 or more", raw)
+		}
+		components[i] = number
+	}
+
+	degrees := components[0]
+	if len(components) > 1 {
+		degrees += components[1] / 60
+	}
+	if len(components) > 2 {
+		degrees += components[2] / 3600
+	}
+	if negative {
+		degrees = -degrees
+	}
+
+	limit := 90.0
+	if axis == Longitude {
+		limit = 180
+	}
+	if degrees < -limit || degrees > limit {
+		return 0, fmt.Errorf("%s %v out of range", axis, degrees)
+	}
+	return degrees, nil
+}
+
+// ParseNumber reads a projected coordinate in metres, allowing a trailing unit and thousands separators
+func ParseNumber(raw string) (float64, error) {
+	value := strings.TrimSpace(strings.ToLower(raw))
+	value = strings.TrimSuffix(value, "m")
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, errEmpty
+	}
+	if strings.Contains(value, ".") {
+		value = strings.ReplaceAll(value, ",", "")
+	}
+	value = strings.ReplaceAll(value, " ", "")
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", raw)
+	}
+	return number, nil
+}
+
+// SplitPair splits a single cell holding both coordinates, such as "4°35'12"N 114°2'30"E" or
+// "4.58, 114.04", into latitude and longitude. Without hemisphere letters latitude comes first.
+func SplitPair(raw string) (lat, lon string, ok bool) {
+	value := strings.TrimSpace(raw)
+
+	for _, separator := range []string{";", "/", ","} {
+		parts := strings.Split(value, separator)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) != "" && strings.TrimSpace(parts[1]) != "" {
+			first, second := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+			if strings.ContainsAny(strings.ToUpper(first), "EW") && strings.ContainsAny(strings.ToUpper(second), "NS") {
+				return second, first, true
+			}
+			return first, second, true
+		}
+	}
+
+	if fields := strings.Fields(value); len(fields) == 2 && isNumber(fields[0]) && isNumber(fields[1]) {
+		return fields[0], fields[1], true
+	}
+
+	// Split at the hemisphere letter that ends (or starts) the first coordinate
+	upper := strings.ToUpper(value)
+	first := strings.IndexAny(upper, "NSEW")
+	if first < 0 {
+		return "", "", false
+	}
+	cut := first + 1
+	if strings.TrimSpace(upper[:first]) == "" {
+		// Leading letters: the second coordinate starts at the next letter
+		next := strings.IndexAny(upper[first+1:], "NSEW")
+		if next < 0 {
+			return "", "", false
+		}
+		cut = first + 1 + next
+	}
+	a, b := strings.TrimSpace(value[:cut]), strings.TrimSpace(value[cut:])
+	if a == "" || b == "" {
+		return "", "", false
+	}
+	if strings.ContainsAny(strings.ToUpper(a), "EW") {
+		return b, a, true
+	}
+	return a, b, true
+}
+
+func isNumber(value string) bool {
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
+
+func (a Axis) String() string {
+	if a == Longitude {
+		return "longitude"
+	}
+	return "latitude"
+}
@@ -0,0 +1,113 @@
+package coordinates
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseAngle(t *testing.T) {
+	tests := []struct {
+		raw     string
+		axis    Axis
+		want    float64
+		wantErr string
+	}{
+		{raw: "4.5867", axis: Latitude, want: 4.5867},
+		{raw: "-4.5867", axis: Latitude, want: -4.5867},
+		{raw: "+114.04", axis: Longitude, want: 114.04},
+		{raw: "4.5867N", axis: Latitude, want: 4.5867},
+		{raw: "4,5867", axis: Latitude, want: 4.5867},
+		{raw: "4°35.2'N", axis: Latitude, want: 4 + 35.2/60},
+		{raw: `4°35'12"N`, axis: Latitude, want: 4 + 35/60.0 + 12/3600.0},
+		{raw: `4º35'12"S`, axis: Latitude, want: -(4 + 35/60.0 + 12/3600.0)},
+		{raw: "N 4 35 12", axis: Latitude, want: 4 + 35/60.0 + 12/3600.0},
+		{raw: "4:35:12 S", axis: Latitude, want: -(4 + 35/60.0 + 12/3600.0)},
+		{raw: `114°02'30.5"E`, axis: Longitude, want: 114 + 2/60.0 + 30.5/3600},
+		{raw: "w 79 23 13.7", axis: Longitude, want: -(79 + 23/60.0 + 13.7/3600)},
+		{raw: "180", axis: Longitude, want: 180},
+		{raw: "", axis: Latitude, wantErr: "empty value"},
+		{raw: "4.5E", axis: Latitude, wantErr: "does not apply to latitude"},
+		{raw: "114N", axis: Longitude, wantErr: "does not apply to longitude"},
+		{raw: "-4.5S", axis: Latitude, wantErr: "both a sign and a hemisphere"},
+		{raw: "4.5°35'", axis: Latitude, wantErr: "fraction before its last component"},
+		{raw: "4°60'", axis: Latitude, wantErr: "60 or more"},
+		{raw: "4°35'60\"", axis: Latitude, wantErr: "60 or more"},
+		{raw: "1 2 3 4", axis: Latitude, wantErr: "not a coordinate"},
+		{raw: "abc", axis: Latitude, wantErr: "not a coordinate"},
+		{raw: "91", axis: Latitude, wantErr: "out of range"},
+		{raw: "180.5W", axis: Longitude, wantErr: "out of range"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := ParseAngle(tt.raw, tt.axis)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseAngle(%q) error = %v, want %q", tt.raw, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAngle(%q): %v", tt.raw, err)
+			}
+			if math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("ParseAngle(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    float64
+		wantErr bool
+	}{
+		{raw: "630084", want: 630084},
+		{raw: "630084.25 m", want: 630084.25},
+		{raw: "4,833,438.5", want: 4833438.5},
+		{raw: "4 833 438", want: 4833438},
+		{raw: "-1200", want: -1200},
+		{raw: "", wantErr: true},
+		{raw: "12a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := ParseNumber(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNumber(%q) error = %v, want error %v", tt.raw, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseNumber(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitPair(t *testing.T) {
+	tests := []struct {
+		raw      string
+		lat, lon string
+		ok       bool
+	}{
+		{raw: "4.58, 114.04", lat: "4.58", lon: "114.04", ok: true},
+		{raw: "4.58; 114.04", lat: "4.58", lon: "114.04", ok: true},
+		{raw: "4.58 114.04", lat: "4.58", lon: "114.04", ok: true},
+		{raw: `4°35'12"N 114°2'30"E`, lat: `4°35'12"N`, lon: `114°2'30"E`, ok: true},
+		{raw: `114°2'30"E / 4°35'12"N`, lat: `4°35'12"N`, lon: `114°2'30"E`, ok: true},
+		{raw: "N 4 35 12 E 114 2 30", lat: "N 4 35 12", lon: "E 114 2 30", ok: true},
+		{raw: "4.58", ok: false},
+		{raw: "N 4 35 12", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			lat, lon, ok := SplitPair(tt.raw)
+			if ok != tt.ok || lat != tt.lat || lon != tt.lon {
+				t.Errorf("SplitPair(%q) = %q, %q, %v, want %q, %q, %v", tt.raw, lat, lon, ok, tt.lat, tt.lon, tt.ok)
+			}
+		})
+	}
+}
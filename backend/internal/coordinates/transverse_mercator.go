@@ -0,0 +1,97 @@
+package coordinates
+
+import "math"
+
+// ellipsoid is a reference ellipsoid given by its semi-major axis in metres and flattening
+type ellipsoid struct {
+	a float64
+	f float64
+}
+
+var (
+	wgs84 = ellipsoid{a: 6378137, f: 1 / 298.257223563}
+	grs80 = ellipsoid{a: 6378137, f: 1 / 298.257222101}
+)
+
+// transverseMercator is a transverse Mercator projection such as a UTM zone
+type transverseMercator struct {
+	ellipsoid
+	lon0 float64 // central meridian, degrees
+	k0   float64 // scale factor on the central meridian
+	fe   float64 // false easting, metres
+	fn   float64 // false northing, metres
+}
+
+// utm returns the projection of a UTM zone
+func utm(e ellipsoid, zone int, south bool) transverseMercator {
+	tm := transverseMercator{ellipsoid: e, lon0: float64(zone*6 - 183), k0: 0.9996, fe: 500000}
+	if south {
+		tm.fn = 10000000
+	}
+	return tm
+}
+
+// inverse converts easting and northing to longitude and latitude in degrees on the same ellipsoid.
+// It uses the Krüger series to sixth order in n (Karney, 2011), accurate to well under a millimetre
+// within a UTM zone and to a few millimetres several thousand kilometres from the central meridian.
+func (tm transverseMercator) inverse(easting, northing float64) (lon, lat float64) {
+	n := tm.f / (2 - tm.f)
+	n2, n3 := n*n, n*n*n
+	n4, n5, n6 := n3*n, n3*n2, n3*n3
+
+	// Rectifying radius
+	A := tm.a / (1 + n) * (1 + n2/4 + n4/64 + n6/256)
+
+	beta := [6]float64{
+		n/2 - 2*n2/3 + 37*n3/96 - n4/360 - 81*n5/512 + 96199*n6/604800,
+		n2/48 + n3/15 - 437*n4/1440 + 46*n5/105 - 1118711*n6/3870720,
+		17*n3/480 - 37*n4/840 - 209*n5/4480 + 5569*n6/90720,
+		4397*n4/161280 - 11*n5/504 - 830251*n6/7257600,
+		4583*n5/161280 - 108847*n6/3991680,
+		20648693 * n6 / 638668800,
+	}
+
+	xi := (northing - tm.fn) / (tm.k0 * A)
+	eta := (easting - tm.fe) / (tm.k0 * A)
+	// Beyond the poles the series wraps around to plausible but wrong positions
+	if math.Abs(xi) > math.Pi/2 {
+		return math.NaN(), math.NaN()
+	}
+
+	xiP, etaP := xi, eta
+	for j := 1; j <= 6; j++ {
+		k := float64(2 * j)
+		xiP -= beta[j-1] * math.Sin(k*xi) * math.Cosh(k*eta)
+		etaP -= beta[j-1] * math.Cos(k*xi) * math.Sinh(k*eta)
+	}
+
+	sinhEta, cosXi := math.Sinh(etaP), math.Cos(xiP)
+	tauP := math.Sin(xiP) / math.Hypot(sinhEta, cosXi)
+	lambda := math.Atan2(sinhEta, cosXi)
+
+	// Recover the geodetic latitude from the conformal latitude by Newton's method
+	e2 := tm.f * (2 - tm.f)
+	e := math.Sqrt(e2)
+	tau := tauP
+	for i := 0; i < 10; i++ {
+		sigma := math.Sinh(e * math.Atanh(e*tau/math.Sqrt(1+tau*tau)))
+		tauI := tau*math.Sqrt(1+sigma*sigma) - sigma*math.Sqrt(1+tau*tau)
+		delta := (tauP - tauI) / math.Sqrt(1+tauI*tauI) *
+			(1 + (1-e2)*tau*tau) / ((1 - e2) * math.Sqrt(1+tau*tau))
+		tau += delta
+		if math.Abs(delta) < 1e-12 {
+			break
+		}
+	}
+
+	lat = math.Atan(tau) * 180 / math.Pi
+	lon = tm.lon0 + lambda*180/math.Pi
+	return lon, lat
+}
+
+// webMercatorInverse converts spherical (web) Mercator metres to longitude and latitude in degrees
+func webMercatorInverse(x, y float64) (lon, lat float64) {
+	lon = x / wgs84.a * 180 / math.Pi
+	lat = math.Atan(math.Sinh(y/wgs84.a)) * 180 / math.Pi
+	return lon, lat
+}
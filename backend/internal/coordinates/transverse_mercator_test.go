@@ -0,0 +1,122 @@
+package coordinates
+
+import (
+	"math"
+	"testing"
+)
+
+// forward projects longitude and latitude with the Krüger series (Karney, 2011), independently of
+// inverse, so round trips check the inverse coefficients rather than reuse them
+func (tm transverseMercator) forward(lon, lat float64) (easting, northing float64) {
+	n := tm.f / (2 - tm.f)
+	n2, n3 := n*n, n*n*n
+	n4, n5, n6 := n3*n, n3*n2, n3*n3
+	A := tm.a / (1 + n) * (1 + n2/4 + n4/64 + n6/256)
+
+	alpha := [6]float64{
+		n/2 - 2*n2/3 + 5*n3/16 + 41*n4/180 - 127*n5/288 + 7891*n6/37800,
+		13*n2/48 - 3*n3/5 + 557*n4/1440 + 281*n5/630 - 1983433*n6/1935360,
+		61*n3/240 - 103*n4/140 + 15061*n5/26880 + 167603*n6/181440,
+		49561*n4/161280 - 179*n5/168 + 6601661*n6/7257600,
+		34729*n5/80640 - 3418889*n6/1995840,
+		212378941 * n6 / 319334400,
+	}
+
+	e2 := tm.f * (2 - tm.f)
+	e := math.Sqrt(e2)
+	phi := lat * math.Pi / 180
+	lambda := (lon - tm.lon0) * math.Pi / 180
+
+	tau := math.Tan(phi)
+	sigma := math.Sinh(e * math.Atanh(e*tau/math.Sqrt(1+tau*tau)))
+	tauP := tau*math.Sqrt(1+sigma*sigma) - sigma*math.Sqrt(1+tau*tau)
+
+	xiP := math.Atan2(tauP, math.Cos(lambda))
+	etaP := math.Asinh(math.Sin(lambda) / math.Hypot(tauP, math.Cos(lambda)))
+
+	xi, eta := xiP, etaP
+	for j := 1; j <= 6; j++ {
+		k := float64(2 * j)
+		xi += alpha[j-1] * math.Sin(k*xiP) * math.Cosh(k*etaP)
+		eta += alpha[j-1] * math.Cos(k*xiP) * math.Sinh(k*etaP)
+	}
+	return tm.fe + tm.k0*A*eta, tm.fn + tm.k0*A*xi
+}
+
+func TestTransverseMercatorInverse(t *testing.T) {
+	tests := []struct {
+		name              string
+		tm                transverseMercator
+		easting, northing float64
+		lon, lat          float64
+		tolerance         float64 // degrees
+	}{
+		{"equator on the central meridian", utm(wgs84, 31, false), 500000, 0, 3, 0, 1e-12},
+		{"southern false northing", utm(wgs84, 50, true), 500000, 10000000, 117, 0, 1e-12},
+		// The WGS 84 meridian arc from the equator to 45°N is 4984944.378 m, scaled by k0 on the central meridian
+		{"45°N on the central meridian", utm(wgs84, 31, false), 500000, 4984944.378 * 0.9996, 3, 45, 1e-7},
+		// Wikipedia's UTM example: the CN Tower at 43°38′33.24″N 79°23′13.7″W is 630084 m E 4833438 m N in zone 17
+		{"CN Tower, zone 17N", utm(wgs84, 17, false), 630084, 4833438, -(79 + 23/60.0 + 13.7/3600), 43 + 38/60.0 + 33.24/3600, 1e-5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lon, lat := tt.tm.inverse(tt.easting, tt.northing)
+			if math.Abs(lon-tt.lon) > tt.tolerance || math.Abs(lat-tt.lat) > tt.tolerance {
+				t.Errorf("inverse(%v, %v) = %.9f, %.9f, want %.9f, %.9f", tt.easting, tt.northing, lon, lat, tt.lon, tt.lat)
+			}
+		})
+	}
+}
+
+func TestTransverseMercatorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		tm       transverseMercator
+		lon, lat float64
+	}{
+		{"Sarawak, WGS 84 zone 49N", utm(wgs84, 49, false), 113.9861, 4.3995},
+		{"Sabah, WGS 84 zone 50N", utm(wgs84, 50, false), 116.0735, 5.9804},
+		{"North Sea, ETRS89 zone 31N", utm(grs80, 31, false), 2.2134, 56.8871},
+		{"Gulf of Mexico, NAD83 zone 15N", utm(grs80, 15, false), -92.7761, 27.4102},
+		{"Perth, GDA94 zone 50", utm(grs80, 50, true), 115.8575, -31.9505},
+		{"zone edge", utm(wgs84, 33, false), 12, 60},
+		{"far from the central meridian", utm(wgs84, 33, false), 30, 45},
+		{"high latitude", utm(wgs84, 33, false), 16, 84},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			easting, northing := tt.tm.forward(tt.lon, tt.lat)
+			lon, lat := tt.tm.inverse(easting, northing)
+			// 1e-9 degrees is about 0.1 mm
+			if math.Abs(lon-tt.lon) > 1e-9 || math.Abs(lat-tt.lat) > 1e-9 {
+				t.Errorf("round trip of %v, %v via %.3f, %.3f = %.12f, %.12f", tt.lon, tt.lat, easting, northing, lon, lat)
+			}
+		})
+	}
+}
+
+func TestWebMercatorInverse(t *testing.T) {
+	// Half the equatorial circumference: the edges of the square web map
+	const edge = 20037508.342789244
+
+	tests := []struct {
+		name     string
+		x, y     float64
+		lon, lat float64
+	}{
+		{"origin", 0, 0, 0, 0},
+		{"north east corner", edge, edge, 180, 85.05112877980659},
+		{"south west corner", -edge, -edge, -180, -85.05112877980659},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lon, lat := webMercatorInverse(tt.x, tt.y)
+			if math.Abs(lon-tt.lon) > 1e-9 || math.Abs(lat-tt.lat) > 1e-9 {
+				t.Errorf("webMercatorInverse(%v, %v) = %v, %v, want %v, %v", tt.x, tt.y, lon, lat, tt.lon, tt.lat)
+			}
+		})
+	}
+}
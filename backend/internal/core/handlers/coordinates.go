@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"workbench/internal/coordinates"
+	"workbench/internal/core/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+type CoordinateHandler struct{}
+
+func NewCoordinateHandler() *CoordinateHandler {
+	return &CoordinateHandler{}
+}
+
+func (h *CoordinateHandler) CoordinateRoutes(g *echo.Group) {
+	coords := g.Group("/coordinates")
+	coords.POST("/convert", h.ConvertCoordinates)
+	coords.GET("/crs", h.GetSupportedCRS)
+}
+
+// ConvertCoordinates converts raw x/y values (DMS, decimal degrees or projected metres) to WGS84
+func (h *CoordinateHandler) ConvertCoordinates(c echo.Context) error {
+	var request struct {
+		X   string `json:"x"`
+		Y   string `json:"y"`
+		CRS string `json:"crs"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	position, err := coordinates.Resolve(request.X, request.Y, request.CRS)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, position)
+}
+
+// GetSupportedCRS lists the EPSG codes coordinates can be converted from
+func (h *CoordinateHandler) GetSupportedCRS(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"supported": coordinates.SupportedCRS,
+	})
+}
+
+// resolveRecordCoordinates converts the raw coordinates of a record sent without latitude and longitude.
+// Projected values without a CRS are accepted and kept raw for the well's CRS to resolve.
+func resolveRecordCoordinates(base *models.EPBEBase, meta *models.MetadataInfo) error {
+	if base.Latitude != nil || base.Longitude != nil || (meta.RawX == "" && meta.RawY == "") {
+		return nil
+	}
+	err := models.SetCoordinates(base, meta, meta.RawX, meta.RawY, meta.CoordinateCRS)
+	if errors.Is(err, coordinates.ErrCRSRequired) {
+		return nil
+	}
+	return err
+}
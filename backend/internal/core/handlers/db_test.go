@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"workbench/internal/storage"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// scriptedDB is a database answering each statement with the result of the first rule whose pattern
+// matches it, and remembering the statements it ran. Statements without a rule fail.
+type scriptedDB struct {
+	mu         sync.Mutex
+	rules      []scriptedRule
+	statements []scriptedStatement
+	// pingDelay is how long a ping takes to be answered
+	pingDelay time.Duration
+}
+
+type scriptedRule struct {
+	pattern  *regexp.Regexp
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+}
+
+type scriptedStatement struct {
+	sql  string
+	args []interface{}
+}
+
+// newScriptedDB opens a GORM connection to a scriptedDB, with the dialect of PostgreSQL
+func newScriptedDB(t *testing.T) (*gorm.DB, *scriptedDB) {
+	t.Helper()
+	script := &scriptedDB{}
+	conn := sql.OpenDB(scriptedConnector{script})
+	t.Cleanup(func() { conn.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	return db, script
+}
+
+// newScriptedHandler is a handler on a scriptedDB, storing its files in a temporary directory
+func newScriptedHandler(t *testing.T) (*ExtractionHandler, *scriptedDB) {
+	t.Helper()
+	db, script := newScriptedDB(t)
+	blobs, err := storage.NewLocal(t.TempDir(), []byte("secret"), "http://localhost")
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	return &ExtractionHandler{db: db, blobs: blobs}, script
+}
+
+// query answers the queries matching pattern with rows of the given columns
+func (s *scriptedDB) query(pattern string, columns []string, rows ...[]driver.Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, scriptedRule{pattern: regexp.MustCompile(pattern), columns: columns, rows: rows})
+}
+
+// exec answers the statements matching pattern with the number of rows affected
+func (s *scriptedDB) exec(pattern string, affected int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, scriptedRule{pattern: regexp.MustCompile(pattern), affected: affected})
+}
+
+// ran returns the statements run so far that match pattern, transaction boundaries included
+func (s *scriptedDB) ran(pattern string) []scriptedStatement {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	re := regexp.MustCompile(pattern)
+	var matched []scriptedStatement
+	for _, statement := range s.statements {
+		if re.MatchString(statement.sql) {
+			matched = append(matched, statement)
+		}
+	}
+	return matched
+}
+
+// log returns the statements run so far, one per line
+func (s *scriptedDB) log() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var lines []string
+	for _, statement := range s.statements {
+		lines = append(lines, statement.sql)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *scriptedDB) run(query string, args []driver.NamedValue) (*scriptedRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	s.statements = append(s.statements, scriptedStatement{sql: query, args: values})
+	for i := range s.rules {
+		if s.rules[i].pattern.MatchString(query) {
+			return &s.rules[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unscripted statement: %s", query)
+}
+
+type scriptedConnector struct{ db *scriptedDB }
+
+func (c scriptedConnector) Connect(context.Context) (driver.Conn, error) { return scriptedConn(c), nil }
+func (c scriptedConnector) Driver() driver.Driver                        { return scriptedDriver{} }
+
+type scriptedDriver struct{}
+
+func (scriptedDriver) Open(string) (driver.Conn, error) {
+	return nil, fmt.Errorf("open through the connector")
+}
+
+type scriptedConn struct{ db *scriptedDB }
+
+func (c scriptedConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepared statements are not scripted")
+}
+func (c scriptedConn) Close() error { return nil }
+func (c scriptedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c scriptedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.statements = append(c.db.statements, scriptedStatement{sql: "BEGIN"})
+	return scriptedTx(c), nil
+}
+
+// Ping answers after the ping delay, unless ctx is done first
+func (c scriptedConn) Ping(ctx context.Context) error {
+	c.db.mu.Lock()
+	delay := c.db.pingDelay
+	c.db.mu.Unlock()
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c scriptedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rule, err := c.db.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(rule.affected), nil
+}
+
+func (c scriptedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rule, err := c.db.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &scriptedRows{columns: rule.columns, rows: rule.rows}, nil
+}
+
+// CheckNamedValue converts arguments as database/sql does, passing through those it cannot convert,
+// such as slices
+func (c scriptedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if converted, err := driver.DefaultParameterConverter.ConvertValue(value.Value); err == nil {
+		value.Value = converted
+	}
+	return nil
+}
+
+type scriptedTx struct{ db *scriptedDB }
+
+func (t scriptedTx) Commit() error   { return t.end("COMMIT") }
+func (t scriptedTx) Rollback() error { return t.end("ROLLBACK") }
+
+func (t scriptedTx) end(statement string) error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	t.db.statements = append(t.db.statements, scriptedStatement{sql: statement})
+	return nil
+}
+
+type scriptedRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *scriptedRows) Columns() []string { return r.columns }
+func (r *scriptedRows) Close() error      { return nil }
+
+func (r *scriptedRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
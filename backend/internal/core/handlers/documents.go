@@ -0,0 +1,473 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"workbench/internal/core/models"
+	"workbench/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// versionAttempts bounds the tries to record an extraction under a free version number
+	versionAttempts = 3
+	// uniqueViolation is PostgreSQL's error code for a duplicate key
+	uniqueViolation = "23505"
+)
+
+// findDocument loads a document by its ID. Files are only ever located through a document, so a
+// request cannot name a path outside the input directory.
+func (h *ExtractionHandler) findDocument(id string) (*models.Document, error) {
+	documentID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if h.db == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var document models.Document
+	if err := h.db.First(&document, "id = ?", documentID).Error; err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+// documentLookupError answers a failed findDocument
+func documentLookupError(c echo.Context, err error) error {
+	if err == gorm.ErrRecordNotFound {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Document not found",
+		})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{
+		"error": "Failed to retrieve document",
+	})
+}
+
+// documentKey returns the storage key of the document's PDF
+func documentKey(document *models.Document) string {
+	return storage.DocumentKey(document.StoredName())
+}
+
+// findDocumentByHash loads the document with the given content hash. It returns nil when there is none.
+func (h *ExtractionHandler) findDocumentByHash(ctx context.Context, sha256 string) (*models.Document, error) {
+	if h.db == nil {
+		return nil, nil
+	}
+
+	var document models.Document
+	err := h.db.WithContext(ctx).First(&document, "sha256 = ?", sha256).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+// storeDocument stores an uploaded PDF under its content hash and records it. When a document with the
+// same content exists, that one is returned with the upload counted, and created is false.
+func (h *ExtractionHandler) storeDocument(ctx context.Context, content io.Reader, document models.Document) (*models.Document, bool, error) {
+	existing, err := h.findDocumentByHash(ctx, document.SHA256)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		if err := h.db.WithContext(ctx).Model(existing).UpdateColumn("uploads", gorm.Expr("uploads + 1")).Error; err != nil {
+			return nil, false, err
+		}
+		existing.Uploads++
+		slog.InfoContext(ctx, "♻️ PDF uploaded before", "document_id", existing.ID, "uploads", existing.Uploads)
+
+		// An object removed from storage is restored by the new upload
+		if _, err := h.blobs.Stat(ctx, documentKey(existing)); errors.Is(err, storage.ErrNotFound) {
+			if err := h.blobs.Put(ctx, documentKey(existing), content, existing.Size, "application/pdf"); err != nil {
+				return nil, false, err
+			}
+		}
+		return existing, false, nil
+	}
+
+	if err := h.blobs.Put(ctx, documentKey(&document), content, document.Size, "application/pdf"); err != nil {
+		return nil, false, err
+	}
+	if h.db != nil {
+		if err := h.db.WithContext(ctx).Create(&document).Error; err != nil {
+			// The same file may have been uploaded at the same time
+			if existing, _ := h.findDocumentByHash(ctx, document.SHA256); existing != nil {
+				return existing, false, nil
+			}
+			h.blobs.Delete(ctx, documentKey(&document))
+			return nil, false, err
+		}
+	}
+
+	slog.InfoContext(ctx, "💾 Stored document", "document_id", document.ID, "sha256", document.SHA256)
+	return &document, true, nil
+}
+
+// previousExtraction builds the response to a repeated upload from the document's latest completed
+// extraction. It returns nil when no extraction of the document completed.
+func (h *ExtractionHandler) previousExtraction(ctx context.Context, document *models.Document) (map[string]interface{}, error) {
+	if h.db == nil {
+		return nil, nil
+	}
+
+	var job models.ExtractionJob
+	err := h.db.WithContext(ctx).
+		Where("document_id = ? AND status = ?", document.ID, models.ExtractionStatusCompleted).
+		Order("finished_at DESC").
+		First(&job).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The cached outcome expires; the extractor's JSON file stays
+	var results map[string]interface{}
+	if outcome := h.extractionOutcome(ctx, job.ID); outcome != nil && outcome.Error == "" {
+		results = outcome.Results
+	} else {
+		jsonFile, err := h.readExtractedJSON(ctx, extractionOutputKey(job.Filename, job.Version, "_extracted.json"))
+		if err != nil {
+			return nil, err
+		}
+		results = map[string]interface{}{"json_files": nil, "files_count": 0}
+		if jsonFile != nil {
+			results["json_files"] = []map[string]interface{}{jsonFile}
+			results["files_count"] = 1
+		}
+	}
+
+	imported, err := h.importedRecords(ctx, document.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"message":           "PDF was uploaded before; returning its earlier extraction. Upload it with reextract=true to extract it again",
+		"duplicate":         true,
+		"job_id":            job.ID,
+		"results":           results,
+		"document_id":       document.ID,
+		"original_filename": document.OriginalFilename,
+		"pages":             document.Pages,
+		"uploads":           document.Uploads,
+		"imported_records":  imported,
+		"processed_at":      job.FinishedAt,
+	}, nil
+}
+
+// documentRecords counts the records saved from a document, by table
+type documentRecords struct {
+	Carbonate int64 `json:"petrography_carbonate"`
+	Clastic   int64 `json:"petrography_clastic"`
+	Total     int64 `json:"total"`
+}
+
+// importedRecords counts the records saved from a document, soft-deleted ones excluded
+func (h *ExtractionHandler) importedRecords(ctx context.Context, documentID uuid.UUID) (*documentRecords, error) {
+	if h.db == nil {
+		return &documentRecords{}, nil
+	}
+	return countRecords(h.db.WithContext(ctx), documentID)
+}
+
+// countRecords counts the records saved from a document through db, which may be a transaction
+func countRecords(db *gorm.DB, documentID uuid.UUID) (*documentRecords, error) {
+	var imported documentRecords
+	if err := db.Model(&models.EPBEPetrographyCarbonate{}).Where("document_id = ?", documentID).Count(&imported.Carbonate).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.EPBEPetrographyClastic{}).Where("document_id = ?", documentID).Count(&imported.Clastic).Error; err != nil {
+		return nil, err
+	}
+	imported.Total = imported.Carbonate + imported.Clastic
+	return &imported, nil
+}
+
+// saveVersionedExtractionJob records a new extraction of a document under the document's next version
+// number. The document row stays locked until the job is recorded, so concurrent extractions of a
+// document get distinct versions and never write over each other's output; a conflict with the
+// unique version index is retried. Without a database the job is not recorded.
+func (h *ExtractionHandler) saveVersionedExtractionJob(ctx context.Context, job *models.ExtractionJob) error {
+	if h.db == nil {
+		job.Version = 1
+		return nil
+	}
+
+	var err error
+	for attempt := 1; attempt <= versionAttempts; attempt++ {
+		err = h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var document models.Document
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&document, "id = ?", job.DocumentID).Error; err != nil {
+				return err
+			}
+			var latest int
+			if err := tx.Model(&models.ExtractionJob{}).Where("document_id = ?", document.ID).
+				Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+				return err
+			}
+			job.Version = latest + 1
+			return tx.Create(job).Error
+		})
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) || pgErr.Code != uniqueViolation {
+			break
+		}
+		slog.WarnContext(ctx, "⚠️ Extraction version taken, retrying", "version", job.Version, "attempt", attempt)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to record extraction job", "error", err)
+	}
+	return err
+}
+
+// extractionVersions returns the versions of a document's extractions, whose outputs are stored
+func extractionVersions(db *gorm.DB, documentID uuid.UUID) ([]int, error) {
+	versions := []int{}
+	// Version 0 marks extractions from before versions, whose output a later one wrote over
+	err := db.Model(&models.ExtractionJob{}).
+		Where("document_id = ? AND version > 0", documentID).
+		Distinct().Order("version").Pluck("version", &versions).Error
+	return versions, err
+}
+
+// cleanUploader reduces the uploader name given with an upload to printable characters, at most 255
+// bytes long
+func cleanUploader(name string) string {
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// documentUsage is the disk space taken by a document
+type documentUsage struct {
+	DocumentID       uuid.UUID `json:"document_id"`
+	OriginalFilename string    `json:"original_filename"`
+	SHA256           string    `json:"sha256"`
+	Uploads          int       `json:"uploads"`
+	PDFBytes         int64     `json:"pdf_bytes"`
+	// OutputBytes is taken by the extractor's JSON and Markdown output, every version included
+	OutputBytes int64 `json:"output_bytes"`
+	// PageBytes is taken by the single pages and images rendered for the viewer
+	PageBytes  int64 `json:"page_bytes"`
+	TotalBytes int64 `json:"total_bytes"`
+	// SavedBytes is the space the repeated uploads would have taken without deduplication
+	SavedBytes int64 `json:"saved_bytes"`
+}
+
+// documentUsage measures the stored objects of a document
+func (h *ExtractionHandler) documentUsage(ctx context.Context, document *models.Document) documentUsage {
+	usage := documentUsage{
+		DocumentID:       document.ID,
+		OriginalFilename: document.OriginalFilename,
+		SHA256:           document.SHA256,
+		Uploads:          document.Uploads,
+	}
+	if object, err := h.blobs.Stat(ctx, documentKey(document)); err == nil {
+		usage.PDFBytes = object.Size
+	}
+	var versions []int
+	if h.db != nil {
+		versions, _ = extractionVersions(h.db.WithContext(ctx), document.ID)
+	}
+	if len(versions) == 0 {
+		versions = []int{1}
+	}
+	for _, version := range versions {
+		for _, suffix := range []string{"_extracted.json", "_extracted.md"} {
+			if object, err := h.blobs.Stat(ctx, extractionOutputKey(document.StoredName(), version, suffix)); err == nil {
+				usage.OutputBytes += object.Size
+			}
+		}
+	}
+	if pages, err := h.blobs.List(ctx, storage.PageKey(document.ID.String(), "")); err == nil {
+		for _, page := range pages {
+			usage.PageBytes += page.Size
+		}
+	}
+	usage.TotalBytes = usage.PDFBytes + usage.OutputBytes + usage.PageBytes
+	if document.Uploads > 1 {
+		usage.SavedBytes = int64(document.Uploads-1) * document.Size
+	}
+	return usage
+}
+
+// GetStorageUsage reports the disk space of the stored documents, largest first, with totals over all
+// documents
+func (h *ExtractionHandler) GetStorageUsage(c echo.Context) error {
+	ctx := c.Request().Context()
+	pagination := parsePagination(c)
+
+	var totals struct {
+		Documents  int64
+		PDFBytes   int64
+		SavedBytes int64
+	}
+	if err := h.db.WithContext(ctx).Model(&models.Document{}).
+		Select("COUNT(*) AS documents, COALESCE(SUM(size), 0) AS pdf_bytes, COALESCE(SUM((uploads - 1) * size), 0) AS saved_bytes").
+		Scan(&totals).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to compute storage usage",
+		})
+	}
+
+	var documents []models.Document
+	if err := h.db.WithContext(ctx).Order("size DESC, id").
+		Limit(pagination.GetLimit()).Offset(pagination.GetOffset()).
+		Find(&documents).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to compute storage usage",
+		})
+	}
+
+	usage := make([]documentUsage, 0, len(documents))
+	for i := range documents {
+		usage = append(usage, h.documentUsage(ctx, &documents[i]))
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"documents":   usage,
+		"pdf_bytes":   totals.PDFBytes,
+		"saved_bytes": totals.SavedBytes,
+		"pagination": map[string]interface{}{
+			"page":  pagination.GetPage(),
+			"limit": pagination.GetLimit(),
+			"total": totals.Documents,
+		},
+	})
+}
+
+// GetDocumentStorage reports the disk space of one document
+func (h *ExtractionHandler) GetDocumentStorage(c echo.Context) error {
+	document, err := h.findDocument(c.Param("id"))
+	if err != nil {
+		return documentLookupError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, h.documentUsage(c.Request().Context(), document))
+}
+
+// discardDocument removes a document that could not be queued, with its file
+func (h *ExtractionHandler) discardDocument(ctx context.Context, document *models.Document) {
+	if err := h.blobs.Delete(ctx, documentKey(document)); err != nil {
+		slog.WarnContext(ctx, "⚠️ Failed to remove stored PDF", "document_id", document.ID, "error", err)
+	}
+	if h.db == nil {
+		return
+	}
+	if err := h.db.WithContext(ctx).Delete(document).Error; err != nil {
+		slog.WarnContext(ctx, "⚠️ Failed to remove document", "document_id", document.ID, "error", err)
+	}
+}
+
+// documentDisposition returns the Content-Disposition of a document's PDF, shown inline under its
+// original name
+func documentDisposition(document *models.Document) string {
+	disposition := mime.FormatMediaType("inline", map[string]string{"filename": document.OriginalFilename})
+	if disposition == "" {
+		return "inline"
+	}
+	return disposition
+}
+
+// immutableCache lets browsers keep a file without asking again: a document's PDF never changes under
+// its ID, nor a page under its rendering settings
+const immutableCache = "private, max-age=31536000, immutable"
+
+// serveObject streams a stored object. http.ServeContent answers range requests, which PDF viewers use
+// to load large files in parts, and conditional requests against etag and the modification time.
+func serveObject(c echo.Context, reader storage.Reader, object *storage.Object, name, etag, cacheControl string) {
+	c.Response().Header().Set("ETag", etag)
+	c.Response().Header().Set("Cache-Control", cacheControl)
+	http.ServeContent(c.Response(), c.Request(), name, object.ModTime, reader)
+}
+
+// objectETag returns an ETag that changes whenever the object is written again
+func objectETag(object *storage.Object) string {
+	return fmt.Sprintf(`"%x-%x"`, object.ModTime.UnixNano(), object.Size)
+}
+
+// documentETag returns the ETag of a document's PDF: its content hash, or for documents stored before
+// files were content-addressed, the stored object's
+func documentETag(document *models.Document, object *storage.Object) string {
+	if document.SHA256 != "" {
+		return `"` + document.SHA256 + `"`
+	}
+	return objectETag(object)
+}
+
+// serveDocument streams the document's PDF from storage
+func (h *ExtractionHandler) serveDocument(c echo.Context, document *models.Document) error {
+	ctx := c.Request().Context()
+	reader, object, err := h.blobs.Get(ctx, documentKey(document))
+	if errors.Is(err, storage.ErrNotFound) {
+		slog.WarnContext(ctx, "❌ PDF file not found", "document_id", document.ID, "key", documentKey(document))
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "PDF file not found",
+		})
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to read stored PDF", "document_id", document.ID, "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to read PDF",
+		})
+	}
+	defer reader.Close()
+
+	c.Response().Header().Set("Content-Type", "application/pdf")
+	c.Response().Header().Set("Content-Disposition", documentDisposition(document))
+	serveObject(c, reader, object, document.StoredName(), documentETag(document, object), immutableCache)
+	return nil
+}
+
+// GetDocumentURL returns a short-lived signed URL of the document's PDF, which the viewer can load
+// straight from storage
+func (h *ExtractionHandler) GetDocumentURL(c echo.Context) error {
+	document, err := h.findDocument(c.Param("id"))
+	if err != nil {
+		return documentLookupError(c, err)
+	}
+
+	expiresAt := time.Now().Add(h.storage.URLTTL)
+	signed, err := h.blobs.SignedURL(c.Request().Context(), documentKey(document), h.storage.URLTTL, documentDisposition(document))
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "❌ Failed to sign URL", "document_id", document.ID, "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to sign URL",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"url":        signed,
+		"expires_at": expiresAt.Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"workbench/internal/core/models"
+	"workbench/internal/database"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Supported export formats and unit systems
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatXLSX = "xlsx"
+
+	UnitsBoth     = "both"
+	UnitsMetric   = "metric"
+	UnitsImperial = "imperial"
+)
+
+// MIMEApplicationXLSX is the content type of Excel workbooks
+const MIMEApplicationXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// exportRequest describes an export after its parameters have been validated
+type exportRequest struct {
+	Table     string
+	Format    string
+	Units     string
+	Search    string
+	ListQuery *database.ListQuery
+	Columns   []string
+}
+
+// parseExportRequest validates the export format and units and resolves the output columns
+func parseExportRequest(c echo.Context, db *gorm.DB, model interface{}, table string) (*exportRequest, error) {
+	listQuery, err := parseListQuery(c, db, model)
+	if err != nil {
+		return nil, err
+	}
+
+	format := strings.ToLower(c.QueryParam("format"))
+	if format == "" {
+		format = ExportFormatCSV
+	}
+	if format != ExportFormatCSV && format != ExportFormatXLSX {
+		return nil, fmt.Errorf("unsupported export format %q, expected csv or xlsx", format)
+	}
+
+	units := strings.ToLower(c.QueryParam("units"))
+	if units == "" {
+		units = UnitsBoth
+	}
+	if units != UnitsBoth && units != UnitsMetric && units != UnitsImperial {
+		return nil, fmt.Errorf("unsupported units %q, expected metric, imperial or both", units)
+	}
+
+	columns, err := exportColumns(db, model, listQuery.Fields, units)
+	if err != nil {
+		return nil, err
+	}
+
+	return &exportRequest{
+		Table:     table,
+		Format:    format,
+		Units:     units,
+		Search:    c.QueryParam("q"),
+		ListQuery: listQuery,
+		Columns:   columns,
+	}, nil
+}
+
+// exportColumns returns the model's ePBE template columns in template order (the struct field order),
+// restricted to the requested fieldset and unit system. Internal columns such as well_id or raw_x,
+// which the template does not know, are left out.
+func exportColumns(db *gorm.DB, model interface{}, fields []string, units string) ([]string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, fmt.Errorf("failed to parse model schema: %w", err)
+	}
+
+	dropped := map[string]bool{}
+	for _, pair := range models.EPBEUnitPairs {
+		switch units {
+		case UnitsMetric:
+			dropped[pair.Imperial] = true
+		case UnitsImperial:
+			dropped[pair.Metric] = true
+		}
+	}
+
+	wanted := map[string]bool{}
+	for _, field := range fields {
+		wanted[field] = true
+		// Asking for a length in one unit system implies its counterpart in the other
+		for _, pair := range models.EPBEUnitPairs {
+			if field == pair.Metric || field == pair.Imperial {
+				wanted[pair.Metric] = true
+				wanted[pair.Imperial] = true
+			}
+		}
+	}
+
+	columns := make([]string, 0, len(stmt.Schema.DBNames))
+	for _, name := range stmt.Schema.DBNames {
+		if _, known := models.EPBEHeaders[name]; !known || dropped[name] || (len(fields) > 0 && !wanted[name]) {
+			continue
+		}
+		columns = append(columns, name)
+	}
+	return columns, nil
+}
+
+// exportRecords writes every record matching query as CSV or XLSX
+func exportRecords[T any](c echo.Context, db *gorm.DB, query *gorm.DB, req *exportRequest) error {
+	var model T
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&model); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to prepare export",
+		})
+	}
+
+	exportedAt := time.Now().UTC()
+	filename := fmt.Sprintf("%s_%s.%s", req.Table, exportedAt.Format("20060102_150405"), req.Format)
+
+	headers := make([]string, 0, len(req.Columns))
+	for _, column := range req.Columns {
+		headers = append(headers, models.EPBEHeader(column))
+	}
+
+	res := c.Response()
+	res.Header().Set("X-Export-Generated-At", exportedAt.Format(time.RFC3339))
+
+	if req.Format == ExportFormatCSV {
+		res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+		res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+		res.WriteHeader(http.StatusOK)
+
+		writer := csv.NewWriter(res)
+		writer.Write(headers)
+
+		count := 0
+		err := scanBatches[T](c, db, query, req.ListQuery, func(batch []T) error {
+			for i := range batch {
+				values := exportRowValues(stmt.Schema, &batch[i], req.Columns, req.Units)
+				record := make([]string, len(values))
+				for j, value := range values {
+					record[j] = formatExportValue(value)
+				}
+				if err := writer.Write(record); err != nil {
+					return err
+				}
+				count++
+			}
+			writer.Flush()
+			return writer.Error()
+		})
+		writer.Flush()
+		if err != nil {
+			slog.ErrorContext(c.Request().Context(), "❌ CSV export aborted", "table", req.Table, "rows", count, "error", err)
+		}
+		return nil
+	}
+
+	file := excelize.NewFile()
+	defer file.Close()
+
+	dataSheet := "Data"
+	file.SetSheetName("Sheet1", dataSheet)
+	sw, err := file.NewStreamWriter(dataSheet)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to prepare export",
+		})
+	}
+
+	headerRow := make([]interface{}, len(headers))
+	for i, header := range headers {
+		headerRow[i] = header
+	}
+	sw.SetRow("A1", headerRow)
+
+	count := 0
+	err = scanBatches[T](c, db, query, req.ListQuery, func(batch []T) error {
+		for i := range batch {
+			values := exportRowValues(stmt.Schema, &batch[i], req.Columns, req.Units)
+			row := make([]interface{}, len(values))
+			for j, value := range values {
+				switch v := value.(type) {
+				case time.Time:
+					row[j] = v.Format(time.RFC3339)
+				case string:
+					row[j] = escapeFormula(v)
+				default:
+					row[j] = value
+				}
+			}
+			cell, _ := excelize.CoordinatesToCellName(1, count+2)
+			if err := sw.SetRow(cell, row); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "❌ XLSX export failed", "table", req.Table, "rows", count, "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to export records",
+		})
+	}
+	if err := sw.Flush(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to export records",
+		})
+	}
+
+	// Metadata sheet describing where the data came from
+	metaSheet := "Metadata"
+	file.NewSheet(metaSheet)
+	metadata := [][]interface{}{
+		{"Property", "Value"},
+		{"Table", req.Table},
+		{"Exported At", exportedAt.Format(time.RFC3339)},
+		{"Record Count", count},
+		{"Units", req.Units},
+		{"Filter", escapeFormula(strings.Join(c.QueryParams()["filter"], " AND "))},
+		{"Search", escapeFormula(req.Search)},
+		{"Sort", escapeFormula(c.QueryParam("sort"))},
+		{"Fields", escapeFormula(c.QueryParam("fields"))},
+		{"Column Order", "ePBE template"},
+	}
+	for i, row := range metadata {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		file.SetSheetRow(metaSheet, cell, &row)
+	}
+
+	res.Header().Set(echo.HeaderContentType, MIMEApplicationXLSX)
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	res.WriteHeader(http.StatusOK)
+	if err := file.Write(res); err != nil {
+		slog.ErrorContext(c.Request().Context(), "❌ Failed to write XLSX export", "table", req.Table, "error", err)
+	}
+	return nil
+}
+
+// exportRowValues reads the export columns of a record, filling the selected unit system
+// from the other one when only that was recorded
+func exportRowValues(s *schema.Schema, record interface{}, columns []string, units string) []interface{} {
+	rv := reflect.Indirect(reflect.ValueOf(record))
+
+	read := func(column string) interface{} {
+		field := s.LookUpField(column)
+		if field == nil {
+			return nil
+		}
+		value, _ := field.ValueOf(context.Background(), rv)
+		return derefValue(value)
+	}
+
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		values[i] = read(column)
+	}
+
+	if units == UnitsBoth {
+		return values
+	}
+
+	for i, column := range columns {
+		if values[i] != nil {
+			continue
+		}
+		for _, pair := range models.EPBEUnitPairs {
+			switch {
+			case units == UnitsMetric && column == pair.Metric:
+				if ft, ok := read(pair.Imperial).(float64); ok {
+					values[i] = math.Round(ft*models.MetresPerFoot*100) / 100
+				}
+			case units == UnitsImperial && column == pair.Imperial:
+				if m, ok := read(pair.Metric).(float64); ok {
+					values[i] = math.Round(m/models.MetresPerFoot*100) / 100
+				}
+			}
+		}
+	}
+	return values
+}
+
+// derefValue dereferences pointers so nil pointers become nil interfaces
+func derefValue(value interface{}) interface{} {
+	rv := reflect.ValueOf(value)
+	for rv.IsValid() && rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	return rv.Interface()
+}
+
+// formatExportValue renders a value for a CSV cell
+func formatExportValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case string:
+		return escapeFormula(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formulaPrefixes start cells that spreadsheet programs evaluate as formulas
+const formulaPrefixes = "=+-@"
+
+// escapeFormula prefixes text that a spreadsheet would run as a formula with a quote, so values taken
+// from PDFs and uploads are shown as text. The import removes the quote again.
+func escapeFormula(text string) string {
+	if text != "" && strings.ContainsRune(formulaPrefixes, rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// unescapeFormula undoes escapeFormula
+func unescapeFormula(text string) string {
+	if len(text) > 1 && text[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(text[1])) {
+		return text[1:]
+	}
+	return text
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xuri/excelize/v2"
+)
+
+// exportCarbonate runs a carbonate export with the query parameters on two scripted records, one
+// measured in metres and one in feet only
+func exportCarbonate(t *testing.T, params url.Values) (*httptest.ResponseRecorder, *scriptedDB) {
+	t.Helper()
+	db, script := newScriptedDB(t)
+	script.query(`^SELECT \* FROM "petrography_carbonate"`,
+		[]string{"id", "well_name_field_name", "basin", "top_depth_mmddf", "top_depth_ftmddf", "remark"},
+		[]driver.Value{int64(1), "A-1", "Sarawak", 100.0, nil, "=1+1"},
+		[]driver.Value{int64(2), "A-1", "Sarawak", nil, 328.084, "plug"})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/petrography-carbonate/export?"+params.Encode(), nil)
+	rec := httptest.NewRecorder()
+	if err := NewPetrographyCarbonateHandler(db).ExportPetrographyCarbonateRecords(echo.New().NewContext(req, rec)); err != nil {
+		t.Fatalf("ExportPetrographyCarbonateRecords: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	return rec, script
+}
+
+func TestExportPetrographyRecords(t *testing.T) {
+	csvParams := url.Values{
+		"filter": {"basin.eq.Sarawak"},
+		// Asked out of order, the fields come out in template order
+		"fields": {"remark,top_depth_mmddf,well_name_field_name"},
+		"units":  {"metric"},
+	}
+	// The ID always comes with the fieldset
+	wantRows := [][]string{
+		{"Well Name/Field Name", "Top Depth (m MDDF)", "Remark", "ID"},
+		// Formulas are quoted, and depths recorded in feet only are converted
+		{"A-1", "100", "'=1+1", "1"},
+		{"A-1", "100", "plug", "2"},
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		rec, script := exportCarbonate(t, csvParams)
+		if got := rec.Header().Get(echo.HeaderContentType); !strings.HasPrefix(got, "text/csv") {
+			t.Errorf("Content-Type = %q", got)
+		}
+		var want []string
+		for _, row := range wantRows {
+			want = append(want, strings.Join(row, ","))
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != strings.Join(want, "\n") {
+			t.Errorf("CSV =\n%s\nwant\n%s", got, strings.Join(want, "\n"))
+		}
+
+		// The list filter narrows the exported records
+		selects := script.ran(`^SELECT \* FROM "petrography_carbonate"`)
+		if len(selects) != 1 || !strings.Contains(selects[0].sql, `"basin" = $1`) || selects[0].args[0] != "Sarawak" {
+			t.Errorf("export query = %v, want the basin filter", selects)
+		}
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		params := url.Values{"format": {"xlsx"}}
+		for _, key := range []string{"filter", "fields", "units"} {
+			params[key] = csvParams[key]
+		}
+		rec, _ := exportCarbonate(t, params)
+		if got := rec.Header().Get(echo.HeaderContentType); got != MIMEApplicationXLSX {
+			t.Errorf("Content-Type = %q", got)
+		}
+
+		file, err := excelize.OpenReader(rec.Body)
+		if err != nil {
+			t.Fatalf("OpenReader: %v", err)
+		}
+		defer file.Close()
+		rows, err := file.GetRows("Data")
+		if err != nil {
+			t.Fatalf("GetRows: %v", err)
+		}
+		if len(rows) != len(wantRows) {
+			t.Fatalf("data rows = %v, want %v", rows, wantRows)
+		}
+		for i := range wantRows {
+			if strings.Join(rows[i], "|") != strings.Join(wantRows[i], "|") {
+				t.Errorf("data row %d = %v, want %v", i+1, rows[i], wantRows[i])
+			}
+		}
+
+		metadata, err := file.GetRows("Metadata")
+		if err != nil {
+			t.Fatalf("GetRows: %v", err)
+		}
+		properties := map[string]string{}
+		for _, row := range metadata {
+			if len(row) == 2 {
+				properties[row[0]] = row[1]
+			}
+		}
+		for property, want := range map[string]string{
+			"Table": "petrography_carbonate", "Record Count": "2", "Units": "metric", "Filter": "basin.eq.Sarawak",
+		} {
+			if properties[property] != want {
+				t.Errorf("metadata %s = %q, want %q", property, properties[property], want)
+			}
+		}
+	})
+}
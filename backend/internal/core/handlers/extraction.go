@@ -2,80 +2,178 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"workbench/internal/cache"
+	"workbench/internal/config"
+	"workbench/internal/coordinates"
 	"workbench/internal/core/models"
-
+	"workbench/internal/database"
+	"workbench/internal/logging"
+	"workbench/internal/metrics"
+	"workbench/internal/pdfinfo"
+	"workbench/internal/queue"
+	"workbench/internal/storage"
+
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 )
 
 type ExtractionHandler struct {
-	db *gorm.DB
+	db         *gorm.DB
+	extraction config.ExtractionConfig
+	upload     config.UploadConfig
+	storage    config.StorageConfig
+	// blobs keeps the uploaded PDFs and the extractor's output
+	blobs storage.Storage
+	// rendering runs one page script per page and format, however many requests ask for it
+	rendering singleflight.Group
+	// queue carries extraction jobs to the workers of any replica, which leave the outcome in results
+	queue   queue.Queue
+	results cache.Cache
+	// finished holds, by job ID, a channel closed when a job of a local request has its outcome stored
+	finished sync.Map
+	// consuming is cancelled when the workers should stop taking jobs; workers counts them
+	consuming     context.Context
+	stopConsuming context.CancelFunc
+	workers       sync.WaitGroup
+	// stopWatching ends the heartbeats of a shared queue once the workers are done
+	stopWatching context.CancelFunc
+
+	// jobs is cancelled when a shutdown runs out of time, killing the extractor processes
+	jobs       context.Context
+	cancelJobs context.CancelFunc
+	// draining is closed when a shutdown starts; active counts running extractions and imports
+	mu       sync.Mutex
+	draining chan struct{}
+	active   sync.WaitGroup
 }
 
-func NewExtractionHandler(db *gorm.DB) *ExtractionHandler {
-	return &ExtractionHandler{db: db}
+// NewExtractionHandler starts extraction.Workers workers taking jobs from jobQueue. With a shared
+// queue, cache and storage, a PDF uploaded to any replica can be extracted by any other.
+func NewExtractionHandler(db *gorm.DB, jobQueue queue.Queue, results cache.Cache, blobs storage.Storage, extraction config.ExtractionConfig, upload config.UploadConfig, storageConfig config.StorageConfig) *ExtractionHandler {
+	jobs, cancelJobs := context.WithCancel(context.Background())
+	consuming, stopConsuming := context.WithCancel(context.Background())
+	h := &ExtractionHandler{
+		db:            db,
+		extraction:    extraction,
+		upload:        upload,
+		storage:       storageConfig,
+		blobs:         blobs,
+		queue:         jobQueue,
+		results:       results,
+		consuming:     consuming,
+		stopConsuming: stopConsuming,
+		jobs:          jobs,
+		cancelJobs:    cancelJobs,
+		draining:      make(chan struct{}),
+	}
+	for i := 0; i < extraction.Workers; i++ {
+		h.workers.Add(1)
+		go h.work()
+	}
+	watching, stopWatching := context.WithCancel(context.Background())
+	h.stopWatching = stopWatching
+	if jobQueue.Shared() {
+		go h.watchConsumers(watching)
+	}
+	return h
 }
 
 func (h *ExtractionHandler) ExtractionRoutes(g *echo.Group) {
 	extraction := g.Group("/extraction")
 	extraction.POST("/process-pdf", h.ProcessPDF)
 	extraction.POST("/save-to-db", h.SaveToDatabase)
+	extraction.POST("/import", h.ImportSpreadsheet)
+	extraction.GET("/import/:id", h.GetImportJob)
+	extraction.POST("/import/:id/resume", h.ResumeImport)
 	extraction.GET("/status/:id", h.GetExtractionStatus)
 	extraction.GET("/debug", h.DebugFiles)
 	extraction.GET("/latest-json", h.GetLatestJson)
-	extraction.GET("/pdf/:filename", h.ServePDF)
-	extraction.GET("/pdf/:filename/page/:page", h.ServePDFPage)
+	extraction.GET("/storage", h.GetStorageUsage)
+	extraction.GET("/documents", h.ListDocuments)
+	extraction.GET("/documents/:id", h.GetDocument)
+	extraction.DELETE("/documents/:id", h.DeleteDocument)
+	extraction.POST("/documents/:id/reextract", h.ReextractDocument)
+	extraction.GET("/documents/:id/extractions/:version", h.GetExtractionVersion)
+	extraction.GET("/documents/:id/storage", h.GetDocumentStorage)
+	extraction.GET("/pdf/:id", h.ServePDF)
+	extraction.GET("/pdf/:id/url", h.GetDocumentURL)
+	extraction.GET("/pdf/:id/page/:page", h.ServePDFPage)
+	extraction.GET("/pdf/:id/page/:page/thumbnail", h.GetPageThumbnail)
+	// PDF viewers ask for a file's size before loading it in ranges
+	extraction.HEAD("/pdf/:id", h.ServePDF)
+	extraction.HEAD("/pdf/:id/page/:page", h.ServePDFPage)
+}
+
+// uploadOverhead is the room left above a file size limit for the multipart headers and other fields
+const uploadOverhead = 1 << 20
+
+// errUploadTooLarge is returned by uploadedFile for a file over its size limit
+var errUploadTooLarge = errors.New("upload too large")
+
+// uploadedFile returns the named file of a multipart upload of at most limit bytes. The body is capped
+// before the form is parsed, so an oversized upload is cut off instead of being read to memory or disk.
+func uploadedFile(c echo.Context, name string, limit config.ByteSize) (*multipart.FileHeader, error) {
+	max := int64(limit) + uploadOverhead
+	if c.Request().ContentLength > max {
+		return nil, errUploadTooLarge
+	}
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, max)
+
+	file, err := c.FormFile(name)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || (err == nil && file.Size > int64(limit)) {
+		return nil, errUploadTooLarge
+	}
+	return file, err
 }
 
 // ProcessPDF handles PDF upload and extraction
 func (h *ExtractionHandler) ProcessPDF(c echo.Context) error {
-	fmt.Printf("Processing PDF")
-	// Get the uploaded file
-	file, err := c.FormFile("file")
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "No file uploaded",
-		})
+	if !h.startJob() {
+		return shuttingDown(c)
 	}
+	defer h.active.Done()
 
-	fmt.Printf("DEBUG: Received file upload: %s, size: %d\n", file.Filename, file.Size)
+	// Every log line of this extraction, the Python extractor's included, carries the job ID
+	job := models.ExtractionJob{ID: uuid.New(), Status: models.ExtractionStatusQueued, Worker: workerName}
+	ctx := logging.WithJobID(c.Request().Context(), job.ID.String())
 
-	// Validate file type
-	if !strings.HasSuffix(strings.ToLower(file.Filename), ".pdf") {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Only PDF files are supported",
+	// Get the uploaded file
+	file, err := uploadedFile(c, "file", h.upload.MaxPDFSize)
+	if errors.Is(err, errUploadTooLarge) {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{
+			"error": fmt.Sprintf("PDF is larger than the %s limit", h.upload.MaxPDFSize),
 		})
 	}
-
-	// Create input directory if it doesn't exist
-	inputDir := "../final_extraction_system/input_pdfs/"
-	if err := os.MkdirAll(inputDir, 0755); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to create input directory",
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "No file uploaded",
 		})
 	}
 
-	fmt.Printf("Input directory: %s", inputDir)
+	slog.InfoContext(ctx, "📄 Received PDF upload", "filename", file.Filename, "size", file.Size)
 
-	// Generate unique filename to avoid conflicts
-	timestamp := time.Now().Format("20060102_150405")
-	uniqueFilename := fmt.Sprintf("%s_%s", timestamp, file.Filename)
-	filePath := filepath.Join(inputDir, uniqueFilename)
-
-	// Save uploaded file
 	src, err := file.Open()
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
@@ -84,188 +182,394 @@ func (h *ExtractionHandler) ProcessPDF(c echo.Context) error {
 	}
 	defer src.Close()
 
-	fmt.Println("Saving uploaded file to", filePath)
-
-	dst, err := os.Create(filePath)
+	// The content decides, not the file name: check the PDF structure before storing anything
+	info, err := pdfinfo.Inspect(src, file.Size)
 	if err != nil {
+		slog.WarnContext(ctx, "❌ Rejected PDF upload", "filename", file.Filename, "error", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, pdfinfo.ErrEncrypted) || errors.Is(err, pdfinfo.ErrPasswordProtected) {
+			status = http.StatusUnprocessableEntity
+		}
+		return c.JSON(status, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if info.Pages > h.upload.MaxPDFPages {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{
+			"error": fmt.Sprintf("PDF has %d pages, more than the %d page limit", info.Pages, h.upload.MaxPDFPages),
+		})
+	}
+
+	// Files are stored under the SHA-256 of their content, so a file uploaded again is recognised
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(src, 0, file.Size)); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to create file",
+			"error": "Failed to read uploaded file",
 		})
 	}
-	defer dst.Close()
 
-	if _, err = io.Copy(dst, src); err != nil {
+	// The client's file name is only kept as metadata
+	document, created, err := h.storeDocument(ctx, io.NewSectionReader(src, 0, file.Size), models.Document{
+		ID:               uuid.New(),
+		SHA256:           hex.EncodeToString(hash.Sum(nil)),
+		OriginalFilename: models.CleanFilename(file.Filename),
+		Size:             file.Size,
+		Pages:            info.Pages,
+		PDFVersion:       info.Version,
+		UploadedBy:       cleanUploader(c.FormValue("uploaded_by")),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to store document", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to save file",
 		})
 	}
 
-	fmt.Println("Starting run python extraction")
+	// A file uploaded before is answered with its earlier extraction unless reextract is set
+	if !created && c.FormValue("reextract") != "true" {
+		previous, err := h.previousExtraction(ctx, document)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to retrieve the earlier extraction",
+			})
+		}
+		if previous != nil {
+			return c.JSON(http.StatusOK, previous)
+		}
+	}
 
-	// Run Python extraction script
-	extractionResult, err := h.runPythonExtraction()
-	if err != nil {
-		// Clean up uploaded file on error
-		os.Remove(filePath)
+	job.DocumentID, job.Filename, job.OriginalFilename = &document.ID, document.StoredName(), document.OriginalFilename
+	// Extracting a file again keeps the earlier output as an older version
+	if err := h.saveVersionedExtractionJob(ctx, &job); err != nil {
+		if created {
+			h.discardDocument(ctx, document)
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": fmt.Sprintf("Extraction failed: %v", err),
+			"error": "Failed to record extraction",
 		})
 	}
 
-	// Keep the uploaded PDF file for the viewer (don't delete it)
-	// os.Remove(filePath) // Commented out to keep PDF for viewer
+	// Queue the extraction for the next free worker of any replica and wait for its outcome
+	extractionResult, err := h.extract(ctx, &job)
+	if err != nil {
+		status := http.StatusGatewayTimeout
+		if err == errShuttingDown {
+			status = http.StatusServiceUnavailable
+		}
+		return c.JSON(status, map[string]string{
+			"error":  fmt.Sprintf("Extraction did not finish: %v; check GET /api/v1/extraction/status/%s", err, job.ID),
+			"job_id": job.ID.String(),
+		})
+	}
+	if extractionResult.Error != "" {
+		// The document is kept with its failed job, so the failure can be looked into
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error":  fmt.Sprintf("Extraction failed: %s", extractionResult.Error),
+			"job_id": job.ID.String(),
+		})
+	}
 
 	// Return extraction results
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": "PDF processed successfully",
-		"results": extractionResult,
-		"filename": uniqueFilename, // Return the timestamped filename that's actually stored
-		"original_filename": file.Filename, // Keep original for reference
+		"job_id": job.ID,
+		"version": job.Version,
+		"results": extractionResult.Results,
+		"document_id": document.ID, // Serve the PDF with GET /api/v1/extraction/pdf/:document_id
+		"original_filename": document.OriginalFilename,
+		"pages": document.Pages,
+		"duplicate": !created,
 		"processed_at": time.Now().Format(time.RFC3339),
 	})
 }
 
-// runPythonExtraction executes the Python extraction script
-func (h *ExtractionHandler) runPythonExtraction() (map[string]interface{}, error) {
-	log.Println("🚀 Starting Python extraction function")
-
-	// Get the absolute path to the extraction system directory
-	dir_temp := "../"
-	log.Printf("📁 Working directory: %s", dir_temp)
+// runPythonExtraction executes the Python extraction script on a stored PDF, with the options of a
+// re-extraction, and stores its output as the given version of the document's extraction
+func (h *ExtractionHandler) runPythonExtraction(ctx context.Context, filename string, version int, options *models.ExtractionOptions) (map[string]interface{}, error) {
+	// A shutdown that runs out of time kills the extractor through this context
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(h.jobs, cancel)
+	defer stop()
+	started := time.Now()
+
+	ctx, cancelTimeout := context.WithTimeout(ctx, h.extraction.Timeout)
+	defer cancelTimeout()
+
+	// Each extraction works in its own scratch directory: the PDF is copied there from storage and
+	// the extractor's output copied back, so no host needs the files on its disk beforehand
+	scratch, err := os.MkdirTemp("", "extraction-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %v", err)
+	}
+	defer os.RemoveAll(scratch)
+	inputDir := filepath.Join(scratch, "input")
+	outputRoot := filepath.Join(scratch, "output")
+	if err := h.downloadObject(ctx, storage.DocumentKey(filename), filepath.Join(inputDir, filename)); err != nil {
+		return nil, fmt.Errorf("failed to fetch PDF from storage: %v", err)
+	}
 
-	// Use bash to activate the virtual environment and run the Python script
-	activateScript := filepath.Join(dir_temp, "temp_env", "bin", "activate")
-	pythonScriptPath := filepath.Join(dir_temp, "final_extraction_system", "better_markdown_extractor.py")
+	// The virtual environment's interpreter runs the script without activating the environment
+	scriptPath, _ := filepath.Abs(h.extraction.ScriptPath())
 
-	log.Printf("🐍 Running Python script: %s", pythonScriptPath)
+	slog.InfoContext(ctx, "🐍 Running Python extractor", "script", scriptPath, "dir", h.extraction.Dir)
 
-	// Run: source activate && python script
-	cmd := exec.Command("bash", "-c", fmt.Sprintf("source %s && python %s", activateScript, pythonScriptPath))
-	cmd.Dir = filepath.Join(dir_temp, "final_extraction_system")
+	cmd := exec.CommandContext(ctx, h.extraction.PythonCommand(), scriptPath)
+	cmd.Dir = h.extraction.Dir
+	cmd.Env = append(os.Environ(),
+		"EXTRACTION_INPUT_DIR="+inputDir,
+		"EXTRACTION_OUTPUT_DIR="+outputRoot,
+		"EXTRACTION_INPUT_FILE="+filename,
+		"EXTRACTION_JOB_ID="+logging.JobID(ctx),
+		"LOG_LEVEL="+logging.PythonLevel(ctx),
+	)
+	cmd.Env = append(cmd.Env, extractionOptionsEnv(options)...)
 
-	// Explicitly redirect stdout and stderr for better debugging
+	// Log the extractor's output line by line as it runs, keeping a copy for the response
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture python output: %v", err)
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture python output: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to start Python extractor", "error", err)
+		return nil, fmt.Errorf("failed to start python script: %v", err)
+	}
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	// Run the command and wait for completion
-	err := cmd.Run()
+	copied := make(chan struct{})
+	go func() {
+		logging.CopyPythonLog(ctx, stdoutPipe, "stdout", &stdout)
+		copied <- struct{}{}
+	}()
+	go func() {
+		logging.CopyPythonLog(ctx, stderrPipe, "stderr", &stderr)
+		copied <- struct{}{}
+	}()
+	<-copied
+	<-copied
+
+	// Wait only once the pipes are drained, as it closes them
+	err = cmd.Wait()
+	if h.jobs.Err() != nil {
+		metrics.ExtractionDuration.WithLabelValues("failed").Observe(time.Since(started).Seconds())
+		slog.ErrorContext(ctx, "❌ Python extractor killed by shutdown")
+		return nil, errShuttingDown
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		metrics.ExtractionDuration.WithLabelValues("timeout").Observe(time.Since(started).Seconds())
+		slog.ErrorContext(ctx, "❌ Python extractor timed out", "timeout", h.extraction.Timeout.String())
+		return nil, fmt.Errorf("python script timed out after %s", h.extraction.Timeout)
+	}
 	if err != nil {
-		log.Printf("❌ Python script error: %v", err)
-		log.Printf("📤 Stdout: %s", stdout.String())
-		log.Printf("📤 Stderr: %s", stderr.String())
-		return nil, fmt.Errorf("python script failed: %v, stderr: %s", err, stderr.String())
+		metrics.ExtractionDuration.WithLabelValues("failed").Observe(time.Since(started).Seconds())
+		slog.ErrorContext(ctx, "❌ Python extractor failed", "error", err)
+		return nil, fmt.Errorf("python script failed: %v, last output: %s", err, lastLines(stderr.String(), 5))
 	}
 
 	output := stdout.String() + stderr.String()
-	log.Printf("✅ Python script completed successfully")
-
-	// Read the generated JSON files
-	outputDir := filepath.Join(dir_temp, "final_extraction_system", "output", "markdown")
-	log.Printf("📂 Looking for JSON files in: %s", outputDir)
+	metrics.ExtractionDuration.WithLabelValues("success").Observe(time.Since(started).Seconds())
+	slog.InfoContext(ctx, "✅ Python extractor completed")
 
-	// Check if directory exists
-	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
-		log.Printf("❌ Output directory does not exist: %s", outputDir)
-		return nil, fmt.Errorf("output directory does not exist: %s", outputDir)
+	if err := h.storeOutputs(ctx, filepath.Join(outputRoot, "markdown"), version); err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to store extractor output", "error", err)
+		return nil, fmt.Errorf("failed to store extractor output: %v", err)
 	}
-	
-	// Use os.ReadDir instead of filepath.Glob for more reliable file reading
-	entries, err := os.ReadDir(outputDir)
+
+	// Read the JSON file the extractor wrote for this PDF; there is none when it found no tables
+	jsonFile, err := h.readExtractedJSON(ctx, extractionOutputKey(filename, version, "_extracted.json"))
 	if err != nil {
-		log.Printf("❌ Failed to read output directory: %v", err)
-		return nil, fmt.Errorf("failed to read output directory: %v", err)
+		return nil, err
+	}
+	if jsonFile == nil {
+		return map[string]interface{}{
+			"extraction_output": output,
+			"json_files":        nil,
+			"files_count":       0,
+		}, nil
 	}
+	jsonFiles := []map[string]interface{}{jsonFile}
 
-	log.Printf("📊 Found %d entries in output directory", len(entries))
+	results := map[string]interface{}{
+		"extraction_output": output,
+		"json_files":        jsonFiles,
+		"files_count":       len(jsonFiles),
+	}
+	tables, rows := extractionSummary(results)
+	metrics.DocumentTables.Observe(float64(tables))
+	metrics.DocumentRows.Observe(float64(rows))
 
-	// Filter and collect JSON files with their modification times
-	type fileWithTime struct {
-		path    string
-		modTime time.Time
-		info    os.FileInfo
+	slog.InfoContext(ctx, "🎉 Extraction complete", "file", jsonFile["filename"], "version", version)
+
+	return results, nil
+}
+
+// extractionOptionsEnv passes the options of a re-extraction to the extractor; it reads them from its
+// environment
+func extractionOptionsEnv(options *models.ExtractionOptions) []string {
+	if options == nil {
+		return nil
+	}
+	var env []string
+	if options.Pages != "" {
+		env = append(env, "EXTRACTION_PAGES="+options.Pages)
+	}
+	if len(options.Flavors) > 0 {
+		env = append(env, "EXTRACTION_FLAVORS="+strings.Join(options.Flavors, ","))
 	}
-	var jsonFileList []fileWithTime
+	if options.MinConfidence != nil {
+		env = append(env, "EXTRACTION_MIN_CONFIDENCE="+strconv.FormatFloat(*options.MinConfidence, 'f', -1, 64))
+	}
+	if options.MinRows != nil {
+		env = append(env, "EXTRACTION_MIN_ROWS="+strconv.Itoa(*options.MinRows))
+	}
+	if options.MinCols != nil {
+		env = append(env, "EXTRACTION_MIN_COLS="+strconv.Itoa(*options.MinCols))
+	}
+	if options.MinFilled != nil {
+		env = append(env, "EXTRACTION_MIN_FILLED="+strconv.FormatFloat(*options.MinFilled, 'f', -1, 64))
+	}
+	return env
+}
 
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), ".json") {
-			fullPath := filepath.Join(outputDir, entry.Name())
-			info, err := os.Stat(fullPath)
-			if err != nil {
-				log.Printf("⚠️ Warning: could not stat file %s: %v", fullPath, err)
-				continue
-			}
-			jsonFileList = append(jsonFileList, fileWithTime{
-				path:    fullPath,
-				modTime: info.ModTime(),
-				info:    info,
-			})
-			log.Printf("📄 Found JSON file: %s (modified: %s)", entry.Name(), info.ModTime().Format(time.RFC3339))
-		}
+// extractionSummary adds up the tables and rows of the extractor's JSON files in the results of an
+// extraction
+func extractionSummary(results map[string]interface{}) (tables, rows int) {
+	files, _ := results["json_files"].([]map[string]interface{})
+	for _, file := range files {
+		data, _ := file["data"].(map[string]interface{})
+		summary, _ := data["summary"].(map[string]interface{})
+		fileTables, _ := summary["total_tables"].(float64)
+		fileRows, _ := summary["total_records"].(float64)
+		tables += int(fileTables)
+		rows += int(fileRows)
 	}
+	return tables, rows
+}
 
-	log.Printf("✅ Found %d JSON files total", len(jsonFileList))
+// extractionOutputKey returns the key of the output file with the given suffix of a stored PDF's
+// extraction
+func extractionOutputKey(filename string, version int, suffix string) string {
+	return versionOutputKey(strings.TrimSuffix(filename, filepath.Ext(filename))+suffix, version)
+}
 
-	if len(jsonFileList) == 0 {
-		log.Printf("⚠️ No JSON files found in output directory")
-		return map[string]interface{}{
-			"extraction_output": output,
-			"json_files":        nil,
-			"files_count":       0,
-		}, nil
+// versionOutputKey returns the key of an output file of the given extraction version. The first
+// version keeps the name the extractor gives its files; later versions are kept next to it under v<N>/.
+func versionOutputKey(name string, version int) string {
+	if version > 1 {
+		name = fmt.Sprintf("v%d/%s", version, name)
 	}
+	return storage.OutputKey(name)
+}
 
-	// Sort by modification time (most recent last)
-	sort.Slice(jsonFileList, func(i, j int) bool {
-		return jsonFileList[i].modTime.Before(jsonFileList[j].modTime)
-	})
+// downloadObject copies a stored object to a local file
+func (h *ExtractionHandler) downloadObject(ctx context.Context, key, file string) error {
+	reader, _, err := h.blobs.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
 
-	// Get the most recent file
-	mostRecent := jsonFileList[len(jsonFileList)-1]
-	log.Printf("📖 Reading most recent JSON file: %s", mostRecent.info.Name())
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	dst, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, reader); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
 
-	content, err := os.ReadFile(mostRecent.path)
+// storeOutputs copies the files the extractor wrote to dir into storage, as the given version
+func (h *ExtractionHandler) storeOutputs(ctx context.Context, dir string, version int) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
 	if err != nil {
-		log.Printf("❌ Failed to read JSON file: %v", err)
-		return nil, fmt.Errorf("failed to read most recent json file: %v", err)
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if err := h.storeOutput(ctx, filepath.Join(dir, entry.Name()), version); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	var jsonData map[string]interface{}
-	if err := json.Unmarshal(content, &jsonData); err != nil {
-		log.Printf("❌ Failed to parse JSON: %v", err)
-		return nil, fmt.Errorf("failed to parse json: %v", err)
+// storeOutput copies one output file into storage
+func (h *ExtractionHandler) storeOutput(ctx context.Context, file string, version int) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	contentType := "text/markdown"
+	if filepath.Ext(file) == ".json" {
+		contentType = "application/json"
 	}
+	return h.blobs.Put(ctx, versionOutputKey(info.Name(), version), f, info.Size(), contentType)
+}
 
-	log.Printf("✅ Successfully parsed JSON data from %s", mostRecent.info.Name())
+// readExtractedJSON reads a JSON file the extractor wrote, stored under key. It returns nil when
+// there is none.
+func (h *ExtractionHandler) readExtractedJSON(ctx context.Context, key string) (map[string]interface{}, error) {
+	slog.DebugContext(ctx, "📖 Reading JSON file", "key", key)
 
-	jsonFiles := []map[string]interface{}{
-		{
-			"filename": mostRecent.info.Name(),
-			"path":     mostRecent.path,
-			"data":     jsonData,
-			"size":     mostRecent.info.Size(),
-			"modified": mostRecent.info.ModTime().Format(time.RFC3339),
-		},
+	reader, object, err := h.blobs.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		slog.WarnContext(ctx, "⚠️ Extractor wrote no JSON file", "key", key)
+		return nil, nil
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to read JSON file", "key", key, "error", err)
+		return nil, fmt.Errorf("failed to read json file: %v", err)
 	}
+	defer reader.Close()
 
-	log.Printf("🎉 Extraction complete! Returning %d JSON file(s)", len(jsonFiles))
+	var jsonData map[string]interface{}
+	if err := json.NewDecoder(reader).Decode(&jsonData); err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to parse JSON", "key", key, "error", err)
+		return nil, fmt.Errorf("failed to parse json: %v", err)
+	}
 
 	return map[string]interface{}{
-		"extraction_output": output,
-		"json_files":        jsonFiles,
-		"files_count":       len(jsonFiles),
+		"filename": path.Base(key),
+		"path":     key,
+		"data":     jsonData,
+		"size":     object.Size,
+		"modified": object.ModTime.Format(time.RFC3339),
 	}, nil
 }
 
+// lastLines returns the last n non-empty lines of output, joined by " | "
+func lastLines(output string, n int) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, " | ")
+}
+
 // readMarkdownFiles reads all markdown files from the output directory
 func (h *ExtractionHandler) readJsonFiles(outputDir string) ([]map[string]interface{}, error) {
 	var files []map[string]interface{}
 
-	fmt.Printf("DEBUG: Looking for JSON files in: %s\n", outputDir)
-
 	// Check if output directory exists
 	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
-		fmt.Printf("DEBUG: Output directory does not exist: %s\n", outputDir)
 		return files, nil
 	}
 
@@ -276,21 +580,17 @@ func (h *ExtractionHandler) readJsonFiles(outputDir string) ([]map[string]interf
 		}
 
 		if strings.HasSuffix(strings.ToLower(path), ".json") {
-			fmt.Printf("DEBUG: Found JSON file: %s\n", path)
 			content, err := os.ReadFile(path)
 			if err != nil {
-				fmt.Printf("DEBUG: Error reading file %s: %v\n", path, err)
 				return err
 			}
 
 			// Parse JSON content
 			var jsonData map[string]interface{}
 			if err := json.Unmarshal(content, &jsonData); err != nil {
-				fmt.Printf("DEBUG: Error parsing JSON from %s: %v\n", path, err)
 				return err
 			}
 
-			fmt.Printf("DEBUG: Successfully parsed JSON from %s\n", path)
 			files = append(files, map[string]interface{}{
 				"filename": info.Name(),
 				"path":     path,
@@ -341,25 +641,43 @@ func (h *ExtractionHandler) readMarkdownFiles(outputDir string) ([]map[string]in
 	return files, err
 }
 
-// GetExtractionStatus returns the status of an extraction (placeholder for future async processing)
+// GetExtractionStatus returns the recorded state of an extraction job, with its results while they are kept
 func (h *ExtractionHandler) GetExtractionStatus(c echo.Context) error {
-	id := c.Param("id")
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Extraction not found",
+		})
+	}
 
-	// For now, just return a simple status
-	// In the future, this could check a database for extraction status
+	var job models.ExtractionJob
+	if err := h.db.First(&job, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Extraction not found",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve extraction",
+		})
+	}
+
+	var outcome extractionOutcome
+	if job.Status == models.ExtractionStatusCompleted &&
+		h.results.Get(c.Request().Context(), extractionResultKey(job.ID), &outcome) == nil {
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"job":     job,
+			"results": outcome.Results,
+		})
+	}
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"id":      id,
-		"status":  "completed",
-		"message": "Extraction status endpoint - ready for async implementation",
+		"job": job,
 	})
 }
 
 // DebugFiles returns debug information about JSON files
 func (h *ExtractionHandler) DebugFiles(c echo.Context) error {
-	dir_temp := "../"
-	outputDir := filepath.Join(dir_temp, "final_extraction_system", "output", "markdown")
-
-	files, err := filepath.Glob(filepath.Join(outputDir, "*.json"))
+	files, err := h.outputJSONFiles(c.Request().Context())
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
 			"error": err.Error(),
@@ -367,17 +685,31 @@ func (h *ExtractionHandler) DebugFiles(c echo.Context) error {
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"output_dir":  outputDir,
-		"files_found": len(files),
-		"files":       files,
+		"output_prefix": storage.Outputs,
+		"files_found":   len(files),
+		"files":         files,
 	})
 }
 
+// outputJSONFiles lists the extractor's stored JSON files
+func (h *ExtractionHandler) outputJSONFiles(ctx context.Context) ([]storage.Object, error) {
+	objects, err := h.blobs.List(ctx, storage.Outputs)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]storage.Object, 0, len(objects))
+	for _, object := range objects {
+		if strings.HasSuffix(object.Key, ".json") {
+			files = append(files, object)
+		}
+	}
+	return files, nil
+}
+
 // GetLatestJson returns the most recent JSON file
 func (h *ExtractionHandler) GetLatestJson(c echo.Context) error {
-	outputDir := "../final_extraction_system/output/markdown"
-
-	files, err := filepath.Glob(filepath.Join(outputDir, "*.json"))
+	ctx := c.Request().Context()
+	files, err := h.outputJSONFiles(ctx)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
 			"error": err.Error(),
@@ -391,137 +723,106 @@ func (h *ExtractionHandler) GetLatestJson(c echo.Context) error {
 	}
 
 	// Get the most recent file
-	mostRecentFile := files[len(files)-1]
-	content, err := os.ReadFile(mostRecentFile)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
-			"error": err.Error(),
-		})
+	mostRecent := files[0]
+	for _, file := range files[1:] {
+		if file.ModTime.After(mostRecent.ModTime) {
+			mostRecent = file
+		}
 	}
-
-	var jsonData map[string]interface{}
-	if err := json.Unmarshal(content, &jsonData); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
-			"error": err.Error(),
-		})
+	jsonFile, err := h.readExtractedJSON(ctx, mostRecent.Key)
+	if err == nil && jsonFile == nil {
+		err = storage.ErrNotFound
 	}
-
-	fileInfo, err := os.Stat(mostRecentFile)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
-	
-	// Extract timestamped PDF filename from JSON filename
-	// JSON filename format: "20251013_102142_PMO3ANDUPP03 - PETROGRAPHIC STUDY OF ANDING UTARA-1ST1 DITCH CUTTINGS_extracted.json"
-	// Timestamped PDF filename: "20251013_102142_PMO3ANDUPP03 - PETROGRAPHIC STUDY OF ANDING UTARA-1ST1 DITCH CUTTINGS.pdf"
-	jsonFilename := fileInfo.Name()
-	timestampedPdfFilename := jsonFilename
-	
-	// Convert JSON filename to PDF filename
-	if strings.Contains(jsonFilename, "_extracted.json") {
-		timestampedPdfFilename = strings.Replace(jsonFilename, "_extracted.json", ".pdf", 1)
+
+	response := jsonFile
+	// The extractor names its output after the stored PDF, "<SHA-256>_extracted.json", or
+	// "<document ID>_extracted.json" for documents stored before files were content-addressed
+	stem := strings.TrimSuffix(path.Base(mostRecent.Key), "_extracted.json")
+	if documentID, err := uuid.Parse(stem); err == nil {
+		response["document_id"] = documentID
+	} else if document, err := h.findDocumentByHash(ctx, stem); err == nil && document != nil {
+		response["document_id"] = document.ID
 	}
-	
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"filename": timestampedPdfFilename,
-		"path": mostRecentFile,
-		"data": jsonData,
-		"size": fileInfo.Size(),
-		"modified": fileInfo.ModTime().Format(time.RFC3339),
-	})
+	return c.JSON(http.StatusOK, response)
 }
 
 // ServePDF handles serving PDF files for the frontend viewer
 func (h *ExtractionHandler) ServePDF(c echo.Context) error {
-	filename := c.Param("filename")
-	if filename == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Filename parameter is required",
-		})
-	}
-
-	// Look for the PDF file in the input_pdfs directory (where uploaded PDFs are stored)
-	// Backend runs from backend/ directory, so we need to go up one level
-	inputPdfsPath := filepath.Join("..", "final_extraction_system", "input_pdfs", filename)
-	
-	// Check if file exists
-	if _, err := os.Stat(inputPdfsPath); os.IsNotExist(err) {
-		log.Printf("❌ PDF file not found: %s", inputPdfsPath)
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "PDF file not found",
-		})
+	document, err := h.findDocument(c.Param("id"))
+	if err != nil {
+		return documentLookupError(c, err)
 	}
-	
-	pdfPath := inputPdfsPath
 
-	// Set appropriate headers for PDF viewing
-	c.Response().Header().Set("Content-Type", "application/pdf")
-	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", filename))
-	
-	// Serve the file
-	return c.File(pdfPath)
+	return h.serveDocument(c, document)
 }
 
-// ServePDFPage handles serving specific PDF pages for the frontend viewer
+// ServePDFPage serves one page of a document for the frontend viewer: a single-page PDF, or with
+// format=png or webp an image rendered at dpi
 func (h *ExtractionHandler) ServePDFPage(c echo.Context) error {
-	filename := c.Param("filename")
-	pageStr := c.Param("page")
-	
-	if filename == "" || pageStr == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Filename and page parameters are required",
-		})
-	}
-
-	// Convert page to integer
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid page number",
-		})
-	}
-
-	// Look for the PDF file in the input_pdfs directory
-	// Backend runs from backend/ directory, so we need to go up one level
-	inputPdfsPath := filepath.Join("..", "final_extraction_system", "input_pdfs", filename)
-	
-	// Check if file exists
-	if _, err := os.Stat(inputPdfsPath); os.IsNotExist(err) {
-		log.Printf("❌ PDF file not found: %s", inputPdfsPath)
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "PDF file not found",
-		})
-	}
-
-	// For now, serve the full PDF (we can implement page extraction later)
-	// Set appropriate headers for PDF viewing
-	c.Response().Header().Set("Content-Type", "application/pdf")
-	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", filename))
-	
-	// Serve the file
-	return c.File(inputPdfsPath)
+	return h.servePageRequest(c, false)
 }
 
 // SaveToDatabase handles saving extracted tables to database
 func (h *ExtractionHandler) SaveToDatabase(c echo.Context) error {
-	log.Println("🚀 Starting save to database process")
+	ctx := c.Request().Context()
 
 	// Parse request body
 	var request struct {
 		Tables   []map[string]interface{} `json:"tables"`
 		Filename string                   `json:"filename"`
+		DryRun   bool                     `json:"dry_run"`
+		// CRS is the EPSG code of the report's coordinates; a table may override it with its own "crs"
+		CRS string `json:"crs"`
+		// DocumentID links the records to the uploaded PDF; saving a document's tables a second time
+		// is refused unless AllowDuplicates is set
+		DocumentID      string `json:"document_id"`
+		AllowDuplicates bool   `json:"allow_duplicates"`
 	}
 
 	if err := c.Bind(&request); err != nil {
-		log.Printf("❌ Failed to parse request: %v", err)
+		slog.WarnContext(ctx, "❌ Failed to parse request", "error", err)
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": "Invalid request format",
 		})
 	}
 
-	log.Printf("📊 Received %d tables to save", len(request.Tables))
+	if request.CRS != "" {
+		if _, err := coordinates.Lookup(request.CRS); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	var documentID *uuid.UUID
+	var imported *documentRecords
+	if request.DocumentID != "" {
+		document, err := h.findDocument(request.DocumentID)
+		if err != nil {
+			return documentLookupError(c, err)
+		}
+		documentID = &document.ID
+		if imported, err = h.importedRecords(ctx, document.ID); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to check previously imported records",
+			})
+		}
+		if imported.Total > 0 && !request.DryRun && !request.AllowDuplicates {
+			slog.WarnContext(ctx, "⚠️ Document already imported", "document_id", document.ID, "records", imported.Total)
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"error":            fmt.Sprintf("%d records from this document were already imported; set allow_duplicates to import them again", imported.Total),
+				"document_id":      document.ID,
+				"imported_records": imported,
+			})
+		}
+	}
+
+	slog.InfoContext(ctx, "📊 Saving extracted tables", "tables", len(request.Tables), "filename", request.Filename, "dry_run", request.DryRun)
 
 	// Filter out empty tables
 	validTables := make([]map[string]interface{}, 0)
@@ -531,9 +832,9 @@ func (h *ExtractionHandler) SaveToDatabase(c echo.Context) error {
 
 		if ok1 && ok2 && len(headers) > 0 && len(rows) > 0 {
 			validTables = append(validTables, table)
-			log.Printf("✅ Table %d: %d headers, %d rows", i+1, len(headers), len(rows))
+			slog.DebugContext(ctx, "✅ Table accepted", "table", i+1, "headers", len(headers), "rows", len(rows))
 		} else {
-			log.Printf("⚠️ Skipping empty table %d", i+1)
+			slog.InfoContext(ctx, "⚠️ Skipping empty table", "table", i+1)
 		}
 	}
 
@@ -543,55 +844,68 @@ func (h *ExtractionHandler) SaveToDatabase(c echo.Context) error {
 		})
 	}
 
-	log.Printf("💾 Processing %d valid tables", len(validTables))
-
 	// Process each table
 	savedTables := 0
 	totalRecords := 0
+	rowResults := make([]RowResult, 0)
 
 	for i, table := range validTables {
-		log.Printf("🔄 Processing table %d/%d", i+1, len(validTables))
-
 		// Map headers to database fields using fuzzy matching
-		log.Printf("🔍 Mapping headers for table %d", i+1)
 		headers, _ := table["headers"].([]interface{})
-		log.Printf("📋 Original headers: %v", headers)
-		
-		mappedData, err := h.mapTableToDatabaseFields(table)
+		slog.DebugContext(ctx, "🔍 Mapping headers", "table", i+1, "headers", headers)
+
+		mappedData, err := h.mapTableToDatabaseFields(ctx, table)
 		if err != nil {
-			log.Printf("❌ Failed to map table %d: %v", i+1, err)
+			slog.WarnContext(ctx, "❌ Failed to map table", "table", i+1, "error", err)
 			continue
 		}
 		
-		log.Printf("🎯 Mapped data keys: %v", getMapKeys(mappedData))
-		if mapping, ok := mappedData["mapping"].(map[int]string); ok {
-			log.Printf("🔗 Column mapping: %v", mapping)
-		}
-		
 		// Save to appropriate tables based on mapped fields
-		records, err := h.saveTableToDatabase(mappedData)
+		opts := saveOptions{DryRun: request.DryRun, CRS: request.CRS, DocumentID: documentID}
+		if crs, ok := table["crs"].(string); ok && crs != "" {
+			opts.CRS = crs
+		}
+		records, results, err := h.saveTableToDatabase(h.db.WithContext(ctx), mappedData, opts)
+		// Each row is written on its own, so the rows reported are saved whatever err says
+		if !request.DryRun {
+			countSavedRows(results)
+		}
+		for _, result := range results {
+			result.Table = i + 1
+			rowResults = append(rowResults, result)
+		}
 		if err != nil {
-			log.Printf("❌ Failed to save table %d: %v", i+1, err)
+			slog.WarnContext(ctx, "❌ Failed to save table", "table", i+1, "error", err)
 			continue
 		}
-		
-		log.Printf("✅ Table %d saved: %d records", i+1, records)
+
+		slog.InfoContext(ctx, "✅ Table saved", "table", i+1, "records", records)
 		savedTables++
 		totalRecords += records
 	}
 
-	log.Printf("🎉 Save complete: %d tables, %d total records", savedTables, totalRecords)
+	slog.InfoContext(ctx, "🎉 Save complete", "tables", savedTables, "records", totalRecords, "dry_run", request.DryRun)
+
+	details := fmt.Sprintf("Successfully saved %d tables with %d total records to database", savedTables, totalRecords)
+	if request.DryRun {
+		details = fmt.Sprintf("Dry run: validated %d tables, nothing was written", savedTables)
+	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"success":       true,
+		"dry_run":       request.DryRun,
 		"saved_tables":  savedTables,
 		"total_records": totalRecords,
-		"details":       fmt.Sprintf("Successfully saved %d tables with %d total records to database", savedTables, totalRecords),
+		"summary":       summarizeRowResults(rowResults),
+		"rows":          rowResults,
+		"details":       details,
+		// Records of the document saved before this request, if it names one
+		"imported_records": imported,
 	})
 }
 
 // mapTableToDatabaseFields maps table headers to database field names using fuzzy matching
-func (h *ExtractionHandler) mapTableToDatabaseFields(table map[string]interface{}) (map[string]interface{}, error) {
+func (h *ExtractionHandler) mapTableToDatabaseFields(ctx context.Context, table map[string]interface{}) (map[string]interface{}, error) {
 	headers, ok1 := table["headers"].([]interface{})
 	rows, ok2 := table["rows"].([]interface{})
 
@@ -611,7 +925,16 @@ func (h *ExtractionHandler) mapTableToDatabaseFields(table map[string]interface{
 		// Try exact match first
 		if dbField, exists := fieldMappings[headerStr]; exists {
 			headerMapping[i] = dbField
-			log.Printf("✅ Exact match found: '%s' -> '%s'", headerStr, dbField)
+			metrics.HeaderMappings.WithLabelValues("exact").Inc()
+			slog.DebugContext(ctx, "✅ Exact match found", "header", headerStr, "field", dbField)
+			continue
+		}
+
+		// ePBE template labels and raw column names, as written by the export endpoints
+		if dbField, exists := models.EPBEColumnForHeader(headerStr); exists {
+			headerMapping[i] = dbField
+			metrics.HeaderMappings.WithLabelValues("epbe").Inc()
+			slog.DebugContext(ctx, "✅ ePBE header match found", "header", headerStr, "field", dbField)
 			continue
 		}
 
@@ -629,9 +952,12 @@ func (h *ExtractionHandler) mapTableToDatabaseFields(table map[string]interface{
 
 		if bestMatch != "" {
 			headerMapping[i] = bestMatch
-			log.Printf("🔗 Mapped '%s' -> '%s' (score: %.2f)", headerStr, bestMatch, bestScore)
+			metrics.HeaderMappings.WithLabelValues("fuzzy").Inc()
+			metrics.FuzzyScore.Observe(bestScore)
+			slog.DebugContext(ctx, "🔗 Fuzzy match found", "header", headerStr, "field", bestMatch, "score", bestScore)
 		} else {
-			log.Printf("⚠️ No mapping found for header: '%s'", headerStr)
+			metrics.HeaderMappings.WithLabelValues("miss").Inc()
+			slog.DebugContext(ctx, "⚠️ No mapping found for header", "header", headerStr)
 		}
 	}
 
@@ -668,54 +994,68 @@ func (h *ExtractionHandler) calculateSimilarity(s1, s2 string) float64 {
 }
 
 
+// countSavedRows adds rows to the saved rows metric. It is called once the rows are committed, so dry
+// runs and rolled back chunks are not counted.
+func countSavedRows(results []RowResult) {
+	for _, result := range results {
+		metrics.Rows.WithLabelValues(result.Target, result.Status).Inc()
+	}
+}
+
 // saveTableToDatabase saves the mapped table data to the appropriate database table
-func (h *ExtractionHandler) saveTableToDatabase(mappedData map[string]interface{}) (int, error) {
+func (h *ExtractionHandler) saveTableToDatabase(db *gorm.DB, mappedData map[string]interface{}, opts saveOptions) (int, []RowResult, error) {
 	headers, _ := mappedData["headers"].([]interface{})
 	rows, _ := mappedData["rows"].([]interface{})
 	mapping, _ := mappedData["mapping"].(map[int]string)
-	
-	log.Printf("📋 Headers: %v", headers)
-	log.Printf("🔗 Mapping: %v", mapping)
-	log.Printf("📊 Rows: %d", len(rows))
-	
-	// Get database connection
-	db := h.db
+	ctx := db.Statement.Context
+
+	slog.DebugContext(ctx, "🔗 Saving mapped table", "mapping", mapping, "rows", len(rows))
 	
 	// Determine which tables to save to based on mapped fields
 	totalRecords := 0
+	results := make([]RowResult, 0, len(rows))
 	
 	// Check if we have carbonate fields
 	carbonateFields := h.getCarbonateFields(mapping)
+	if opts.Target == TargetClastic {
+		carbonateFields = nil
+	}
 	if len(carbonateFields) > 0 {
-		log.Printf("💾 Saving to petrography_carbonate table with fields: %v", carbonateFields)
-		records, err := h.insertCarbonateRecords(db, headers, rows, mapping)
+		slog.DebugContext(ctx, "💾 Saving to petrography_carbonate", "fields", carbonateFields)
+		records, rowResults, err := h.insertCarbonateRecords(db, headers, rows, mapping, opts)
 		if err != nil {
-			log.Printf("❌ Failed to save to carbonate table: %v", err)
+			slog.ErrorContext(ctx, "❌ Failed to save to petrography_carbonate", "error", err)
 		} else {
 			totalRecords += records
-			log.Printf("✅ Saved %d records to carbonate table", records)
+			results = append(results, rowResults...)
 		}
 	}
 	
 	// Check if we have clastic fields
 	clasticFields := h.getClasticFields(mapping)
+	if opts.Target == TargetCarbonate {
+		clasticFields = nil
+	}
 	if len(clasticFields) > 0 {
-		log.Printf("💾 Saving to petrography_clastic table with fields: %v", clasticFields)
-		records, err := h.insertClasticRecords(db, headers, rows, mapping)
+		slog.DebugContext(ctx, "💾 Saving to petrography_clastic", "fields", clasticFields)
+		records, rowResults, err := h.insertClasticRecords(db, headers, rows, mapping, opts)
 		if err != nil {
-			log.Printf("❌ Failed to save to clastic table: %v", err)
+			slog.ErrorContext(ctx, "❌ Failed to save to petrography_clastic", "error", err)
 		} else {
 			totalRecords += records
-			log.Printf("✅ Saved %d records to clastic table", records)
+			results = append(results, rowResults...)
 		}
 	}
 	
-	if totalRecords == 0 {
-		log.Printf("⚠️ No records saved - no matching fields found")
-		return 0, fmt.Errorf("no matching fields found for any table")
+	if len(carbonateFields) == 0 && len(clasticFields) == 0 {
+		return 0, results, fmt.Errorf("no matching fields found for any table")
+	}
+	
+	if totalRecords == 0 && !opts.DryRun {
+		return 0, results, fmt.Errorf("no records saved")
 	}
 	
-	return totalRecords, nil
+	return totalRecords, results, nil
 }
 
 // getCarbonateFields returns the fields that belong to the carbonate table
@@ -746,7 +1086,8 @@ func (h *ExtractionHandler) isCarbonateField(field string) bool {
 	commonFields := map[string]bool{
 		"well_name_field_name": true, "country": true, "region": true, "sub_region": true,
 		"business_regions": true, "basin": true, "sub_basin": true, "uwi": true,
-		"latitude": true, "longitude": true, "formation_name": true, "reservoir_name": true,
+		"latitude": true, "longitude": true, "easting": true, "northing": true,
+		"formation_name": true, "reservoir_name": true,
 		"period": true, "epoch": true, "age": true, "onshore_offshore": true,
 		"water_depth_m": true, "water_depth_ft": true, "top_depth_mmddf": true,
 		"bottom_depth_mmddf": true, "top_depth_mtvddf": true, "top_depth_mtvdss": true,
@@ -791,7 +1132,8 @@ func (h *ExtractionHandler) isClasticField(field string) bool {
 	commonFields := map[string]bool{
 		"well_name_field_name": true, "country": true, "region": true, "sub_region": true,
 		"business_regions": true, "basin": true, "sub_basin": true, "uwi": true,
-		"latitude": true, "longitude": true, "formation_name": true, "reservoir_name": true,
+		"latitude": true, "longitude": true, "easting": true, "northing": true,
+		"formation_name": true, "reservoir_name": true,
 		"period": true, "epoch": true, "age": true, "onshore_offshore": true,
 		"water_depth_m": true, "water_depth_ft": true, "top_depth_mmddf": true,
 		"bottom_depth_mmddf": true, "top_depth_mtvddf": true, "top_depth_mtvdss": true,
@@ -867,12 +1209,27 @@ func getMapKeys(m map[string]interface{}) []string {
 }
 
 // insertCarbonateRecords inserts data into the petrography_carbonate table
-func (h *ExtractionHandler) insertCarbonateRecords(db *gorm.DB, headers []interface{}, rows []interface{}, mapping map[int]string) (int, error) {
+func (h *ExtractionHandler) insertCarbonateRecords(db *gorm.DB, headers []interface{}, rows []interface{}, mapping map[int]string, opts saveOptions) (int, []RowResult, error) {
 	recordCount := 0
+	results := make([]RowResult, 0, len(rows))
+
+	columns, err := database.ModelColumns(db, &models.EPBEPetrographyCarbonate{})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Rows to insert, with the index of their result
+	var records []*models.EPBEPetrographyCarbonate
+	var recordResults []int
+
+	for rowIndex, row := range rows {
+		result := RowResult{Row: rowIndex + 1, Target: "petrography_carbonate"}
 
-	for _, row := range rows {
 		rowSlice, ok := row.([]interface{})
 		if !ok {
+			result.Status = RowStatusSkipped
+			result.Error = "row is not a list of cells"
+			results = append(results, result)
 			continue
 		}
 
@@ -885,29 +1242,32 @@ func (h *ExtractionHandler) insertCarbonateRecords(db *gorm.DB, headers []interf
 			}
 		}
 		if !hasData {
+			result.Status = RowStatusSkipped
+			result.Error = "empty row"
+			results = append(results, result)
 			continue
 		}
 
+		result.Warnings = rowWarnings(rowSlice, mapping, columns)
+		result.Warnings = append(result.Warnings, vocabularyWarnings(db, rowSlice, mapping)...)
+
 		// Create carbonate record
 		carbonate := models.EPBEPetrographyCarbonate{}
+		carbonate.DocumentID = opts.DocumentID
+		var rawX, rawY string
 
 		// Map data to struct fields
-		log.Printf("🔍 Processing carbonate row %d with %d cells", recordCount+1, len(rowSlice))
 		for colIndex, cell := range rowSlice {
 			cellStr, ok := cell.(string)
 			if !ok {
-				log.Printf("⚠️ Cell %d is not a string: %v", colIndex, cell)
 				continue
 			}
 
 			fieldName, exists := mapping[colIndex]
 			if !exists {
-				log.Printf("⚠️ No mapping for column %d (value: %s)", colIndex, cellStr)
 				continue
 			}
 			
-			log.Printf("📝 Mapping column %d: '%s' -> %s", colIndex, cellStr, fieldName)
-			
 			// Map field names to struct fields
 			switch fieldName {
 			// String fields
@@ -949,10 +1309,14 @@ func (h *ExtractionHandler) insertCarbonateRecords(db *gorm.DB, headers []interf
 				carbonate.AnalysisTypes = cellStr
 			
 			// Float64 fields
-			case "latitude":
-				carbonate.Latitude = stringToFloat64Ptr(cellStr)
-			case "longitude":
-				carbonate.Longitude = stringToFloat64Ptr(cellStr)
+			case "latitude", "northing":
+				if rawY == "" {
+					rawY = strings.TrimSpace(cellStr)
+				}
+			case "longitude", "easting":
+				if rawX == "" {
+					rawX = strings.TrimSpace(cellStr)
+				}
 			case "water_depth_m":
 				carbonate.WaterDepthM = stringToFloat64Ptr(cellStr)
 			case "water_depth_ft":
@@ -1162,26 +1526,85 @@ func (h *ExtractionHandler) insertCarbonateRecords(db *gorm.DB, headers []interf
 			}
 		}
 
-		// Insert record
-		if err := db.Create(&carbonate).Error; err != nil {
-			log.Printf("❌ Failed to insert carbonate record: %v", err)
+		// Coordinates may be DMS strings or projected values; keep the raw text and convert to WGS84
+		if rawX != "" || rawY != "" {
+			if err := models.SetCoordinates(&carbonate.EPBEBase, &carbonate.MetadataInfo, rawX, rawY, opts.CRS); err != nil {
+				result.Warnings = append(result.Warnings, "coordinates: "+err.Error())
+			}
+		}
+		warnings, err := timescaleWarnings(&carbonate.EPBEBase, &carbonate.MetadataInfo)
+		result.Warnings = append(result.Warnings, warnings...)
+		if err != nil {
+			result.Status = RowStatusFailed
+			result.Error = "geologic time: " + err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		if opts.DryRun {
+			result.Status = RowStatusValid
+			results = append(results, result)
+			continue
+		}
+
+		records = append(records, &carbonate)
+		recordResults = append(recordResults, len(results))
+		results = append(results, result)
+	}
+
+	// Vocabularies, wells and formation tops are looked up once for the whole batch
+	samples := make([]models.Sample, len(records))
+	for i, record := range records {
+		samples[i] = record
+	}
+	if err := models.PrepareSamples(db, samples...); err != nil {
+		return 0, nil, err
+	}
+
+	for i, record := range records {
+		result := &results[recordResults[i]]
+
+		// Insert record; inside a transaction this runs under a savepoint so one bad row does not abort the rest
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return tx.Create(record).Error
+		}); err != nil {
+			slog.WarnContext(db.Statement.Context, "❌ Failed to insert carbonate record", "row", result.Row, "error", err)
+			result.Status = RowStatusFailed
+			result.Error = err.Error()
 			continue
 		}
 
+		result.Status = RowStatusInserted
+		result.ID = record.ID
 		recordCount++
 	}
 
-	log.Printf("✅ Inserted %d carbonate records", recordCount)
-	return recordCount, nil
+	slog.InfoContext(db.Statement.Context, "✅ Inserted carbonate records", "records", recordCount)
+	return recordCount, results, nil
 }
 
 // insertClasticRecords inserts data into the petrography_clastic table
-func (h *ExtractionHandler) insertClasticRecords(db *gorm.DB, headers []interface{}, rows []interface{}, mapping map[int]string) (int, error) {
+func (h *ExtractionHandler) insertClasticRecords(db *gorm.DB, headers []interface{}, rows []interface{}, mapping map[int]string, opts saveOptions) (int, []RowResult, error) {
 	recordCount := 0
+	results := make([]RowResult, 0, len(rows))
+
+	columns, err := database.ModelColumns(db, &models.EPBEPetrographyClastic{})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Rows to insert, with the index of their result
+	var records []*models.EPBEPetrographyClastic
+	var recordResults []int
+
+	for rowIndex, row := range rows {
+		result := RowResult{Row: rowIndex + 1, Target: "petrography_clastic"}
 
-	for _, row := range rows {
 		rowSlice, ok := row.([]interface{})
 		if !ok {
+			result.Status = RowStatusSkipped
+			result.Error = "row is not a list of cells"
+			results = append(results, result)
 			continue
 		}
 
@@ -1194,11 +1617,19 @@ func (h *ExtractionHandler) insertClasticRecords(db *gorm.DB, headers []interfac
 			}
 		}
 		if !hasData {
+			result.Status = RowStatusSkipped
+			result.Error = "empty row"
+			results = append(results, result)
 			continue
 		}
 
+		result.Warnings = rowWarnings(rowSlice, mapping, columns)
+		result.Warnings = append(result.Warnings, vocabularyWarnings(db, rowSlice, mapping)...)
+
 		// Create clastic record
 		clastic := models.EPBEPetrographyClastic{}
+		clastic.DocumentID = opts.DocumentID
+		var rawX, rawY string
 
 		// Map data to struct fields
 		for colIndex, cell := range rowSlice {
@@ -1259,10 +1690,14 @@ func (h *ExtractionHandler) insertClasticRecords(db *gorm.DB, headers []interfac
 				clastic.Sorting = cellStr
 			
 			// Float64 fields
-			case "latitude":
-				clastic.Latitude = stringToFloat64Ptr(cellStr)
-			case "longitude":
-				clastic.Longitude = stringToFloat64Ptr(cellStr)
+			case "latitude", "northing":
+				if rawY == "" {
+					rawY = strings.TrimSpace(cellStr)
+				}
+			case "longitude", "easting":
+				if rawX == "" {
+					rawX = strings.TrimSpace(cellStr)
+				}
 			case "water_depth_m":
 				clastic.WaterDepthM = stringToFloat64Ptr(cellStr)
 			case "water_depth_ft":
@@ -1424,15 +1859,59 @@ func (h *ExtractionHandler) insertClasticRecords(db *gorm.DB, headers []interfac
 			}
 		}
 
-		// Insert record
-		if err := db.Create(&clastic).Error; err != nil {
-			log.Printf("❌ Failed to insert clastic record: %v", err)
+		// Coordinates may be DMS strings or projected values; keep the raw text and convert to WGS84
+		if rawX != "" || rawY != "" {
+			if err := models.SetCoordinates(&clastic.EPBEBase, &clastic.MetadataInfo, rawX, rawY, opts.CRS); err != nil {
+				result.Warnings = append(result.Warnings, "coordinates: "+err.Error())
+			}
+		}
+		warnings, err := timescaleWarnings(&clastic.EPBEBase, &clastic.MetadataInfo)
+		result.Warnings = append(result.Warnings, warnings...)
+		if err != nil {
+			result.Status = RowStatusFailed
+			result.Error = "geologic time: " + err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		if opts.DryRun {
+			result.Status = RowStatusValid
+			results = append(results, result)
+			continue
+		}
+
+		records = append(records, &clastic)
+		recordResults = append(recordResults, len(results))
+		results = append(results, result)
+	}
+
+	// Vocabularies, wells and formation tops are looked up once for the whole batch
+	samples := make([]models.Sample, len(records))
+	for i, record := range records {
+		samples[i] = record
+	}
+	if err := models.PrepareSamples(db, samples...); err != nil {
+		return 0, nil, err
+	}
+
+	for i, record := range records {
+		result := &results[recordResults[i]]
+
+		// Insert record; inside a transaction this runs under a savepoint so one bad row does not abort the rest
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return tx.Create(record).Error
+		}); err != nil {
+			slog.WarnContext(db.Statement.Context, "❌ Failed to insert clastic record", "row", result.Row, "error", err)
+			result.Status = RowStatusFailed
+			result.Error = err.Error()
 			continue
 		}
 
+		result.Status = RowStatusInserted
+		result.ID = record.ID
 		recordCount++
 	}
 
-	log.Printf("✅ Inserted %d clastic records", recordCount)
-	return recordCount, nil
+	slog.InfoContext(db.Statement.Context, "✅ Inserted clastic records", "records", recordCount)
+	return recordCount, results, nil
 }
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"workbench/internal/cache"
+	"workbench/internal/core/models"
+	"workbench/internal/logging"
+	"workbench/internal/metrics"
+	"workbench/internal/queue"
+
+	"github.com/google/uuid"
+)
+
+// extractionQueue is the queue extraction jobs are sent to
+const extractionQueue = "extraction"
+
+// extractionResultTTL is how long the outcome of an extraction stays available to the status endpoint
+const extractionResultTTL = time.Hour
+
+// extractionPollInterval is how often a request waiting for an extraction run by another replica
+// checks for its outcome
+const extractionPollInterval = time.Second
+
+// extractionTask is the queue message of an extraction job
+type extractionTask struct {
+	JobID uuid.UUID `json:"job_id"`
+	// Filename is the stored name of the PDF in storage, which replicas must share
+	Filename  string `json:"filename"`
+	RequestID string `json:"request_id,omitempty"`
+	// Version and Options are those of the job, which decide the extractor's settings and where its
+	// output is kept
+	Version int                       `json:"version,omitempty"`
+	Options *models.ExtractionOptions `json:"options,omitempty"`
+}
+
+// extractionOutcome is the result of an extraction, kept in the results cache
+type extractionOutcome struct {
+	Results map[string]interface{} `json:"results,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+func extractionResultKey(id uuid.UUID) string {
+	return "extraction:result:" + id.String()
+}
+
+// extract queues a recorded extraction job and waits for its outcome. It returns an error when it stops
+// waiting before the job finished; the job then goes on and its outcome can be looked up later.
+func (h *ExtractionHandler) extract(ctx context.Context, job *models.ExtractionJob) (*extractionOutcome, error) {
+	finished := make(chan struct{})
+	h.finished.Store(job.ID, finished)
+	defer h.finished.Delete(job.ID)
+
+	body, err := json.Marshal(extractionTask{
+		JobID:     job.ID,
+		Filename:  job.Filename,
+		RequestID: logging.RequestID(ctx),
+		Version:   job.Version,
+		Options:   job.Options,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := h.queue.Enqueue(ctx, extractionQueue, body); err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to queue extraction", "error", err)
+		outcome := &extractionOutcome{Error: fmt.Sprintf("failed to queue extraction: %v", err)}
+		h.finishExtractionJob(ctx, job.ID, nil, errors.New(outcome.Error))
+		return outcome, nil
+	}
+	h.updateQueueDepth(ctx)
+	slog.InfoContext(ctx, "📥 Extraction queued")
+
+	ticker := time.NewTicker(extractionPollInterval)
+	defer ticker.Stop()
+	for {
+		if outcome := h.extractionOutcome(ctx, job.ID); outcome != nil {
+			return outcome, nil
+		}
+		select {
+		case <-finished:
+		case <-ticker.C:
+		case <-h.jobs.Done():
+			return nil, errShuttingDown
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// extractionOutcome returns the outcome of a finished job, or nil while it is queued or running
+func (h *ExtractionHandler) extractionOutcome(ctx context.Context, id uuid.UUID) *extractionOutcome {
+	var outcome extractionOutcome
+	err := h.results.Get(ctx, extractionResultKey(id), &outcome)
+	if err == nil {
+		return &outcome
+	}
+	if !errors.Is(err, cache.ErrNotFound) {
+		slog.WarnContext(ctx, "⚠️ Failed to read extraction result", "error", err)
+	}
+	if h.db == nil {
+		return nil
+	}
+
+	// A job failed by RecoverJobs after its worker died has no outcome in the cache
+	var job models.ExtractionJob
+	if err := h.db.WithContext(ctx).Select("status", "error").First(&job, "id = ?", id).Error; err != nil {
+		return nil
+	}
+	switch job.Status {
+	case models.ExtractionStatusFailed:
+		return &extractionOutcome{Error: job.Error}
+	case models.ExtractionStatusCompleted:
+		return &extractionOutcome{Error: "the extraction results have expired"}
+	}
+	return nil
+}
+
+// work runs extraction jobs from the queue until the handler stops consuming
+func (h *ExtractionHandler) work() {
+	defer h.workers.Done()
+	for {
+		msg, err := h.queue.Dequeue(h.consuming, extractionQueue)
+		if h.consuming.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Error("❌ Failed to take extraction job from the queue", "error", err)
+			select {
+			case <-time.After(extractionPollInterval):
+			case <-h.consuming.Done():
+				return
+			}
+			continue
+		}
+
+		h.runExtractionTask(msg.Body)
+		if err := h.queue.Ack(context.Background(), msg); err != nil {
+			slog.Error("❌ Failed to acknowledge extraction job", "error", err)
+		}
+	}
+}
+
+// runExtractionTask runs one queued extraction and stores its outcome
+func (h *ExtractionHandler) runExtractionTask(body []byte) {
+	var task extractionTask
+	if err := json.Unmarshal(body, &task); err != nil {
+		slog.Error("❌ Dropping malformed extraction job", "error", err)
+		return
+	}
+	ctx := logging.WithJobID(logging.WithRequestID(context.Background(), task.RequestID), task.JobID.String())
+	h.updateQueueDepth(ctx)
+
+	// A message can be delivered twice; only the worker that moves the job out of queued runs it
+	if !h.claimExtractionJob(ctx, task.JobID) {
+		slog.InfoContext(ctx, "⏭️ Skipping extraction job that is no longer queued")
+		return
+	}
+
+	metrics.ExtractionRunning.Inc()
+	results, err := h.runPythonExtraction(ctx, task.Filename, task.Version, task.Options)
+	metrics.ExtractionRunning.Dec()
+
+	outcome := extractionOutcome{Results: results}
+	if err != nil {
+		outcome.Error = err.Error()
+	}
+	// Store the outcome before the status, so a waiter that sees the job finished finds it
+	if err := h.results.Set(ctx, extractionResultKey(task.JobID), outcome, extractionResultTTL); err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to store extraction result", "error", err)
+	}
+	h.finishExtractionJob(ctx, task.JobID, results, err)
+
+	if finished, ok := h.finished.LoadAndDelete(task.JobID); ok {
+		close(finished.(chan struct{}))
+	}
+}
+
+// watchConsumers sends this replica's heartbeats to the shared queue and requeues the jobs of workers
+// that stopped sending theirs, so a replica that dies does not leave its jobs queued until it restarts
+func (h *ExtractionHandler) watchConsumers(ctx context.Context) {
+	ticker := time.NewTicker(queue.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		if err := queue.Heartbeat(ctx, h.queue, extractionQueue); err != nil && ctx.Err() == nil {
+			slog.Warn("⚠️ Failed to send queue heartbeat", "error", err)
+		}
+		consumers, moved, err := queue.RecoverStale(ctx, h.queue, extractionQueue)
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("⚠️ Failed to requeue jobs of stopped workers", "error", err)
+		}
+		if moved > 0 {
+			slog.Warn("♻️ Requeued queue messages of stopped workers", "workers", consumers, "count", moved)
+			if err := h.requeueExtractionJobs(ctx, consumers); err != nil {
+				slog.Error("❌ Failed to requeue extraction jobs", "error", err)
+			}
+			h.updateQueueDepth(ctx)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// updateQueueDepth samples the number of extraction jobs waiting in the queue
+func (h *ExtractionHandler) updateQueueDepth(ctx context.Context) {
+	depth, err := h.queue.Len(ctx, extractionQueue)
+	if err != nil {
+		return
+	}
+	metrics.ExtractionQueueDepth.Set(float64(depth))
+}
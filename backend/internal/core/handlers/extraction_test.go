@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"workbench/internal/config"
+
+	"github.com/labstack/echo/v4"
+)
+
+// multipartBody is a form with one file field named file holding size bytes
+func multipartBody(t *testing.T, size int) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "upload.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(bytes.Repeat([]byte("x"), size))
+	form.WriteField("target", "carbonate")
+	form.Close()
+	return &body, form.FormDataContentType()
+}
+
+func TestUploadedFile(t *testing.T) {
+	const limit = config.ByteSize(1024)
+
+	tests := []struct {
+		name string
+		size int
+		// chunked hides the length, so only the capped body can stop the upload
+		chunked bool
+		wantErr error
+	}{
+		{name: "within the limit", size: 1000},
+		{name: "at the limit", size: 1024},
+		{name: "over the limit within the overhead", size: 2000, wantErr: errUploadTooLarge},
+		{name: "declared length over the cap", size: 2 << 20, wantErr: errUploadTooLarge},
+		{name: "streamed body over the cap", size: 2 << 20, chunked: true, wantErr: errUploadTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, contentType := multipartBody(t, tt.size)
+			var reader io.Reader = body
+			if tt.chunked {
+				reader = io.MultiReader(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/extraction/process-pdf", reader)
+			req.Header.Set(echo.HeaderContentType, contentType)
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+
+			file, err := uploadedFile(c, "file", limit)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("uploadedFile = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("uploadedFile: %v", err)
+			}
+			if file.Size != int64(tt.size) {
+				t.Errorf("size = %d, want %d", file.Size, tt.size)
+			}
+			// The other fields of the form stay readable
+			if target := c.FormValue("target"); target != "carbonate" {
+				t.Errorf("target = %q, want carbonate", target)
+			}
+		})
+	}
+
+	t.Run("no file", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/extraction/process-pdf", strings.NewReader(""))
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+		if _, err := uploadedFile(c, "file", limit); err == nil || errors.Is(err, errUploadTooLarge) {
+			t.Errorf("uploadedFile = %v, want a missing file error", err)
+		}
+	})
+}
@@ -37,10 +37,12 @@ func GetFieldMappings() map[string]string {
 		"replacement, undiff": "replacement", "replacement undiff": "replacement",
 		
 		// Coordinates
-		"lat": "latitude", "latitude": "latitude", "lat.": "latitude", "north": "latitude", "north_lat": "latitude", "n_lat": "latitude", "y_coord": "latitude", "y_coordinate": "latitude",
-		"lon": "longitude", "longitude": "longitude", "long.": "longitude", "east": "longitude", "east_lon": "longitude", "e_lon": "longitude", "x_coord": "longitude", "x_coordinate": "longitude",
+		"lat": "latitude", "latitude": "latitude", "lat.": "latitude", "north": "latitude", "north_lat": "latitude", "n_lat": "latitude",
+		"lon": "longitude", "longitude": "longitude", "long.": "longitude", "east": "longitude", "east_lon": "longitude", "e_lon": "longitude",
 		"coord": "latitude", "coordinate": "latitude", "coordinates": "latitude", "position": "latitude",
-		"x": "longitude", "y": "latitude", "easting": "longitude", "northing": "latitude",
+		// Grid coordinates are converted to latitude/longitude using the CRS given with the import or the well
+		"x": "easting", "x_coord": "easting", "x_coordinate": "easting", "easting": "easting",
+		"y": "northing", "y_coord": "northing", "y_coordinate": "northing", "northing": "northing",
 		
 		// Geological information
 		"formation": "formation_name", "form": "formation_name", "unit": "formation_name", "formation_name": "formation_name", "formation name": "formation_name", "geologic_formation": "formation_name",
@@ -0,0 +1,462 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"workbench/internal/core/models"
+	"workbench/internal/database"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// formationTopHeaders maps normalized CSV headers to formation top fields
+var formationTopHeaders = map[string]string{
+	"uwi":            "uwi",
+	"well_uwi":       "uwi",
+	"formation":      "formation_name",
+	"formation_name": "formation_name",
+	"name":           "formation_name",
+	"top_name":       "formation_name",
+	"reservoir":      "reservoir_name",
+	"reservoir_name": "reservoir_name",
+	"zone":           "reservoir_name",
+	"top":            "top_depth",
+	"top_depth":      "top_depth",
+	"depth":          "top_depth",
+	"base":           "base_depth",
+	"base_depth":     "base_depth",
+	"bottom":         "base_depth",
+	"bottom_depth":   "base_depth",
+	"datum":          "datum",
+	"depth_datum":    "datum",
+	"unit":           "unit",
+	"units":          "unit",
+	"depth_unit":     "unit",
+	"source":         "source",
+}
+
+// GetFormationTops lists a well's formation tops, shallowest first
+func (h *WellHandler) GetFormationTops(c echo.Context) error {
+	well, err := h.findWell(c.Param("id"))
+	if err != nil {
+		return wellLookupError(c, err)
+	}
+
+	var tops []models.FormationTop
+	if err := h.db.Where("well_id = ?", well.ID).Order("datum, unit, top_depth").Find(&tops).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve formation tops",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"well_id": well.ID,
+		"data":    tops,
+	})
+}
+
+// CreateFormationTop adds a formation top to a well and reassigns the well's samples
+func (h *WellHandler) CreateFormationTop(c echo.Context) error {
+	well, err := h.findWell(c.Param("id"))
+	if err != nil {
+		return wellLookupError(c, err)
+	}
+
+	var top models.FormationTop
+	if err := c.Bind(&top); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+	if err := top.Normalize(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	top.ID = 0
+	top.WellID = well.ID
+
+	if err := h.db.Create(&top).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create formation top",
+		})
+	}
+
+	return h.respondFormationTop(c, http.StatusCreated, well, &top)
+}
+
+// UpdateFormationTop replaces a formation top and reassigns the well's samples
+func (h *WellHandler) UpdateFormationTop(c echo.Context) error {
+	well, top, err := h.findFormationTop(c)
+	if err != nil {
+		return formationTopLookupError(c, err)
+	}
+
+	var updateData models.FormationTop
+	if err := c.Bind(&updateData); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+	if err := updateData.Normalize(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	top.FormationName = updateData.FormationName
+	top.ReservoirName = updateData.ReservoirName
+	top.TopDepth = updateData.TopDepth
+	top.BaseDepth = updateData.BaseDepth
+	top.Datum = updateData.Datum
+	top.Unit = updateData.Unit
+	top.Source = updateData.Source
+
+	if err := h.db.Save(top).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update formation top",
+		})
+	}
+
+	return h.respondFormationTop(c, http.StatusOK, well, top)
+}
+
+// DeleteFormationTop removes a formation top and reassigns the well's samples
+func (h *WellHandler) DeleteFormationTop(c echo.Context) error {
+	well, top, err := h.findFormationTop(c)
+	if err != nil {
+		return formationTopLookupError(c, err)
+	}
+
+	if err := h.db.Delete(top).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete formation top",
+		})
+	}
+
+	report, err := database.AssignFormations(h.db, &well.ID)
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "❌ Failed to reassign formations", "well", well.UWI, "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Formation top deleted but samples could not be reassigned",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":     "Formation top deleted successfully",
+		"assignments": report,
+	})
+}
+
+// ImportFormationTops loads formation tops from a CSV file. Posted to a well, every row belongs to it;
+// posted to /wells/formation-tops/import, each row names its well in a UWI column. With replace=true
+// the existing tops of every well in the file are removed first.
+func (h *WellHandler) ImportFormationTops(c echo.Context) error {
+	var fixedWell *models.Well
+	if id := c.Param("id"); id != "" {
+		well, err := h.findWell(id)
+		if err != nil {
+			return wellLookupError(c, err)
+		}
+		fixedWell = well
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "No file uploaded",
+		})
+	}
+	src, err := file.Open()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to open uploaded file",
+		})
+	}
+	defer src.Close()
+
+	replace, _ := strconv.ParseBool(c.FormValue("replace"))
+	dryRun, _ := strconv.ParseBool(c.FormValue("dry_run"))
+
+	reader := csv.NewReader(src)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Failed to read CSV header",
+		})
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		if field, ok := formationTopHeaders[formationTopHeaderKey(name)]; ok {
+			if _, seen := columns[field]; !seen {
+				columns[field] = i
+			}
+		}
+	}
+	_, hasFormation := columns["formation_name"]
+	_, hasTop := columns["top_depth"]
+	_, hasUWI := columns["uwi"]
+	if !hasFormation || !hasTop || (fixedWell == nil && !hasUWI) {
+		required := "formation and top columns are required"
+		if fixedWell == nil {
+			required = "uwi, formation and top columns are required"
+		}
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": required,
+		})
+	}
+
+	// Parse every row before writing, so the well list is known for replace
+	type parsedTop struct {
+		index int // into results
+		top   models.FormationTop
+	}
+	var parsed []parsedTop
+	var results []RowResult
+	wells := map[string]*models.Well{}
+	if fixedWell != nil {
+		wells[fixedWell.UWI] = fixedWell
+	}
+	for rowNumber := 2; ; rowNumber++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		result := RowResult{Row: rowNumber, Target: "formation_tops"}
+		if err != nil {
+			result.Status = RowStatusFailed
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+		cell := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		top, err := formationTopFromCells(cell)
+		if err == nil && cell("formation_name") == "" && cell("top_depth") == "" {
+			result.Status = RowStatusSkipped
+			results = append(results, result)
+			continue
+		}
+
+		well := fixedWell
+		if err == nil && well == nil {
+			uwi := cell("uwi")
+			if well = wells[uwi]; well == nil {
+				var found models.Well
+				lookupErr := h.db.Where("uwi = ?", uwi).Take(&found).Error
+				switch {
+				case uwi == "":
+					err = fmt.Errorf("uwi is required")
+				case errors.Is(lookupErr, gorm.ErrRecordNotFound):
+					err = fmt.Errorf("no well with UWI %q", uwi)
+				case lookupErr != nil:
+					err = lookupErr
+				default:
+					well = &found
+					wells[uwi] = well
+				}
+			}
+		}
+		if err != nil {
+			result.Status = RowStatusFailed
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		top.WellID = well.ID
+		results = append(results, result)
+		parsed = append(parsed, parsedTop{index: len(results) - 1, top: top})
+	}
+
+	if !dryRun {
+		err = h.db.Transaction(func(tx *gorm.DB) error {
+			if replace {
+				for _, well := range wells {
+					if err := tx.Where("well_id = ?", well.ID).Delete(&models.FormationTop{}).Error; err != nil {
+						return err
+					}
+				}
+			}
+			for i := range parsed {
+				if err := tx.Create(&parsed[i].top).Error; err != nil {
+					return fmt.Errorf("row %d: %w", results[parsed[i].index].Row, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			slog.ErrorContext(c.Request().Context(), "❌ Formation top import failed", "error", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to import formation tops",
+			})
+		}
+	}
+	for _, p := range parsed {
+		result := &results[p.index]
+		result.ID = p.top.ID
+		if dryRun {
+			result.Status = RowStatusValid
+		} else {
+			result.Status = RowStatusInserted
+		}
+	}
+
+	response := map[string]interface{}{
+		"summary": summarizeRowResults(results),
+		"rows":    results,
+		"dry_run": dryRun,
+	}
+	if !dryRun {
+		assignments := &database.FormationReport{Straddling: []database.FormationStraddle{}}
+		for _, well := range wells {
+			report, err := database.AssignFormations(h.db, &well.ID)
+			if err != nil {
+				slog.ErrorContext(c.Request().Context(), "❌ Failed to reassign formations", "well", well.UWI, "error", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": "Formation tops imported but samples could not be reassigned",
+				})
+			}
+			assignments.Wells += report.Wells
+			assignments.SamplesAssigned += report.SamplesAssigned
+			assignments.SamplesCleared += report.SamplesCleared
+			assignments.Straddling = append(assignments.Straddling, report.Straddling...)
+		}
+		response["assignments"] = assignments
+		slog.InfoContext(c.Request().Context(), "🪨 Imported formation tops", "tops", len(parsed), "wells", len(wells))
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// AssignFormations assigns formation and reservoir from formation tops to the samples of one well,
+// or of every well when no ID is given
+func (h *WellHandler) AssignFormations(c echo.Context) error {
+	var wellID *uint
+	if id := c.Param("id"); id != "" {
+		well, err := h.findWell(id)
+		if err != nil {
+			return wellLookupError(c, err)
+		}
+		wellID = &well.ID
+	}
+
+	report, err := database.AssignFormations(h.db, wellID)
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "❌ Formation assignment failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to assign formations",
+		})
+	}
+
+	slog.InfoContext(c.Request().Context(), "🪨 Formations assigned",
+		"samples", report.SamplesAssigned, "wells", report.Wells, "straddling", len(report.Straddling))
+	return c.JSON(http.StatusOK, report)
+}
+
+// respondFormationTop reassigns the well's samples after a top changed and returns the top
+func (h *WellHandler) respondFormationTop(c echo.Context, status int, well *models.Well, top *models.FormationTop) error {
+	report, err := database.AssignFormations(h.db, &well.ID)
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "❌ Failed to reassign formations", "well", well.UWI, "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Formation top saved but samples could not be reassigned",
+		})
+	}
+
+	return c.JSON(status, map[string]interface{}{
+		"formation_top": top,
+		"assignments":   report,
+	})
+}
+
+// findFormationTop loads the well and one of its formation tops from the route
+func (h *WellHandler) findFormationTop(c echo.Context) (*models.Well, *models.FormationTop, error) {
+	well, err := h.findWell(c.Param("id"))
+	if err != nil {
+		return nil, nil, err
+	}
+	topID, err := strconv.ParseUint(c.Param("topId"), 10, 32)
+	if err != nil {
+		return nil, nil, errInvalidID
+	}
+
+	var top models.FormationTop
+	if err := h.db.Where("id = ? AND well_id = ?", uint(topID), well.ID).First(&top).Error; err != nil {
+		return nil, nil, err
+	}
+	return well, &top, nil
+}
+
+// formationTopLookupError answers a failed findFormationTop
+func formationTopLookupError(c echo.Context, err error) error {
+	switch err {
+	case errInvalidID:
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid ID",
+		})
+	case gorm.ErrRecordNotFound:
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Well or formation top not found",
+		})
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve formation top",
+		})
+	}
+}
+
+// formationTopFromCells builds a formation top from the cells of a CSV row
+func formationTopFromCells(cell func(field string) string) (models.FormationTop, error) {
+	top := models.FormationTop{
+		FormationName: cell("formation_name"),
+		ReservoirName: cell("reservoir_name"),
+		Datum:         cell("datum"),
+		Unit:          cell("unit"),
+		Source:        cell("source"),
+	}
+	if cell("formation_name") == "" && cell("top_depth") == "" {
+		return top, nil
+	}
+
+	depth, err := strconv.ParseFloat(cell("top_depth"), 64)
+	if err != nil {
+		return top, fmt.Errorf("top depth %q is not a number", cell("top_depth"))
+	}
+	top.TopDepth = depth
+	if raw := cell("base_depth"); raw != "" {
+		base, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return top, fmt.Errorf("base depth %q is not a number", raw)
+		}
+		top.BaseDepth = &base
+	}
+	return top, top.Normalize()
+}
+
+// formationTopHeaderKey reduces a CSV header such as "Top Depth (m)" to "top_depth"
+func formationTopHeaderKey(header string) string {
+	header = strings.ToLower(header)
+	if i := strings.Index(header, "("); i > 0 {
+		header = header[:i]
+	}
+	return strings.Join(strings.FieldsFunc(header, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "_")
+}
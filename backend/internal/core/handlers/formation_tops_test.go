@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"workbench/internal/database"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestAssignFormations(t *testing.T) {
+	db, script := newScriptedDB(t)
+	script.query(`^SELECT \* FROM "wells"`, []string{"id", "uwi"}, []driver.Value{int64(7), "UWI-7"})
+	// Upper runs down to Lower, whose base is at 200 m
+	script.query(`^SELECT \* FROM "formation_tops"`,
+		[]string{"id", "well_id", "formation_name", "reservoir_name", "top_depth", "base_depth", "datum", "unit"},
+		[]driver.Value{int64(1), int64(7), "Upper", "R1", 100.0, nil, "MDDF", "m"},
+		[]driver.Value{int64(2), int64(7), "Lower", "R2", 150.0, 200.0, "MDDF", "m"})
+	sampleColumns := []string{"id", "top_depth_mmddf", "bottom_depth_mmddf", "formation_name", "reservoir_name", "formation_assignment"}
+	script.query(`FROM "petrography_carbonate"`, sampleColumns,
+		// Within Upper
+		[]driver.Value{int64(11), 110.0, 120.0, "", "", ""},
+		// Across the top of Lower, mostly below it
+		[]driver.Value{int64(12), 140.0, 170.0, "", "", ""},
+		// Below every base
+		[]driver.Value{int64(13), 250.0, nil, "", "", ""})
+	script.query(`FROM "petrography_clastic"`, sampleColumns)
+	script.exec(`^UPDATE "petrography_carbonate"`, 1)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/wells/7/assign-formations", nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("7")
+	if err := NewWellHandler(db).AssignFormations(c); err != nil {
+		t.Fatalf("AssignFormations: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s\n%s", rec.Code, rec.Body, script.log())
+	}
+
+	var report database.FormationReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("body: %v", err)
+	}
+	if report.Wells != 1 || report.SamplesAssigned != 2 || report.SamplesCleared != 0 {
+		t.Errorf("report = %+v, want 1 well and 2 samples assigned", report)
+	}
+	if len(report.Straddling) != 1 {
+		t.Fatalf("straddling = %+v, want sample 12", report.Straddling)
+	}
+	straddle := report.Straddling[0]
+	if straddle.Table != "petrography_carbonate" || straddle.ID != 12 || straddle.WellID != 7 ||
+		straddle.Formation != "Lower" || fmt.Sprint(straddle.Crossed) != "[Upper Lower]" {
+		t.Errorf("straddling sample = %+v, want 12 in Lower crossing [Upper Lower]", straddle)
+	}
+
+	// The columns are written in name order: formation_assignment, formation_name, reservoir_name, id
+	var updates []string
+	for _, statement := range script.ran(`^UPDATE "petrography_carbonate"`) {
+		updates = append(updates, fmt.Sprint(statement.args))
+	}
+	want := []string{"[assigned Upper R1 11]", "[straddles Lower R2 12]"}
+	if fmt.Sprint(updates) != fmt.Sprint(want) {
+		t.Errorf("updates = %v, want %v", updates, want)
+	}
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"workbench/internal/cache"
+	"workbench/internal/core/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MIMEApplicationGeoJSON is the content type of GeoJSON documents
+const MIMEApplicationGeoJSON = "application/geo+json"
+
+// clusterRadiusPx is the on-screen size of a cluster cell, in pixels of a 256px web map tile
+const clusterRadiusPx = 60
+
+// maxClusterZoom is the zoom level from which wells are never clustered
+const maxClusterZoom = 22
+
+// wellSummary is a well with the sample statistics shown on the map
+type wellSummary struct {
+	ID                uint
+	UWI               string
+	WellNameFieldName string
+	Country           string
+	Basin             string
+	Latitude          float64
+	Longitude         float64
+	CarbonateCount    int64
+	ClasticCount      int64
+	PorositySum       float64
+	PorosityCount     int64
+	PermeabilitySum   float64
+	PermeabilityCount int64
+}
+
+// GeoJSONFeature is a point feature of a GeoJSON FeatureCollection
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id,omitempty"`
+	Geometry   GeoJSONPoint           `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSONPoint is a GeoJSON point geometry; coordinates are longitude, latitude
+type GeoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// sampleStatsSubquery aggregates one sample table per well
+func sampleStatsSubquery(table string) string {
+	return fmt.Sprintf(`SELECT well_id,
+		COUNT(*) AS samples,
+		SUM(visible_porosity_percent) AS porosity_sum,
+		COUNT(visible_porosity_percent) AS porosity_count,
+		SUM(permeability_md) AS permeability_sum,
+		COUNT(permeability_md) AS permeability_count
+	FROM %s WHERE deleted_at IS NULL AND well_id IS NOT NULL GROUP BY well_id`, table)
+}
+
+// GetWellsGeoJSON returns wells as a GeoJSON FeatureCollection with sample counts and average porosity
+// and permeability. It accepts the list filters (including bbox, point/radius_km and polygon), type to
+// keep wells with carbonate or clastic samples, include_empty, and zoom to cluster nearby wells.
+func (h *WellHandler) GetWellsGeoJSON(c echo.Context) error {
+	listQuery, err := parseListQuery(c, h.db, &models.Well{})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	query := h.db.Table("wells").Select(`wells.id, wells.uwi, wells.well_name_field_name, wells.country, wells.basin,
+		wells.latitude, wells.longitude,
+		COALESCE(cb.samples, 0) AS carbonate_count,
+		COALESCE(cl.samples, 0) AS clastic_count,
+		COALESCE(cb.porosity_sum, 0) + COALESCE(cl.porosity_sum, 0) AS porosity_sum,
+		COALESCE(cb.porosity_count, 0) + COALESCE(cl.porosity_count, 0) AS porosity_count,
+		COALESCE(cb.permeability_sum, 0) + COALESCE(cl.permeability_sum, 0) AS permeability_sum,
+		COALESCE(cb.permeability_count, 0) + COALESCE(cl.permeability_count, 0) AS permeability_count`).
+		Joins("LEFT JOIN (" + sampleStatsSubquery("petrography_carbonate") + ") cb ON cb.well_id = wells.id").
+		Joins("LEFT JOIN (" + sampleStatsSubquery("petrography_clastic") + ") cl ON cl.well_id = wells.id").
+		Where("wells.latitude IS NOT NULL AND wells.longitude IS NOT NULL").
+		Scopes(listQuery.Where())
+
+	switch strings.ToLower(c.QueryParam("type")) {
+	case "":
+		if include, _ := strconv.ParseBool(c.QueryParam("include_empty")); !include {
+			query = query.Where("COALESCE(cb.samples, 0) + COALESCE(cl.samples, 0) > 0")
+		}
+	case TargetCarbonate:
+		query = query.Where("cb.samples > 0")
+	case TargetClastic:
+		query = query.Where("cl.samples > 0")
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "type must be carbonate or clastic",
+		})
+	}
+
+	// The summaries depend on every parameter but zoom, which only clusters them
+	key := url.Values{}
+	for name, values := range c.QueryParams() {
+		if name != "zoom" {
+			key[name] = values
+		}
+	}
+	wells, err := cache.Remember(c.Request().Context(), cache.Default(), cache.Wells, "geojson:"+key.Encode(), func() ([]wellSummary, error) {
+		var wells []wellSummary
+		err := query.Order("wells.id").Scan(&wells).Error
+		return wells, err
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve wells",
+		})
+	}
+
+	var features []GeoJSONFeature
+	if zoom := c.QueryParam("zoom"); zoom != "" {
+		level, err := strconv.Atoi(zoom)
+		if err != nil || level < 0 || level > maxClusterZoom {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("zoom must be between 0 and %d", maxClusterZoom),
+			})
+		}
+		features = clusterWells(wells, level)
+	} else {
+		features = make([]GeoJSONFeature, 0, len(wells))
+		for i := range wells {
+			features = append(features, wellFeature(&wells[i]))
+		}
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationGeoJSON)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+}
+
+// wellFeature renders a single well
+func wellFeature(w *wellSummary) GeoJSONFeature {
+	properties := summaryProperties(w.CarbonateCount, w.ClasticCount, w.PorositySum, w.PorosityCount, w.PermeabilitySum, w.PermeabilityCount)
+	properties["cluster"] = false
+	properties["well_id"] = w.ID
+	properties["uwi"] = w.UWI
+	properties["well_name"] = w.WellNameFieldName
+	properties["country"] = w.Country
+	properties["basin"] = w.Basin
+
+	return GeoJSONFeature{
+		Type:       "Feature",
+		ID:         fmt.Sprintf("well-%d", w.ID),
+		Geometry:   GeoJSONPoint{Type: "Point", Coordinates: [2]float64{w.Longitude, w.Latitude}},
+		Properties: properties,
+	}
+}
+
+// clusterWells groups wells into square grid cells sized for the zoom level. A cell holding one well
+// is returned as that well; larger cells become a cluster at the mean position of their wells.
+func clusterWells(wells []wellSummary, zoom int) []GeoJSONFeature {
+	cell := clusterRadiusPx * 360 / (256 * math.Pow(2, float64(zoom)))
+
+	type cellKey struct{ x, y int64 }
+	cells := map[cellKey][]*wellSummary{}
+	var order []cellKey
+	for i := range wells {
+		key := cellKey{
+			x: int64(math.Floor(wells[i].Longitude / cell)),
+			y: int64(math.Floor(wells[i].Latitude / cell)),
+		}
+		if _, ok := cells[key]; !ok {
+			order = append(order, key)
+		}
+		cells[key] = append(cells[key], &wells[i])
+	}
+
+	features := make([]GeoJSONFeature, 0, len(order))
+	for _, key := range order {
+		members := cells[key]
+		if len(members) == 1 {
+			features = append(features, wellFeature(members[0]))
+			continue
+		}
+
+		var total wellSummary
+		var lon, lat float64
+		ids := make([]uint, 0, len(members))
+		for _, w := range members {
+			lon += w.Longitude
+			lat += w.Latitude
+			total.CarbonateCount += w.CarbonateCount
+			total.ClasticCount += w.ClasticCount
+			total.PorositySum += w.PorositySum
+			total.PorosityCount += w.PorosityCount
+			total.PermeabilitySum += w.PermeabilitySum
+			total.PermeabilityCount += w.PermeabilityCount
+			ids = append(ids, w.ID)
+		}
+
+		properties := summaryProperties(total.CarbonateCount, total.ClasticCount, total.PorositySum, total.PorosityCount, total.PermeabilitySum, total.PermeabilityCount)
+		properties["cluster"] = true
+		properties["point_count"] = len(members)
+		properties["well_ids"] = ids
+
+		features = append(features, GeoJSONFeature{
+			Type: "Feature",
+			ID:   fmt.Sprintf("cluster-%d-%d-%d", zoom, key.x, key.y),
+			Geometry: GeoJSONPoint{
+				Type:        "Point",
+				Coordinates: [2]float64{lon / float64(len(members)), lat / float64(len(members))},
+			},
+			Properties: properties,
+		})
+	}
+	return features
+}
+
+// summaryProperties renders sample counts, the dominant sample type and the averages
+func summaryProperties(carbonate, clastic int64, porositySum float64, porosityCount int64, permeabilitySum float64, permeabilityCount int64) map[string]interface{} {
+	dominant := "none"
+	switch {
+	case carbonate > 0 && clastic > 0:
+		dominant = "mixed"
+	case carbonate > 0:
+		dominant = TargetCarbonate
+	case clastic > 0:
+		dominant = TargetClastic
+	}
+
+	properties := map[string]interface{}{
+		"carbonate_count":              carbonate,
+		"clastic_count":                clastic,
+		"sample_count":                 carbonate + clastic,
+		"dominant_type":                dominant,
+		"avg_visible_porosity_percent": nil,
+		"avg_permeability_md":          nil,
+	}
+	if porosityCount > 0 {
+		properties["avg_visible_porosity_percent"] = math.Round(porositySum/float64(porosityCount)*100) / 100
+	}
+	if permeabilityCount > 0 {
+		properties["avg_permeability_md"] = math.Round(permeabilitySum/float64(permeabilityCount)*100) / 100
+	}
+	return properties
+}
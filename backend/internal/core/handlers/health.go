@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"workbench/internal/cache"
+	"workbench/internal/config"
+	"workbench/internal/database"
+	"workbench/internal/queue"
+	"workbench/internal/storage"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Check statuses
+const (
+	CheckOK   = "ok"
+	CheckFail = "fail"
+)
+
+// readinessTimeout bounds a whole readiness probe
+const readinessTimeout = 10 * time.Second
+
+// pythonCheckTTL is how long the result of the Python check is reused; importing Camelot takes
+// seconds, too slow to repeat on every probe
+const pythonCheckTTL = time.Minute
+
+// CheckResult is the outcome of one readiness check
+type CheckResult struct {
+	Status    string      `json:"status"`
+	LatencyMS float64     `json:"latency_ms"`
+	Error     string      `json:"error,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+	// CheckedAt is set when the result is reused from an earlier probe
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+}
+
+type HealthHandler struct {
+	db      *gorm.DB
+	cfg     *config.Config
+	started time.Time
+	// draining is set once a shutdown has started
+	draining atomic.Bool
+
+	// python caches the Python check
+	pythonMu        sync.Mutex
+	python          CheckResult
+	pythonCheckedAt time.Time
+}
+
+func NewHealthHandler(db *gorm.DB, cfg *config.Config) *HealthHandler {
+	return &HealthHandler{db: db, cfg: cfg, started: time.Now()}
+}
+
+// HealthRoutes registers the probes at the root of the server, outside the API
+func (h *HealthHandler) HealthRoutes(e *echo.Echo) {
+	e.GET("/livez", h.Livez)
+	e.GET("/readyz", h.Readyz)
+	// Kept for existing clients; it only tells that the process is serving
+	e.GET("/health", h.Livez)
+}
+
+// Livez reports that the process is up and serving requests. It checks no dependency, so a broken
+// database makes the instance unready, not restarted.
+func (h *HealthHandler) Livez(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":         CheckOK,
+		"message":        "Server is running",
+		"uptime_seconds": int64(time.Since(h.started).Seconds()),
+	})
+}
+
+// Drain makes the instance unready, so load balancers stop routing to it while it shuts down
+func (h *HealthHandler) Drain() {
+	h.draining.Store(true)
+}
+
+// Readyz runs every dependency check and answers 503 when any fails, so no uploads are routed to
+// an instance that cannot process them. Once a shutdown has started it answers 503 without checking.
+func (h *HealthHandler) Readyz(c echo.Context) error {
+	if h.draining.Load() {
+		return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
+			"status":   CheckFail,
+			"draining": true,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) CheckResult{
+		"database":   h.checkDatabase,
+		"migrations": h.checkMigrations,
+		"python":     h.checkPython,
+		"storage":    h.checkStorage,
+	}
+	if h.cfg.Redis.Enabled {
+		checks["redis"] = h.checkRedis
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]CheckResult, len(checks))
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) CheckResult) {
+			defer wg.Done()
+			result := check(ctx)
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	status, code := CheckOK, http.StatusOK
+	for _, result := range results {
+		if result.Status != CheckOK {
+			status, code = CheckFail, http.StatusServiceUnavailable
+		}
+	}
+	return c.JSON(code, map[string]interface{}{
+		"status": status,
+		"checks": results,
+	})
+}
+
+// timed runs fn and wraps its error and details into a CheckResult with its latency
+func timed(fn func() (interface{}, error)) CheckResult {
+	start := time.Now()
+	details, err := fn()
+	result := CheckResult{
+		Status:    CheckOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   details,
+	}
+	if err != nil {
+		result.Status = CheckFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// checkDatabase pings the database within the probe's deadline
+func (h *HealthHandler) checkDatabase(ctx context.Context) CheckResult {
+	return timed(func() (interface{}, error) {
+		if h.db == nil {
+			return nil, fmt.Errorf("database not initialized")
+		}
+		sqlDB, err := h.db.DB()
+		if err != nil {
+			return nil, err
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return nil, err
+		}
+		stats := sqlDB.Stats()
+		return map[string]int{
+			"open_connections": stats.OpenConnections,
+			"in_use":           stats.InUse,
+			"idle":             stats.Idle,
+		}, nil
+	})
+}
+
+// checkRedis pings the Redis holding the shared queue and cache, and reports the extraction backlog
+func (h *HealthHandler) checkRedis(ctx context.Context) CheckResult {
+	return timed(func() (interface{}, error) {
+		if err := cache.Default().Ping(ctx); err != nil {
+			return nil, err
+		}
+		queued, err := queue.Default().Len(ctx, extractionQueue)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]int64{"queued_extractions": queued}, nil
+	})
+}
+
+// checkMigrations verifies that every migration of this build is applied
+func (h *HealthHandler) checkMigrations(ctx context.Context) CheckResult {
+	return timed(func() (interface{}, error) {
+		if h.db == nil {
+			return nil, fmt.Errorf("database not initialized")
+		}
+		states, err := database.MigrationStatus(h.db.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		var current, expected int64
+		var pending []string
+		for _, state := range states {
+			if state.Applied && state.Version > current {
+				current = state.Version
+			}
+			if !state.Missing && state.Version > expected {
+				expected = state.Version
+			}
+			if !state.Applied {
+				pending = append(pending, fmt.Sprintf("%d_%s", state.Version, state.Name))
+			}
+		}
+		details := map[string]interface{}{
+			"current_version":  current,
+			"expected_version": expected,
+		}
+		if len(pending) > 0 {
+			return details, fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+		}
+		return details, nil
+	})
+}
+
+// checkPython verifies that the interpreter runs and imports the extractor's libraries. The result
+// is reused for pythonCheckTTL, unless the probe was cancelled or timed out before Python answered.
+func (h *HealthHandler) checkPython(ctx context.Context) CheckResult {
+	h.pythonMu.Lock()
+	defer h.pythonMu.Unlock()
+
+	if !h.pythonCheckedAt.IsZero() && time.Since(h.pythonCheckedAt) < pythonCheckTTL {
+		result := h.python
+		checkedAt := h.pythonCheckedAt
+		result.CheckedAt = &checkedAt
+		return result
+	}
+
+	extraction := h.cfg.Extraction
+	result := timed(func() (interface{}, error) {
+		cmd := exec.CommandContext(ctx, extraction.PythonCommand(), "-c", "import camelot, pandas; print(camelot.__version__)")
+		cmd.Dir = extraction.Dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("%s cannot import camelot: %v: %s", extraction.Python, err, lastLines(string(output), 1))
+		}
+		return map[string]string{
+			"interpreter": extraction.Python,
+			"camelot":     strings.TrimSpace(string(output)),
+		}, nil
+	})
+	if ctx.Err() != nil {
+		return result
+	}
+	h.python, h.pythonCheckedAt = result, time.Now()
+	return result
+}
+
+// checkStorage verifies that the document storage is reachable, and that every directory the server
+// writes to, extraction scratch directories included, is writable and has at least
+// storage.min_free_space free
+func (h *HealthHandler) checkStorage(ctx context.Context) CheckResult {
+	dirs := []string{h.cfg.Upload.Dir, os.TempDir()}
+	if h.cfg.Storage.Backend == config.StorageLocal {
+		dirs = append(dirs, h.cfg.Storage.LocalDir)
+	}
+
+	return timed(func() (interface{}, error) {
+		details := map[string]interface{}{}
+		var problems []string
+		if blobs := storage.Default(); blobs != nil {
+			if err := blobs.Ping(ctx); err != nil {
+				problems = append(problems, fmt.Sprintf("%s storage: %v", h.cfg.Storage.Backend, err))
+			}
+		}
+		for _, dir := range dirs {
+			free, err := checkDirectory(dir)
+			if err != nil {
+				problems = append(problems, err.Error())
+				continue
+			}
+			details[dir] = map[string]interface{}{"free_bytes": free}
+			if free < uint64(h.cfg.Storage.MinFreeSpace) {
+				problems = append(problems, fmt.Sprintf("%s has %s free, below %s", dir, config.ByteSize(free), h.cfg.Storage.MinFreeSpace))
+			}
+		}
+		if len(problems) > 0 {
+			return details, fmt.Errorf("%s", strings.Join(problems, "; "))
+		}
+		return details, nil
+	})
+}
+
+// checkDirectory creates dir if needed, writes and removes a probe file in it and returns the
+// free space of its filesystem
+func checkDirectory(dir string) (uint64, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("%s cannot be created: %v", dir, err)
+	}
+	probe, err := os.CreateTemp(dir, ".readyz-*")
+	if err != nil {
+		return 0, fmt.Errorf("%s is not writable: %v", dir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	free, err := freeSpace(filepath.Clean(dir))
+	if err != nil {
+		return 0, fmt.Errorf("free space of %s unknown: %v", dir, err)
+	}
+	return free, nil
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"workbench/internal/config"
+)
+
+func TestCheckDatabase(t *testing.T) {
+	db, script := newScriptedDB(t)
+	h := NewHealthHandler(db, config.Default())
+
+	if result := h.checkDatabase(context.Background()); result.Status != CheckOK {
+		t.Fatalf("status = %s: %s", result.Status, result.Error)
+	}
+
+	// A database slow to answer fails the probe at its deadline
+	script.pingDelay = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	result := h.checkDatabase(ctx)
+	if result.Status != CheckFail || !strings.Contains(result.Error, "deadline exceeded") {
+		t.Errorf("status = %s, error = %q, want a failure at the deadline", result.Status, result.Error)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("check took %s, past the probe's deadline", elapsed)
+	}
+
+	if result := NewHealthHandler(nil, config.Default()).checkDatabase(context.Background()); result.Status != CheckFail {
+		t.Errorf("status without a database = %s, want fail", result.Status)
+	}
+}
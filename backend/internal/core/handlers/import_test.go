@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"workbench/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// importSheet has three data rows, sheet rows 2 to 4
+const importSheet = "well name,top depth,lithology\nA-1,100,limestone\nA-1,110,dolostone\nA-1,120,limestone\n"
+
+// newImportHandler is a handler with a CSV import of importSheet whose first processed rows are committed
+func newImportHandler(t *testing.T, processed int) (*ExtractionHandler, *scriptedDB, uuid.UUID) {
+	t.Helper()
+	h, script := newScriptedHandler(t)
+	id := uuid.New()
+	key := storage.ImportKey(id.String() + ".csv")
+	if err := h.blobs.Put(context.Background(), key, strings.NewReader(importSheet), int64(len(importSheet)), "text/csv"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	now := time.Now()
+	script.query(`FROM "import_jobs"`,
+		[]string{"id", "filename", "format", "target", "stored_path", "status", "processed_rows", "created_at", "updated_at"},
+		[]driver.Value{id.String(), "samples.csv", "csv", "carbonate", key, "in_progress", int64(processed), now, now})
+	script.query(`^SELECT \* FROM "vocabulary_`, nil)
+	script.query(`^INSERT INTO "vocabulary_unknowns"`, []string{"id"})
+	script.query(`^INSERT INTO "petrography_carbonate"`, []string{"id"}, []driver.Value{int64(1)})
+	script.exec(`^(SAVEPOINT|UPDATE "import_jobs")`, 1)
+	return h, script, id
+}
+
+// resumeImport calls ResumeImport with the form and returns the status and decoded body
+func resumeImport(t *testing.T, h *ExtractionHandler, id uuid.UUID, form url.Values) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/import/"+id.String()+"/resume", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id.String())
+
+	if err := h.ResumeImport(c); err != nil {
+		t.Fatalf("ResumeImport: %v", err)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body: %v", err)
+	}
+	return rec.Code, body
+}
+
+// responseRows returns the sheet rows of an import response
+func responseRows(body map[string]interface{}) []int {
+	rows := []int{}
+	results, _ := body["rows"].([]interface{})
+	for _, result := range results {
+		row, _ := result.(map[string]interface{})["row"].(float64)
+		rows = append(rows, int(row))
+	}
+	return rows
+}
+
+func TestResumeImport(t *testing.T) {
+	t.Run("committed chunk resumes after the processed rows", func(t *testing.T) {
+		h, script, id := newImportHandler(t, 1)
+		status, body := resumeImport(t, h, id, url.Values{"limit": {"1"}})
+		if status != http.StatusOK {
+			t.Fatalf("status = %d: %v", status, body)
+		}
+		if rows := responseRows(body); fmt.Sprint(rows) != "[3]" {
+			t.Errorf("rows = %v, want [3]", rows)
+		}
+		if body["done"] != false || body["next_row"] != float64(4) {
+			t.Errorf("done = %v, next_row = %v, want false and 4", body["done"], body["next_row"])
+		}
+		if _, ok := body["next_offset"]; ok {
+			t.Errorf("next_offset given for a committed import")
+		}
+		// The chunk and the progress are saved together, under the lock of the job
+		if len(script.ran(`^INSERT INTO "petrography_carbonate"`)) != 1 || len(script.ran(`FOR UPDATE`)) != 1 {
+			t.Errorf("chunk not committed under the job lock:\n%s", script.log())
+		}
+		job, _ := body["import"].(map[string]interface{})
+		if job["processed_rows"] != float64(2) || job["status"] != "in_progress" {
+			t.Errorf("job = %v, want 2 processed rows in progress", job)
+		}
+	})
+
+	t.Run("committed import completes", func(t *testing.T) {
+		h, _, id := newImportHandler(t, 2)
+		status, body := resumeImport(t, h, id, url.Values{})
+		if status != http.StatusOK {
+			t.Fatalf("status = %d: %v", status, body)
+		}
+		if rows := responseRows(body); fmt.Sprint(rows) != "[4]" || body["done"] != true {
+			t.Errorf("rows = %v, done = %v, want [4] and done", rows, body["done"])
+		}
+		job, _ := body["import"].(map[string]interface{})
+		if job["status"] != "completed" || job["total_rows"] != float64(3) {
+			t.Errorf("job = %v, want completed with 3 rows", job)
+		}
+		key := storage.ImportKey(id.String() + ".csv")
+		if _, err := h.blobs.Stat(context.Background(), key); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("spreadsheet kept after the import completed: %v", err)
+		}
+	})
+
+	t.Run("dry run chunks continue from the next offset", func(t *testing.T) {
+		h, script, id := newImportHandler(t, 0)
+		var rows []int
+		form := url.Values{"dry_run": {"true"}, "limit": {"2"}}
+		for calls := 0; ; calls++ {
+			if calls == 3 {
+				t.Fatalf("dry run not done after %d calls, rows %v", calls, rows)
+			}
+			status, body := resumeImport(t, h, id, form)
+			if status != http.StatusOK {
+				t.Fatalf("status = %d: %v", status, body)
+			}
+			rows = append(rows, responseRows(body)...)
+			if body["done"] == true {
+				if _, ok := body["next_offset"]; ok {
+					t.Errorf("next_offset given for a finished dry run")
+				}
+				job, _ := body["import"].(map[string]interface{})
+				if job["status"] != "validated" {
+					t.Errorf("status = %v, want validated", job["status"])
+				}
+				break
+			}
+			offset, ok := body["next_offset"].(float64)
+			if !ok {
+				t.Fatalf("partial dry run without next_offset: %v", body)
+			}
+			form.Set("offset", fmt.Sprint(offset))
+		}
+
+		if fmt.Sprint(rows) != "[2 3 4]" {
+			t.Errorf("rows = %v, want [2 3 4]", rows)
+		}
+		if inserts := script.ran(`^INSERT INTO "petrography_carbonate"`); len(inserts) != 0 {
+			t.Errorf("dry run inserted %d records", len(inserts))
+		}
+	})
+
+	t.Run("offset without a dry run", func(t *testing.T) {
+		h, _, id := newImportHandler(t, 0)
+		if status, body := resumeImport(t, h, id, url.Values{"offset": {"2"}}); status != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400: %v", status, body)
+		}
+	})
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"workbench/internal/core/models"
+	"workbench/internal/database"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// lasNullValue is written for missing samples, as is customary for LAS files
+const lasNullValue = -999.25
+
+// lasDefaultDepth is the index column used when none is requested
+const lasDefaultDepth = "top_depth_mmddf"
+
+// lasExcludedCurves are numeric columns that are identifiers or header data rather than curves
+var lasExcludedCurves = map[string]bool{
+	"id":               true,
+	"master_record_id": true,
+	"well_id":          true,
+	"latitude":         true,
+	"longitude":        true,
+	"age_start_ma":     true,
+	"age_end_ma":       true,
+}
+
+var lasUnsafeFilename = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// lasRequest describes a LAS export after its parameters have been validated
+type lasRequest struct {
+	Table  string
+	UWI    string
+	Well   string
+	Depth  string
+	Curves []string
+	Where  func(db *gorm.DB) *gorm.DB
+}
+
+// isDepthColumn reports whether a column can index a LAS file
+func isDepthColumn(column string) bool {
+	return strings.HasPrefix(column, "top_depth_") || strings.HasPrefix(column, "bottom_depth_")
+}
+
+// isLengthColumn reports whether a column is one of the metric/imperial length pairs
+func isLengthColumn(column string) bool {
+	for _, pair := range models.EPBEUnitPairs {
+		if column == pair.Metric || column == pair.Imperial {
+			return true
+		}
+	}
+	return false
+}
+
+// lasUnit returns the LAS unit mnemonic of a numeric column, blank when the column does not record
+// its unit. Only the totals and porosities are labelled as percentages; the point counts of single
+// constituents are left blank, as the template gives them no unit.
+func lasUnit(column string) string {
+	for _, pair := range models.EPBEUnitPairs {
+		switch column {
+		case pair.Metric:
+			return "M"
+		case pair.Imperial:
+			return "F"
+		}
+	}
+
+	switch column {
+	case "permeability_md":
+		return "MD"
+	case "grain_density_g_cc":
+		return "G/C3"
+	case "latitude", "longitude":
+		return "DEG"
+	}
+	if strings.HasSuffix(column, "_percent") {
+		return "%"
+	}
+	return ""
+}
+
+// parseLASRequest validates the well, depth index and curve parameters
+func parseLASRequest(c echo.Context, db *gorm.DB, model interface{}, table string) (*lasRequest, error) {
+	req := &lasRequest{
+		Table: table,
+		UWI:   strings.TrimSpace(c.QueryParam("uwi")),
+		Well:  strings.TrimSpace(c.QueryParam("well")),
+		Depth: c.QueryParam("depth"),
+	}
+	if req.UWI == "" && req.Well == "" {
+		return nil, fmt.Errorf("uwi or well is required")
+	}
+	if req.Depth == "" {
+		req.Depth = lasDefaultDepth
+	}
+	if !isDepthColumn(req.Depth) {
+		return nil, fmt.Errorf("depth must be a top_depth_* or bottom_depth_* column")
+	}
+
+	columns, err := database.ModelColumns(db, model)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := columns[req.Depth]; !ok {
+		return nil, fmt.Errorf("unknown depth column %q", req.Depth)
+	}
+
+	// Any structured filter from the list API narrows the samples further
+	listQuery, err := database.ParseListQuery(c.QueryParams(), columns)
+	if err != nil {
+		return nil, err
+	}
+	req.Where = listQuery.Where()
+
+	if curves := c.QueryParam("curves"); curves != "" {
+		for _, curve := range strings.Split(curves, ",") {
+			curve = strings.TrimSpace(curve)
+			column, ok := columns[curve]
+			if !ok {
+				return nil, fmt.Errorf("unknown curve column %q", curve)
+			}
+			if column.Kind != database.KindNumber || lasExcludedCurves[curve] {
+				return nil, fmt.Errorf("curve column %q is not a numeric measurement", curve)
+			}
+			if curve != req.Depth && !containsCurve(req.Curves, curve) {
+				req.Curves = append(req.Curves, curve)
+			}
+		}
+		return req, nil
+	}
+
+	// Default to every numeric measurement, in ePBE column order
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, fmt.Errorf("failed to parse model schema: %w", err)
+	}
+	for _, name := range stmt.Schema.DBNames {
+		column, ok := columns[name]
+		if !ok || column.Kind != database.KindNumber || lasExcludedCurves[name] || isLengthColumn(name) {
+			continue
+		}
+		req.Curves = append(req.Curves, name)
+	}
+	return req, nil
+}
+
+func containsCurve(curves []string, curve string) bool {
+	for _, c := range curves {
+		if c == curve {
+			return true
+		}
+	}
+	return false
+}
+
+// exportLAS writes the samples of one well as a LAS 2.0 file indexed by the requested depth column
+func exportLAS[T any](c echo.Context, db *gorm.DB, req *lasRequest) error {
+	var model T
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&model); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to prepare LAS export",
+		})
+	}
+
+	query := db.Model(&model).Scopes(req.Where).Where(fmt.Sprintf("%q IS NOT NULL", req.Depth))
+	if req.UWI != "" {
+		query = query.Where("uwi = ?", req.UWI)
+	} else {
+		query = query.Where("well_name_field_name = ?", req.Well)
+	}
+
+	var records []T
+	if err := query.Order(fmt.Sprintf("%q ASC, id ASC", req.Depth)).Find(&records).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve samples for LAS export",
+		})
+	}
+	if len(records) == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "No samples with a depth found for this well",
+		})
+	}
+
+	read := func(record *T, column string) (float64, bool) {
+		rv := reflect.Indirect(reflect.ValueOf(record))
+		field := stmt.Schema.LookUpField(column)
+		if field == nil {
+			return 0, false
+		}
+		value, _ := field.ValueOf(c.Request().Context(), rv)
+		if v, ok := derefValue(value).(float64); ok && !math.IsNaN(v) {
+			return v, true
+		}
+		return 0, false
+	}
+	text := func(record *T, column string) string {
+		field := stmt.Schema.LookUpField(column)
+		if field == nil {
+			return ""
+		}
+		value, _ := field.ValueOf(c.Request().Context(), reflect.Indirect(reflect.ValueOf(record)))
+		if v := derefValue(value); v != nil {
+			return strings.TrimSpace(fmt.Sprint(v))
+		}
+		return ""
+	}
+
+	first := &records[0]
+	depthUnit := lasUnit(req.Depth)
+	start, _ := read(first, req.Depth)
+	stop, _ := read(&records[len(records)-1], req.Depth)
+
+	// LAS allows a constant step; anything else is written as irregular (STEP 0)
+	step := 0.0
+	if len(records) > 1 {
+		second, _ := read(&records[1], req.Depth)
+		step = second - start
+		for i := 2; i < len(records) && step > 0; i++ {
+			prev, _ := read(&records[i-1], req.Depth)
+			cur, _ := read(&records[i], req.Depth)
+			if math.Abs((cur-prev)-step) > 1e-6 {
+				step = 0
+			}
+		}
+		if step < 0 {
+			step = 0
+		}
+	}
+
+	wellName := text(first, "well_name_field_name")
+	uwi := text(first, "uwi")
+	location := ""
+	if lat, ok := read(first, "latitude"); ok {
+		if lon, ok := read(first, "longitude"); ok {
+			location = fmt.Sprintf("%.7f, %.7f", lat, lon)
+		}
+	}
+
+	name := wellName
+	if uwi != "" {
+		name = uwi
+	}
+	filename := fmt.Sprintf("%s_%s.las", strings.Trim(lasUnsafeFilename.ReplaceAllString(name, "_"), "_"), req.Table)
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/plain; charset=utf-8")
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	res.WriteHeader(http.StatusOK)
+
+	w := bufio.NewWriter(res)
+	defer w.Flush()
+
+	header := func(mnemonic, unit, data, description string) {
+		// LAS delimits the description at the colon, so keep colons out of the data field
+		data = strings.ReplaceAll(data, ":", " ")
+		fmt.Fprintf(w, " %-18s %-32s : %s\n", mnemonic+"."+unit, data, description)
+	}
+	number := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 4, 64)
+	}
+
+	fmt.Fprintln(w, "~VERSION INFORMATION")
+	header("VERS", "", "2.0", "CWLS LOG ASCII STANDARD - VERSION 2.0")
+	header("WRAP", "", "NO", "ONE LINE PER DEPTH STEP")
+
+	fmt.Fprintln(w, "~WELL INFORMATION")
+	fmt.Fprintln(w, "#MNEM.UNIT          DATA                               DESCRIPTION")
+	header("STRT", depthUnit, number(start), "START DEPTH")
+	header("STOP", depthUnit, number(stop), "STOP DEPTH")
+	header("STEP", depthUnit, number(step), "STEP (0 = IRREGULAR SAMPLING)")
+	header("NULL", "", number(lasNullValue), "NULL VALUE")
+	header("COMP", "", text(first, "owner"), "COMPANY")
+	header("WELL", "", wellName, "WELL")
+	header("FLD", "", text(first, "sub_basin"), "FIELD")
+	header("LOC", "", location, "LOCATION (LATITUDE, LONGITUDE)")
+	header("PROV", "", text(first, "region"), "PROVINCE")
+	header("CTRY", "", text(first, "country"), "COUNTRY")
+	header("SRVC", "", text(first, "data_generator"), "SERVICE COMPANY")
+	header("DATE", "", time.Now().UTC().Format("2006-01-02"), "EXPORT DATE")
+	header("UWI", "", uwi, "UNIQUE WELL ID")
+
+	fmt.Fprintln(w, "~CURVE INFORMATION")
+	fmt.Fprintln(w, "#MNEM.UNIT          API CODE                           CURVE DESCRIPTION")
+	header("DEPT", depthUnit, "", models.EPBEHeader(req.Depth))
+	for _, curve := range req.Curves {
+		header(strings.ToUpper(curve), lasUnit(curve), "", models.EPBEHeader(curve))
+	}
+
+	fmt.Fprintln(w, "~PARAMETER INFORMATION")
+	header("TABLE", "", req.Table, "SOURCE TABLE")
+	header("BASIN", "", text(first, "basin"), "BASIN")
+	header("FORM", "", text(first, "formation_name"), "FORMATION")
+	header("ENVI", "", text(first, "onshore_offshore"), "ONSHORE/OFFSHORE")
+	header("DREF", "", text(first, "depth_reference_type"), "DEPTH REFERENCE")
+	if depthUnit == "F" {
+		if v, ok := read(first, "depth_reference_elevation_ft"); ok {
+			header("EREF", "F", number(v), "ELEVATION OF DEPTH REFERENCE")
+		}
+		if v, ok := read(first, "ground_level_elevation_ft"); ok {
+			header("EGL", "F", number(v), "GROUND LEVEL ELEVATION")
+		}
+	} else {
+		if v, ok := read(first, "depth_reference_elevation_m"); ok {
+			header("EREF", "M", number(v), "ELEVATION OF DEPTH REFERENCE")
+		}
+		if v, ok := read(first, "ground_level_elevation_m"); ok {
+			header("EGL", "M", number(v), "GROUND LEVEL ELEVATION")
+		}
+	}
+
+	fmt.Fprintln(w, "~OTHER INFORMATION")
+	fmt.Fprintf(w, "Exported from the %s table: %d samples, irregularly spaced thin-section and core plug data.\n", req.Table, len(records))
+
+	// Data section: one line per sample in curve order
+	fmt.Fprint(w, "~A  DEPT")
+	for _, curve := range req.Curves {
+		fmt.Fprintf(w, " %14s", strings.ToUpper(curve))
+	}
+	fmt.Fprintln(w)
+	for i := range records {
+		depth, _ := read(&records[i], req.Depth)
+		fmt.Fprintf(w, "%12s", number(depth))
+		for _, curve := range req.Curves {
+			v, ok := read(&records[i], curve)
+			if !ok {
+				v = lasNullValue
+			}
+			fmt.Fprintf(w, " %14s", number(v))
+		}
+		fmt.Fprintln(w)
+	}
+
+	slog.InfoContext(c.Request().Context(), "📈 Exported samples as LAS", "samples", len(records), "table", req.Table, "well", name)
+	return nil
+}
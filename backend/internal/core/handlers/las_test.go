@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// lasLines returns the lines of the LAS header sections declaring the mnemonic, or the data lines when
+// the mnemonic is ~A
+func lasLines(body, mnemonic string) []string {
+	var lines []string
+	inData := false
+	for _, line := range strings.Split(body, "\n") {
+		switch {
+		case strings.HasPrefix(line, "~A"):
+			inData = true
+		case inData && mnemonic == "~A" && line != "":
+			lines = append(lines, line)
+		case !inData && strings.HasPrefix(strings.TrimSpace(line), mnemonic+"."):
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestExportLAS(t *testing.T) {
+	columns := []string{"id", "well_name_field_name", "uwi", "country", "latitude", "longitude", "top_depth_mmddf", "top_depth_ftmddf",
+		"calcite", "total_porosity_percent", "permeability_md"}
+	samples := [][]driver.Value{
+		{int64(1), "A-1", "UWI-1", "Norway", 60.5, 2.25, 100.0, 328.0, 12.5, 20.0, 150.0},
+		{int64(2), "A-1", "UWI-1", "Norway", 60.5, 2.25, 110.0, 361.0, nil, 18.5, 90.0},
+		{int64(3), "A-1", "UWI-1", "Norway", 60.5, 2.25, 120.0, 394.5, 30.0, 15.0, nil},
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		header map[string]string
+		curves map[string]string
+		data   []string
+	}{
+		{
+			name:  "metres at a regular step",
+			query: "uwi=UWI-1&curves=calcite,total_porosity_percent,permeability_md",
+			header: map[string]string{
+				"STRT.M": "100.0000", "STOP.M": "120.0000", "STEP.M": "10.0000", "NULL.": "-999.2500",
+				"WELL.": "A-1", "UWI.": "UWI-1", "CTRY.": "Norway", "LOC.": "60.5000000, 2.2500000",
+			},
+			// Point counts record no unit; totals are percentages
+			curves: map[string]string{"DEPT": "M", "CALCITE": "", "TOTAL_POROSITY_PERCENT": "%", "PERMEABILITY_MD": "MD"},
+			data: []string{
+				"100.0000 12.5000 20.0000 150.0000",
+				"110.0000 -999.2500 18.5000 90.0000",
+				"120.0000 30.0000 15.0000 -999.2500",
+			},
+		},
+		{
+			name:   "feet at irregular steps",
+			query:  "well=A-1&depth=top_depth_ftmddf&curves=total_porosity_percent",
+			header: map[string]string{"STRT.F": "328.0000", "STOP.F": "394.5000", "STEP.F": "0.0000"},
+			curves: map[string]string{"DEPT": "F", "TOTAL_POROSITY_PERCENT": "%"},
+			data:   []string{"328.0000 20.0000", "361.0000 18.5000", "394.5000 15.0000"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, script := newScriptedDB(t)
+			script.query(`^SELECT \* FROM "petrography_carbonate"`, columns, samples...)
+			h := NewPetrographyCarbonateHandler(db)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/petrography-carbonate/export/las?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			if err := h.ExportPetrographyCarbonateLAS(echo.New().NewContext(req, rec)); err != nil {
+				t.Fatalf("ExportPetrographyCarbonateLAS: %v", err)
+			}
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get(echo.HeaderContentDisposition); !strings.Contains(got, "petrography_carbonate.las") {
+				t.Errorf("Content-Disposition = %q", got)
+			}
+			body := rec.Body.String()
+
+			for mnemonic, want := range tt.header {
+				lines := lasLines(body, strings.SplitN(mnemonic, ".", 2)[0])
+				if len(lines) != 1 {
+					t.Errorf("%s declared %d times", mnemonic, len(lines))
+					continue
+				}
+				data := strings.Join(strings.Fields(strings.SplitN(lines[0], ":", 2)[0]), " ")
+				if data != mnemonic+" "+want {
+					t.Errorf("header %q, want %s %s", data, mnemonic, want)
+				}
+			}
+
+			for curve, unit := range tt.curves {
+				lines := lasLines(body, curve)
+				if len(lines) != 1 {
+					t.Errorf("curve %s declared %d times", curve, len(lines))
+					continue
+				}
+				if got := strings.Fields(lines[0])[0]; got != curve+"."+unit {
+					t.Errorf("curve %s declared as %q, want unit %q", curve, got, unit)
+				}
+			}
+
+			var data []string
+			for _, line := range lasLines(body, "~A") {
+				data = append(data, strings.Join(strings.Fields(line), " "))
+			}
+			if strings.Join(data, "\n") != strings.Join(tt.data, "\n") {
+				t.Errorf("data =\n%s\nwant\n%s", strings.Join(data, "\n"), strings.Join(tt.data, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"workbench/internal/core/models"
+	"workbench/internal/database"
+	"workbench/internal/logging"
+	"workbench/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errDocumentBusy is returned when a document to delete has extractions queued or running
+var errDocumentBusy = errors.New("document has extractions in progress")
+
+// errDocumentHasRecords is returned when a document to delete without cascade has records saved from it
+var errDocumentHasRecords = errors.New("document has records")
+
+// documentWellCondition keeps documents with records of a well whose name or UWI matches
+const documentWellCondition = `(EXISTS (SELECT 1 FROM petrography_carbonate r WHERE r.document_id = documents.id AND r.deleted_at IS NULL AND (r.well_name_field_name ILIKE ? ESCAPE '\' OR r.uwi ILIKE ? ESCAPE '\'))
+	OR EXISTS (SELECT 1 FROM petrography_clastic r WHERE r.document_id = documents.id AND r.deleted_at IS NULL AND (r.well_name_field_name ILIKE ? ESCAPE '\' OR r.uwi ILIKE ? ESCAPE '\')))`
+
+// ListDocuments lists the uploaded documents, newest first, with the list filters, sort and pagination.
+// q searches the file names, uploader the uploaders and well the wells of the records saved from the
+// documents; from and to (YYYY-MM-DD, inclusive) bound the upload date.
+func (h *ExtractionHandler) ListDocuments(c echo.Context) error {
+	params := url.Values{}
+	for key, values := range c.QueryParams() {
+		params[key] = values
+	}
+	if params.Get("sort") == "" {
+		params.Set("sort", "-created_at")
+	}
+	columns, err := database.ModelColumns(h.db, &models.Document{})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve documents",
+		})
+	}
+	listQuery, err := database.ParseListQuery(params, columns)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	query := h.db.Model(&models.Document{}).Scopes(
+		listQuery.Where(),
+		database.Search(c.QueryParam("q"), "original_filename"),
+		database.Search(c.QueryParam("uploader"), "uploaded_by"),
+	)
+	if well := c.QueryParam("well"); well != "" {
+		pattern := database.ContainsPattern(well)
+		query = query.Where(documentWellCondition, pattern, pattern, pattern, pattern)
+	}
+	for _, bound := range []struct{ param, condition string }{{"from", "created_at >= ?"}, {"to", "created_at < ?"}} {
+		value := c.QueryParam(bound.param)
+		if value == "" {
+			continue
+		}
+		date, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("%s must be a date (YYYY-MM-DD), got %q", bound.param, value),
+			})
+		}
+		if bound.param == "to" {
+			date = date.AddDate(0, 0, 1)
+		}
+		query = query.Where(bound.condition, date)
+	}
+
+	return respondList[models.Document](c, h.db, query, listQuery, "Failed to retrieve documents", nil)
+}
+
+// GetDocument returns a document with its extractions, newest version first, the records saved from it
+// by table, the wells of those records and its disk space
+func (h *ExtractionHandler) GetDocument(c echo.Context) error {
+	ctx := c.Request().Context()
+	document, err := h.findDocument(c.Param("id"))
+	if err != nil {
+		return documentLookupError(c, err)
+	}
+
+	var extractions []models.ExtractionJob
+	if err := h.db.WithContext(ctx).Where("document_id = ?", document.ID).
+		Order("version DESC, created_at DESC").Find(&extractions).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve extractions",
+		})
+	}
+	records, err := h.importedRecords(ctx, document.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to count records",
+		})
+	}
+	wells := []string{}
+	if err := h.db.WithContext(ctx).Raw(`SELECT well_name_field_name FROM petrography_carbonate WHERE document_id = ? AND deleted_at IS NULL
+		UNION SELECT well_name_field_name FROM petrography_clastic WHERE document_id = ? AND deleted_at IS NULL
+		ORDER BY 1`, document.ID, document.ID).Scan(&wells).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve wells",
+		})
+	}
+
+	// The tables of the document are those of its latest completed extraction
+	var latest *models.ExtractionJob
+	for i := range extractions {
+		if extractions[i].Status == models.ExtractionStatusCompleted {
+			latest = &extractions[i]
+			break
+		}
+	}
+	response := map[string]interface{}{
+		"document":         document,
+		"extractions":      extractions,
+		"imported_records": records,
+		"wells":            wells,
+		"storage":          h.documentUsage(ctx, document),
+	}
+	if latest != nil {
+		response["latest_version"] = latest.Version
+		response["tables"] = latest.TablesFound
+		response["rows"] = latest.RowsFound
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetExtractionVersion returns the extractor's output of one version of a document's extraction
+func (h *ExtractionHandler) GetExtractionVersion(c echo.Context) error {
+	ctx := c.Request().Context()
+	document, err := h.findDocument(c.Param("id"))
+	if err != nil {
+		return documentLookupError(c, err)
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid version",
+		})
+	}
+
+	var job models.ExtractionJob
+	err = h.db.WithContext(ctx).
+		Where("document_id = ? AND version = ? AND status = ?", document.ID, version, models.ExtractionStatusCompleted).
+		Order("finished_at DESC").First(&job).Error
+	if err == gorm.ErrRecordNotFound {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "No completed extraction with this version",
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve extraction",
+		})
+	}
+
+	jsonFile, err := h.readExtractedJSON(ctx, extractionOutputKey(job.Filename, job.Version, "_extracted.json"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	jsonFiles := []map[string]interface{}{}
+	if jsonFile != nil {
+		jsonFiles = append(jsonFiles, jsonFile)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"document_id": document.ID,
+		"version":     job.Version,
+		"job_id":      job.ID,
+		"options":     job.Options,
+		"results": map[string]interface{}{
+			"json_files":  jsonFiles,
+			"files_count": len(jsonFiles),
+		},
+		"processed_at": job.FinishedAt,
+	})
+}
+
+// ReextractDocument extracts a stored document again with the pages ("1-3,7"), flavor (lattice,
+// stream or both) and quality thresholds of the request. The output is kept as a new version next
+// to the earlier ones, so missed tables can be recovered without uploading the file again.
+func (h *ExtractionHandler) ReextractDocument(c echo.Context) error {
+	if !h.startJob() {
+		return shuttingDown(c)
+	}
+	defer h.active.Done()
+
+	document, err := h.findDocument(c.Param("id"))
+	if err != nil {
+		return documentLookupError(c, err)
+	}
+
+	var request struct {
+		Pages         string   `json:"pages"`
+		Flavor        string   `json:"flavor"`
+		MinConfidence *float64 `json:"min_confidence"`
+		MinRows       *int     `json:"min_rows"`
+		MinCols       *int     `json:"min_cols"`
+		MinFilled     *float64 `json:"min_filled"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request format",
+		})
+	}
+	options := &models.ExtractionOptions{
+		MinConfidence: request.MinConfidence,
+		MinRows:       request.MinRows,
+		MinCols:       request.MinCols,
+		MinFilled:     request.MinFilled,
+	}
+	if err := h.countPages(c.Request().Context(), document); err != nil {
+		slog.ErrorContext(c.Request().Context(), "❌ Failed to count pages", "document_id", document.ID, "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to read document",
+		})
+	}
+	if options.Pages, err = normalizePages(request.Pages, document.Pages); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	switch strings.ToLower(request.Flavor) {
+	case "":
+	case models.ExtractionFlavorLattice, models.ExtractionFlavorStream:
+		options.Flavors = []string{strings.ToLower(request.Flavor)}
+	case "both":
+		options.Flavors = []string{models.ExtractionFlavorLattice, models.ExtractionFlavorStream}
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "flavor must be lattice, stream or both",
+		})
+	}
+	if err := checkQualityThresholds(options); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	job := models.ExtractionJob{
+		ID:               uuid.New(),
+		DocumentID:       &document.ID,
+		Filename:         document.StoredName(),
+		OriginalFilename: document.OriginalFilename,
+		Status:           models.ExtractionStatusQueued,
+		Options:          options,
+		Worker:           workerName,
+	}
+	ctx := logging.WithJobID(c.Request().Context(), job.ID.String())
+	if err := h.saveVersionedExtractionJob(ctx, &job); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to record extraction",
+		})
+	}
+	slog.InfoContext(ctx, "🔁 Extracting document again", "document_id", document.ID, "version", job.Version, "pages", options.Pages, "flavors", options.Flavors)
+
+	outcome, err := h.extract(ctx, &job)
+	if err != nil {
+		status := http.StatusGatewayTimeout
+		if err == errShuttingDown {
+			status = http.StatusServiceUnavailable
+		}
+		return c.JSON(status, map[string]string{
+			"error":  fmt.Sprintf("Extraction did not finish: %v; check GET /api/v1/extraction/status/%s", err, job.ID),
+			"job_id": job.ID.String(),
+		})
+	}
+	if outcome.Error != "" {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error":  fmt.Sprintf("Extraction failed: %s", outcome.Error),
+			"job_id": job.ID.String(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":           "PDF extracted again",
+		"job_id":            job.ID,
+		"version":           job.Version,
+		"options":           options,
+		"results":           outcome.Results,
+		"document_id":       document.ID,
+		"original_filename": document.OriginalFilename,
+		"pages":             document.Pages,
+		"processed_at":      time.Now().Format(time.RFC3339),
+	})
+}
+
+// normalizePages checks a page list such as "1-3, 7" against the document's page count and returns it
+// without spaces. An empty list stands for every page.
+func normalizePages(spec string, pages int) (string, error) {
+	spec = strings.ReplaceAll(spec, " ", "")
+	if spec == "" || strings.EqualFold(spec, "all") {
+		return "", nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		end := start
+		if err == nil && isRange {
+			end, err = strconv.Atoi(last)
+		}
+		if err != nil || !pageInRange(start, pages) || !pageInRange(end, pages) || end < start {
+			return "", fmt.Errorf("invalid page range %q: pages run from 1 to %d", part, pages)
+		}
+	}
+	return spec, nil
+}
+
+// checkQualityThresholds rejects thresholds outside their range
+func checkQualityThresholds(options *models.ExtractionOptions) error {
+	if options.MinConfidence != nil && (*options.MinConfidence < 0 || *options.MinConfidence > 100) {
+		return fmt.Errorf("min_confidence must be between 0 and 100")
+	}
+	if options.MinRows != nil && *options.MinRows < 1 {
+		return fmt.Errorf("min_rows must be at least 1")
+	}
+	if options.MinCols != nil && *options.MinCols < 1 {
+		return fmt.Errorf("min_cols must be at least 1")
+	}
+	if options.MinFilled != nil && (*options.MinFilled < 0 || *options.MinFilled > 1) {
+		return fmt.Errorf("min_filled must be between 0 and 1")
+	}
+	return nil
+}
+
+// DeleteDocument deletes a document with its extractions, the extractor's output and the rendered
+// pages. While records saved from it remain it answers 409, unless cascade=true, which deletes those
+// records as well; while an extraction of it is queued or running it always answers 409.
+func (h *ExtractionHandler) DeleteDocument(c echo.Context) error {
+	ctx := c.Request().Context()
+	document, err := h.findDocument(c.Param("id"))
+	if err != nil {
+		return documentLookupError(c, err)
+	}
+	cascade := c.QueryParam("cascade") == "true"
+
+	// The document row stays locked until it is deleted, so no extraction can be queued and no record
+	// saved from it in between. Extraction jobs go with the document through their foreign key.
+	var versions []int
+	var active int64
+	var records *documentRecords
+	err = h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Document{}, "id = ?", document.ID).Error; err != nil {
+			return err
+		}
+		// A queued or running extraction would write output and records for a document that is gone
+		if err := tx.Model(&models.ExtractionJob{}).
+			Where("document_id = ? AND status IN ?", document.ID, []string{models.ExtractionStatusQueued, models.ExtractionStatusRunning}).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return errDocumentBusy
+		}
+		if records, err = countRecords(tx, document.ID); err != nil {
+			return err
+		}
+		if records.Total > 0 && !cascade {
+			return errDocumentHasRecords
+		}
+		if versions, err = extractionVersions(tx, document.ID); err != nil {
+			return err
+		}
+
+		if cascade {
+			if err := tx.Where("document_id = ?", document.ID).Delete(&models.EPBEPetrographyCarbonate{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("document_id = ?", document.ID).Delete(&models.EPBEPetrographyClastic{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(document).Error
+	})
+	if err == errDocumentBusy {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":              "The document is being extracted; delete it once its extractions have finished",
+			"active_extractions": active,
+		})
+	}
+	if err == errDocumentHasRecords {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":            fmt.Sprintf("%d records were saved from this document; delete them first or pass cascade=true", records.Total),
+			"imported_records": records,
+		})
+	}
+	if err == gorm.ErrRecordNotFound {
+		return documentLookupError(c, err)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to delete document", "document_id", document.ID, "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete document",
+		})
+	}
+	h.deleteDocumentFiles(ctx, document, versions)
+
+	slog.InfoContext(ctx, "🗑️ Deleted document", "document_id", document.ID, "cascade", cascade, "records", records.Total)
+	response := map[string]interface{}{
+		"message":     "Document deleted",
+		"document_id": document.ID,
+	}
+	if cascade {
+		response["deleted_records"] = records
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// deleteDocumentFiles removes the stored objects of a deleted document. Failures are logged only: the
+// document is gone and its objects can no longer be reached.
+func (h *ExtractionHandler) deleteDocumentFiles(ctx context.Context, document *models.Document, versions []int) {
+	keys := []string{documentKey(document)}
+	for _, version := range versions {
+		for _, suffix := range []string{"_extracted.json", "_extracted.md"} {
+			keys = append(keys, extractionOutputKey(document.StoredName(), version, suffix))
+		}
+	}
+	pages, err := h.blobs.List(ctx, storage.PageKey(document.ID.String(), ""))
+	if err != nil {
+		slog.WarnContext(ctx, "⚠️ Failed to list rendered pages", "document_id", document.ID, "error", err)
+	}
+	for _, page := range pages {
+		keys = append(keys, page.Key)
+	}
+
+	for _, key := range keys {
+		if err := h.blobs.Delete(ctx, key); err != nil {
+			slog.WarnContext(ctx, "⚠️ Failed to remove stored file", "document_id", document.ID, "key", key, "error", err)
+		}
+	}
+}
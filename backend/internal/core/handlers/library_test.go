@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// documentColumns are the columns a scripted documents query answers with
+var documentColumns = []string{"id", "sha256", "original_filename", "size", "uploads", "created_at", "updated_at"}
+
+func documentRow(id uuid.UUID) []driver.Value {
+	now := time.Now()
+	return []driver.Value{id.String(), "abc123", "report.pdf", int64(1024), int64(1), now, now}
+}
+
+func TestDeleteDocument(t *testing.T) {
+	id := uuid.New()
+	count := func(n int64) [][]driver.Value { return [][]driver.Value{{n}} }
+
+	tests := []struct {
+		name    string
+		cascade bool
+		records int64
+		status  int
+		// deletes are the tables rows are deleted from, in order
+		deletes []string
+	}{
+		{name: "without records", status: http.StatusOK, deletes: []string{"documents"}},
+		{name: "with records", records: 3, status: http.StatusConflict},
+		{name: "with records and cascade", cascade: true, records: 3, status: http.StatusOK,
+			deletes: []string{"petrography_carbonate", "petrography_clastic", "documents"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, script := newScriptedHandler(t)
+			script.query(`FOR UPDATE`, []string{"id"}, []driver.Value{id.String()})
+			script.query(`^SELECT \* FROM "documents"`, documentColumns, documentRow(id))
+			script.query(`SELECT count\(\*\) FROM "extraction_jobs"`, []string{"count"}, count(0)...)
+			script.query(`SELECT count\(\*\) FROM "petrography_carbonate"`, []string{"count"}, count(tt.records)...)
+			script.query(`SELECT count\(\*\) FROM "petrography_clastic"`, []string{"count"}, count(0)...)
+			script.query(`SELECT DISTINCT "version" FROM "extraction_jobs"`, []string{"version"}, []driver.Value{int64(1)})
+			script.exec(`^(UPDATE|DELETE FROM) "`, 1)
+
+			target := "/api/v1/library/documents/" + id.String()
+			if tt.cascade {
+				target += "?cascade=true"
+			}
+			req := httptest.NewRequest(http.MethodDelete, target, nil)
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(id.String())
+
+			if err := h.DeleteDocument(c); err != nil {
+				t.Fatalf("DeleteDocument: %v", err)
+			}
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s\n%s", rec.Code, tt.status, rec.Body, script.log())
+			}
+
+			// The records are counted under the lock of the document row, in the transaction deleting it
+			log := script.log()
+			locked := strings.Index(log, "FOR UPDATE")
+			counted := strings.Index(log, `SELECT count(*) FROM "petrography_carbonate"`)
+			if locked < 0 || counted < locked || strings.LastIndex(log, "BEGIN") > locked {
+				t.Errorf("records not counted under the document lock:\n%s", log)
+			}
+
+			var deletes []string
+			for _, statement := range script.ran(`^(UPDATE|DELETE FROM) "`) {
+				table := strings.SplitN(statement.sql, `"`, 3)[1]
+				deletes = append(deletes, table)
+			}
+			if strings.Join(deletes, ",") != strings.Join(tt.deletes, ",") {
+				t.Errorf("deleted from %v, want %v", deletes, tt.deletes)
+			}
+
+			var body map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body: %v", err)
+			}
+			switch {
+			case tt.status == http.StatusConflict:
+				if len(script.ran(`^COMMIT$`)) != 0 {
+					t.Errorf("refused delete committed:\n%s", log)
+				}
+				imported, _ := body["imported_records"].(map[string]interface{})
+				if imported["total"] != float64(tt.records) {
+					t.Errorf("imported_records = %v, want a total of %d", body["imported_records"], tt.records)
+				}
+			case tt.cascade:
+				deleted, _ := body["deleted_records"].(map[string]interface{})
+				if deleted["total"] != float64(tt.records) {
+					t.Errorf("deleted_records = %v, want a total of %d", body["deleted_records"], tt.records)
+				}
+			default:
+				if _, ok := body["deleted_records"]; ok {
+					t.Errorf("deleted_records reported without cascade: %v", body)
+				}
+			}
+		})
+	}
+}
+
+func TestListDocumentsWellFilter(t *testing.T) {
+	h, script := newScriptedHandler(t)
+	script.query(`SELECT count\(\*\) FROM "documents"`, []string{"count"}, []driver.Value{int64(0)})
+	script.query(`SELECT \* FROM "documents"`, documentColumns)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/library/documents?well=50%25_A", nil)
+	rec := httptest.NewRecorder()
+	if err := h.ListDocuments(echo.New().NewContext(req, rec)); err != nil {
+		t.Fatalf("ListDocuments: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s\n%s", rec.Code, rec.Body, script.log())
+	}
+
+	// The wildcards of the well match themselves only
+	statements := script.ran(`ILIKE`)
+	if len(statements) == 0 {
+		t.Fatalf("no well condition:\n%s", script.log())
+	}
+	for _, statement := range statements {
+		if !strings.Contains(statement.sql, `ILIKE $1 ESCAPE '\'`) {
+			t.Errorf("well condition without an escape character: %s", statement.sql)
+		}
+		for _, arg := range statement.args[:4] {
+			if arg != `%50\%\_A%` {
+				t.Errorf("well pattern = %v, want %s", arg, `%50\%\_A%`)
+			}
+		}
+	}
+}
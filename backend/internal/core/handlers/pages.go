@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"workbench/internal/core/models"
+	"workbench/internal/logging"
+	"workbench/internal/pdfinfo"
+	"workbench/internal/storage"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// pageTimeout bounds the page script, which handles a single page
+	pageTimeout = time.Minute
+
+	defaultPageDPI = 150
+	minPageDPI     = 36
+	maxPageDPI     = 300
+
+	defaultThumbnailWidth = 200
+	maxThumbnailWidth     = 600
+)
+
+// pageContentTypes lists the formats a page is served in
+var pageContentTypes = map[string]string{
+	"pdf":  "application/pdf",
+	"png":  "image/png",
+	"webp": "image/webp",
+}
+
+// pageRender describes one rendition of a page: a single-page PDF, or an image at DPI or Width pixels wide
+type pageRender struct {
+	Page   int
+	Format string
+	DPI    int
+	Width  int
+}
+
+// name returns the name the rendition is stored under, unique to its settings
+func (r pageRender) name() string {
+	switch {
+	case r.Format == "pdf":
+		return fmt.Sprintf("page-%d.pdf", r.Page)
+	case r.Width > 0:
+		return fmt.Sprintf("page-%d-w%d.%s", r.Page, r.Width, r.Format)
+	default:
+		return fmt.Sprintf("page-%d-%ddpi.%s", r.Page, r.DPI, r.Format)
+	}
+}
+
+// errPageNotFound is returned for a page past the end of the document
+var errPageNotFound = errors.New("page not found")
+
+// pageInRange reports whether a document with the given page count has the page
+func pageInRange(page, pages int) bool {
+	return page >= 1 && page <= pages
+}
+
+// countPages fills in the page count of a document stored before pages were counted, reading it from
+// the stored PDF. The count is saved, so the PDF is read once.
+func (h *ExtractionHandler) countPages(ctx context.Context, document *models.Document) error {
+	if document.Pages > 0 {
+		return nil
+	}
+	reader, object, err := h.blobs.Get(ctx, documentKey(document))
+	if err != nil {
+		return fmt.Errorf("failed to open PDF: %v", err)
+	}
+	defer reader.Close()
+	info, err := pdfinfo.Inspect(reader, object.Size)
+	if err != nil {
+		return fmt.Errorf("failed to count pages: %v", err)
+	}
+
+	document.Pages = info.Pages
+	if document.PDFVersion == "" {
+		document.PDFVersion = info.Version
+	}
+	if err := h.db.WithContext(ctx).Model(document).UpdateColumns(map[string]interface{}{
+		"pages":       document.Pages,
+		"pdf_version": document.PDFVersion,
+	}).Error; err != nil {
+		slog.WarnContext(ctx, "⚠️ Failed to save page count", "document_id", document.ID, "error", err)
+	}
+	return nil
+}
+
+// parsePageRender reads the page number and the format query parameter. Images are rendered at the
+// dpi query parameter, or at the width parameter for thumbnails. The document's page count must be
+// known, see countPages.
+func parsePageRender(c echo.Context, document *models.Document, thumbnail bool) (pageRender, error) {
+	page, err := strconv.Atoi(c.Param("page"))
+	if err != nil || page < 1 {
+		return pageRender{}, errors.New("invalid page number")
+	}
+	if !pageInRange(page, document.Pages) {
+		return pageRender{}, fmt.Errorf("%w: the document has %d pages", errPageNotFound, document.Pages)
+	}
+
+	render := pageRender{Page: page, Format: strings.ToLower(c.QueryParam("format"))}
+	if render.Format == "" {
+		render.Format = "pdf"
+		if thumbnail {
+			render.Format = "webp"
+		}
+	}
+	if _, ok := pageContentTypes[render.Format]; !ok || (thumbnail && render.Format == "pdf") {
+		return pageRender{}, fmt.Errorf("unsupported format %q", render.Format)
+	}
+
+	if thumbnail {
+		render.Width = defaultThumbnailWidth
+		if value := c.QueryParam("width"); value != "" {
+			width, err := strconv.Atoi(value)
+			if err != nil || width < 1 || width > maxThumbnailWidth {
+				return pageRender{}, fmt.Errorf("width must be between 1 and %d", maxThumbnailWidth)
+			}
+			render.Width = width
+		}
+	} else if render.Format != "pdf" {
+		render.DPI = defaultPageDPI
+		if value := c.QueryParam("dpi"); value != "" {
+			dpi, err := strconv.Atoi(value)
+			if err != nil || dpi < minPageDPI || dpi > maxPageDPI {
+				return pageRender{}, fmt.Errorf("dpi must be between %d and %d", minPageDPI, maxPageDPI)
+			}
+			render.DPI = dpi
+		}
+	}
+	return render, nil
+}
+
+// GetPageThumbnail serves a small image of a page, webp unless format asks for png
+func (h *ExtractionHandler) GetPageThumbnail(c echo.Context) error {
+	return h.servePageRequest(c, true)
+}
+
+// servePageRequest serves the page of the document a request names, once checked against its page count
+func (h *ExtractionHandler) servePageRequest(c echo.Context, thumbnail bool) error {
+	ctx := c.Request().Context()
+	document, err := h.findDocument(c.Param("id"))
+	if err != nil {
+		return documentLookupError(c, err)
+	}
+	if err := h.countPages(ctx, document); err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to count pages", "document_id", document.ID, "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to read document",
+		})
+	}
+
+	render, err := parsePageRender(c, document, thumbnail)
+	if errors.Is(err, errPageNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	return h.servePage(c, document, render)
+}
+
+// servePage streams a rendition of a page, rendering and storing it on first use
+func (h *ExtractionHandler) servePage(c echo.Context, document *models.Document, render pageRender) error {
+	ctx := c.Request().Context()
+	key := storage.PageKey(document.ID.String(), render.name())
+
+	if _, err := h.blobs.Stat(ctx, key); errors.Is(err, storage.ErrNotFound) {
+		// Requests for the same page while it renders wait for that render
+		_, err, _ := h.rendering.Do(key, func() (interface{}, error) {
+			return nil, h.renderPage(ctx, document, render, key)
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "❌ Failed to render page", "document_id", document.ID, "page", render.Page, "format", render.Format, "error", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to render page",
+			})
+		}
+	} else if err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to read stored page", "key", key, "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to read page",
+		})
+	}
+
+	reader, object, err := h.blobs.Get(ctx, key)
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to read stored page", "key", key, "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to read page",
+		})
+	}
+	defer reader.Close()
+
+	// The download is named after the uploaded file and the page
+	stem := strings.TrimSuffix(document.OriginalFilename, filepath.Ext(document.OriginalFilename))
+	filename := fmt.Sprintf("%s-page-%d.%s", stem, render.Page, render.Format)
+	disposition := mime.FormatMediaType("inline", map[string]string{"filename": filename})
+	if disposition == "" {
+		disposition = "inline"
+	}
+
+	c.Response().Header().Set("Content-Type", pageContentTypes[render.Format])
+	c.Response().Header().Set("Content-Disposition", disposition)
+	serveObject(c, reader, object, render.name(), objectETag(object), immutableCache)
+	return nil
+}
+
+// renderPage runs the page script on the document and stores its output under key
+func (h *ExtractionHandler) renderPage(ctx context.Context, document *models.Document, render pageRender, key string) error {
+	// The render outlives a client that gives up, so the next request finds it stored
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), pageTimeout)
+	defer cancel()
+	started := time.Now()
+
+	scratch, err := os.MkdirTemp("", "page-*")
+	if err != nil {
+		return fmt.Errorf("failed to create scratch directory: %v", err)
+	}
+	defer os.RemoveAll(scratch)
+	input := filepath.Join(scratch, document.StoredName())
+	output := filepath.Join(scratch, render.name())
+	if err := h.downloadObject(ctx, documentKey(document), input); err != nil {
+		return fmt.Errorf("failed to fetch PDF from storage: %v", err)
+	}
+
+	scriptPath, _ := filepath.Abs(h.extraction.PageScriptPath())
+	args := []string{scriptPath, input, strconv.Itoa(render.Page), output, "--format", render.Format}
+	if render.Width > 0 {
+		args = append(args, "--width", strconv.Itoa(render.Width))
+	} else if render.DPI > 0 {
+		args = append(args, "--dpi", strconv.Itoa(render.DPI))
+	}
+
+	cmd := exec.CommandContext(ctx, h.extraction.PythonCommand(), args...)
+	cmd.Dir = h.extraction.Dir
+	cmd.Env = append(os.Environ(), "LOG_LEVEL="+logging.PythonLevel(ctx))
+	out, err := cmd.CombinedOutput()
+	logging.CopyPythonLog(ctx, bytes.NewReader(out), "output", nil)
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("page script timed out after %s", pageTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("page script failed: %v, last output: %s", err, lastLines(string(out), 3))
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		return fmt.Errorf("page script wrote no output: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if err := h.blobs.Put(ctx, key, file, info.Size(), pageContentTypes[render.Format]); err != nil {
+		return fmt.Errorf("failed to store page: %v", err)
+	}
+
+	slog.InfoContext(ctx, "🖼️ Rendered page", "document_id", document.ID, "page", render.Page, "format", render.Format, "duration", time.Since(started).String())
+	return nil
+}
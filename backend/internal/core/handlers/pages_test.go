@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"workbench/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// twoPagePDF is a PDF with two empty pages
+func twoPagePDF() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] >>",
+	}
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
+
+func TestServePDFPageUncountedDocument(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+	h, script := newScriptedHandler(t)
+	// Stored before pages were counted, the document has none recorded
+	script.query(`^SELECT \* FROM "documents"`, documentColumns, documentRow(id))
+	script.exec(`^UPDATE "documents" SET`, 1)
+
+	pdf := twoPagePDF()
+	if err := h.blobs.Put(ctx, storage.DocumentKey("abc123.pdf"), bytes.NewReader(pdf), int64(len(pdf)), "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	// The first page is rendered already, so serving it runs no page script
+	page := []byte("%PDF-1.4 page 1")
+	if err := h.blobs.Put(ctx, storage.PageKey(id.String(), "page-1.pdf"), bytes.NewReader(page), int64(len(page)), "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	tests := []struct {
+		page   string
+		status int
+	}{
+		{page: "1", status: http.StatusOK},
+		{page: "3", status: http.StatusNotFound},
+		{page: "0", status: http.StatusBadRequest},
+		{page: "first", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run("page "+tt.page, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/extraction/pdf/"+id.String()+"/page/"+tt.page, nil)
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+			c.SetParamNames("id", "page")
+			c.SetParamValues(id.String(), tt.page)
+
+			if err := h.ServePDFPage(c); err != nil {
+				t.Fatalf("ServePDFPage: %v", err)
+			}
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusOK && !bytes.Equal(rec.Body.Bytes(), page) {
+				t.Errorf("body = %q, want the stored page", rec.Body)
+			}
+			if tt.status == http.StatusNotFound && !strings.Contains(rec.Body.String(), "2 pages") {
+				t.Errorf("body = %s, want the page count", rec.Body)
+			}
+		})
+	}
+
+	// The count read from the PDF is saved with the document
+	updates := script.ran(`^UPDATE "documents" SET`)
+	if len(updates) == 0 {
+		t.Fatalf("page count not saved:\n%s", script.log())
+	}
+	if !strings.Contains(updates[0].sql, `"pages"=$1`) || fmt.Sprint(updates[0].args[0]) != "2" {
+		t.Errorf("update %s %v does not save 2 pages", updates[0].sql, updates[0].args)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"log/slog"
 	"net/http"
 	"strconv"
 
@@ -11,6 +12,26 @@ import (
 	"gorm.io/gorm"
 )
 
+// petrographyCarbonateSearchFields are the text columns matched by the free-text q parameter
+var petrographyCarbonateSearchFields = []string{
+	"country",
+	"region",
+	"sub_region",
+	"basin",
+	"sub_basin",
+	"well_name_field_name",
+	"uwi",
+	"formation_name",
+	"reservoir_name",
+	"period",
+	"epoch",
+	"age",
+	"lithofacies_core",
+	"microfacies_thin_section",
+	"depofacies",
+	"analysis_types",
+}
+
 type PetrographyCarbonateHandler struct {
 	db *gorm.DB
 }
@@ -24,6 +45,9 @@ func (h *PetrographyCarbonateHandler) PetrographyCarbonateRoutes(g *echo.Group)
 	carbonate.POST("", h.CreatePetrographyCarbonate)
 	carbonate.GET("", h.GetPetrographyCarbonateRecords)
 	carbonate.GET("/search", h.SearchPetrographyCarbonateRecords)
+	carbonate.GET("/columns", h.GetPetrographyCarbonateColumns)
+	carbonate.GET("/export", h.ExportPetrographyCarbonateRecords)
+	carbonate.GET("/las", h.ExportPetrographyCarbonateLAS)
 	carbonate.GET("/:id", h.GetPetrographyCarbonate)
 	carbonate.PUT("/:id", h.UpdatePetrographyCarbonate)
 	carbonate.DELETE("/:id", h.DeletePetrographyCarbonate)
@@ -46,6 +70,23 @@ func (h *PetrographyCarbonateHandler) CreatePetrographyCarbonate(c echo.Context)
 		})
 	}
 
+	if err := resolveRecordCoordinates(&record.EPBEBase, &record.MetadataInfo); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid coordinates: " + err.Error(),
+		})
+	}
+	if err := models.ApplyTimescale(&record.EPBEBase, &record.MetadataInfo); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	if err := models.PrepareSamples(h.db, &record); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create petrography carbonate record",
+		})
+	}
+
 	// Create record
 	if err := h.db.Create(&record).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
@@ -82,38 +123,63 @@ func (h *PetrographyCarbonateHandler) GetPetrographyCarbonate(c echo.Context) er
 	return c.JSON(http.StatusOK, record)
 }
 
-// GetPetrographyCarbonateRecords retrieves all petrography carbonate records with pagination
+// GetPetrographyCarbonateRecords retrieves petrography carbonate records with filtering, sorting, sparse fieldsets,
+// offset or cursor pagination, and optional NDJSON streaming
 func (h *PetrographyCarbonateHandler) GetPetrographyCarbonateRecords(c echo.Context) error {
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(c.QueryParam("page"))
-	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	// Parse filter, sort and fields parameters
+	listQuery, err := parseListQuery(c, h.db, &models.EPBEPetrographyCarbonate{})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	query := h.db.Model(&models.EPBEPetrographyCarbonate{}).Scopes(listQuery.Where())
+
+	return respondList[models.EPBEPetrographyCarbonate](c, h.db, query, listQuery, "Failed to retrieve petrography carbonate records", nil)
+}
 
-	pagination := &models.Pagination{
-		Page:  page,
-		Limit: limit,
+// ExportPetrographyCarbonateRecords exports every record matching the list filters as CSV or XLSX in ePBE column order
+func (h *PetrographyCarbonateHandler) ExportPetrographyCarbonateRecords(c echo.Context) error {
+	req, err := parseExportRequest(c, h.db, &models.EPBEPetrographyCarbonate{}, "petrography_carbonate")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
 	}
 
-	var records []models.EPBEPetrographyCarbonate
-	var total int64
+	query := h.db.Model(&models.EPBEPetrographyCarbonate{}).Scopes(req.ListQuery.Where())
+	if req.Search != "" {
+		query = query.Scopes(database.Search(req.Search, petrographyCarbonateSearchFields...))
+	}
 
-	// Count total records
-	h.db.Model(&models.EPBEPetrographyCarbonate{}).Count(&total)
+	slog.InfoContext(c.Request().Context(), "📤 Exporting petrography carbonate records", "format", req.Format, "units", req.Units)
+	return exportRecords[models.EPBEPetrographyCarbonate](c, h.db, query, req)
+}
 
-	// Get records with pagination
-	if err := h.db.Scopes(database.Paginate(pagination)).Find(&records).Error; err != nil {
+// ExportPetrographyCarbonateLAS exports one well's samples as a LAS 2.0 file indexed by a depth column
+func (h *PetrographyCarbonateHandler) ExportPetrographyCarbonateLAS(c echo.Context) error {
+	req, err := parseLASRequest(c, h.db, &models.EPBEPetrographyCarbonate{}, "petrography_carbonate")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return exportLAS[models.EPBEPetrographyCarbonate](c, h.db, req)
+}
+
+// GetPetrographyCarbonateColumns lists the columns that can be used in filter, sort and fields parameters
+func (h *PetrographyCarbonateHandler) GetPetrographyCarbonateColumns(c echo.Context) error {
+	columns, err := database.ModelColumns(h.db, &models.EPBEPetrographyCarbonate{})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to retrieve petrography carbonate records",
+			"error": "Failed to describe petrography carbonate columns",
 		})
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"records": records,
-		"pagination": map[string]interface{}{
-			"page":        pagination.GetPage(),
-			"limit":       pagination.GetLimit(),
-			"total":       total,
-			"total_pages": (total + int64(pagination.GetLimit()) - 1) / int64(pagination.GetLimit()),
-		},
+		"columns": columns,
 	})
 }
 
@@ -159,11 +225,28 @@ func (h *PetrographyCarbonateHandler) UpdatePetrographyCarbonate(c echo.Context)
 	record.UWI = updateData.UWI
 	record.Latitude = updateData.Latitude
 	record.Longitude = updateData.Longitude
+	record.CoordinateCRS = updateData.CoordinateCRS
+	record.RawX = updateData.RawX
+	record.RawY = updateData.RawY
+	if err := resolveRecordCoordinates(&record.EPBEBase, &record.MetadataInfo); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid coordinates: " + err.Error(),
+		})
+	}
+	// A formation or reservoir edited by hand is no longer taken from the well's tops
+	if updateData.FormationName != record.FormationName || updateData.ReservoirName != record.ReservoirName {
+		record.FormationAssignment = ""
+	}
 	record.FormationName = updateData.FormationName
 	record.ReservoirName = updateData.ReservoirName
 	record.Period = updateData.Period
 	record.Epoch = updateData.Epoch
 	record.Age = updateData.Age
+	if err := models.ApplyTimescale(&record.EPBEBase, &record.MetadataInfo); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
 	record.OnshoreOffshore = updateData.OnshoreOffshore
 	record.WaterDepthM = updateData.WaterDepthM
 	record.WaterDepthFt = updateData.WaterDepthFt
@@ -308,6 +391,11 @@ func (h *PetrographyCarbonateHandler) UpdatePetrographyCarbonate(c echo.Context)
 	record.MetadataDataSourceName = updateData.MetadataDataSourceName
 	record.SessionID = updateData.SessionID
 
+	if err := models.PrepareSamples(h.db, &record); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update petrography carbonate record",
+		})
+	}
 	if err := h.db.Save(&record).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to update petrography carbonate record",
@@ -352,7 +440,7 @@ func (h *PetrographyCarbonateHandler) DeletePetrographyCarbonate(c echo.Context)
 	})
 }
 
-// SearchPetrographyCarbonateRecords searches petrography carbonate records by various fields
+// SearchPetrographyCarbonateRecords searches petrography carbonate records by various fields, combined with any structured filter
 func (h *PetrographyCarbonateHandler) SearchPetrographyCarbonateRecords(c echo.Context) error {
 	query := c.QueryParam("q")
 	if query == "" {
@@ -361,56 +449,21 @@ func (h *PetrographyCarbonateHandler) SearchPetrographyCarbonateRecords(c echo.C
 		})
 	}
 
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(c.QueryParam("page"))
-	limit, _ := strconv.Atoi(c.QueryParam("limit"))
-
-	pagination := &models.Pagination{
-		Page:  page,
-		Limit: limit,
+	// Parse filter, sort and fields parameters
+	listQuery, err := parseListQuery(c, h.db, &models.EPBEPetrographyCarbonate{})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
 	}
 
-	var records []models.EPBEPetrographyCarbonate
-	var total int64
-
-	// Count total records with search
+	// Search text columns and apply the structured filter
 	searchQuery := h.db.Model(&models.EPBEPetrographyCarbonate{}).Scopes(
-		database.Search(query,
-			"country",
-			"region",
-			"sub_region",
-			"basin",
-			"sub_basin",
-			"well_name_field_name",
-			"uwi",
-			"formation_name",
-			"reservoir_name",
-			"period",
-			"epoch",
-			"age",
-			"lithofacies_core",
-			"microfacies_thin_section",
-			"depofacies",
-			"analysis_types",
-		),
+		listQuery.Where(),
+		database.Search(query, petrographyCarbonateSearchFields...),
 	)
-	searchQuery.Count(&total)
-
-	// Get records with search and pagination
-	if err := searchQuery.Scopes(database.Paginate(pagination)).Find(&records).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to search petrography carbonate records",
-		})
-	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"records": records,
-		"pagination": map[string]interface{}{
-			"page":        pagination.GetPage(),
-			"limit":       pagination.GetLimit(),
-			"total":       total,
-			"total_pages": (total + int64(pagination.GetLimit()) - 1) / int64(pagination.GetLimit()),
-		},
+	return respondList[models.EPBEPetrographyCarbonate](c, h.db, searchQuery, listQuery, "Failed to search petrography carbonate records", map[string]interface{}{
 		"query": query,
 	})
 }
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"log/slog"
 	"net/http"
 	"strconv"
 
@@ -11,6 +12,26 @@ import (
 	"gorm.io/gorm"
 )
 
+// petrographyClasticSearchFields are the text columns matched by the free-text q parameter
+var petrographyClasticSearchFields = []string{
+	"country",
+	"region",
+	"sub_region",
+	"basin",
+	"sub_basin",
+	"well_name_field_name",
+	"uwi",
+	"formation_name",
+	"reservoir_name",
+	"period",
+	"epoch",
+	"age",
+	"grain_size",
+	"grain_shape",
+	"lithofacies",
+	"analysis_types",
+}
+
 type PetrographyClasticHandler struct {
 	db *gorm.DB
 }
@@ -24,6 +45,9 @@ func (h *PetrographyClasticHandler) PetrographyClasticRoutes(g *echo.Group) {
 	clastic.POST("", h.CreatePetrographyClastic)
 	clastic.GET("", h.GetPetrographyClasticRecords)
 	clastic.GET("/search", h.SearchPetrographyClasticRecords)
+	clastic.GET("/columns", h.GetPetrographyClasticColumns)
+	clastic.GET("/export", h.ExportPetrographyClasticRecords)
+	clastic.GET("/las", h.ExportPetrographyClasticLAS)
 	clastic.GET("/:id", h.GetPetrographyClastic)
 	clastic.PUT("/:id", h.UpdatePetrographyClastic)
 	clastic.DELETE("/:id", h.DeletePetrographyClastic)
@@ -46,6 +70,23 @@ func (h *PetrographyClasticHandler) CreatePetrographyClastic(c echo.Context) err
 		})
 	}
 
+	if err := resolveRecordCoordinates(&record.EPBEBase, &record.MetadataInfo); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid coordinates: " + err.Error(),
+		})
+	}
+	if err := models.ApplyTimescale(&record.EPBEBase, &record.MetadataInfo); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	if err := models.PrepareSamples(h.db, &record); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create petrography clastic record",
+		})
+	}
+
 	// Create record
 	if err := h.db.Create(&record).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
@@ -82,38 +123,63 @@ func (h *PetrographyClasticHandler) GetPetrographyClastic(c echo.Context) error
 	return c.JSON(http.StatusOK, record)
 }
 
-// GetPetrographyClasticRecords retrieves all petrography clastic records with pagination
+// GetPetrographyClasticRecords retrieves petrography clastic records with filtering, sorting, sparse fieldsets,
+// offset or cursor pagination, and optional NDJSON streaming
 func (h *PetrographyClasticHandler) GetPetrographyClasticRecords(c echo.Context) error {
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(c.QueryParam("page"))
-	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	// Parse filter, sort and fields parameters
+	listQuery, err := parseListQuery(c, h.db, &models.EPBEPetrographyClastic{})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	query := h.db.Model(&models.EPBEPetrographyClastic{}).Scopes(listQuery.Where())
+
+	return respondList[models.EPBEPetrographyClastic](c, h.db, query, listQuery, "Failed to retrieve petrography clastic records", nil)
+}
 
-	pagination := &models.Pagination{
-		Page:  page,
-		Limit: limit,
+// ExportPetrographyClasticRecords exports every record matching the list filters as CSV or XLSX in ePBE column order
+func (h *PetrographyClasticHandler) ExportPetrographyClasticRecords(c echo.Context) error {
+	req, err := parseExportRequest(c, h.db, &models.EPBEPetrographyClastic{}, "petrography_clastic")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
 	}
 
-	var records []models.EPBEPetrographyClastic
-	var total int64
+	query := h.db.Model(&models.EPBEPetrographyClastic{}).Scopes(req.ListQuery.Where())
+	if req.Search != "" {
+		query = query.Scopes(database.Search(req.Search, petrographyClasticSearchFields...))
+	}
 
-	// Count total records
-	h.db.Model(&models.EPBEPetrographyClastic{}).Count(&total)
+	slog.InfoContext(c.Request().Context(), "📤 Exporting petrography clastic records", "format", req.Format, "units", req.Units)
+	return exportRecords[models.EPBEPetrographyClastic](c, h.db, query, req)
+}
 
-	// Get records with pagination
-	if err := h.db.Scopes(database.Paginate(pagination)).Find(&records).Error; err != nil {
+// ExportPetrographyClasticLAS exports one well's samples as a LAS 2.0 file indexed by a depth column
+func (h *PetrographyClasticHandler) ExportPetrographyClasticLAS(c echo.Context) error {
+	req, err := parseLASRequest(c, h.db, &models.EPBEPetrographyClastic{}, "petrography_clastic")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return exportLAS[models.EPBEPetrographyClastic](c, h.db, req)
+}
+
+// GetPetrographyClasticColumns lists the columns that can be used in filter, sort and fields parameters
+func (h *PetrographyClasticHandler) GetPetrographyClasticColumns(c echo.Context) error {
+	columns, err := database.ModelColumns(h.db, &models.EPBEPetrographyClastic{})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to retrieve petrography clastic records",
+			"error": "Failed to describe petrography clastic columns",
 		})
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"records": records,
-		"pagination": map[string]interface{}{
-			"page":        pagination.GetPage(),
-			"limit":       pagination.GetLimit(),
-			"total":       total,
-			"total_pages": (total + int64(pagination.GetLimit()) - 1) / int64(pagination.GetLimit()),
-		},
+		"columns": columns,
 	})
 }
 
@@ -159,11 +225,28 @@ func (h *PetrographyClasticHandler) UpdatePetrographyClastic(c echo.Context) err
 	record.UWI = updateData.UWI
 	record.Latitude = updateData.Latitude
 	record.Longitude = updateData.Longitude
+	record.CoordinateCRS = updateData.CoordinateCRS
+	record.RawX = updateData.RawX
+	record.RawY = updateData.RawY
+	if err := resolveRecordCoordinates(&record.EPBEBase, &record.MetadataInfo); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid coordinates: " + err.Error(),
+		})
+	}
+	// A formation or reservoir edited by hand is no longer taken from the well's tops
+	if updateData.FormationName != record.FormationName || updateData.ReservoirName != record.ReservoirName {
+		record.FormationAssignment = ""
+	}
 	record.FormationName = updateData.FormationName
 	record.ReservoirName = updateData.ReservoirName
 	record.Period = updateData.Period
 	record.Epoch = updateData.Epoch
 	record.Age = updateData.Age
+	if err := models.ApplyTimescale(&record.EPBEBase, &record.MetadataInfo); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
 	record.OnshoreOffshore = updateData.OnshoreOffshore
 	record.WaterDepthM = updateData.WaterDepthM
 	record.WaterDepthFt = updateData.WaterDepthFt
@@ -318,6 +401,11 @@ func (h *PetrographyClasticHandler) UpdatePetrographyClastic(c echo.Context) err
 	record.MetadataDataSourceName = updateData.MetadataDataSourceName
 	record.SessionID = updateData.SessionID
 
+	if err := models.PrepareSamples(h.db, &record); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update petrography clastic record",
+		})
+	}
 	if err := h.db.Save(&record).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to update petrography clastic record",
@@ -362,7 +450,7 @@ func (h *PetrographyClasticHandler) DeletePetrographyClastic(c echo.Context) err
 	})
 }
 
-// SearchPetrographyClasticRecords searches petrography clastic records by various fields
+// SearchPetrographyClasticRecords searches petrography clastic records by various fields, combined with any structured filter
 func (h *PetrographyClasticHandler) SearchPetrographyClasticRecords(c echo.Context) error {
 	query := c.QueryParam("q")
 	if query == "" {
@@ -371,56 +459,21 @@ func (h *PetrographyClasticHandler) SearchPetrographyClasticRecords(c echo.Conte
 		})
 	}
 
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(c.QueryParam("page"))
-	limit, _ := strconv.Atoi(c.QueryParam("limit"))
-
-	pagination := &models.Pagination{
-		Page:  page,
-		Limit: limit,
+	// Parse filter, sort and fields parameters
+	listQuery, err := parseListQuery(c, h.db, &models.EPBEPetrographyClastic{})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
 	}
 
-	var records []models.EPBEPetrographyClastic
-	var total int64
-
-	// Count total records with search
+	// Search text columns and apply the structured filter
 	searchQuery := h.db.Model(&models.EPBEPetrographyClastic{}).Scopes(
-		database.Search(query,
-			"country",
-			"region",
-			"sub_region",
-			"basin",
-			"sub_basin",
-			"well_name_field_name",
-			"uwi",
-			"formation_name",
-			"reservoir_name",
-			"period",
-			"epoch",
-			"age",
-			"grain_size",
-			"grain_shape",
-			"lithofacies",
-			"analysis_types",
-		),
+		listQuery.Where(),
+		database.Search(query, petrographyClasticSearchFields...),
 	)
-	searchQuery.Count(&total)
-
-	// Get records with search and pagination
-	if err := searchQuery.Scopes(database.Paginate(pagination)).Find(&records).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to search petrography clastic records",
-		})
-	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"records": records,
-		"pagination": map[string]interface{}{
-			"page":        pagination.GetPage(),
-			"limit":       pagination.GetLimit(),
-			"total":       total,
-			"total_pages": (total + int64(pagination.GetLimit()) - 1) / int64(pagination.GetLimit()),
-		},
+	return respondList[models.EPBEPetrographyClastic](c, h.db, searchQuery, listQuery, "Failed to search petrography clastic records", map[string]interface{}{
 		"query": query,
 	})
 }
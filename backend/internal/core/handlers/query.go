@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"workbench/internal/core/models"
+	"workbench/internal/database"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// MIMEApplicationNDJSON is the content type of newline delimited JSON streams
+const MIMEApplicationNDJSON = "application/x-ndjson"
+
+// streamBatchSize is the number of rows fetched per keyset query while streaming
+const streamBatchSize = 500
+
+// parseListQuery validates the filter, sort and fields query parameters against the model's known columns
+func parseListQuery(c echo.Context, db *gorm.DB, model interface{}) (*database.ListQuery, error) {
+	columns, err := database.ModelColumns(db, model)
+	if err != nil {
+		return nil, err
+	}
+	return database.ParseListQuery(c.QueryParams(), columns)
+}
+
+// parsePagination reads the page, limit and cursor query parameters.
+// Passing cursor (even empty) switches the request to keyset pagination.
+func parsePagination(c echo.Context) *models.Pagination {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	pagination := &models.Pagination{
+		Page:  page,
+		Limit: limit,
+	}
+	if _, ok := c.QueryParams()["cursor"]; ok {
+		cursor := c.QueryParam("cursor")
+		pagination.Cursor = &cursor
+	}
+	return pagination
+}
+
+// wantsTotal reports whether the total row count should be computed. It defaults to true
+// for page/offset requests, for backwards compatibility, and to false for cursor requests.
+func wantsTotal(c echo.Context, pagination *models.Pagination) bool {
+	if value, err := strconv.ParseBool(c.QueryParam("include_total")); err == nil {
+		return value
+	}
+	return !pagination.IsCursor()
+}
+
+// wantsStream reports whether the client asked for an NDJSON stream of all matching rows
+func wantsStream(c echo.Context) bool {
+	if strings.EqualFold(c.QueryParam("format"), "ndjson") {
+		return true
+	}
+	return strings.Contains(c.Request().Header.Get(echo.HeaderAccept), MIMEApplicationNDJSON)
+}
+
+// respondList answers a list or search request for query, which must already carry the model and
+// any WHERE conditions. It handles offset pagination, keyset pagination and NDJSON streaming.
+// extra is merged into the JSON response body.
+func respondList[T any](c echo.Context, db *gorm.DB, query *gorm.DB, listQuery *database.ListQuery, errorMessage string, extra map[string]interface{}) error {
+	if wantsStream(c) {
+		return streamRecords[T](c, db, query, listQuery, errorMessage)
+	}
+
+	pagination := parsePagination(c)
+
+	var total int64
+	includeTotal := wantsTotal(c, pagination)
+	if includeTotal {
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": errorMessage,
+			})
+		}
+	}
+
+	var records []T
+	paginationInfo := map[string]interface{}{}
+
+	if pagination.IsCursor() {
+		after, err := listQuery.After(*pagination.Cursor, "id")
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		// Fetch one extra row to know whether another page exists
+		limit := pagination.GetCursorLimit()
+		if err := query.Session(&gorm.Session{}).Scopes(
+			after,
+			listQuery.Select(),
+			database.OrderBy(listQuery.OrderClause("id")),
+		).Limit(limit + 1).Find(&records).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": errorMessage,
+			})
+		}
+
+		hasMore := len(records) > limit
+		if hasMore {
+			records = records[:limit]
+		}
+
+		var nextCursor string
+		if hasMore {
+			nextCursor, err = listQuery.NextCursor(db, &records[len(records)-1], "id")
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": errorMessage,
+				})
+			}
+		}
+
+		paginationInfo["limit"] = limit
+		paginationInfo["next_cursor"] = nextCursor
+		paginationInfo["has_more"] = hasMore
+		if includeTotal {
+			paginationInfo["total"] = total
+		}
+	} else {
+		if err := query.Session(&gorm.Session{}).Scopes(
+			listQuery.Select(),
+			database.OrderBy(listQuery.OrderClause("id")),
+			database.Paginate(pagination),
+		).Find(&records).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": errorMessage,
+			})
+		}
+
+		paginationInfo["page"] = pagination.GetPage()
+		paginationInfo["limit"] = pagination.GetLimit()
+		if includeTotal {
+			paginationInfo["total"] = total
+			paginationInfo["total_pages"] = (total + int64(pagination.GetLimit()) - 1) / int64(pagination.GetLimit())
+		}
+	}
+
+	result, err := selectFields(records, listQuery.Fields)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": errorMessage,
+		})
+	}
+
+	response := map[string]interface{}{
+		"records":    result,
+		"pagination": paginationInfo,
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// streamRecords writes every row matching query as newline delimited JSON
+func streamRecords[T any](c echo.Context, db *gorm.DB, query *gorm.DB, listQuery *database.ListQuery, errorMessage string) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, MIMEApplicationNDJSON)
+	res.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(res)
+	streamed := 0
+
+	err := scanBatches[T](c, db, query.Scopes(listQuery.Select()), listQuery, func(batch []T) error {
+		for i := range batch {
+			item, err := projectRecord(&batch[i], listQuery.Fields)
+			if err != nil {
+				return err
+			}
+			if err := encoder.Encode(item); err != nil {
+				return err
+			}
+			streamed++
+		}
+		res.Flush()
+		return nil
+	})
+	if err != nil {
+		// Headers are already sent, so report the failure as the last line of the stream
+		slog.ErrorContext(c.Request().Context(), "❌ Stream aborted", "rows", streamed, "error", err)
+		encoder.Encode(map[string]string{"error": errorMessage})
+	}
+
+	return nil
+}
+
+// scanBatches reads every row matching query in keyset batches and hands each batch to fn.
+// Memory stays flat and concurrent inserts cannot shift the scan the way OFFSET would.
+func scanBatches[T any](c echo.Context, db *gorm.DB, query *gorm.DB, listQuery *database.ListQuery, fn func(batch []T) error) error {
+	cursor := ""
+	for {
+		after, err := listQuery.After(cursor, "id")
+		if err != nil {
+			return err
+		}
+
+		var batch []T
+		if err := query.Session(&gorm.Session{}).WithContext(c.Request().Context()).Scopes(
+			after,
+			database.OrderBy(listQuery.OrderClause("id")),
+		).Limit(streamBatchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+
+		if err := fn(batch); err != nil {
+			return err
+		}
+
+		if len(batch) < streamBatchSize {
+			return nil
+		}
+		cursor, err = listQuery.NextCursor(db, &batch[len(batch)-1], "id")
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// selectFields projects records onto the requested fieldset using their JSON names,
+// which match the column names for the ePBE models
+func selectFields[T any](records []T, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return records, nil
+	}
+
+	projected := make([]interface{}, 0, len(records))
+	for i := range records {
+		item, err := projectRecord(&records[i], fields)
+		if err != nil {
+			return nil, err
+		}
+		projected = append(projected, item)
+	}
+	return projected, nil
+}
+
+// projectRecord keeps only the requested fields of a single record
+func projectRecord(record interface{}, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return record, nil
+	}
+
+	content, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	var row map[string]json.RawMessage
+	if err := json.Unmarshal(content, &row); err != nil {
+		return nil, fmt.Errorf("failed to decode record: %w", err)
+	}
+
+	item := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := row[field]; ok {
+			item[field] = value
+		}
+	}
+	return item, nil
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"workbench/internal/core/models"
+	"workbench/internal/queue"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// errShuttingDown fails jobs refused or interrupted by a shutdown
+var errShuttingDown = errors.New("interrupted: the server is shutting down")
+
+// jobKillGrace is how long Shutdown waits for killed extractions to record their failure
+const jobKillGrace = 5 * time.Second
+
+// workerName identifies this host in extraction jobs
+var workerName = func() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return host
+}()
+
+// WorkerName returns the name this host records on the extraction jobs it runs
+func WorkerName() string {
+	return workerName
+}
+
+// startJob registers a running extraction or import; it returns false once a shutdown has started
+func (h *ExtractionHandler) startJob() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	select {
+	case <-h.draining:
+		return false
+	default:
+	}
+	h.active.Add(1)
+	return true
+}
+
+// isDraining reports whether a shutdown has started
+func (h *ExtractionHandler) isDraining() bool {
+	select {
+	case <-h.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// shuttingDown answers requests for new jobs during a shutdown
+func shuttingDown(c echo.Context) error {
+	c.Response().Header().Set("Retry-After", "30")
+	return c.JSON(http.StatusServiceUnavailable, map[string]string{
+		"error": "Server is shutting down, retry shortly",
+	})
+}
+
+// Shutdown stops accepting extractions and imports and waits until ctx is done for the running
+// ones. Imports stop after their current chunk, which is committed with their progress, so they
+// can be resumed. The workers go on taking queued extractions until no request of this replica
+// waits for one. Extractions still running at the deadline are killed and recorded as failed.
+func (h *ExtractionHandler) Shutdown(ctx context.Context) error {
+	// Other replicas take over this one's reserved jobs once its heartbeats stop
+	defer h.stopWatching()
+	h.mu.Lock()
+	if !h.isDraining() {
+		close(h.draining)
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.active.Wait()
+		h.stopConsuming()
+		h.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	h.stopConsuming()
+	h.cancelJobs()
+	select {
+	case <-done:
+	case <-time.After(jobKillGrace):
+	}
+	return fmt.Errorf("jobs still running at the shutdown deadline were interrupted: %w", ctx.Err())
+}
+
+// RecoverJobs fails the extractions a previous run of this host left running, and those of any host
+// started longer ago than the extraction timeout, which cannot still be running. Jobs this host queued
+// are failed too unless the queue is shared, where they wait for any replica; the messages this host
+// had taken but not finished are requeued, and skipped by the workers once their job is failed. Those
+// of other replicas that stopped sending heartbeats are requeued with their jobs, to run again. It
+// returns the number of extractions failed and of imports waiting to be resumed.
+func (h *ExtractionHandler) RecoverJobs(ctx context.Context) (int64, int64, error) {
+	now := time.Now()
+	stale := h.db.Where("status = ? AND (worker = ? OR started_at < ?)",
+		models.ExtractionStatusRunning, workerName, now.Add(-h.extraction.Timeout))
+	if !h.queue.Shared() {
+		stale = stale.Or("status = ? AND worker = ?", models.ExtractionStatusQueued, workerName)
+	}
+	result := h.db.WithContext(ctx).Model(&models.ExtractionJob{}).Where(stale).
+		Updates(map[string]interface{}{
+			"status":      models.ExtractionStatusFailed,
+			"error":       "interrupted: the server stopped before the extraction finished",
+			"finished_at": now,
+		})
+	if result.Error != nil {
+		return 0, 0, fmt.Errorf("failed to recover extraction jobs: %w", result.Error)
+	}
+	consumers, _, err := queue.Recover(ctx, h.queue, extractionQueue)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := h.requeueExtractionJobs(ctx, consumers); err != nil {
+		return 0, 0, err
+	}
+
+	var imports int64
+	if err := h.db.WithContext(ctx).Model(&models.ImportJob{}).
+		Where("status = ?", models.ImportStatusInProgress).Count(&imports).Error; err != nil {
+		return 0, 0, fmt.Errorf("failed to count unfinished imports: %w", err)
+	}
+	return result.RowsAffected, imports, nil
+}
+
+// requeueExtractionJobs puts the jobs that stopped workers left running back in the queued state, so
+// the messages requeued for them are run by the next free worker instead of being skipped
+func (h *ExtractionHandler) requeueExtractionJobs(ctx context.Context, workers []string) error {
+	if h.db == nil || len(workers) == 0 {
+		return nil
+	}
+	result := h.db.WithContext(ctx).Model(&models.ExtractionJob{}).
+		Where("status = ? AND worker IN ?", models.ExtractionStatusRunning, workers).
+		Updates(map[string]interface{}{
+			"status":     models.ExtractionStatusQueued,
+			"started_at": nil,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to requeue extraction jobs: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		slog.WarnContext(ctx, "♻️ Requeued extractions of stopped workers", "workers", workers, "count", result.RowsAffected)
+	}
+	return nil
+}
+
+// claimExtractionJob marks a queued job running on this host. It returns false when the job is gone
+// or no longer queued, having been run or failed already.
+func (h *ExtractionHandler) claimExtractionJob(ctx context.Context, id uuid.UUID) bool {
+	if h.db == nil {
+		return true
+	}
+	result := h.db.WithContext(ctx).Model(&models.ExtractionJob{}).
+		Where("id = ? AND status = ?", id, models.ExtractionStatusQueued).
+		Updates(map[string]interface{}{
+			"status":     models.ExtractionStatusRunning,
+			"worker":     workerName,
+			"started_at": time.Now(),
+		})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "❌ Failed to claim extraction job", "error", result.Error)
+		return false
+	}
+	return result.RowsAffected == 1
+}
+
+// finishExtractionJob records the outcome of an extraction, with the numbers of tables and rows found
+func (h *ExtractionHandler) finishExtractionJob(ctx context.Context, id uuid.UUID, results map[string]interface{}, err error) {
+	if h.db == nil {
+		return
+	}
+	tables, rows := extractionSummary(results)
+	updates := map[string]interface{}{
+		"status":       models.ExtractionStatusCompleted,
+		"error":        "",
+		"tables_found": tables,
+		"rows_found":   rows,
+		"finished_at":  time.Now(),
+	}
+	if err != nil {
+		updates["status"], updates["error"] = models.ExtractionStatusFailed, err.Error()
+	}
+	// The outcome is recorded even when the request was cancelled
+	if err := h.db.WithContext(context.WithoutCancel(ctx)).Model(&models.ExtractionJob{}).
+		Where("id = ?", id).Updates(updates).Error; err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to record extraction outcome", "error", err)
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"workbench/internal/storage"
+
+	"github.com/labstack/echo/v4"
+)
+
+// StorageHandler serves the signed URLs of the local storage backend; an S3 service serves its own
+type StorageHandler struct {
+	local *storage.Local
+}
+
+func NewStorageHandler(local *storage.Local) *StorageHandler {
+	return &StorageHandler{local: local}
+}
+
+func (h *StorageHandler) StorageRoutes(g *echo.Group) {
+	route := strings.TrimPrefix(storage.LocalRoute, "/api/v1") + "*"
+	g.GET(route, h.ServeSigned)
+	g.HEAD(route, h.ServeSigned)
+}
+
+// ServeSigned streams an object when the URL's signature is valid and has not expired
+func (h *StorageHandler) ServeSigned(c echo.Context) error {
+	ctx := c.Request().Context()
+	key := c.Param("*")
+	if err := h.local.Verify(key, c.QueryParams()); err != nil {
+		slog.WarnContext(ctx, "❌ Refused signed URL", "key", key, "error", err)
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "Invalid or expired URL",
+		})
+	}
+
+	reader, object, err := h.local.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "File not found",
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to read file",
+		})
+	}
+	defer reader.Close()
+
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		c.Response().Header().Set("Content-Type", contentType)
+	}
+	if disposition := c.QueryParam("disposition"); disposition != "" {
+		c.Response().Header().Set("Content-Disposition", disposition)
+	}
+	// Browsers may keep the file while the URL is valid
+	expires, _ := strconv.ParseInt(c.QueryParam("expires"), 10, 64)
+	maxAge := max(expires-time.Now().Unix(), 0)
+	serveObject(c, reader, object, path.Base(key), objectETag(object), fmt.Sprintf("private, max-age=%d", maxAge))
+	return nil
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"workbench/internal/timescale"
+
+	"github.com/labstack/echo/v4"
+)
+
+type TimescaleHandler struct{}
+
+func NewTimescaleHandler() *TimescaleHandler {
+	return &TimescaleHandler{}
+}
+
+func (h *TimescaleHandler) TimescaleRoutes(g *echo.Group) {
+	ts := g.Group("/timescale")
+	ts.GET("/parse", h.ParseTime)
+	ts.GET("/derive", h.DeriveTime)
+	ts.GET("/units", h.GetUnits)
+}
+
+// ParseTime reads a geologic time (unit name, abbreviation, range or age in Ma) and returns its span
+// with the chart units containing it
+func (h *TimescaleHandler) ParseTime(c echo.Context) error {
+	text := c.QueryParam("q")
+	interval, err := timescale.Parse(text)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"text":     text,
+		"interval": interval,
+		"period":   timescale.Containing(interval, timescale.RankPeriod),
+		"epoch":    timescale.Containing(interval, timescale.RankEpoch),
+		"age":      timescale.Containing(interval, timescale.RankAge),
+	})
+}
+
+// DeriveTime checks a record's period, epoch and age against each other and returns the levels they imply
+func (h *TimescaleHandler) DeriveTime(c echo.Context) error {
+	derivation, err := timescale.Derive(c.QueryParam("period"), c.QueryParam("epoch"), c.QueryParam("age"))
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, derivation)
+}
+
+// GetUnits lists the chart units, optionally of one rank or below one parent
+func (h *TimescaleHandler) GetUnits(c echo.Context) error {
+	rank := timescale.Rank(strings.ToLower(c.QueryParam("rank")))
+	switch rank {
+	case "", timescale.RankPeriod, timescale.RankEpoch, timescale.RankAge:
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "rank must be period, epoch or age",
+		})
+	}
+
+	var parent *timescale.Unit
+	if name := c.QueryParam("parent"); name != "" {
+		if parent = timescale.Lookup(name); parent == nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Unknown parent unit: " + name,
+			})
+		}
+	}
+
+	units := []timescale.Unit{}
+	for _, unit := range timescale.Units {
+		if rank != "" && unit.Rank != rank {
+			continue
+		}
+		if parent != nil && unit.Parent != parent.Name {
+			continue
+		}
+		units = append(units, unit)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"units": units,
+		"count": len(units),
+	})
+}
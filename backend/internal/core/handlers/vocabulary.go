@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"workbench/internal/core/models"
+	"workbench/internal/database"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type VocabularyHandler struct {
+	db *gorm.DB
+}
+
+func NewVocabularyHandler(db *gorm.DB) *VocabularyHandler {
+	return &VocabularyHandler{db: db}
+}
+
+func (h *VocabularyHandler) VocabularyRoutes(g *echo.Group) {
+	vocabularies := g.Group("/vocabularies")
+	vocabularies.GET("", h.GetVocabularies)
+	vocabularies.GET("/lookup", h.LookupVocabularyValue)
+	vocabularies.GET("/unknown", h.GetUnknownValues)
+	vocabularies.POST("/unknown/:id/resolve", h.ResolveUnknownValue)
+	vocabularies.DELETE("/unknown/:id", h.DismissUnknownValue)
+	vocabularies.GET("/:vocabulary/terms", h.GetVocabularyTerms)
+	vocabularies.POST("/:vocabulary/terms", h.CreateVocabularyTerm)
+	vocabularies.PUT("/:vocabulary/terms/:id", h.UpdateVocabularyTerm)
+	vocabularies.DELETE("/:vocabulary/terms/:id", h.DeleteVocabularyTerm)
+}
+
+// vocabularyTermRequest is the body of term create and update requests
+type vocabularyTermRequest struct {
+	Value    string   `json:"value"`
+	Synonyms []string `json:"synonyms"`
+	ParentID *uint    `json:"parent_id"`
+}
+
+// GetVocabularies lists the controlled vocabularies with their columns, parent and term count
+func (h *VocabularyHandler) GetVocabularies(c echo.Context) error {
+	var counts []struct {
+		Vocabulary string
+		Count      int64
+	}
+	if err := h.db.Model(&models.VocabularyTerm{}).Select("vocabulary, COUNT(*) AS count").Group("vocabulary").Scan(&counts).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve vocabularies",
+		})
+	}
+	termCounts := map[string]int64{}
+	for _, count := range counts {
+		termCounts[count.Vocabulary] = count.Count
+	}
+
+	names := make([]string, 0, len(models.VocabularyColumns))
+	for name := range models.VocabularyColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vocabularies := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		vocabularies = append(vocabularies, map[string]interface{}{
+			"name":    name,
+			"parent":  models.VocabularyParents[name],
+			"columns": models.VocabularyColumns[name],
+			"terms":   termCounts[name],
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"vocabularies": vocabularies,
+	})
+}
+
+// GetVocabularyTerms lists the terms of a vocabulary for dropdowns. parent narrows them to the children
+// of a term (by ID or value) and q to values starting with it.
+func (h *VocabularyHandler) GetVocabularyTerms(c echo.Context) error {
+	vocabulary := c.Param("vocabulary")
+	if _, ok := models.VocabularyColumns[vocabulary]; !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Vocabulary not found",
+		})
+	}
+
+	query := h.db.Preload("Synonyms").Where("vocabulary = ?", vocabulary)
+
+	if parent := strings.TrimSpace(c.QueryParam("parent")); parent != "" {
+		parentID, err := strconv.ParseUint(parent, 10, 32)
+		if err != nil {
+			term, err := models.LookupVocabulary(h.db, models.VocabularyParents[vocabulary], parent)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": "Failed to retrieve vocabulary terms",
+				})
+			}
+			if term == nil {
+				return c.JSON(http.StatusOK, map[string]interface{}{
+					"vocabulary": vocabulary,
+					"terms":      []models.VocabularyTerm{},
+					"count":      0,
+				})
+			}
+			parentID = uint64(term.ID)
+		}
+		query = query.Where("parent_id = ?", uint(parentID))
+	}
+
+	if q := strings.TrimSpace(c.QueryParam("q")); q != "" {
+		query = query.Where("LOWER(value) LIKE ?", strings.ToLower(q)+"%")
+	}
+
+	var terms []models.VocabularyTerm
+	if err := query.Order("value").Find(&terms).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve vocabulary terms",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"vocabulary": vocabulary,
+		"terms":      terms,
+		"count":      len(terms),
+	})
+}
+
+// CreateVocabularyTerm adds a term with its synonyms and rewrites existing records that use any of them
+func (h *VocabularyHandler) CreateVocabularyTerm(c echo.Context) error {
+	var request vocabularyTermRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	term := models.VocabularyTerm{Vocabulary: c.Param("vocabulary"), Value: request.Value, ParentID: request.ParentID}
+	if err := database.SaveVocabularyTerm(h.db, &term, request.Synonyms); err != nil {
+		return vocabularyError(c, err, "Failed to create vocabulary term")
+	}
+
+	return h.respondWithRewrite(c, http.StatusCreated, &term)
+}
+
+// UpdateVocabularyTerm replaces a term's value, parent and synonyms
+func (h *VocabularyHandler) UpdateVocabularyTerm(c echo.Context) error {
+	term, err := h.findTerm(c.Param("vocabulary"), c.Param("id"))
+	if err != nil {
+		return vocabularyError(c, err, "Failed to retrieve vocabulary term")
+	}
+
+	var request vocabularyTermRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	term.Value = request.Value
+	term.ParentID = request.ParentID
+	if err := database.SaveVocabularyTerm(h.db, term, request.Synonyms); err != nil {
+		return vocabularyError(c, err, "Failed to update vocabulary term")
+	}
+
+	return h.respondWithRewrite(c, http.StatusOK, term)
+}
+
+// DeleteVocabularyTerm deletes a term without children; records keep their values
+func (h *VocabularyHandler) DeleteVocabularyTerm(c echo.Context) error {
+	term, err := h.findTerm(c.Param("vocabulary"), c.Param("id"))
+	if err != nil {
+		return vocabularyError(c, err, "Failed to retrieve vocabulary term")
+	}
+
+	var children int64
+	if err := h.db.Model(&models.VocabularyTerm{}).Where("parent_id = ?", term.ID).Count(&children).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete vocabulary term",
+		})
+	}
+	if children > 0 {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":    "Vocabulary term still has child terms",
+			"children": children,
+		})
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("term_id = ?", term.ID).Delete(&models.VocabularySynonym{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(term).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete vocabulary term",
+		})
+	}
+	models.InvalidateVocabularies()
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Vocabulary term deleted successfully",
+	})
+}
+
+// LookupVocabularyValue returns the canonical term for a value of a vocabulary
+func (h *VocabularyHandler) LookupVocabularyValue(c echo.Context) error {
+	vocabulary := c.QueryParam("vocabulary")
+	value := c.QueryParam("value")
+	if _, ok := models.VocabularyColumns[vocabulary]; !ok || strings.TrimSpace(value) == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "vocabulary and value are required",
+		})
+	}
+
+	term, err := models.LookupVocabulary(h.db, vocabulary, value)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to look up value",
+		})
+	}
+
+	response := map[string]interface{}{
+		"vocabulary": vocabulary,
+		"value":      value,
+		"known":      term != nil,
+		"canonical":  nil,
+		"term":       term,
+	}
+	if term != nil {
+		response["canonical"] = term.Value
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetUnknownValues lists values that matched no term, most frequent first
+func (h *VocabularyHandler) GetUnknownValues(c echo.Context) error {
+	query := h.db.Model(&models.VocabularyUnknown{})
+	if vocabulary := c.QueryParam("vocabulary"); vocabulary != "" {
+		query = query.Where("vocabulary = ?", vocabulary)
+	}
+
+	var unknown []models.VocabularyUnknown
+	if err := query.Order("occurrences DESC, value").Find(&unknown).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve unknown values",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"unknown": unknown,
+		"count":   len(unknown),
+	})
+}
+
+// ResolveUnknownValue curates an unknown value, either as a synonym of term_id or as a new term
+// (with an optional parent_id), and rewrites the records holding it
+func (h *VocabularyHandler) ResolveUnknownValue(c echo.Context) error {
+	var unknown models.VocabularyUnknown
+	if err := h.db.Where("id = ?", c.Param("id")).Take(&unknown).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Unknown value not found",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve unknown value",
+		})
+	}
+
+	var request struct {
+		TermID   *uint `json:"term_id"`
+		ParentID *uint `json:"parent_id"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	var term *models.VocabularyTerm
+	var synonyms []string
+	if request.TermID != nil {
+		existing, err := h.findTerm(unknown.Vocabulary, strconv.FormatUint(uint64(*request.TermID), 10))
+		if err != nil {
+			return vocabularyError(c, err, "Failed to retrieve vocabulary term")
+		}
+		term = existing
+		for _, synonym := range term.Synonyms {
+			synonyms = append(synonyms, synonym.Value)
+		}
+		synonyms = append(synonyms, unknown.Value)
+	} else {
+		term = &models.VocabularyTerm{Vocabulary: unknown.Vocabulary, Value: unknown.Value, ParentID: request.ParentID}
+	}
+
+	// Saving the term also clears the unknown entry
+	if err := database.SaveVocabularyTerm(h.db, term, synonyms); err != nil {
+		return vocabularyError(c, err, "Failed to resolve unknown value")
+	}
+
+	return h.respondWithRewrite(c, http.StatusOK, term)
+}
+
+// DismissUnknownValue removes a value from the curation list; it is listed again if seen again
+func (h *VocabularyHandler) DismissUnknownValue(c echo.Context) error {
+	result := h.db.Where("id = ?", c.Param("id")).Delete(&models.VocabularyUnknown{})
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to dismiss unknown value",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Unknown value not found",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Unknown value dismissed",
+	})
+}
+
+// respondWithRewrite normalizes stored records to a saved term and returns the term with the count
+func (h *VocabularyHandler) respondWithRewrite(c echo.Context, status int, term *models.VocabularyTerm) error {
+	rewritten, err := database.RewriteVocabularyValues(h.db, term)
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "❌ Failed to rewrite vocabulary values", "vocabulary", term.Vocabulary, "value", term.Value, "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Term saved but existing records could not be normalized",
+		})
+	}
+	if rewritten > 0 {
+		slog.InfoContext(c.Request().Context(), "📚 Normalized records", "records", rewritten, "vocabulary", term.Vocabulary, "value", term.Value)
+	}
+
+	return c.JSON(status, map[string]interface{}{
+		"term":            term,
+		"records_updated": rewritten,
+	})
+}
+
+// findTerm loads a term of a vocabulary with its synonyms
+func (h *VocabularyHandler) findTerm(vocabulary, id string) (*models.VocabularyTerm, error) {
+	termID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, errInvalidID
+	}
+
+	var term models.VocabularyTerm
+	if err := h.db.Preload("Synonyms").Where("id = ? AND vocabulary = ?", uint(termID), vocabulary).Take(&term).Error; err != nil {
+		return nil, err
+	}
+	return &term, nil
+}
+
+// vocabularyError answers a failed vocabulary lookup or save
+func vocabularyError(c echo.Context, err error, message string) error {
+	switch {
+	case errors.Is(err, errInvalidID):
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid term ID",
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Vocabulary term not found",
+		})
+	case errors.Is(err, database.ErrUnknownVocabulary):
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Vocabulary not found",
+		})
+	case errors.Is(err, database.ErrSynonymTaken):
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": err.Error(),
+		})
+	case errors.Is(err, database.ErrInvalidParent), errors.Is(err, database.ErrTermValueRequired):
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	default:
+		slog.ErrorContext(c.Request().Context(), "❌ "+message, "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": message,
+		})
+	}
+}
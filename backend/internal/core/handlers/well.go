@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"workbench/internal/coordinates"
+	"workbench/internal/core/models"
+	"workbench/internal/database"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// wellSearchFields are the text columns matched by the free-text q parameter
+var wellSearchFields = []string{
+	"uwi", "well_name_field_name", "country", "region", "sub_region", "basin", "sub_basin",
+}
+
+var errInvalidID = errors.New("invalid ID")
+
+type WellHandler struct {
+	db *gorm.DB
+}
+
+func NewWellHandler(db *gorm.DB) *WellHandler {
+	return &WellHandler{db: db}
+}
+
+func (h *WellHandler) WellRoutes(g *echo.Group) {
+	wells := g.Group("/wells")
+	wells.POST("", h.CreateWell)
+	wells.GET("", h.GetWells)
+	wells.GET("/search", h.SearchWells)
+	wells.GET("/geojson", h.GetWellsGeoJSON)
+	wells.POST("/deduplicate", h.DeduplicateWells)
+	wells.POST("/assign-formations", h.AssignFormations)
+	wells.POST("/formation-tops/import", h.ImportFormationTops)
+	wells.GET("/:id", h.GetWell)
+	wells.PUT("/:id", h.UpdateWell)
+	wells.DELETE("/:id", h.DeleteWell)
+
+	// Formation tops of a well
+	wells.GET("/:id/formation-tops", h.GetFormationTops)
+	wells.POST("/:id/formation-tops", h.CreateFormationTop)
+	wells.POST("/:id/formation-tops/import", h.ImportFormationTops)
+	wells.PUT("/:id/formation-tops/:topId", h.UpdateFormationTop)
+	wells.DELETE("/:id/formation-tops/:topId", h.DeleteFormationTop)
+	wells.POST("/:id/assign-formations", h.AssignFormations)
+}
+
+// CreateWell creates a new well
+func (h *WellHandler) CreateWell(c echo.Context) error {
+	var well models.Well
+
+	if err := c.Bind(&well); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Validate required fields
+	well.UWI = strings.TrimSpace(well.UWI)
+	if well.UWI == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "UWI is required",
+		})
+	}
+
+	if well.CRS != "" {
+		crs, err := coordinates.Lookup(well.CRS)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		well.CRS = crs.String()
+	}
+
+	// Check if well already exists
+	var existing models.Well
+	if err := h.db.Where("uwi = ?", well.UWI).First(&existing).Error; err == nil {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "Well with this UWI already exists",
+		})
+	}
+
+	well.ID = 0
+	if err := h.db.Create(&well).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create well",
+		})
+	}
+
+	return c.JSON(http.StatusCreated, well)
+}
+
+// GetWell retrieves a well by ID
+func (h *WellHandler) GetWell(c echo.Context) error {
+	well, err := h.findWell(c.Param("id"))
+	if err != nil {
+		return wellLookupError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, well)
+}
+
+// GetWells retrieves wells with filtering, sorting, sparse fieldsets and pagination
+func (h *WellHandler) GetWells(c echo.Context) error {
+	// Parse filter, sort and fields parameters
+	listQuery, err := parseListQuery(c, h.db, &models.Well{})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	query := h.db.Model(&models.Well{}).Scopes(listQuery.Where())
+
+	return respondList[models.Well](c, h.db, query, listQuery, "Failed to retrieve wells", nil)
+}
+
+// SearchWells searches wells by UWI, name and location, combined with any structured filter
+func (h *WellHandler) SearchWells(c echo.Context) error {
+	query := c.QueryParam("q")
+	if query == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Search query is required",
+		})
+	}
+
+	// Parse filter, sort and fields parameters
+	listQuery, err := parseListQuery(c, h.db, &models.Well{})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	searchQuery := h.db.Model(&models.Well{}).Scopes(
+		listQuery.Where(),
+		database.Search(query, wellSearchFields...),
+	)
+
+	return respondList[models.Well](c, h.db, searchQuery, listQuery, "Failed to search wells", map[string]interface{}{
+		"query": query,
+	})
+}
+
+// UpdateWell updates a well header and rewrites it on every linked sample in the same transaction
+func (h *WellHandler) UpdateWell(c echo.Context) error {
+	well, err := h.findWell(c.Param("id"))
+	if err != nil {
+		return wellLookupError(c, err)
+	}
+
+	// Bind update data
+	var updateData models.Well
+	if err := c.Bind(&updateData); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	updateData.UWI = strings.TrimSpace(updateData.UWI)
+	if updateData.UWI == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "UWI is required",
+		})
+	}
+	if updateData.UWI != well.UWI {
+		var existing models.Well
+		if err := h.db.Where("uwi = ? AND id <> ?", updateData.UWI, well.ID).First(&existing).Error; err == nil {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Well with this UWI already exists",
+			})
+		}
+	}
+
+	if updateData.CRS != "" {
+		crs, err := coordinates.Lookup(updateData.CRS)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		updateData.CRS = crs.String()
+	}
+
+	// Update well fields (exclude ID and timestamps)
+	well.UWI = updateData.UWI
+	well.WellNameFieldName = updateData.WellNameFieldName
+	well.Country = updateData.Country
+	well.Region = updateData.Region
+	well.SubRegion = updateData.SubRegion
+	well.BusinessRegions = updateData.BusinessRegions
+	well.Basin = updateData.Basin
+	well.SubBasin = updateData.SubBasin
+	well.Latitude = updateData.Latitude
+	well.Longitude = updateData.Longitude
+	well.OnshoreOffshore = updateData.OnshoreOffshore
+	well.WaterDepthM = updateData.WaterDepthM
+	well.WaterDepthFt = updateData.WaterDepthFt
+	well.CRS = updateData.CRS
+
+	var samplesUpdated int64
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// A well without a location takes it from its samples' raw coordinates once its CRS is known
+		if well.Latitude == nil && well.Longitude == nil && well.CRS != "" {
+			if err := locateWellFromSamples(tx, well); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Save(well).Error; err != nil {
+			return err
+		}
+
+		// Samples carry a copy of the header for the ePBE template; keep every copy in step
+		for _, table := range database.WellSampleTables {
+			result := tx.Table(table).Where("well_id = ?", well.ID).Updates(well.HeaderValues())
+			if result.Error != nil {
+				return result.Error
+			}
+			samplesUpdated += result.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "❌ Failed to update well", "well_id", well.ID, "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update well",
+		})
+	}
+
+	slog.InfoContext(c.Request().Context(), "🛢️ Well updated", "well", well.UWI, "samples", samplesUpdated)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"well":            well,
+		"samples_updated": samplesUpdated,
+	})
+}
+
+// DeleteWell deletes a well that no sample references
+func (h *WellHandler) DeleteWell(c echo.Context) error {
+	well, err := h.findWell(c.Param("id"))
+	if err != nil {
+		return wellLookupError(c, err)
+	}
+
+	// Soft-deleted samples still hold the foreign key, so count them too
+	var linked int64
+	for _, table := range database.WellSampleTables {
+		var count int64
+		if err := h.db.Table(table).Where("well_id = ?", well.ID).Count(&count).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to delete well",
+			})
+		}
+		linked += count
+	}
+	if linked > 0 {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":          "Well still has samples",
+			"linked_samples": linked,
+		})
+	}
+
+	if err := h.db.Delete(well).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete well",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Well deleted successfully",
+	})
+}
+
+// DeduplicateWells links samples without a well to one by UWI and reports conflicting header values
+func (h *WellHandler) DeduplicateWells(c echo.Context) error {
+	report, err := database.DeduplicateWells(h.db)
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "❌ Well deduplication failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to deduplicate wells",
+		})
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// locateWellFromSamples sets the well's location from the first linked sample whose raw coordinates
+// were waiting for a CRS, and records the well's CRS on every such sample
+func locateWellFromSamples(tx *gorm.DB, well *models.Well) error {
+	pending := "well_id = ? AND COALESCE(coordinate_crs, '') = '' AND (COALESCE(raw_x, '') <> '' OR COALESCE(raw_y, '') <> '')"
+
+	for _, table := range database.WellSampleTables {
+		var raw struct {
+			RawX string
+			RawY string
+		}
+		result := tx.Table(table).Select("raw_x, raw_y").Where(pending, well.ID).Order("id").Limit(1).Scan(&raw)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		position, err := coordinates.Resolve(raw.RawX, raw.RawY, well.CRS)
+		if err != nil {
+			slog.WarnContext(tx.Statement.Context, "⚠️ Cannot locate well from sample coordinates", "well", well.UWI, "table", table, "error", err)
+			continue
+		}
+		well.Latitude = &position.Latitude
+		well.Longitude = &position.Longitude
+		break
+	}
+	if well.Latitude == nil {
+		return nil
+	}
+
+	for _, table := range database.WellSampleTables {
+		if err := tx.Table(table).Where(pending, well.ID).Update("coordinate_crs", well.CRS).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findWell loads a well by its numeric ID
+func (h *WellHandler) findWell(id string) (*models.Well, error) {
+	wellID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, errInvalidID
+	}
+
+	var well models.Well
+	if err := h.db.Where("id = ?", uint(wellID)).First(&well).Error; err != nil {
+		return nil, err
+	}
+	return &well, nil
+}
+
+// wellLookupError answers a failed findWell
+func wellLookupError(c echo.Context, err error) error {
+	switch err {
+	case errInvalidID:
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid well ID",
+		})
+	case gorm.ErrRecordNotFound:
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Well not found",
+		})
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve well",
+		})
+	}
+}
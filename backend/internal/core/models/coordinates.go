@@ -0,0 +1,27 @@
+package models
+
+import (
+	"strings"
+
+	"workbench/internal/coordinates"
+)
+
+// SetCoordinates records a sample's source coordinates and CRS and fills latitude and longitude in WGS84.
+// When they cannot be converted, e.g. projected values without a CRS, latitude and longitude are left
+// empty and the raw values kept, so a CRS set on the well later can still resolve them.
+func SetCoordinates(base *EPBEBase, meta *MetadataInfo, rawX, rawY, crs string) error {
+	meta.RawX = strings.TrimSpace(rawX)
+	meta.RawY = strings.TrimSpace(rawY)
+	meta.CoordinateCRS = strings.TrimSpace(crs)
+
+	position, err := coordinates.Resolve(meta.RawX, meta.RawY, meta.CoordinateCRS)
+	if err != nil {
+		base.Latitude, base.Longitude = nil, nil
+		return err
+	}
+
+	base.Latitude = &position.Latitude
+	base.Longitude = &position.Longitude
+	meta.CoordinateCRS = position.CRS
+	return nil
+}
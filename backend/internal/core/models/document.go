@@ -0,0 +1,58 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Document is an uploaded PDF report. Its file is stored under the SHA-256 of its content, so uploading
+// the same file again finds this document instead of storing a copy; the uploaded name is kept as
+// metadata only and never used to build a path.
+type Document struct {
+	ID               uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	SHA256           string    `json:"sha256" gorm:"column:sha256;size:64;index"`
+	OriginalFilename string    `json:"original_filename" gorm:"size:255"`
+	Size             int64     `json:"size"`
+	// Uploads counts the times the file was uploaded, the first included
+	Uploads    int    `json:"uploads" gorm:"default:1"`
+	Pages      int    `json:"pages"`
+	PDFVersion string `json:"pdf_version" gorm:"size:10"`
+	// UploadedBy names who uploaded the document first, as given with the upload
+	UploadedBy string    `json:"uploaded_by" gorm:"size:255"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// StoredName returns the name of the document's file in the extractor's input directory. Documents
+// uploaded before files were content-addressed keep their ID-based name.
+func (d *Document) StoredName() string {
+	if d.SHA256 != "" {
+		return d.SHA256 + ".pdf"
+	}
+	return d.ID.String() + ".pdf"
+}
+
+// CleanFilename reduces an uploaded file name to its last element, without control characters and
+// at most 255 bytes long
+func CleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" || name == "." || name == "/" {
+		return "document.pdf"
+	}
+	return name
+}
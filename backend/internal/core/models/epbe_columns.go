@@ -0,0 +1,277 @@
+package models
+
+import "strings"
+
+// EPBEHeaders maps database column names to the header labels of the ePBE spreadsheet template.
+// Column order is not kept here; it always follows the struct field order of each model. Columns the
+// server derives, such as well_id, raw_x or age_start_ma, are not part of the template and have no
+// label, so exports leave them out and source headers never map to them.
+var EPBEHeaders = map[string]string{
+	"country":                              "Country",
+	"region":                               "Region",
+	"sub_region":                           "Sub-Region",
+	"business_regions":                     "Business Region",
+	"basin":                                "Basin",
+	"sub_basin":                            "Sub-Basin",
+	"well_name_field_name":                 "Well Name/Field Name",
+	"uwi":                                  "UWI",
+	"latitude":                             "Latitude",
+	"longitude":                            "Longitude",
+	"formation_name":                       "Formation Name",
+	"reservoir_name":                       "Reservoir Name",
+	"period":                               "Period",
+	"epoch":                                "Epoch",
+	"age":                                  "Age",
+	"onshore_offshore":                     "Onshore/Offshore",
+	"water_depth_m":                        "Water Depth (m)",
+	"water_depth_ft":                       "Water Depth (ft)",
+	"depth_reference_type":                 "Depth Reference Type",
+	"depth_reference_elevation_m":          "Depth Reference Elevation (m)",
+	"depth_reference_elevation_ft":         "Depth Reference Elevation (ft)",
+	"ground_level_elevation_m":             "Ground Level Elevation (m)",
+	"ground_level_elevation_ft":            "Ground Level Elevation (ft)",
+	"top_depth_mmddf":                      "Top Depth (m MDDF)",
+	"top_depth_mtvddf":                     "Top Depth (m TVDDF)",
+	"top_depth_mtvdss":                     "Top Depth (m TVDSS)",
+	"top_depth_mbml":                       "Top Depth (m BML)",
+	"bottom_depth_mmddf":                   "Bottom Depth (m MDDF)",
+	"bottom_depth_mtvddf":                  "Bottom Depth (m TVDDF)",
+	"bottom_depth_mtvdss":                  "Bottom Depth (m TVDSS)",
+	"bottom_depth_mbml":                    "Bottom Depth (m BML)",
+	"top_depth_ftmddf":                     "Top Depth (ft MDDF)",
+	"top_depth_fttvddf":                    "Top Depth (ft TVDDF)",
+	"top_depth_fttvdss":                    "Top Depth (ft TVDSS)",
+	"top_depth_ftbml":                      "Top Depth (ft BML)",
+	"bottom_depth_ftmddf":                  "Bottom Depth (ft MDDF)",
+	"bottom_depth_fttvddf":                 "Bottom Depth (ft TVDDF)",
+	"bottom_depth_fttvdss":                 "Bottom Depth (ft TVDSS)",
+	"bottom_depth_ftbml":                   "Bottom Depth (ft BML)",
+	"data_source":                          "Data Source",
+	"analysis_type":                        "Analysis Type",
+	"owner":                                "Owner",
+	"ownership":                            "Ownership",
+	"assurance":                            "Assurance",
+	"data_generator":                       "Data Generator",
+	"data_generation_date":                 "Data Generation Date",
+	"remark":                               "Remark",
+	"data_entry_date":                      "Data Entry Date",
+	"data_entry_mode":                      "Data Entry Mode",
+	"data_entry_focal":                     "Data Entry Focal",
+	"metadata_discipline_name":             "Metadata Discipline Name",
+	"metadata_data_source_name":            "Metadata Data Source Name",
+	"session_id":                           "Session ID",
+	"updated_timestamp":                    "Updated Timestamp",
+	"created_timestamp":                    "Created Timestamp",
+	"id":                                   "ID",
+	"duplicate_status":                     "Duplicate Status",
+	"duplicate_resolution_action":          "Duplicate Resolution Action",
+	"master_record_id":                     "Master Record ID",
+	"review_queue_id":                      "Review Queue ID",
+	"resolution_timestamp":                 "Resolution Timestamp",
+	"resolution_reason":                    "Resolution Reason",
+	"lithofacies_core":                     "Lithofacies (Core)",
+	"microfacies_thin_section":             "Microfacies (Thin Section)",
+	"depofacies":                           "Depofacies",
+	"visible_porosity_percent":             "Visible Porosity (%)",
+	"he_porosity_percent":                  "He Porosity (%)",
+	"permeability_md":                      "Permeability (mD)",
+	"calcite":                              "Calcite",
+	"dolomite":                             "Dolomite",
+	"micrite":                              "Micrite",
+	"micrite_envelopes":                    "Micrite Envelopes",
+	"microspar_pseudospar":                 "Microspar/Pseudospar",
+	"kaolinite":                            "Kaolinite",
+	"clay":                                 "Clay",
+	"total_mineralogy_matrix_percent":      "Total Mineralogy Matrix (%)",
+	"bioclasts":                            "Bioclasts",
+	"lepido":                               "Lepido",
+	"coral":                                "Coral",
+	"rhodolith":                            "Rhodolith",
+	"red_algae":                            "Red Algae",
+	"red_algae_enc":                        "Red Algae (Enc.)",
+	"green_algae":                          "Green Algae",
+	"echinoderms":                          "Echinoderms",
+	"miliolid":                             "Miliolid",
+	"lepidocyclina":                        "Lepidocyclina",
+	"cycloclypeus":                         "Cycloclypeus",
+	"operculina":                           "Operculina",
+	"other_rotaliids":                      "Other Rotaliids",
+	"gypsinid":                             "Gypsinid",
+	"planorbulinella":                      "Planorbulinella",
+	"hemotremid":                           "Hemotremid",
+	"heterostegina":                        "Heterostegina",
+	"enc_frm":                              "Enc. Foram",
+	"planktonic":                           "Planktonic",
+	"bryozoans":                            "Bryozoans",
+	"amphistegina":                         "Amphistegina",
+	"gastropods":                           "Gastropods",
+	"bivalve":                              "Bivalve",
+	"ostracod":                             "Ostracod",
+	"oncoids":                              "Oncoids",
+	"undiff_molluscs":                      "Undiff. Molluscs",
+	"undiff_benthonic":                     "Undiff. Benthonic",
+	"undiff_skeletal":                      "Undiff. Skeletal",
+	"undiff_foram":                         "Undiff. Foram",
+	"total_skeletal_percent":               "Total Skeletal (%)",
+	"organic":                              "Organic",
+	"peloids":                              "Peloids",
+	"micritised_grains":                    "Micritised Grains",
+	"pseudoclasts":                         "Pseudoclasts",
+	"intraclast":                           "Intraclast",
+	"quartz":                               "Quartz",
+	"total_non_skeletal_percent":           "Total Non-Skeletal (%)",
+	"interparticle":                        "Interparticle",
+	"intraparticle":                        "Intraparticle",
+	"intercrystalline":                     "Intercrystalline",
+	"matrix_intercrystalline":              "Matrix Intercrystalline",
+	"mouldic":                              "Mouldic",
+	"vuggy":                                "Vuggy",
+	"fractures":                            "Fractures",
+	"micro":                                "Micro",
+	"total_porosity_percent":               "Total Porosity (%)",
+	"fringing":                             "Fringing",
+	"meniscus":                             "Meniscus",
+	"blocky":                               "Blocky",
+	"sparry":                               "Sparry",
+	"micritic":                             "Micritic",
+	"pendant":                              "Pendant",
+	"syntax":                               "Syntax",
+	"calcite_syntaxial":                    "Calcite Syntaxial",
+	"calcite_fringing":                     "Calcite Fringing",
+	"calcite_mosaic":                       "Calcite Mosaic",
+	"calcite_blocky":                       "Calcite Blocky",
+	"calcite_ferroan":                      "Calcite Ferroan",
+	"pyrite":                               "Pyrite",
+	"fluorite":                             "Fluorite",
+	"total_cement_percent":                 "Total Cement (%)",
+	"replacement":                          "Replacement",
+	"saddle":                               "Saddle",
+	"total_dolomite_percent":               "Total Dolomite (%)",
+	"stylolite":                            "Stylolite",
+	"bioturbation":                         "Bioturbation",
+	"total_accessories_percent":            "Total Accessories (%)",
+	"total_percent":                        "Total (%)",
+	"analysis_types":                       "Analysis Types",
+	"grain_size":                           "Grain Size",
+	"grain_shape":                          "Grain Shape",
+	"grain_contact":                        "Grain Contact",
+	"sedimentary_structure":                "Sedimentary Structure",
+	"sorting":                              "Sorting",
+	"lithofacies":                          "Lithofacies",
+	"ambient_he_porosity_percent":          "Ambient He Porosity (%)",
+	"grain_density_g_cc":                   "Grain Density (g/cc)",
+	"monocrystalline_quartz":               "Monocrystalline Quartz",
+	"polycrystalline_quartz":               "Polycrystalline Quartz",
+	"total_quartz_percent":                 "Total Quartz (%)",
+	"potassium_feldspar":                   "Potassium Feldspar",
+	"plagioclase":                          "Plagioclase",
+	"feldspar_undifferentiated":            "Feldspar Undifferentiated",
+	"total_feldspar_percent":               "Total Feldspar (%)",
+	"muscovite":                            "Muscovite",
+	"biotite":                              "Biotite",
+	"mica_undifferentiated":                "Mica Undifferentiated",
+	"total_mica_percent":                   "Total Mica (%)",
+	"zircon":                               "Zircon",
+	"tourmaline":                           "Tourmaline",
+	"heavy_minerals_undifferentiated":      "Heavy Minerals Undifferentiated",
+	"total_heavy_minerals_percent":         "Total Heavy Minerals (%)",
+	"plutonic_rock_fragments":              "Plutonic Rock Fragments",
+	"mafic_intermediate_volcanic_fragment": "Mafic/Intermediate Volcanic Fragment",
+	"volcanic_rock_fragment":               "Volcanic Rock Fragment",
+	"total_igneous_rf_percent":             "Total Igneous RF (%)",
+	"quartzose_rock_fragment":              "Quartzose Rock Fragment",
+	"schistose_rock_fragment":              "Schistose Rock Fragment",
+	"metamorphic_rock_fragment_undifferentiated":    "Metamorphic Rock Fragment Undifferentiated",
+	"total_metamorphic_rf_percent":                  "Total Metamorphic RF (%)",
+	"sandstone_siltstone_rock_fragments":            "Sandstone/Siltstone Rock Fragments",
+	"argillaceous_rock_fragments":                   "Argillaceous Rock Fragments",
+	"siliciclastic_rock_fragments_undifferentiated": "Siliciclastic Rock Fragments Undifferentiated",
+	"limestone_rock_fragments":                      "Limestone Rock Fragments",
+	"dolostone_rock_fragments":                      "Dolostone Rock Fragments",
+	"chert":                                         "Chert",
+	"total_sedimentary_rf_percent":                  "Total Sedimentary RF (%)",
+	"total_rock_fragments_percent":                  "Total Rock Fragments (%)",
+	"rip_up_clast":                                  "Rip-Up Clast",
+	"glauconite":                                    "Glauconite",
+	"bioclast":                                      "Bioclast",
+	"foraminifera_grains":                           "Foraminifera Grains",
+	"undifferentiated_other_grains":                 "Undifferentiated Other Grains",
+	"total_other_grains_percent":                    "Total Other Grains (%)",
+	"clay_matrix":                                   "Clay Matrix",
+	"mixed_clay_silt_fine_matrix":                   "Mixed Clay/Silt Fine Matrix",
+	"silt_very_fine_matrix":                         "Silt/Very Fine Matrix",
+	"organic_matrix":                                "Organic Matrix",
+	"matrix_undifferentiated":                       "Matrix Undifferentiated",
+	"total_matrix_percent":                          "Total Matrix (%)",
+	"kaolinite_replaces_k_feldspar":                 "Kaolinite Replaces K-Feldspar",
+	"illite_pore_grain_lining":                      "Illite Pore/Grain Lining",
+	"illite_pore_filling":                           "Illite Pore Filling",
+	"illite_replaces_k_feldspar":                    "Illite Replaces K-Feldspar",
+	"total_authigenic_clay_percent":                 "Total Authigenic Clay (%)",
+	"syntaxial_quartz_overgrowths":                  "Syntaxial Quartz Overgrowths",
+	"feldspar_overgrowths":                          "Feldspar Overgrowths",
+	"fe_calcite":                                    "Fe-Calcite",
+	"fe_dolomite":                                   "Fe-Dolomite",
+	"siderite":                                      "Siderite",
+	"mn_siderite":                                   "Mn-Siderite",
+	"iron_oxide_minerals":                           "Iron Oxide Minerals",
+	"total_authigenic_non_clay_percent":             "Total Authigenic Non-Clay (%)",
+	"intergranular":                                 "Intergranular",
+	"pri_porosity_intragranular":                    "Primary Porosity Intragranular",
+	"total_primary_porosity_percent":                "Total Primary Porosity (%)",
+	"sec_porosity_intragranular":                    "Secondary Porosity Intragranular",
+	"intracrystalline":                              "Intracrystalline",
+	"fracture":                                      "Fracture",
+	"total_secondary_porosity_percent":              "Total Secondary Porosity (%)",
+}
+
+// EPBEHeader returns the ePBE template header for a column, falling back to the column name
+func EPBEHeader(column string) string {
+	if header, ok := EPBEHeaders[column]; ok {
+		return header
+	}
+	return column
+}
+
+// epbeColumnsByHeader is the reverse of EPBEHeaders, keyed by lower-cased label
+var epbeColumnsByHeader = func() map[string]string {
+	columns := make(map[string]string, len(EPBEHeaders))
+	for column, header := range EPBEHeaders {
+		columns[strings.ToLower(header)] = column
+	}
+	return columns
+}()
+
+// EPBEColumnForHeader resolves an ePBE template label, or a column name itself, to its column
+func EPBEColumnForHeader(header string) (string, bool) {
+	header = strings.ToLower(strings.TrimSpace(header))
+	if _, ok := EPBEHeaders[header]; ok {
+		return header, true
+	}
+	column, ok := epbeColumnsByHeader[header]
+	return column, ok
+}
+
+// MetresPerFoot converts lengths between the metric and imperial columns
+const MetresPerFoot = 0.3048
+
+// UnitPair links a metric length column to its imperial counterpart
+type UnitPair struct {
+	Metric   string
+	Imperial string
+}
+
+// EPBEUnitPairs lists the length columns that the ePBE template stores in both metres and feet
+var EPBEUnitPairs = []UnitPair{
+	{Metric: "water_depth_m", Imperial: "water_depth_ft"},
+	{Metric: "depth_reference_elevation_m", Imperial: "depth_reference_elevation_ft"},
+	{Metric: "ground_level_elevation_m", Imperial: "ground_level_elevation_ft"},
+	{Metric: "top_depth_mmddf", Imperial: "top_depth_ftmddf"},
+	{Metric: "top_depth_mtvddf", Imperial: "top_depth_fttvddf"},
+	{Metric: "top_depth_mtvdss", Imperial: "top_depth_fttvdss"},
+	{Metric: "top_depth_mbml", Imperial: "top_depth_ftbml"},
+	{Metric: "bottom_depth_mmddf", Imperial: "bottom_depth_ftmddf"},
+	{Metric: "bottom_depth_mtvddf", Imperial: "bottom_depth_fttvddf"},
+	{Metric: "bottom_depth_mtvdss", Imperial: "bottom_depth_fttvdss"},
+	{Metric: "bottom_depth_mbml", Imperial: "bottom_depth_ftbml"},
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Extraction job statuses
+const (
+	ExtractionStatusQueued    = "queued"
+	ExtractionStatusRunning   = "running"
+	ExtractionStatusCompleted = "completed"
+	ExtractionStatusFailed    = "failed"
+)
+
+// ExtractionJob records a PDF extraction so its outcome can be looked up, and so an extraction cut
+// off by a shutdown or crash is marked failed on the next start instead of staying running forever
+type ExtractionJob struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	DocumentID *uuid.UUID `json:"document_id,omitempty" gorm:"type:uuid;index"`
+	// Filename is the stored name of the PDF in the extractor's input directory
+	Filename         string `json:"filename" gorm:"size:255"`
+	OriginalFilename string `json:"original_filename" gorm:"size:255"`
+	Status           string `json:"status" gorm:"size:20;index"`
+	// Version numbers the extractions of a document from 1; each keeps its own output. Extractions from
+	// before versions whose output was written over have version 0.
+	Version int `json:"version" gorm:"default:1"`
+	// Options are the pages, flavors and quality thresholds asked for; nil runs the extractor's defaults
+	Options *ExtractionOptions `json:"options,omitempty" gorm:"type:jsonb;serializer:json"`
+	// TablesFound and RowsFound count what a completed extraction found
+	TablesFound int `json:"tables_found"`
+	RowsFound   int `json:"rows_found"`
+	// Worker is the host that ran the extraction
+	Worker     string     `json:"worker" gorm:"size:255"`
+	Error      string     `json:"error,omitempty" gorm:"type:text"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Extraction flavors of Camelot: lattice reads ruled tables, stream tables laid out by whitespace
+const (
+	ExtractionFlavorLattice = "lattice"
+	ExtractionFlavorStream  = "stream"
+)
+
+// ExtractionOptions narrow a re-extraction. Zero values keep the extractor's defaults: every page,
+// the stream flavor, and tables of at least 3 rows and 2 columns, 50% confidence and 40% filled cells.
+type ExtractionOptions struct {
+	// Pages is a page list such as "1-3,7"
+	Pages   string   `json:"pages,omitempty"`
+	Flavors []string `json:"flavors,omitempty"`
+	// MinConfidence is the lowest Camelot accuracy kept, in percent
+	MinConfidence *float64 `json:"min_confidence,omitempty"`
+	MinRows       *int     `json:"min_rows,omitempty"`
+	MinCols       *int     `json:"min_cols,omitempty"`
+	// MinFilled is the lowest share of non-empty cells kept, from 0 to 1
+	MinFilled *float64 `json:"min_filled,omitempty"`
+}
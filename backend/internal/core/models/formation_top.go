@@ -0,0 +1,290 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Depth datums of the TopDepth*/BottomDepth* sample columns
+var DepthDatums = []string{"MDDF", "TVDDF", "TVDSS", "BML"}
+
+// Depth units of the TopDepth*/BottomDepth* sample columns
+const (
+	DepthUnitMetres = "m"
+	DepthUnitFeet   = "ft"
+)
+
+// Formation assignment states of a sample. Samples with a formation and no state were named by hand
+// and are never overwritten.
+const (
+	FormationAssigned  = "assigned"
+	FormationStraddles = "straddles"
+)
+
+// FormationTop is a formation pick in a well: the formation from TopDepth down to BaseDepth, measured
+// against Datum in Unit. Without a base the formation extends to the next deeper top of the same datum.
+type FormationTop struct {
+	ID            uint     `json:"id" gorm:"primaryKey"`
+	WellID        uint     `json:"well_id" gorm:"not null;index"`
+	Well          *Well    `json:"-" gorm:"foreignKey:WellID;constraint:OnDelete:CASCADE"`
+	FormationName string   `json:"formation_name" gorm:"size:255;not null"`
+	ReservoirName string   `json:"reservoir_name" gorm:"size:255"`
+	TopDepth      float64  `json:"top_depth" gorm:"type:decimal(10,2);not null"`
+	BaseDepth     *float64 `json:"base_depth" gorm:"type:decimal(10,2)"`
+	Datum         string   `json:"datum" gorm:"size:10;not null;default:MDDF"`
+	Unit          string   `json:"unit" gorm:"size:5;not null;default:m"`
+	Source        string   `json:"source" gorm:"size:255"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (FormationTop) TableName() string {
+	return "formation_tops"
+}
+
+// Normalize canonicalizes the datum and unit and checks the depths
+func (t *FormationTop) Normalize() error {
+	t.FormationName = strings.TrimSpace(t.FormationName)
+	t.ReservoirName = strings.TrimSpace(t.ReservoirName)
+	t.Source = strings.TrimSpace(t.Source)
+	if t.FormationName == "" {
+		return fmt.Errorf("formation name is required")
+	}
+
+	datum := strings.ToUpper(strings.TrimSpace(t.Datum))
+	if datum == "" {
+		datum = "MDDF"
+	}
+	if !containsDatum(datum) {
+		return fmt.Errorf("datum %q must be one of %s", t.Datum, strings.Join(DepthDatums, ", "))
+	}
+	t.Datum = datum
+
+	switch strings.ToLower(strings.TrimSpace(t.Unit)) {
+	case "", "m", "metre", "metres", "meter", "meters":
+		t.Unit = DepthUnitMetres
+	case "ft", "feet", "foot":
+		t.Unit = DepthUnitFeet
+	default:
+		return fmt.Errorf("unit %q must be m or ft", t.Unit)
+	}
+
+	if t.BaseDepth != nil && *t.BaseDepth <= t.TopDepth {
+		return fmt.Errorf("base depth %v must be deeper than top depth %v", *t.BaseDepth, t.TopDepth)
+	}
+	return nil
+}
+
+func containsDatum(datum string) bool {
+	for _, d := range DepthDatums {
+		if d == datum {
+			return true
+		}
+	}
+	return false
+}
+
+// DepthColumns returns the sample columns holding depths in a datum and unit, e.g. top_depth_mmddf
+func DepthColumns(datum, unit string) (top, bottom string) {
+	suffix := unit + strings.ToLower(datum)
+	return "top_depth_" + suffix, "bottom_depth_" + suffix
+}
+
+// sampleDepths returns a sample's top and bottom in a datum and unit; bottom is top for spot samples
+func sampleDepths(depth *DepthInfo, datum, unit string) (top, bottom *float64) {
+	columns := map[string][2]*float64{
+		"mMDDF":   {depth.TopDepthMMDDF, depth.BottomDepthMMDDF},
+		"mTVDDF":  {depth.TopDepthMTVDDF, depth.BottomDepthMTVDDF},
+		"mTVDSS":  {depth.TopDepthMTVDSS, depth.BottomDepthMTVDSS},
+		"mBML":    {depth.TopDepthMBML, depth.BottomDepthMBML},
+		"ftMDDF":  {depth.TopDepthFtMDDF, depth.BottomDepthFtMDDF},
+		"ftTVDDF": {depth.TopDepthFtTVDDF, depth.BottomDepthFtTVDDF},
+		"ftTVDSS": {depth.TopDepthFtTVDSS, depth.BottomDepthFtTVDSS},
+		"ftBML":   {depth.TopDepthFtBML, depth.BottomDepthFtBML},
+	}
+	pair := columns[unit+datum]
+	top, bottom = pair[0], pair[1]
+	if top == nil {
+		top, bottom = bottom, nil
+	}
+	if top == nil {
+		return nil, nil
+	}
+	if bottom == nil || *bottom < *top {
+		bottom = top
+	}
+	return top, bottom
+}
+
+// formationInterval is a formation top with its resolved base; Base is nil for the deepest open top
+type formationInterval struct {
+	Top  *FormationTop
+	Base *float64
+}
+
+// FormationMatch is the formation a sample interval falls in
+type FormationMatch struct {
+	Formation string `json:"formation"`
+	Reservoir string `json:"reservoir"`
+	Status    string `json:"status"`
+	// Crossed lists every formation the sample interval reaches, shallowest first, when it straddles a boundary
+	Crossed []string `json:"crossed,omitempty"`
+}
+
+// MatchFormation finds the formation of a sample from the well's tops. The tops of the datum and unit
+// the sample has depths in are used, in the order of DepthDatums with metres first. A sample crossing
+// a boundary is assigned the formation covering most of it and flagged as straddling. It returns nil
+// when no top applies.
+func MatchFormation(tops []FormationTop, depth *DepthInfo) *FormationMatch {
+	for _, unit := range []string{DepthUnitMetres, DepthUnitFeet} {
+		for _, datum := range DepthDatums {
+			intervals := formationIntervals(tops, datum, unit)
+			if len(intervals) == 0 {
+				continue
+			}
+			top, bottom := sampleDepths(depth, datum, unit)
+			if top == nil {
+				continue
+			}
+			return matchIntervals(intervals, *top, *bottom)
+		}
+	}
+	return nil
+}
+
+// formationIntervals returns the tops of one datum and unit, shallowest first, with open bases closed
+// at the next deeper top
+func formationIntervals(tops []FormationTop, datum, unit string) []formationInterval {
+	var intervals []formationInterval
+	for i := range tops {
+		if tops[i].Datum == datum && tops[i].Unit == unit {
+			intervals = append(intervals, formationInterval{Top: &tops[i], Base: tops[i].BaseDepth})
+		}
+	}
+	sort.SliceStable(intervals, func(i, j int) bool {
+		return intervals[i].Top.TopDepth < intervals[j].Top.TopDepth
+	})
+	for i := range intervals {
+		if intervals[i].Base == nil && i+1 < len(intervals) {
+			next := intervals[i+1].Top.TopDepth
+			intervals[i].Base = &next
+		}
+	}
+	return intervals
+}
+
+func matchIntervals(intervals []formationInterval, top, bottom float64) *FormationMatch {
+	type hit struct {
+		interval formationInterval
+		overlap  float64
+	}
+	var hits []hit
+	for _, interval := range intervals {
+		start := interval.Top.TopDepth
+		if interval.Base != nil && top >= *interval.Base {
+			continue
+		}
+		if bottom < start || (bottom == start && top < start) {
+			continue
+		}
+		// A spot sample on a top belongs to the formation below it
+		end := bottom
+		if interval.Base != nil && *interval.Base < end {
+			end = *interval.Base
+		}
+		begin := top
+		if start > begin {
+			begin = start
+		}
+		hits = append(hits, hit{interval: interval, overlap: end - begin})
+	}
+	if len(hits) == 0 {
+		return nil
+	}
+
+	best := hits[0]
+	for _, h := range hits[1:] {
+		if h.overlap > best.overlap {
+			best = h
+		}
+	}
+	match := &FormationMatch{
+		Formation: best.interval.Top.FormationName,
+		Reservoir: best.interval.Top.ReservoirName,
+		Status:    FormationAssigned,
+	}
+	if len(hits) > 1 {
+		match.Status = FormationStraddles
+		for _, h := range hits {
+			match.Crossed = append(match.Crossed, h.interval.Top.FormationName)
+		}
+	}
+	return match
+}
+
+// ApplyFormation writes a match to a sample unless its formation was named by hand. Without a match,
+// a formation assigned earlier is cleared and the reservoir kept. It reports whether the sample changed.
+func ApplyFormation(base *EPBEBase, meta *MetadataInfo, match *FormationMatch) bool {
+	if meta.FormationAssignment == "" && strings.TrimSpace(base.FormationName) != "" {
+		return false
+	}
+
+	formation, reservoir, status := "", "", ""
+	if match != nil {
+		formation, reservoir, status = match.Formation, match.Reservoir, match.Status
+		if reservoir == "" && meta.FormationAssignment == "" {
+			// A reservoir named by hand is kept when the top has none
+			reservoir = base.ReservoirName
+		}
+	} else if meta.FormationAssignment == "" {
+		return false
+	} else {
+		// The reservoir may have been entered by hand, so only the formation is cleared
+		reservoir = base.ReservoirName
+	}
+
+	changed := base.FormationName != formation || base.ReservoirName != reservoir || meta.FormationAssignment != status
+	base.FormationName, base.ReservoirName, meta.FormationAssignment = formation, reservoir, status
+	return changed
+}
+
+// assignFormations sets the formation of linked samples from their wells' tops, read in one query
+func assignFormations(tx *gorm.DB, samples []sampleParts) error {
+	var pending []sampleParts
+	var wellIDs []uint
+	seen := map[uint]bool{}
+	for _, sample := range samples {
+		if sample.meta.WellID == nil {
+			continue
+		}
+		if sample.meta.FormationAssignment == "" && strings.TrimSpace(sample.base.FormationName) != "" {
+			continue
+		}
+		pending = append(pending, sample)
+		if id := *sample.meta.WellID; !seen[id] {
+			seen[id] = true
+			wellIDs = append(wellIDs, id)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var tops []FormationTop
+	if err := tx.Session(&gorm.Session{NewDB: true}).Where("well_id IN ?", wellIDs).Find(&tops).Error; err != nil {
+		return err
+	}
+	byWell := map[uint][]FormationTop{}
+	for _, top := range tops {
+		byWell[top.WellID] = append(byWell[top.WellID], top)
+	}
+	for _, sample := range pending {
+		ApplyFormation(sample.base, sample.meta, MatchFormation(byWell[*sample.meta.WellID], sample.depth))
+	}
+	return nil
+}
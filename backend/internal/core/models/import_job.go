@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Import job statuses
+const (
+	ImportStatusPending    = "pending"
+	ImportStatusValidated  = "validated"
+	ImportStatusInProgress = "in_progress"
+	ImportStatusCompleted  = "completed"
+	ImportStatusFailed     = "failed"
+)
+
+// ImportJob tracks a bulk spreadsheet import so a large file can be validated first
+// and resumed from the last committed row after an interruption. StoredKey names the uploaded file in
+// storage, kept until the import completes.
+type ImportJob struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	Filename  string    `json:"filename" gorm:"size:255"`
+	Format    string    `json:"format" gorm:"size:10"`
+	Sheet     string    `json:"sheet" gorm:"size:255"`
+	Target    string    `json:"target" gorm:"size:50"`
+	CRS       string    `json:"crs" gorm:"size:50"`
+	StoredKey string    `json:"-" gorm:"column:stored_path;size:500"`
+	Status    string    `json:"status" gorm:"size:20;index"`
+
+	// Progress, in data rows (the header row is not counted)
+	TotalRows       int `json:"total_rows"`
+	ProcessedRows   int `json:"processed_rows"`
+	InsertedRecords int `json:"inserted_records"`
+	FailedRows      int `json:"failed_rows"`
+	SkippedRows     int `json:"skipped_rows"`
+
+	LastError string    `json:"last_error,omitempty" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
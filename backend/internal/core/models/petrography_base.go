@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -81,6 +82,8 @@ type MetadataInfo struct {
 
 	// Session tracking
 	SessionID string `json:"session_id" gorm:"column:session_id;size:100;index"`
+	// Uploaded PDF the record was extracted from
+	DocumentID *uuid.UUID `json:"document_id" gorm:"column:document_id;type:uuid;index"`
 
 	UpdatedTimestamp time.Time `json:"updated_timestamp" gorm:"column:updated_timestamp;default:CURRENT_TIMESTAMP"`
 	CreatedTimestamp time.Time `json:"created_timestamp" gorm:"column:created_timestamp;default:CURRENT_TIMESTAMP"`
@@ -88,6 +91,24 @@ type MetadataInfo struct {
 	// Primary Key (comes after metadata fields in SQL)
 	ID uint `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
 
+	// Well the sample belongs to, linked by UWI in PrepareSamples
+	WellID *uint `json:"well_id" gorm:"column:well_id;index"`
+	Well   *Well `json:"-" gorm:"foreignKey:WellID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	// Coordinates as written in the source (longitude or easting, latitude or northing) and their CRS;
+	// latitude and longitude always hold the WGS84 conversion
+	CoordinateCRS string `json:"coordinate_crs" gorm:"column:coordinate_crs;size:50"`
+	RawX          string `json:"raw_x" gorm:"column:raw_x;size:100"`
+	RawY          string `json:"raw_y" gorm:"column:raw_y;size:100"`
+
+	// Span of Period, Epoch and Age in Ma on the ICS chart, used for interval filters
+	AgeStartMa *float64 `json:"age_start_ma" gorm:"column:age_start_ma;type:decimal(10,4);index"`
+	AgeEndMa   *float64 `json:"age_end_ma" gorm:"column:age_end_ma;type:decimal(10,4);index"`
+
+	// How FormationName was set from the well's formation tops: "assigned", "straddles" when the sample
+	// crosses a boundary, or empty when entered by hand
+	FormationAssignment string `json:"formation_assignment" gorm:"column:formation_assignment;size:20;index"`
+
 	// Duplicate detection fields (last columns in SQL)
 	DuplicateStatus           string     `json:"duplicate_status" gorm:"column:duplicate_status;size:50"`
 	DuplicateResolutionAction string     `json:"duplicate_resolution_action" gorm:"column:duplicate_resolution_action;size:50"`
@@ -129,3 +150,46 @@ func (nf NullFloat64) Value() (driver.Value, error) {
 	}
 	return nf.Float64, nil
 }
+
+// Sample is a petrography record: the ePBE header, depths and metadata plus its controlled fields
+type Sample interface {
+	sampleParts() sampleParts
+}
+
+type sampleParts struct {
+	base  *EPBEBase
+	depth *DepthInfo
+	meta  *MetadataInfo
+	// fields maps vocabulary to the record field holding its value
+	fields map[string]*string
+}
+
+// PrepareSamples normalizes the controlled values of samples, links them to their wells by UWI and
+// assigns their formations from the wells' tops. The lookups run once for all the samples, so
+// imports call it per chunk rather than per row.
+func PrepareSamples(tx *gorm.DB, samples ...Sample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	db := tx.Session(&gorm.Session{NewDB: true})
+	index, err := loadVocabularies(db)
+	if err != nil {
+		return err
+	}
+
+	parts := make([]sampleParts, len(samples))
+	unknowns := vocabularyUnknowns{}
+	for i, sample := range samples {
+		parts[i] = sample.sampleParts()
+		index.normalize(parts[i].fields, unknowns)
+	}
+	if err := unknowns.record(db); err != nil {
+		return err
+	}
+
+	if err := linkWells(db, parts); err != nil {
+		return err
+	}
+	return assignFormations(db, parts)
+}
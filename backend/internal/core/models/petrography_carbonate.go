@@ -1,5 +1,7 @@
 package models
 
+import "gorm.io/gorm"
+
 type EPBEPetrographyCarbonate struct {
 	// Base fields - Country, Region, Sub-Region, Business Region, Basin, Sub-Basin, Well Name/Field Name, UWI, Latitude, Longitude, Onshore/Offshore, Water Depth (m), Water Depth (ft)
 	EPBEBase
@@ -115,3 +117,16 @@ type EPBEPetrographyCarbonate struct {
 func (EPBEPetrographyCarbonate) TableName() string {
 	return "petrography_carbonate"
 }
+
+func (r *EPBEPetrographyCarbonate) sampleParts() sampleParts {
+	fields := r.vocabularyFields()
+	fields[VocabularyLithofacies] = &r.LithofaciesCore
+	fields[VocabularyDepofacies] = &r.Depofacies
+	return sampleParts{base: &r.EPBEBase, depth: &r.DepthInfo, meta: &r.MetadataInfo, fields: fields}
+}
+
+// BeforeSave completes the geologic time, rejecting a Period, Epoch and Age that disagree. Controlled
+// values, the well and the formation are resolved beforehand by PrepareSamples.
+func (r *EPBEPetrographyCarbonate) BeforeSave(tx *gorm.DB) error {
+	return ApplyTimescale(&r.EPBEBase, &r.MetadataInfo)
+}
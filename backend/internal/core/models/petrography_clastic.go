@@ -1,5 +1,7 @@
 package models
 
+import "gorm.io/gorm"
+
 type EPBEPetrographyClastic struct {
 	// Base fields - Country, Region, Sub-Region, Business Region, Basin, Sub-Basin, Well Name/Field Name, UWI, Latitude, Longitude, Onshore/Offshore, Water Depth (m), Water Depth (ft)
 	EPBEBase
@@ -125,3 +127,15 @@ type EPBEPetrographyClastic struct {
 func (EPBEPetrographyClastic) TableName() string {
 	return "petrography_clastic"
 }
+
+func (r *EPBEPetrographyClastic) sampleParts() sampleParts {
+	fields := r.vocabularyFields()
+	fields[VocabularyLithofacies] = &r.Lithofacies
+	return sampleParts{base: &r.EPBEBase, depth: &r.DepthInfo, meta: &r.MetadataInfo, fields: fields}
+}
+
+// BeforeSave completes the geologic time, rejecting a Period, Epoch and Age that disagree. Controlled
+// values, the well and the formation are resolved beforehand by PrepareSamples.
+func (r *EPBEPetrographyClastic) BeforeSave(tx *gorm.DB) error {
+	return ApplyTimescale(&r.EPBEBase, &r.MetadataInfo)
+}
@@ -0,0 +1,31 @@
+package models
+
+import "workbench/internal/timescale"
+
+// ApplyTimescale reads Period, Epoch and Age against the ICS chart, fills the ones left empty and
+// records the span in Ma. When the three disagree nothing is filled, the span is cleared and
+// timescale.ErrInconsistent is returned.
+func ApplyTimescale(base *EPBEBase, meta *MetadataInfo) error {
+	derivation, err := timescale.Derive(base.Period, base.Epoch, base.Age)
+	if err != nil {
+		meta.AgeStartMa, meta.AgeEndMa = nil, nil
+		return err
+	}
+	if derivation.Interval == nil {
+		meta.AgeStartMa, meta.AgeEndMa = nil, nil
+		return nil
+	}
+
+	start, end := derivation.Interval.Start, derivation.Interval.End
+	meta.AgeStartMa, meta.AgeEndMa = &start, &end
+	if base.Period == "" && derivation.Period != nil {
+		base.Period = derivation.Period.Name
+	}
+	if base.Epoch == "" && derivation.Epoch != nil {
+		base.Epoch = derivation.Epoch.Name
+	}
+	if base.Age == "" && derivation.Age != nil {
+		base.Age = derivation.Age.Name
+	}
+	return nil
+}
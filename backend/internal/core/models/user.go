@@ -27,6 +27,9 @@ type JSON map[string]interface{}
 type Pagination struct {
 	Page  int `json:"page" form:"page"`
 	Limit int `json:"limit" form:"limit"`
+
+	// Cursor switches to keyset pagination when non-nil; an empty cursor requests the first page
+	Cursor *string `json:"cursor,omitempty" form:"cursor"`
 }
 
 // GetPage returns the current page (1-indexed)
@@ -48,6 +51,23 @@ func (p *Pagination) GetLimit() int {
 	return p.Limit
 }
 
+// GetCursorLimit returns the limit per page for keyset pagination, which allows larger pages
+// because it does not degrade with depth like OFFSET does
+func (p *Pagination) GetCursorLimit() int {
+	if p.Limit <= 0 {
+		return 100
+	}
+	if p.Limit > 1000 {
+		return 1000
+	}
+	return p.Limit
+}
+
+// IsCursor reports whether keyset pagination was requested
+func (p *Pagination) IsCursor() bool {
+	return p.Cursor != nil
+}
+
 // GetOffset returns the offset for pagination
 func (p *Pagination) GetOffset() int {
 	return (p.GetPage() - 1) * p.GetLimit()
@@ -0,0 +1,278 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"workbench/internal/cache"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Controlled vocabularies
+const (
+	VocabularyCountry         = "country"
+	VocabularyRegion          = "region"
+	VocabularyBasin           = "basin"
+	VocabularySubBasin        = "sub_basin"
+	VocabularyOnshoreOffshore = "onshore_offshore"
+	VocabularyLithofacies     = "lithofacies"
+	VocabularyDepofacies      = "depofacies"
+)
+
+// VocabularyColumns gives, per vocabulary, the column holding its values in each table
+var VocabularyColumns = map[string]map[string]string{
+	VocabularyCountry:         {"wells": "country", "petrography_carbonate": "country", "petrography_clastic": "country"},
+	VocabularyRegion:          {"wells": "region", "petrography_carbonate": "region", "petrography_clastic": "region"},
+	VocabularyBasin:           {"wells": "basin", "petrography_carbonate": "basin", "petrography_clastic": "basin"},
+	VocabularySubBasin:        {"wells": "sub_basin", "petrography_carbonate": "sub_basin", "petrography_clastic": "sub_basin"},
+	VocabularyOnshoreOffshore: {"wells": "onshore_offshore", "petrography_carbonate": "onshore_offshore", "petrography_clastic": "onshore_offshore"},
+	VocabularyLithofacies:     {"petrography_carbonate": "lithofacies_core", "petrography_clastic": "lithofacies"},
+	VocabularyDepofacies:      {"petrography_carbonate": "depofacies"},
+}
+
+// VocabularyParents is the location hierarchy: the vocabulary a term's parent belongs to
+var VocabularyParents = map[string]string{
+	VocabularyRegion:   VocabularyCountry,
+	VocabularyBasin:    VocabularyRegion,
+	VocabularySubBasin: VocabularyBasin,
+}
+
+// vocabularySuffixes are generic trailing words ignored when matching, so "Sarawak Basin",
+// "SARAWAK" and "Sarawak Bsn" are the same basin
+var vocabularySuffixes = map[string][][]string{
+	VocabularyBasin:    {{"basin"}, {"bsn"}},
+	VocabularySubBasin: {{"sub", "basin"}, {"subbasin"}, {"sub", "bsn"}, {"basin"}, {"bsn"}},
+}
+
+// VocabularyTerm is a managed value of a controlled text column
+type VocabularyTerm struct {
+	ID         uint                `json:"id" gorm:"primaryKey"`
+	Vocabulary string              `json:"vocabulary" gorm:"size:50;not null;index"`
+	Value      string              `json:"value" gorm:"size:255;not null"`
+	ParentID   *uint               `json:"parent_id" gorm:"index"`
+	Parent     *VocabularyTerm     `json:"-" gorm:"foreignKey:ParentID;constraint:OnDelete:RESTRICT"`
+	Synonyms   []VocabularySynonym `json:"synonyms" gorm:"foreignKey:TermID;constraint:OnDelete:CASCADE"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+}
+
+// VocabularySynonym is a spelling that normalizes to a term. Every term has one for its own value;
+// the unique key keeps two terms of a vocabulary from claiming the same spelling.
+type VocabularySynonym struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	TermID     uint   `json:"term_id" gorm:"not null;index"`
+	Vocabulary string `json:"-" gorm:"size:50;not null;uniqueIndex:idx_vocabulary_synonym_key"`
+	Value      string `json:"value" gorm:"size:255;not null"`
+	Key        string `json:"-" gorm:"size:255;not null;uniqueIndex:idx_vocabulary_synonym_key"`
+}
+
+// VocabularyUnknown is a value seen in a controlled column that matches no term, kept for curation
+type VocabularyUnknown struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Vocabulary  string    `json:"vocabulary" gorm:"size:50;not null;uniqueIndex:idx_vocabulary_unknown_key"`
+	Value       string    `json:"value" gorm:"size:255;not null"`
+	Key         string    `json:"-" gorm:"size:255;not null;uniqueIndex:idx_vocabulary_unknown_key"`
+	Occurrences int64     `json:"occurrences" gorm:"not null;default:0"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// VocabularyKey reduces a value to the form used for matching: lower case, punctuation and spacing
+// folded, and generic suffixes such as "basin" dropped
+func VocabularyKey(vocabulary, value string) string {
+	words := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, suffix := range vocabularySuffixes[vocabulary] {
+		if len(words) > len(suffix) && equalWords(words[len(words)-len(suffix):], suffix) {
+			words = words[:len(words)-len(suffix)]
+			break
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func equalWords(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// vocabularyIndex is an in-memory copy of the vocabularies used while normalizing records
+type vocabularyIndex struct {
+	terms map[uint]VocabularyTerm
+	keys  map[string]uint // vocabulary + "\x00" + key
+}
+
+// vocabularyCacheTTL bounds how stale the index can get; while the shared generation cannot be read,
+// writes are only picked up when it expires
+const vocabularyCacheTTL = time.Minute
+
+var vocabularyCache struct {
+	sync.Mutex
+	index    *vocabularyIndex
+	loadedAt time.Time
+	// generation is the shared cache generation the index was loaded at; writes on any replica bump it
+	generation int64
+}
+
+// InvalidateVocabularies drops the cached vocabularies after terms or synonyms change, on every replica
+func InvalidateVocabularies() {
+	vocabularyCache.Lock()
+	vocabularyCache.index = nil
+	vocabularyCache.Unlock()
+	cache.Invalidate(context.Background(), cache.Default(), cache.Vocabularies)
+}
+
+func loadVocabularies(db *gorm.DB) (*vocabularyIndex, error) {
+	generation := cache.Generation(db.Statement.Context, cache.Default(), cache.Vocabularies)
+
+	vocabularyCache.Lock()
+	defer vocabularyCache.Unlock()
+	if vocabularyCache.index != nil && time.Since(vocabularyCache.loadedAt) < vocabularyCacheTTL &&
+		(generation < 0 || generation == vocabularyCache.generation) {
+		return vocabularyCache.index, nil
+	}
+
+	var terms []VocabularyTerm
+	if err := db.Find(&terms).Error; err != nil {
+		return nil, err
+	}
+	var synonyms []VocabularySynonym
+	if err := db.Find(&synonyms).Error; err != nil {
+		return nil, err
+	}
+
+	index := &vocabularyIndex{terms: map[uint]VocabularyTerm{}, keys: map[string]uint{}}
+	for _, term := range terms {
+		index.terms[term.ID] = term
+	}
+	for _, synonym := range synonyms {
+		index.keys[synonym.Vocabulary+"\x00"+synonym.Key] = synonym.TermID
+	}
+
+	vocabularyCache.index = index
+	vocabularyCache.loadedAt = time.Now()
+	vocabularyCache.generation = generation
+	return index, nil
+}
+
+// LookupVocabulary finds the term a value normalizes to
+func LookupVocabulary(db *gorm.DB, vocabulary, value string) (*VocabularyTerm, error) {
+	index, err := loadVocabularies(db.Session(&gorm.Session{NewDB: true}))
+	if err != nil {
+		return nil, err
+	}
+	id, ok := index.keys[vocabulary+"\x00"+VocabularyKey(vocabulary, value)]
+	if !ok {
+		return nil, nil
+	}
+	term := index.terms[id]
+	return &term, nil
+}
+
+// normalizeVocabularies replaces controlled values by their canonical term, fills empty parent fields
+// from the hierarchy and records values that match no term. fields maps vocabulary to the record field.
+func normalizeVocabularies(tx *gorm.DB, fields map[string]*string) error {
+	db := tx.Session(&gorm.Session{NewDB: true})
+	index, err := loadVocabularies(db)
+	if err != nil {
+		return err
+	}
+	unknowns := vocabularyUnknowns{}
+	index.normalize(fields, unknowns)
+	return unknowns.record(db)
+}
+
+// vocabularyUnknowns collects the unmatched values of a batch, keyed by vocabulary + "\x00" + key
+type vocabularyUnknowns map[string]*VocabularyUnknown
+
+// normalize replaces the values of fields by their canonical term, fills empty parent fields from
+// the hierarchy and adds values that match no term to unknowns
+func (index *vocabularyIndex) normalize(fields map[string]*string, unknowns vocabularyUnknowns) {
+	matched := map[string]VocabularyTerm{}
+	for vocabulary, field := range fields {
+		value := strings.TrimSpace(*field)
+		*field = value
+		key := VocabularyKey(vocabulary, value)
+		if key == "" {
+			continue
+		}
+
+		if id, ok := index.keys[vocabulary+"\x00"+key]; ok {
+			matched[vocabulary] = index.terms[id]
+			*field = index.terms[id].Value
+			continue
+		}
+
+		if unknown, ok := unknowns[vocabulary+"\x00"+key]; ok {
+			unknown.Occurrences++
+			continue
+		}
+		unknowns[vocabulary+"\x00"+key] = &VocabularyUnknown{Vocabulary: vocabulary, Value: value, Key: key, Occurrences: 1}
+	}
+
+	// A known sub-basin, basin or region implies its ancestors
+	for _, vocabulary := range []string{VocabularySubBasin, VocabularyBasin, VocabularyRegion} {
+		term, ok := matched[vocabulary]
+		if !ok || term.ParentID == nil {
+			continue
+		}
+		parent, ok := index.terms[*term.ParentID]
+		if !ok {
+			continue
+		}
+		if field, ok := fields[parent.Vocabulary]; ok && *field == "" {
+			*field = parent.Value
+			matched[parent.Vocabulary] = parent
+		}
+	}
+}
+
+// record upserts the unmatched values in one statement, adding to the occurrences of values seen before
+func (unknowns vocabularyUnknowns) record(db *gorm.DB) error {
+	if len(unknowns) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	rows := make([]VocabularyUnknown, 0, len(unknowns))
+	for _, unknown := range unknowns {
+		unknown.FirstSeenAt, unknown.LastSeenAt = now, now
+		rows = append(rows, *unknown)
+	}
+	// A fixed order keeps concurrent imports from deadlocking on the unique index
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Vocabulary != rows[j].Vocabulary {
+			return rows[i].Vocabulary < rows[j].Vocabulary
+		}
+		return rows[i].Key < rows[j].Key
+	})
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "vocabulary"}, {Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"occurrences":  gorm.Expr("vocabulary_unknowns.occurrences + excluded.occurrences"),
+			"last_seen_at": now,
+		}),
+	}).Create(&rows).Error
+}
+
+// vocabularyFields returns the controlled header fields keyed by vocabulary
+func (b *EPBEBase) vocabularyFields() map[string]*string {
+	return map[string]*string{
+		VocabularyCountry:         &b.Country,
+		VocabularyRegion:          &b.Region,
+		VocabularyBasin:           &b.Basin,
+		VocabularySubBasin:        &b.SubBasin,
+		VocabularyOnshoreOffshore: &b.OnshoreOffshore,
+	}
+}
@@ -0,0 +1,190 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Well is the master record of a well, keyed by UWI. Samples keep a copy of the header in
+// their EPBEBase columns for the ePBE template, but the well is the source of truth for it.
+type Well struct {
+	ID uint `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+
+	UWI               string   `json:"uwi" gorm:"column:uwi;size:255;not null;uniqueIndex"`
+	WellNameFieldName string   `json:"well_name_field_name" gorm:"column:well_name_field_name;size:255"`
+	Country           string   `json:"country" gorm:"column:country;size:255"`
+	Region            string   `json:"region" gorm:"column:region;size:255"`
+	SubRegion         string   `json:"sub_region" gorm:"column:sub_region;size:255"`
+	BusinessRegions   string   `json:"business_regions" gorm:"column:business_regions;size:255"`
+	Basin             string   `json:"basin" gorm:"column:basin;size:255"`
+	SubBasin          string   `json:"sub_basin" gorm:"column:sub_basin;size:255"`
+	Latitude          *float64 `json:"latitude" gorm:"column:latitude;type:decimal(10,7)"`
+	Longitude         *float64 `json:"longitude" gorm:"column:longitude;type:decimal(10,7)"`
+	OnshoreOffshore   string   `json:"onshore_offshore" gorm:"column:onshore_offshore;size:255"`
+	WaterDepthM       *float64 `json:"water_depth_m" gorm:"column:water_depth_m;type:decimal(10,2)"`
+	WaterDepthFt      *float64 `json:"water_depth_ft" gorm:"column:water_depth_ft;type:decimal(10,2)"`
+
+	// CRS applies to sample coordinates whose source does not name one, e.g. "EPSG:32650"
+	CRS string `json:"crs" gorm:"column:crs;size:50"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Well) TableName() string {
+	return "wells"
+}
+
+// BeforeSave normalizes the controlled header values
+func (w *Well) BeforeSave(tx *gorm.DB) error {
+	return normalizeVocabularies(tx, map[string]*string{
+		VocabularyCountry:         &w.Country,
+		VocabularyRegion:          &w.Region,
+		VocabularyBasin:           &w.Basin,
+		VocabularySubBasin:        &w.SubBasin,
+		VocabularyOnshoreOffshore: &w.OnshoreOffshore,
+	})
+}
+
+// WellHeaderColumns are the EPBEBase columns that describe the well rather than the sample
+var WellHeaderColumns = []string{
+	"uwi", "well_name_field_name", "country", "region", "sub_region", "business_regions",
+	"basin", "sub_basin", "latitude", "longitude", "onshore_offshore", "water_depth_m", "water_depth_ft",
+}
+
+// NewWellFromBase builds a well from the header fields of a sample
+func NewWellFromBase(base *EPBEBase) *Well {
+	return &Well{
+		UWI:               strings.TrimSpace(base.UWI),
+		WellNameFieldName: base.WellNameFieldName,
+		Country:           base.Country,
+		Region:            base.Region,
+		SubRegion:         base.SubRegion,
+		BusinessRegions:   base.BusinessRegions,
+		Basin:             base.Basin,
+		SubBasin:          base.SubBasin,
+		Latitude:          base.Latitude,
+		Longitude:         base.Longitude,
+		OnshoreOffshore:   base.OnshoreOffshore,
+		WaterDepthM:       base.WaterDepthM,
+		WaterDepthFt:      base.WaterDepthFt,
+	}
+}
+
+// HeaderValues returns the well header keyed by sample column, for propagating it to linked samples
+func (w *Well) HeaderValues() map[string]interface{} {
+	return map[string]interface{}{
+		"uwi":                  w.UWI,
+		"well_name_field_name": w.WellNameFieldName,
+		"country":              w.Country,
+		"region":               w.Region,
+		"sub_region":           w.SubRegion,
+		"business_regions":     w.BusinessRegions,
+		"basin":                w.Basin,
+		"sub_basin":            w.SubBasin,
+		"latitude":             w.Latitude,
+		"longitude":            w.Longitude,
+		"onshore_offshore":     w.OnshoreOffshore,
+		"water_depth_m":        w.WaterDepthM,
+		"water_depth_ft":       w.WaterDepthFt,
+	}
+}
+
+// FillBlanks copies the well header into the sample fields that were left empty
+func (w *Well) FillBlanks(base *EPBEBase) {
+	fillString := func(dst *string, src string) {
+		if strings.TrimSpace(*dst) == "" {
+			*dst = src
+		}
+	}
+	fillFloat := func(dst **float64, src *float64) {
+		if *dst == nil {
+			*dst = src
+		}
+	}
+
+	fillString(&base.WellNameFieldName, w.WellNameFieldName)
+	fillString(&base.Country, w.Country)
+	fillString(&base.Region, w.Region)
+	fillString(&base.SubRegion, w.SubRegion)
+	fillString(&base.BusinessRegions, w.BusinessRegions)
+	fillString(&base.Basin, w.Basin)
+	fillString(&base.SubBasin, w.SubBasin)
+	fillFloat(&base.Latitude, w.Latitude)
+	fillFloat(&base.Longitude, w.Longitude)
+	fillString(&base.OnshoreOffshore, w.OnshoreOffshore)
+	fillFloat(&base.WaterDepthM, w.WaterDepthM)
+	fillFloat(&base.WaterDepthFt, w.WaterDepthFt)
+}
+
+// linkWells points samples at the wells with their UWI, creating a well from the header of the first
+// sample that names a UWI not seen before. Samples without a UWI are left unlinked.
+func linkWells(tx *gorm.DB, samples []sampleParts) error {
+	db := tx.Session(&gorm.Session{NewDB: true})
+
+	var uwis []string
+	first := map[string]*EPBEBase{}
+	for _, sample := range samples {
+		sample.base.UWI = strings.TrimSpace(sample.base.UWI)
+		if uwi := sample.base.UWI; uwi != "" && first[uwi] == nil {
+			first[uwi] = sample.base
+			uwis = append(uwis, uwi)
+		}
+	}
+
+	wells := map[string]*Well{}
+	if len(uwis) > 0 {
+		var found []Well
+		if err := db.Where("uwi IN ?", uwis).Find(&found).Error; err != nil {
+			return err
+		}
+		for i := range found {
+			wells[found[i].UWI] = &found[i]
+		}
+	}
+
+	var missing []string
+	var created []*Well
+	for _, uwi := range uwis {
+		if wells[uwi] == nil {
+			missing = append(missing, uwi)
+			created = append(created, NewWellFromBase(first[uwi]))
+		}
+	}
+	if len(missing) > 0 {
+		// The headers are already normalized with the samples, and concurrent inserts of the same new
+		// UWI race on the unique index, so skip the hooks, ignore the conflict and re-read
+		if err := db.Session(&gorm.Session{SkipHooks: true}).Clauses(clause.OnConflict{DoNothing: true}).Create(&created).Error; err != nil {
+			return err
+		}
+		var found []Well
+		if err := db.Where("uwi IN ?", missing).Find(&found).Error; err != nil {
+			return err
+		}
+		for i := range found {
+			wells[found[i].UWI] = &found[i]
+		}
+	}
+
+	for _, sample := range samples {
+		base, meta := sample.base, sample.meta
+		well := wells[base.UWI]
+		if well == nil {
+			meta.WellID = nil
+			continue
+		}
+
+		// Coordinates left raw for lack of a CRS are converted with the well's
+		if base.Latitude == nil && base.Longitude == nil && meta.CoordinateCRS == "" && well.CRS != "" &&
+			(meta.RawX != "" || meta.RawY != "") {
+			_ = SetCoordinates(base, meta, meta.RawX, meta.RawY, well.CRS)
+		}
+
+		well.FillBlanks(base)
+		meta.WellID = &well.ID
+	}
+	return nil
+}
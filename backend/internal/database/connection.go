@@ -3,31 +3,62 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 
+	"workbench/internal/cache"
 	"workbench/internal/config"
-	"workbench/internal/core/models"
+	"workbench/internal/logging"
+	"workbench/internal/metrics"
 )
 
 var (
 	DB *gorm.DB
 )
 
-// Initialize creates database connection and runs migrations
+// cachedTables lists the tables that cached lookups are derived from, with the generations to invalidate
+var cachedTables = map[string][]string{
+	"wells":                 {cache.Wells},
+	"petrography_carbonate": {cache.Wells},
+	"petrography_clastic":   {cache.Wells},
+	"vocabulary_terms":      {cache.Vocabularies},
+	"vocabulary_synonyms":   {cache.Vocabularies},
+}
+
+// slowQueryThreshold is the duration above which a statement is logged as slow
+const slowQueryThreshold = 200 * time.Millisecond
+
+// Initialize connects to the database and checks that its schema is up to date. Pending migrations
+// are applied only when cfg.AutoMigrate is set; otherwise they are run with the migrate command.
 func Initialize(cfg *config.DatabaseConfig) (*gorm.DB, error) {
+	db, err := Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.AutoMigrate {
+		if _, err := MigrateUp(db, 0); err != nil {
+			return nil, fmt.Errorf("failed to run migrations: %w", err)
+		}
+	}
+	if err := CheckSchema(db); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Connect opens the database connection pool without touching the schema
+func Connect(cfg *config.DatabaseConfig) (*gorm.DB, error) {
 	var err error
 
 	// Configure GORM
 	gormConfig := &gorm.Config{
-		// Set log mode based on environment
-		Logger: logger.Default.LogMode(logger.Info),
-		// Disable foreign key constraint when migrating
-		DisableForeignKeyConstraintWhenMigrating: false,
+		// Statements are logged at debug level, slow ones at warn, through the application logger
+		Logger: logging.NewGormLogger(slowQueryThreshold),
 		// Use singular table names
 		NamingStrategy: nil,
 		// Current time function
@@ -42,11 +73,24 @@ func Initialize(cfg *config.DatabaseConfig) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	// Time every statement for the /metrics endpoint
+	if err := DB.Use(metrics.GormPlugin{}); err != nil {
+		return nil, fmt.Errorf("failed to register metrics plugin: %w", err)
+	}
+
+	// Writes drop the cached lookups derived from the written table
+	if err := DB.Use(cache.GormPlugin{Tables: cachedTables}); err != nil {
+		return nil, fmt.Errorf("failed to register cache plugin: %w", err)
+	}
+
 	// Get underlying SQL database
 	sqlDB, err := DB.DB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get database instance: %w", err)
 	}
+	if err := metrics.RegisterDBStats(sqlDB); err != nil {
+		return nil, fmt.Errorf("failed to register pool metrics: %w", err)
+	}
 
 	// Configure connection pool
 	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
@@ -58,49 +102,10 @@ func Initialize(cfg *config.DatabaseConfig) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	log.Println("✅ Database connection established")
-
-	// Run migrations
-	if err := Migrate(DB); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
-	}
-
+	slog.Info("✅ Database connection established")
 	return DB, nil
 }
 
-// Migrate runs database migrations
-func Migrate(db *gorm.DB) error {
-	log.Println("Running database migrations...")
-
-	// Create UUID extension
-	db.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\"")
-
-	// Auto migrate models
-	err := db.AutoMigrate(
-		&models.User{},
-		&models.EPBEBase{},
-		&models.DepthInfo{},
-		&models.MetadataInfo{},
-		&models.EPBEPetrographyCarbonate{},
-		&models.EPBEPetrographyClastic{},
-	)
-
-	if err != nil {
-		return fmt.Errorf("migration failed: %w", err)
-	}
-
-	// Verify tables were created
-	var tableCount int64
-	err = db.Raw("SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = 'public' AND table_type = 'BASE TABLE'").Scan(&tableCount).Error
-	if err != nil {
-		return fmt.Errorf("failed to count tables: %w", err)
-	}
-	log.Printf("🔍 Tables created: %d", tableCount)
-
-	log.Println("✅ Database migrations completed")
-	return nil
-}
-
 // GetDB returns the database instance
 func GetDB() *gorm.DB {
 	return DB
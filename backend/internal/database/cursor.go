@@ -0,0 +1,217 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// cursorPayload is the opaque content of a keyset pagination cursor
+type cursorPayload struct {
+	// Sort signature the cursor was issued for, so it cannot be replayed against another ordering
+	Sort string `json:"s"`
+	// Values of the sort keys of the last row returned
+	Values []interface{} `json:"v"`
+}
+
+// Keys returns the sort keys used for ordering and keyset pagination, ending with the tie-breaker
+func (q *ListQuery) Keys(tieBreaker string) []SortField {
+	keys := make([]SortField, 0, len(q.Sort)+1)
+	hasTieBreaker := false
+	for _, s := range q.Sort {
+		keys = append(keys, s)
+		if s.Column == tieBreaker {
+			hasTieBreaker = true
+		}
+	}
+	if tieBreaker != "" && !hasTieBreaker {
+		keys = append(keys, SortField{Column: tieBreaker})
+	}
+	return keys
+}
+
+func sortSignature(keys []SortField) string {
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if k.Desc {
+			parts = append(parts, "-"+k.Column)
+		} else {
+			parts = append(parts, k.Column)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// After returns a scope that continues a keyset scan after the row encoded in cursor.
+// An empty cursor starts from the beginning.
+func (q *ListQuery) After(cursor string, tieBreaker string) (func(db *gorm.DB) *gorm.DB, error) {
+	noop := func(db *gorm.DB) *gorm.DB { return db }
+	if cursor == "" {
+		return noop, nil
+	}
+
+	content, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(content, &payload); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	keys := q.Keys(tieBreaker)
+	if payload.Sort != sortSignature(keys) || len(payload.Values) != len(keys) {
+		return nil, fmt.Errorf("cursor does not match the requested sort order")
+	}
+	for i, key := range keys {
+		value, err := q.cursorColumnValue(key.Column, payload.Values[i])
+		if err != nil {
+			return nil, err
+		}
+		payload.Values[i] = value
+	}
+
+	condition := keysetCondition(keys, payload.Values)
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(condition)
+	}, nil
+}
+
+// cursorColumnValue checks a decoded cursor value against the kind of its sort column, so a tampered
+// cursor is rejected instead of reaching the database as a mistyped or composite value
+func (q *ListQuery) cursorColumnValue(name string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	column, ok := q.columns[name]
+	if !ok {
+		// Queries built without a whitelist only get scalar values through
+		switch value.(type) {
+		case string, float64, bool:
+			return value, nil
+		}
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	switch column.Kind {
+	case KindNumber:
+		if v, ok := value.(float64); ok {
+			return v, nil
+		}
+	case KindBool:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	case KindTime:
+		if v, ok := value.(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return t, nil
+			}
+		}
+	default:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid cursor")
+}
+
+// keysetCondition builds the lexicographic "comes after" predicate for the sort keys.
+// PostgreSQL sorts NULLs last in ascending and first in descending order, so NULL
+// cursor values are handled explicitly rather than compared.
+func keysetCondition(keys []SortField, values []interface{}) clause.Expression {
+	var alternatives []clause.Expression
+	var equalities []clause.Expression
+
+	for i, key := range keys {
+		column := clause.Column{Name: key.Column}
+		value := values[i]
+
+		var after, equal clause.Expression
+		switch {
+		case value == nil && !key.Desc:
+			// Nothing sorts after NULL in ascending order
+			after = nil
+			equal = clause.Expr{SQL: "? IS NULL", Vars: []interface{}{column}}
+		case value == nil && key.Desc:
+			after = clause.Expr{SQL: "? IS NOT NULL", Vars: []interface{}{column}}
+			equal = clause.Expr{SQL: "? IS NULL", Vars: []interface{}{column}}
+		case !key.Desc:
+			after = clause.Or(
+				clause.Gt{Column: column, Value: value},
+				clause.Expr{SQL: "? IS NULL", Vars: []interface{}{column}},
+			)
+			equal = clause.Eq{Column: column, Value: value}
+		default:
+			after = clause.Lt{Column: column, Value: value}
+			equal = clause.Eq{Column: column, Value: value}
+		}
+
+		if after != nil {
+			terms := append(append([]clause.Expression{}, equalities...), after)
+			alternatives = append(alternatives, clause.And(terms...))
+		}
+		equalities = append(equalities, equal)
+	}
+
+	if len(alternatives) == 0 {
+		return clause.Expr{SQL: "1 = 0"}
+	}
+	return clause.Or(alternatives...)
+}
+
+// NextCursor encodes the sort key values of record, the last row of a page, as an opaque cursor
+func (q *ListQuery) NextCursor(db *gorm.DB, record interface{}, tieBreaker string) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(record); err != nil {
+		return "", fmt.Errorf("failed to parse model schema: %w", err)
+	}
+
+	keys := q.Keys(tieBreaker)
+	payload := cursorPayload{Sort: sortSignature(keys)}
+
+	rv := reflect.Indirect(reflect.ValueOf(record))
+	for _, key := range keys {
+		field := stmt.Schema.LookUpField(key.Column)
+		if field == nil {
+			return "", fmt.Errorf("unknown sort column %q", key.Column)
+		}
+		value, _ := field.ValueOf(context.Background(), rv)
+		payload.Values = append(payload.Values, cursorValue(value))
+	}
+
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(content), nil
+}
+
+// cursorValue dereferences pointers and normalizes times so the value survives a JSON round trip
+func cursorValue(value interface{}) interface{} {
+	rv := reflect.ValueOf(value)
+	for rv.IsValid() && rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+
+	switch v := rv.Interface().(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return v
+	}
+}
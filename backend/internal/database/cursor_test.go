@@ -0,0 +1,162 @@
+package database
+
+import (
+	"encoding/base64"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type cursorRecord struct {
+	ID               uint
+	Basin            string
+	TopDepthMMDDF    *float64
+	CreatedTimestamp time.Time
+}
+
+func encodeCursor(t *testing.T, raw string) string {
+	t.Helper()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	depth := 1520.5
+	created := time.Date(2024, 5, 1, 8, 30, 0, 123456789, time.FixedZone("MYT", 8*3600))
+
+	tests := []struct {
+		name     string
+		sort     string
+		record   cursorRecord
+		wantSQL  string
+		wantVars []interface{}
+	}{
+		{
+			name:     "tie-breaker only",
+			record:   cursorRecord{ID: 42},
+			wantSQL:  `("id" > $1 OR "id" IS NULL)`,
+			wantVars: []interface{}{42.0},
+		},
+		{
+			name:     "descending number",
+			sort:     "-top_depth_mmddf",
+			record:   cursorRecord{ID: 7, TopDepthMMDDF: &depth},
+			wantSQL:  `("top_depth_mmddf" < $1 OR ("top_depth_mmddf" = $2 AND ("id" > $3 OR "id" IS NULL)))`,
+			wantVars: []interface{}{1520.5, 1520.5, 7.0},
+		},
+		{
+			name:     "ascending null sorts last",
+			sort:     "top_depth_mmddf",
+			record:   cursorRecord{ID: 7},
+			wantSQL:  `("top_depth_mmddf" IS NULL AND ("id" > $1 OR "id" IS NULL))`,
+			wantVars: []interface{}{7.0},
+		},
+		{
+			name:     "descending null",
+			sort:     "-top_depth_mmddf",
+			record:   cursorRecord{ID: 7},
+			wantSQL:  `("top_depth_mmddf" IS NOT NULL OR ("top_depth_mmddf" IS NULL AND ("id" > $1 OR "id" IS NULL)))`,
+			wantVars: []interface{}{7.0},
+		},
+		{
+			name:     "time and string",
+			sort:     "-created_timestamp,basin",
+			record:   cursorRecord{ID: 3, Basin: "Malay", CreatedTimestamp: created},
+			wantSQL:  `("created_timestamp" < $1 OR ("created_timestamp" = $2 AND ("basin" > $3 OR "basin" IS NULL)) OR ("created_timestamp" = $4 AND "basin" = $5 AND ("id" > $6 OR "id" IS NULL)))`,
+			wantVars: []interface{}{created.UTC(), created.UTC(), "Malay", created.UTC(), "Malay", 3.0},
+		},
+	}
+
+	db := dryRunDB(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := ParseListQuery(url.Values{"sort": {tt.sort}}, testColumns)
+			if err != nil {
+				t.Fatalf("ParseListQuery: %v", err)
+			}
+			cursor, err := query.NextCursor(db, &tt.record, "id")
+			if err != nil {
+				t.Fatalf("NextCursor: %v", err)
+			}
+			after, err := query.After(cursor, "id")
+			if err != nil {
+				t.Fatalf("After: %v", err)
+			}
+
+			var rows []map[string]interface{}
+			stmt := db.Table("samples").Scopes(after).Find(&rows).Statement
+			if sql := stmt.SQL.String(); !strings.Contains(sql, "WHERE "+tt.wantSQL) {
+				t.Errorf("sql = %s, want WHERE %s", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(stmt.Vars, tt.wantVars) {
+				t.Errorf("vars = %#v, want %#v", stmt.Vars, tt.wantVars)
+			}
+		})
+	}
+}
+
+func TestCursorEmptyStartsFromBeginning(t *testing.T) {
+	query, err := ParseListQuery(url.Values{}, testColumns)
+	if err != nil {
+		t.Fatalf("ParseListQuery: %v", err)
+	}
+	after, err := query.After("", "id")
+	if err != nil {
+		t.Fatalf("After: %v", err)
+	}
+
+	var rows []map[string]interface{}
+	if sql := dryRunDB(t).Table("samples").Scopes(after).Find(&rows).Statement.SQL.String(); strings.Contains(sql, "WHERE") {
+		t.Errorf("sql = %s, want no condition", sql)
+	}
+}
+
+func TestCursorRejectsTampering(t *testing.T) {
+	tests := []struct {
+		name    string
+		sort    string
+		cursor  string
+		wantErr string
+	}{
+		{"not base64", "", "***", "invalid cursor"},
+		{"not json", "", encodeCursor(t, "not json"), "invalid cursor"},
+		{"other sort order", "basin", encodeCursor(t, `{"s":"-basin,id","v":["a",1]}`), "does not match the requested sort order"},
+		{"missing value", "basin", encodeCursor(t, `{"s":"basin,id","v":["a"]}`), "does not match the requested sort order"},
+		{"extra value", "", encodeCursor(t, `{"s":"id","v":[1,2]}`), "does not match the requested sort order"},
+		{"string for number", "", encodeCursor(t, `{"s":"id","v":["1 OR 1=1"]}`), "invalid cursor"},
+		{"bool for number", "", encodeCursor(t, `{"s":"id","v":[true]}`), "invalid cursor"},
+		{"number for string", "basin", encodeCursor(t, `{"s":"basin,id","v":[5,1]}`), "invalid cursor"},
+		{"number for bool", "verified", encodeCursor(t, `{"s":"verified,id","v":[1,1]}`), "invalid cursor"},
+		{"array value", "basin", encodeCursor(t, `{"s":"basin,id","v":[["a","b"],1]}`), "invalid cursor"},
+		{"object value", "", encodeCursor(t, `{"s":"id","v":[{"sql":"1=1"}]}`), "invalid cursor"},
+		{"malformed time", "created_timestamp", encodeCursor(t, `{"s":"created_timestamp,id","v":["yesterday",1]}`), "invalid cursor"},
+		{"number for time", "created_timestamp", encodeCursor(t, `{"s":"created_timestamp,id","v":[1714552200,1]}`), "invalid cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := ParseListQuery(url.Values{"sort": {tt.sort}}, testColumns)
+			if err != nil {
+				t.Fatalf("ParseListQuery: %v", err)
+			}
+			_, err = query.After(tt.cursor, "id")
+			if err == nil {
+				t.Fatalf("expected an error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCursorAcceptsNullValues(t *testing.T) {
+	query, err := ParseListQuery(url.Values{"sort": {"verified"}}, testColumns)
+	if err != nil {
+		t.Fatalf("ParseListQuery: %v", err)
+	}
+	if _, err := query.After(encodeCursor(t, `{"s":"verified,id","v":[null,9]}`), "id"); err != nil {
+		t.Errorf("After: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"fmt"
+
+	"workbench/internal/core/models"
+
+	"gorm.io/gorm"
+)
+
+// FormationStraddle is a sample whose depth interval crosses a formation boundary
+type FormationStraddle struct {
+	Table     string   `json:"table"`
+	ID        uint     `json:"id"`
+	WellID    uint     `json:"well_id"`
+	Formation string   `json:"formation"`
+	Crossed   []string `json:"crossed"`
+}
+
+// FormationReport summarizes an AssignFormations run
+type FormationReport struct {
+	Wells           int                 `json:"wells"`
+	SamplesAssigned int64               `json:"samples_assigned"`
+	SamplesCleared  int64               `json:"samples_cleared"`
+	Straddling      []FormationStraddle `json:"straddling"`
+}
+
+// formationRow is the part of a sample read and written by AssignFormations
+type formationRow struct {
+	ID uint
+	models.DepthInfo
+	FormationName       string
+	ReservoirName       string
+	FormationAssignment string
+}
+
+// AssignFormations sets formation and reservoir on the samples of a well, or of every well with tops
+// when wellID is nil, from their depth intervals. Formations entered by hand are kept; formations
+// assigned earlier are updated, or cleared when no top covers the sample any more.
+func AssignFormations(db *gorm.DB, wellID *uint) (*FormationReport, error) {
+	report := &FormationReport{Straddling: []FormationStraddle{}}
+
+	var wellIDs []uint
+	if wellID != nil {
+		wellIDs = []uint{*wellID}
+	} else {
+		// Wells with tops, plus wells whose samples still hold a formation from tops since deleted
+		seen := map[uint]bool{}
+		var ids []uint
+		if err := db.Model(&models.FormationTop{}).Distinct("well_id").Pluck("well_id", &ids).Error; err != nil {
+			return nil, fmt.Errorf("failed to read formation tops: %w", err)
+		}
+		for _, table := range WellSampleTables {
+			var assigned []uint
+			if err := db.Table(table).Where("well_id IS NOT NULL AND formation_assignment <> ''").
+				Distinct("well_id").Pluck("well_id", &assigned).Error; err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", table, err)
+			}
+			ids = append(ids, assigned...)
+		}
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				wellIDs = append(wellIDs, id)
+			}
+		}
+	}
+
+	for _, id := range wellIDs {
+		var tops []models.FormationTop
+		if err := db.Where("well_id = ?", id).Find(&tops).Error; err != nil {
+			return nil, fmt.Errorf("failed to read formation tops of well %d: %w", id, err)
+		}
+
+		for _, table := range WellSampleTables {
+			var rows []formationRow
+			if err := db.Table(table).
+				Where("well_id = ? AND deleted_at IS NULL", id).
+				Where("formation_assignment <> '' OR TRIM(COALESCE(formation_name, '')) = ''").
+				Find(&rows).Error; err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", table, err)
+			}
+
+			for _, row := range rows {
+				base := models.EPBEBase{FormationName: row.FormationName, ReservoirName: row.ReservoirName}
+				meta := models.MetadataInfo{FormationAssignment: row.FormationAssignment}
+				match := models.MatchFormation(tops, &row.DepthInfo)
+				if match != nil && match.Status == models.FormationStraddles {
+					report.Straddling = append(report.Straddling, FormationStraddle{
+						Table: table, ID: row.ID, WellID: id, Formation: match.Formation, Crossed: match.Crossed,
+					})
+				}
+				if !models.ApplyFormation(&base, &meta, match) {
+					continue
+				}
+
+				// UpdateColumns skips the model hooks and timestamps; only the formation columns change
+				if err := db.Table(table).Where("id = ?", row.ID).UpdateColumns(map[string]interface{}{
+					"formation_name":       base.FormationName,
+					"reservoir_name":       base.ReservoirName,
+					"formation_assignment": meta.FormationAssignment,
+				}).Error; err != nil {
+					return nil, fmt.Errorf("failed to assign formation to %s %d: %w", table, row.ID, err)
+				}
+				if match == nil {
+					report.SamplesCleared++
+				} else {
+					report.SamplesAssigned++
+				}
+			}
+		}
+		report.Wells++
+	}
+	return report, nil
+}
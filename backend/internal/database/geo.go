@@ -0,0 +1,165 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// EarthRadiusKm is the mean Earth radius used for great-circle distances
+const EarthRadiusKm = 6371.0088
+
+// GeoFilter restricts rows to an area using their latitude and longitude columns.
+// Coordinates are WGS84 decimal degrees and always given longitude first, as in GeoJSON.
+type GeoFilter struct {
+	// BBox is min longitude, min latitude, max longitude, max latitude
+	BBox []float64 `json:"bbox,omitempty"`
+	// Point and RadiusKm select rows within a great-circle distance of a point
+	Point    []float64 `json:"point,omitempty"`
+	RadiusKm float64   `json:"radius_km,omitempty"`
+	// Polygon is a ring of longitude/latitude pairs; it is closed automatically
+	Polygon [][2]float64 `json:"polygon,omitempty"`
+}
+
+// ParseGeoFilter reads the spatial query parameters. It returns nil when none is present.
+//
+//	bbox=minLon,minLat,maxLon,maxLat
+//	point=lon,lat&radius_km=25
+//	polygon=lon,lat,lon,lat,lon,lat
+func ParseGeoFilter(params url.Values) (*GeoFilter, error) {
+	geo := &GeoFilter{}
+	present := false
+
+	if raw := params.Get("bbox"); raw != "" {
+		values, err := parseCoordinates(raw, 4)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bbox: %w", err)
+		}
+		if values[0] > values[2] || values[1] > values[3] {
+			return nil, fmt.Errorf("invalid bbox: minimums must not exceed maximums")
+		}
+		if err := checkLonLat(values[0], values[1]); err != nil {
+			return nil, fmt.Errorf("invalid bbox: %w", err)
+		}
+		if err := checkLonLat(values[2], values[3]); err != nil {
+			return nil, fmt.Errorf("invalid bbox: %w", err)
+		}
+		geo.BBox = values
+		present = true
+	}
+
+	if raw := params.Get("point"); raw != "" {
+		values, err := parseCoordinates(raw, 2)
+		if err != nil {
+			return nil, fmt.Errorf("invalid point: %w", err)
+		}
+		if err := checkLonLat(values[0], values[1]); err != nil {
+			return nil, fmt.Errorf("invalid point: %w", err)
+		}
+		radius, err := strconv.ParseFloat(params.Get("radius_km"), 64)
+		if err != nil || radius <= 0 {
+			return nil, fmt.Errorf("point requires a positive radius_km")
+		}
+		geo.Point = values
+		geo.RadiusKm = radius
+		present = true
+	} else if params.Get("radius_km") != "" {
+		return nil, fmt.Errorf("radius_km requires point")
+	}
+
+	if raw := params.Get("polygon"); raw != "" {
+		parts := strings.Split(raw, ",")
+		if len(parts)%2 != 0 {
+			return nil, fmt.Errorf("invalid polygon: expected longitude,latitude pairs")
+		}
+		for i := 0; i < len(parts); i += 2 {
+			values, err := parseCoordinates(parts[i]+","+parts[i+1], 2)
+			if err != nil {
+				return nil, fmt.Errorf("invalid polygon: %w", err)
+			}
+			if err := checkLonLat(values[0], values[1]); err != nil {
+				return nil, fmt.Errorf("invalid polygon: %w", err)
+			}
+			geo.Polygon = append(geo.Polygon, [2]float64{values[0], values[1]})
+		}
+		if len(geo.Polygon) < 3 {
+			return nil, fmt.Errorf("invalid polygon: at least 3 vertices are required")
+		}
+		present = true
+	}
+
+	if !present {
+		return nil, nil
+	}
+	return geo, nil
+}
+
+func parseCoordinates(raw string, count int) ([]float64, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != count {
+		return nil, fmt.Errorf("expected %d comma separated numbers", count)
+	}
+	values := make([]float64, count)
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, fmt.Errorf("%q is not a number", part)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func checkLonLat(lon, lat float64) error {
+	if lon < -180 || lon > 180 {
+		return fmt.Errorf("longitude %v out of range", lon)
+	}
+	if lat < -90 || lat > 90 {
+		return fmt.Errorf("latitude %v out of range", lat)
+	}
+	return nil
+}
+
+// Expression converts the spatial filter to SQL on the latitude and longitude columns.
+// It only uses core PostgreSQL: plain comparisons, trigonometry and the built-in polygon type.
+func (g *GeoFilter) Expression() clause.Expression {
+	lat := clause.Column{Name: "latitude"}
+	lon := clause.Column{Name: "longitude"}
+
+	exprs := []clause.Expression{
+		clause.Expr{SQL: "? IS NOT NULL AND ? IS NOT NULL", Vars: []interface{}{lat, lon}},
+	}
+
+	if len(g.BBox) == 4 {
+		exprs = append(exprs,
+			clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []interface{}{lon, g.BBox[0], g.BBox[2]}},
+			clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []interface{}{lat, g.BBox[1], g.BBox[3]}},
+		)
+	}
+
+	if len(g.Point) == 2 {
+		// Haversine distance; LEAST guards ASIN against rounding just above 1
+		exprs = append(exprs, clause.Expr{
+			SQL: fmt.Sprintf("2 * %v * ASIN(LEAST(1, SQRT(POWER(SIN(RADIANS(? - ?) / 2), 2) + ", EarthRadiusKm) +
+				"COS(RADIANS(?)) * COS(RADIANS(?)) * POWER(SIN(RADIANS(? - ?) / 2), 2)))) <= ?",
+			Vars: []interface{}{lat, g.Point[1], g.Point[1], lat, lon, g.Point[0], g.RadiusKm},
+		})
+	}
+
+	if len(g.Polygon) >= 3 {
+		vertices := make([]string, len(g.Polygon))
+		for i, v := range g.Polygon {
+			vertices[i] = fmt.Sprintf("(%s,%s)", strconv.FormatFloat(v[0], 'f', -1, 64), strconv.FormatFloat(v[1], 'f', -1, 64))
+		}
+		exprs = append(exprs, clause.Expr{
+			SQL:  "CAST(? AS polygon) @> point(CAST(? AS double precision), CAST(? AS double precision))",
+			Vars: []interface{}{"(" + strings.Join(vertices, ",") + ")", lon, lat},
+		})
+	}
+
+	return clause.And(exprs...)
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"workbench/internal/timescale"
+
+	"gorm.io/gorm/clause"
+)
+
+// Interval match modes
+const (
+	IntervalWithin   = "within"
+	IntervalOverlaps = "overlaps"
+)
+
+// IntervalFilter restricts rows to a span of geologic time using their age_start_ma and age_end_ma columns
+type IntervalFilter struct {
+	Text     string             `json:"text"`
+	Interval timescale.Interval `json:"interval"`
+	Match    string             `json:"match"`
+}
+
+// ParseIntervalFilter reads the geologic time query parameters. It returns nil when none is present.
+//
+//	interval=Miocene                       samples dated within the Miocene
+//	interval=Middle Miocene-Pliocene       names, ranges and abbreviations as accepted by timescale.Parse
+//	interval=15-12 Ma&interval_match=overlaps
+//
+// interval_match=within (default) keeps samples whose whole span lies in the interval, so an
+// "Oligo-Miocene" sample is not a Miocene one; overlaps keeps samples sharing any time with it.
+func ParseIntervalFilter(params url.Values) (*IntervalFilter, error) {
+	text := strings.TrimSpace(params.Get("interval"))
+	match := strings.ToLower(strings.TrimSpace(params.Get("interval_match")))
+	if text == "" {
+		if match != "" {
+			return nil, fmt.Errorf("interval_match requires interval")
+		}
+		return nil, nil
+	}
+
+	switch match {
+	case "":
+		match = IntervalWithin
+	case IntervalWithin, IntervalOverlaps:
+	default:
+		return nil, fmt.Errorf("invalid interval_match %q: expected %s or %s", match, IntervalWithin, IntervalOverlaps)
+	}
+
+	interval, err := timescale.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interval: %w", err)
+	}
+	return &IntervalFilter{Text: text, Interval: interval, Match: match}, nil
+}
+
+// Expression converts the interval filter to SQL. Boundaries follow the chart: a sample dated exactly
+// at a boundary belongs to the younger unit, so 23.03 Ma is Miocene and not Oligocene.
+func (f *IntervalFilter) Expression() clause.Expression {
+	start := clause.Column{Name: "age_start_ma"}
+	end := clause.Column{Name: "age_end_ma"}
+	older, younger := f.Interval.Start, f.Interval.End
+
+	if f.Interval.IsPoint() {
+		// Samples whose span contains the age, including samples dated at exactly that age
+		return clause.Expr{
+			SQL:  "(? >= ? AND ? < ?) OR (? = ? AND ? = ?)",
+			Vars: []interface{}{start, older, end, older, start, older, end, older},
+		}
+	}
+
+	if f.Match == IntervalOverlaps {
+		return clause.Expr{
+			SQL:  "? > ? AND (? < ? OR (? = ? AND ? <= ?))",
+			Vars: []interface{}{start, younger, end, older, start, end, start, older},
+		}
+	}
+
+	expr := clause.Expr{
+		SQL:  "? <= ? AND ? >= ?",
+		Vars: []interface{}{start, older, end, younger},
+	}
+	if younger > 0 {
+		// A sample dated at the interval's top belongs to the next younger unit
+		expr.SQL += " AND (? > ? OR ? > ?)"
+		expr.Vars = append(expr.Vars, start, end, start, younger)
+	}
+	return expr
+}
@@ -0,0 +1,349 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationFiles holds the SQL migrations, named <version>_<name>.up.sql and <version>_<name>.down.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID serializes migrators on one database through a transaction-level advisory lock
+const migrationLockID = 7283451020
+
+// ErrIrreversibleMigration is returned when rolling back a migration without a down step
+var ErrIrreversibleMigration = errors.New("migration cannot be rolled back")
+
+// ErrPendingMigrations is returned at startup when the schema is behind the migrations in this build
+var ErrPendingMigrations = errors.New("database schema has pending migrations")
+
+// SchemaMigration records an applied migration in the schema_migrations table
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migration is one versioned schema or data change. SQL migrations come from the migrations
+// directory; Go migrations, for backfills SQL cannot express, are registered in goMigrations.
+type Migration struct {
+	Version int64
+	Name    string
+	up      func(tx *gorm.DB) error
+	down    func(tx *gorm.DB) error
+}
+
+// Reversible reports whether the migration has a down step
+func (m Migration) Reversible() bool {
+	return m.down != nil
+}
+
+// MigrationState is a migration and whether it is applied
+type MigrationState struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	// Missing marks versions recorded in the database that this build does not know
+	Missing bool `json:"missing,omitempty"`
+}
+
+var migrationFilename = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// noopDown is the down step of data migrations whose changes are kept on rollback
+func noopDown(tx *gorm.DB) error {
+	return nil
+}
+
+// goMigrations are the data migrations written in Go
+var goMigrations = []Migration{
+	{Version: 3, Name: "seed_vocabularies", up: SeedVocabularies, down: noopDown},
+	{Version: 4, Name: "link_samples_to_wells", up: func(tx *gorm.DB) error {
+		report, err := DeduplicateWells(tx)
+		if err != nil {
+			return err
+		}
+		slog.Info("🛢️ Wells linked", "created", report.WellsCreated, "samples_linked", report.SamplesLinked, "header_conflicts", len(report.Conflicts))
+		return nil
+	}, down: noopDown},
+	{Version: 5, Name: "backfill_timescale", up: func(tx *gorm.DB) error {
+		report, err := BackfillTimescale(tx)
+		if err != nil {
+			return err
+		}
+		slog.Info("🪨 Timescale backfilled", "samples_dated", report.SamplesDated, "inconsistent", report.Inconsistent)
+		return nil
+	}, down: noopDown},
+}
+
+// Migrations returns every migration of this build in version order
+func Migrations() ([]Migration, error) {
+	byVersion := map[int64]*Migration{}
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		m := migrationFilename.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		content, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, m[2])
+		}
+		step := sqlStep(string(content))
+		if m[3] == "up" {
+			migration.up = step
+		} else {
+			migration.down = step
+		}
+	}
+
+	for i := range goMigrations {
+		if _, ok := byVersion[goMigrations[i].Version]; ok {
+			return nil, fmt.Errorf("migration version %d is used twice", goMigrations[i].Version)
+		}
+		byVersion[goMigrations[i].Version] = &goMigrations[i]
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == nil {
+			return nil, fmt.Errorf("migration %d_%s has no up step", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// sqlStep runs a migration file; without arguments pgx sends it as one multi-statement query
+func sqlStep(sql string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if strings.TrimSpace(sql) == "" {
+			return nil
+		}
+		return tx.Exec(sql).Error
+	}
+}
+
+// appliedMigrations creates the schema_migrations table if needed and returns its rows by version
+func appliedMigrations(db *gorm.DB) (map[int64]SchemaMigration, error) {
+	if err := db.Exec(`CREATE TABLE IF NOT EXISTS "schema_migrations" (
+		"version" bigint PRIMARY KEY,
+		"name" varchar(255) NOT NULL,
+		"applied_at" timestamptz NOT NULL
+	)`).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var rows []SchemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	applied := make(map[int64]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// MigrationStatus lists every known migration with its state, plus applied versions this build lacks
+func MigrationStatus(db *gorm.DB) ([]MigrationState, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, migration := range migrations {
+		state := MigrationState{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			state.Applied = true
+			state.AppliedAt = &row.AppliedAt
+			delete(applied, migration.Version)
+		}
+		states = append(states, state)
+	}
+	for _, row := range applied {
+		appliedAt := row.AppliedAt
+		states = append(states, MigrationState{Version: row.Version, Name: row.Name, Applied: true, AppliedAt: &appliedAt, Missing: true})
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Version < states[j].Version
+	})
+	return states, nil
+}
+
+// PendingMigrations returns the migrations not applied yet, in version order
+func PendingMigrations(db *gorm.DB) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// MigrateUp applies pending migrations up to and including target, or all of them when target is 0.
+// Each migration runs in its own transaction together with its schema_migrations row.
+func MigrateUp(db *gorm.DB, target int64) ([]Migration, error) {
+	pending, err := PendingMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range pending {
+		if target > 0 && migration.Version > target {
+			break
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			applied, err := lockMigrations(tx)
+			if err != nil {
+				return err
+			}
+			// Another migrator may have applied it while this one waited for the lock
+			if _, ok := applied[migration.Version]; ok {
+				return errSkipMigration
+			}
+			if err := migration.up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if errors.Is(err, errSkipMigration) {
+			continue
+		}
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		slog.Info("⬆️ Applied migration", "version", migration.Version, "name", migration.Name)
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// MigrateDown rolls back the latest applied migrations, steps of them
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]Migration{}
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	var done []Migration
+	for i := 0; i < steps; i++ {
+		var rolledBack *Migration
+		err := db.Transaction(func(tx *gorm.DB) error {
+			applied, err := lockMigrations(tx)
+			if err != nil {
+				return err
+			}
+			var latest int64 = -1
+			for version := range applied {
+				if version > latest {
+					latest = version
+				}
+			}
+			if latest < 0 {
+				return errSkipMigration
+			}
+
+			migration, ok := byVersion[latest]
+			if !ok {
+				return fmt.Errorf("applied migration %d_%s is not in this build", latest, applied[latest].Name)
+			}
+			if !migration.Reversible() {
+				return fmt.Errorf("%w: %d_%s has no down step", ErrIrreversibleMigration, migration.Version, migration.Name)
+			}
+			if err := migration.down(tx); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			rolledBack = &migration
+			return tx.Delete(&SchemaMigration{}, "version = ?", migration.Version).Error
+		})
+		if errors.Is(err, errSkipMigration) {
+			break
+		}
+		if err != nil {
+			return done, err
+		}
+		slog.Info("⬇️ Rolled back migration", "version", rolledBack.Version, "name", rolledBack.Name)
+		done = append(done, *rolledBack)
+	}
+	return done, nil
+}
+
+// errSkipMigration ends a migration transaction without applying anything
+var errSkipMigration = errors.New("nothing to migrate")
+
+// lockMigrations takes the migration lock for the transaction and returns the applied versions
+func lockMigrations(tx *gorm.DB) (map[int64]SchemaMigration, error) {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID).Error; err != nil {
+		return nil, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	return appliedMigrations(tx)
+}
+
+// CheckSchema fails with ErrPendingMigrations when migrations of this build have not been applied
+func CheckSchema(db *gorm.DB) error {
+	pending, err := PendingMigrations(db)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		names := make([]string, len(pending))
+		for i, migration := range pending {
+			names[i] = fmt.Sprintf("%d_%s", migration.Version, migration.Name)
+		}
+		return fmt.Errorf("%w: %s; run `go run ./cmd/migrate up`", ErrPendingMigrations, strings.Join(names, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+-- Drops everything the baseline creates, dependents first
+DROP TABLE IF EXISTS "vocabulary_unknowns";
+DROP TABLE IF EXISTS "vocabulary_synonyms";
+DROP TABLE IF EXISTS "vocabulary_terms";
+DROP TABLE IF EXISTS "import_jobs";
+DROP TABLE IF EXISTS "petrography_clastic";
+DROP TABLE IF EXISTS "petrography_carbonate";
+DROP TABLE IF EXISTS "formation_tops";
+DROP TABLE IF EXISTS "wells";
+DROP TABLE IF EXISTS "users";
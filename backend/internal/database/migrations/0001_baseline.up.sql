@@ -0,0 +1,489 @@
+-- Baseline: the schema GORM AutoMigrate produced before versioned migrations.
+-- Every statement is idempotent so databases created by AutoMigrate are adopted as they are.
+
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+-- Users
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" uuid DEFAULT uuid_generate_v4(),
+    "email" text NOT NULL,
+    "password" text NOT NULL,
+    "first_name" text NOT NULL,
+    "last_name" text NOT NULL,
+    "is_active" boolean DEFAULT true,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+
+-- Wells
+CREATE TABLE IF NOT EXISTS "wells" (
+    "id" bigserial,
+    "uwi" varchar(255) NOT NULL,
+    "well_name_field_name" varchar(255),
+    "country" varchar(255),
+    "region" varchar(255),
+    "sub_region" varchar(255),
+    "business_regions" varchar(255),
+    "basin" varchar(255),
+    "sub_basin" varchar(255),
+    "latitude" decimal(10,7),
+    "longitude" decimal(10,7),
+    "onshore_offshore" varchar(255),
+    "water_depth_m" decimal(10,2),
+    "water_depth_ft" decimal(10,2),
+    "crs" varchar(50),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_wells_uwi" ON "wells" ("uwi");
+
+-- Formation tops
+CREATE TABLE IF NOT EXISTS "formation_tops" (
+    "id" bigserial,
+    "well_id" bigint NOT NULL,
+    "formation_name" varchar(255) NOT NULL,
+    "reservoir_name" varchar(255),
+    "top_depth" decimal(10,2) NOT NULL,
+    "base_depth" decimal(10,2),
+    "datum" varchar(10) NOT NULL DEFAULT 'MDDF',
+    "unit" varchar(5) NOT NULL DEFAULT 'm',
+    "source" varchar(255),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_formation_tops_well" FOREIGN KEY ("well_id") REFERENCES "wells"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_formation_tops_well_id" ON "formation_tops" ("well_id");
+
+-- Carbonate petrography samples
+CREATE TABLE IF NOT EXISTS "petrography_carbonate" (
+    "country" varchar(255),
+    "region" varchar(255),
+    "sub_region" varchar(255),
+    "business_regions" varchar(255),
+    "basin" varchar(255),
+    "sub_basin" varchar(255),
+    "well_name_field_name" varchar(255),
+    "uwi" varchar(255),
+    "latitude" decimal(10,7),
+    "longitude" decimal(10,7),
+    "formation_name" varchar(255),
+    "reservoir_name" varchar(255),
+    "period" varchar(255),
+    "epoch" varchar(255),
+    "age" varchar(255),
+    "onshore_offshore" varchar(255),
+    "water_depth_m" decimal(10,2),
+    "water_depth_ft" decimal(10,2),
+    "depth_reference_type" varchar(255),
+    "depth_reference_elevation_m" decimal(10,2),
+    "depth_reference_elevation_ft" decimal(10,2),
+    "ground_level_elevation_m" decimal(10,2),
+    "ground_level_elevation_ft" decimal(10,2),
+    "top_depth_mmddf" decimal(10,2),
+    "top_depth_mtvddf" decimal(10,2),
+    "top_depth_mtvdss" decimal(10,2),
+    "top_depth_mbml" decimal(10,2),
+    "bottom_depth_mmddf" decimal(10,2),
+    "bottom_depth_mtvddf" decimal(10,2),
+    "bottom_depth_mtvdss" decimal(10,2),
+    "bottom_depth_mbml" decimal(10,2),
+    "top_depth_ftmddf" decimal(10,2),
+    "top_depth_fttvddf" decimal(10,2),
+    "top_depth_fttvdss" decimal(10,2),
+    "top_depth_ftbml" decimal(10,2),
+    "bottom_depth_ftmddf" decimal(10,2),
+    "bottom_depth_fttvddf" decimal(10,2),
+    "bottom_depth_fttvdss" decimal(10,2),
+    "bottom_depth_ftbml" decimal(10,2),
+    "lithofacies_core" varchar(255),
+    "microfacies_thin_section" varchar(255),
+    "depofacies" varchar(255),
+    "visible_porosity_percent" decimal(8,4),
+    "he_porosity_percent" decimal(8,4),
+    "permeability_md" decimal(12,4),
+    "calcite" decimal(8,4),
+    "dolomite" decimal(8,4),
+    "micrite" decimal(8,4),
+    "micrite_envelopes" decimal(8,4),
+    "microspar_pseudospar" decimal(8,4),
+    "kaolinite" decimal(8,4),
+    "clay" decimal(8,4),
+    "total_mineralogy_matrix_percent" decimal(8,4),
+    "bioclasts" decimal(8,4),
+    "lepido" decimal(8,4),
+    "coral" decimal(8,4),
+    "rhodolith" decimal(8,4),
+    "red_algae" decimal(8,4),
+    "red_algae_enc" decimal(8,4),
+    "green_algae" decimal(8,4),
+    "echinoderms" decimal(8,4),
+    "miliolid" decimal(8,4),
+    "lepidocyclina" decimal(8,4),
+    "cycloclypeus" decimal(8,4),
+    "operculina" decimal(8,4),
+    "other_rotaliids" decimal(8,4),
+    "gypsinid" decimal(8,4),
+    "planorbulinella" decimal(8,4),
+    "hemotremid" decimal(8,4),
+    "heterostegina" decimal(8,4),
+    "enc_frm" decimal(8,4),
+    "planktonic" decimal(8,4),
+    "bryozoans" decimal(8,4),
+    "amphistegina" decimal(8,4),
+    "gastropods" decimal(8,4),
+    "bivalve" decimal(8,4),
+    "ostracod" decimal(8,4),
+    "oncoids" decimal(8,4),
+    "undiff_molluscs" decimal(8,4),
+    "undiff_benthonic" decimal(8,4),
+    "undiff_skeletal" decimal(8,4),
+    "undiff_foram" decimal(8,4),
+    "total_skeletal_percent" decimal(8,4),
+    "organic" decimal(8,4),
+    "peloids" decimal(8,4),
+    "micritised_grains" decimal(8,4),
+    "pseudoclasts" decimal(8,4),
+    "intraclast" decimal(8,4),
+    "quartz" decimal(8,4),
+    "total_non_skeletal_percent" decimal(8,4),
+    "interparticle" decimal(8,4),
+    "intraparticle" decimal(8,4),
+    "intercrystalline" decimal(8,4),
+    "matrix_intercrystalline" decimal(8,4),
+    "mouldic" decimal(8,4),
+    "vuggy" decimal(8,4),
+    "fractures" decimal(8,4),
+    "micro" decimal(8,4),
+    "total_porosity_percent" decimal(8,4),
+    "fringing" decimal(8,4),
+    "meniscus" decimal(8,4),
+    "blocky" decimal(8,4),
+    "sparry" decimal(8,4),
+    "micritic" decimal(8,4),
+    "pendant" decimal(8,4),
+    "syntax" decimal(8,4),
+    "calcite_syntaxial" decimal(8,4),
+    "calcite_fringing" decimal(8,4),
+    "calcite_mosaic" decimal(8,4),
+    "calcite_blocky" decimal(8,4),
+    "calcite_ferroan" decimal(8,4),
+    "pyrite" decimal(8,4),
+    "fluorite" decimal(8,4),
+    "total_cement_percent" decimal(8,4),
+    "replacement" decimal(8,4),
+    "saddle" decimal(8,4),
+    "total_dolomite_percent" decimal(8,4),
+    "stylolite" decimal(8,4),
+    "bioturbation" decimal(8,4),
+    "total_accessories_percent" decimal(8,4),
+    "total_percent" decimal(8,4),
+    "analysis_types" varchar(255),
+    "data_source" varchar(255),
+    "analysis_type" varchar(255),
+    "owner" varchar(255),
+    "ownership" varchar(255),
+    "assurance" varchar(255),
+    "data_generator" varchar(255),
+    "data_generation_date" timestamptz,
+    "remark" text,
+    "data_entry_date" timestamptz,
+    "data_entry_mode" varchar(255),
+    "data_entry_focal" varchar(255),
+    "metadata_discipline_name" varchar(100),
+    "metadata_data_source_name" varchar(100),
+    "session_id" varchar(100),
+    "updated_timestamp" timestamptz DEFAULT CURRENT_TIMESTAMP,
+    "created_timestamp" timestamptz DEFAULT CURRENT_TIMESTAMP,
+    "id" bigserial,
+    "well_id" bigint,
+    "coordinate_crs" varchar(50),
+    "raw_x" varchar(100),
+    "raw_y" varchar(100),
+    "age_start_ma" decimal(10,4),
+    "age_end_ma" decimal(10,4),
+    "formation_assignment" varchar(20),
+    "duplicate_status" varchar(50),
+    "duplicate_resolution_action" varchar(50),
+    "master_record_id" bigint,
+    "review_queue_id" varchar(50),
+    "resolution_timestamp" timestamptz,
+    "resolution_reason" varchar(500),
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_petrography_carbonate_well" FOREIGN KEY ("well_id") REFERENCES "wells"("id") ON DELETE RESTRICT ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_petrography_carbonate_age_end_ma" ON "petrography_carbonate" ("age_end_ma");
+CREATE INDEX IF NOT EXISTS "idx_petrography_carbonate_age_start_ma" ON "petrography_carbonate" ("age_start_ma");
+CREATE INDEX IF NOT EXISTS "idx_petrography_carbonate_deleted_at" ON "petrography_carbonate" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_petrography_carbonate_formation_assignment" ON "petrography_carbonate" ("formation_assignment");
+CREATE INDEX IF NOT EXISTS "idx_petrography_carbonate_session_id" ON "petrography_carbonate" ("session_id");
+CREATE INDEX IF NOT EXISTS "idx_petrography_carbonate_well_id" ON "petrography_carbonate" ("well_id");
+
+-- Clastic petrography samples
+CREATE TABLE IF NOT EXISTS "petrography_clastic" (
+    "country" varchar(255),
+    "region" varchar(255),
+    "sub_region" varchar(255),
+    "business_regions" varchar(255),
+    "basin" varchar(255),
+    "sub_basin" varchar(255),
+    "well_name_field_name" varchar(255),
+    "uwi" varchar(255),
+    "latitude" decimal(10,7),
+    "longitude" decimal(10,7),
+    "formation_name" varchar(255),
+    "reservoir_name" varchar(255),
+    "period" varchar(255),
+    "epoch" varchar(255),
+    "age" varchar(255),
+    "onshore_offshore" varchar(255),
+    "water_depth_m" decimal(10,2),
+    "water_depth_ft" decimal(10,2),
+    "depth_reference_type" varchar(255),
+    "depth_reference_elevation_m" decimal(10,2),
+    "depth_reference_elevation_ft" decimal(10,2),
+    "ground_level_elevation_m" decimal(10,2),
+    "ground_level_elevation_ft" decimal(10,2),
+    "top_depth_mmddf" decimal(10,2),
+    "top_depth_mtvddf" decimal(10,2),
+    "top_depth_mtvdss" decimal(10,2),
+    "top_depth_mbml" decimal(10,2),
+    "bottom_depth_mmddf" decimal(10,2),
+    "bottom_depth_mtvddf" decimal(10,2),
+    "bottom_depth_mtvdss" decimal(10,2),
+    "bottom_depth_mbml" decimal(10,2),
+    "top_depth_ftmddf" decimal(10,2),
+    "top_depth_fttvddf" decimal(10,2),
+    "top_depth_fttvdss" decimal(10,2),
+    "top_depth_ftbml" decimal(10,2),
+    "bottom_depth_ftmddf" decimal(10,2),
+    "bottom_depth_fttvddf" decimal(10,2),
+    "bottom_depth_fttvdss" decimal(10,2),
+    "bottom_depth_ftbml" decimal(10,2),
+    "grain_size" varchar(255),
+    "grain_shape" varchar(255),
+    "grain_contact" varchar(255),
+    "sedimentary_structure" varchar(255),
+    "sorting" varchar(255),
+    "lithofacies" varchar(255),
+    "visible_porosity_percent" decimal(8,4),
+    "ambient_he_porosity_percent" decimal(8,4),
+    "permeability_md" decimal(12,4),
+    "grain_density_g_cc" decimal(8,4),
+    "monocrystalline_quartz" decimal(8,4),
+    "polycrystalline_quartz" decimal(8,4),
+    "total_quartz_percent" decimal(8,4),
+    "potassium_feldspar" decimal(8,4),
+    "plagioclase" decimal(8,4),
+    "feldspar_undifferentiated" decimal(8,4),
+    "total_feldspar_percent" decimal(8,4),
+    "muscovite" decimal(8,4),
+    "biotite" decimal(8,4),
+    "mica_undifferentiated" decimal(8,4),
+    "total_mica_percent" decimal(8,4),
+    "zircon" decimal(8,4),
+    "tourmaline" decimal(8,4),
+    "heavy_minerals_undifferentiated" decimal(8,4),
+    "total_heavy_minerals_percent" decimal(8,4),
+    "plutonic_rock_fragments" decimal(8,4),
+    "mafic_intermediate_volcanic_fragment" decimal(8,4),
+    "volcanic_rock_fragment" decimal(8,4),
+    "total_igneous_rf_percent" decimal(8,4),
+    "quartzose_rock_fragment" decimal(8,4),
+    "schistose_rock_fragment" decimal(8,4),
+    "metamorphic_rock_fragment_undifferentiated" decimal(8,4),
+    "total_metamorphic_rf_percent" decimal(8,4),
+    "sandstone_siltstone_rock_fragments" decimal(8,4),
+    "argillaceous_rock_fragments" decimal(8,4),
+    "siliciclastic_rock_fragments_undifferentiated" decimal(8,4),
+    "limestone_rock_fragments" decimal(8,4),
+    "dolostone_rock_fragments" decimal(8,4),
+    "chert" decimal(8,4),
+    "total_sedimentary_rf_percent" decimal(8,4),
+    "total_rock_fragments_percent" decimal(8,4),
+    "rip_up_clast" decimal(8,4),
+    "glauconite" decimal(8,4),
+    "bioclast" decimal(8,4),
+    "foraminifera_grains" decimal(8,4),
+    "undifferentiated_other_grains" decimal(8,4),
+    "total_other_grains_percent" decimal(8,4),
+    "clay_matrix" decimal(8,4),
+    "mixed_clay_silt_fine_matrix" decimal(8,4),
+    "silt_very_fine_matrix" decimal(8,4),
+    "organic_matrix" decimal(8,4),
+    "matrix_undifferentiated" decimal(8,4),
+    "total_matrix_percent" decimal(8,4),
+    "kaolinite" decimal(8,4),
+    "kaolinite_replaces_k_feldspar" decimal(8,4),
+    "illite_pore_grain_lining" decimal(8,4),
+    "illite_pore_filling" decimal(8,4),
+    "illite_replaces_k_feldspar" decimal(8,4),
+    "total_authigenic_clay_percent" decimal(8,4),
+    "syntaxial_quartz_overgrowths" decimal(8,4),
+    "feldspar_overgrowths" decimal(8,4),
+    "fe_calcite" decimal(8,4),
+    "fe_dolomite" decimal(8,4),
+    "siderite" decimal(8,4),
+    "mn_siderite" decimal(8,4),
+    "pyrite" decimal(8,4),
+    "iron_oxide_minerals" decimal(8,4),
+    "total_authigenic_non_clay_percent" decimal(8,4),
+    "intergranular" decimal(8,4),
+    "intercrystalline" decimal(8,4),
+    "pri_porosity_intragranular" decimal(8,4),
+    "total_primary_porosity_percent" decimal(8,4),
+    "sec_porosity_intragranular" decimal(8,4),
+    "intracrystalline" decimal(8,4),
+    "mouldic" decimal(8,4),
+    "fracture" decimal(8,4),
+    "total_secondary_porosity_percent" decimal(8,4),
+    "total_percent" decimal(8,4),
+    "analysis_types" varchar(255),
+    "data_source" varchar(255),
+    "analysis_type" varchar(255),
+    "owner" varchar(255),
+    "ownership" varchar(255),
+    "assurance" varchar(255),
+    "data_generator" varchar(255),
+    "data_generation_date" timestamptz,
+    "remark" text,
+    "data_entry_date" timestamptz,
+    "data_entry_mode" varchar(255),
+    "data_entry_focal" varchar(255),
+    "metadata_discipline_name" varchar(100),
+    "metadata_data_source_name" varchar(100),
+    "session_id" varchar(100),
+    "updated_timestamp" timestamptz DEFAULT CURRENT_TIMESTAMP,
+    "created_timestamp" timestamptz DEFAULT CURRENT_TIMESTAMP,
+    "id" bigserial,
+    "well_id" bigint,
+    "coordinate_crs" varchar(50),
+    "raw_x" varchar(100),
+    "raw_y" varchar(100),
+    "age_start_ma" decimal(10,4),
+    "age_end_ma" decimal(10,4),
+    "formation_assignment" varchar(20),
+    "duplicate_status" varchar(50),
+    "duplicate_resolution_action" varchar(50),
+    "master_record_id" bigint,
+    "review_queue_id" varchar(50),
+    "resolution_timestamp" timestamptz,
+    "resolution_reason" varchar(500),
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_petrography_clastic_well" FOREIGN KEY ("well_id") REFERENCES "wells"("id") ON DELETE RESTRICT ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_petrography_clastic_age_end_ma" ON "petrography_clastic" ("age_end_ma");
+CREATE INDEX IF NOT EXISTS "idx_petrography_clastic_age_start_ma" ON "petrography_clastic" ("age_start_ma");
+CREATE INDEX IF NOT EXISTS "idx_petrography_clastic_deleted_at" ON "petrography_clastic" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_petrography_clastic_formation_assignment" ON "petrography_clastic" ("formation_assignment");
+CREATE INDEX IF NOT EXISTS "idx_petrography_clastic_session_id" ON "petrography_clastic" ("session_id");
+CREATE INDEX IF NOT EXISTS "idx_petrography_clastic_well_id" ON "petrography_clastic" ("well_id");
+
+-- Spreadsheet import jobs
+CREATE TABLE IF NOT EXISTS "import_jobs" (
+    "id" uuid,
+    "filename" varchar(255),
+    "format" varchar(10),
+    "sheet" varchar(255),
+    "target" varchar(50),
+    "crs" varchar(50),
+    "stored_path" varchar(500),
+    "status" varchar(20),
+    "total_rows" bigint,
+    "processed_rows" bigint,
+    "inserted_records" bigint,
+    "failed_rows" bigint,
+    "skipped_rows" bigint,
+    "last_error" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_import_jobs_status" ON "import_jobs" ("status");
+
+-- Controlled vocabularies
+CREATE TABLE IF NOT EXISTS "vocabulary_terms" (
+    "id" bigserial,
+    "vocabulary" varchar(50) NOT NULL,
+    "value" varchar(255) NOT NULL,
+    "parent_id" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_vocabulary_terms_parent" FOREIGN KEY ("parent_id") REFERENCES "vocabulary_terms"("id") ON DELETE RESTRICT
+);
+CREATE INDEX IF NOT EXISTS "idx_vocabulary_terms_parent_id" ON "vocabulary_terms" ("parent_id");
+CREATE INDEX IF NOT EXISTS "idx_vocabulary_terms_vocabulary" ON "vocabulary_terms" ("vocabulary");
+
+CREATE TABLE IF NOT EXISTS "vocabulary_synonyms" (
+    "id" bigserial,
+    "term_id" bigint NOT NULL,
+    "vocabulary" varchar(50) NOT NULL,
+    "value" varchar(255) NOT NULL,
+    "key" varchar(255) NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_vocabulary_terms_synonyms" FOREIGN KEY ("term_id") REFERENCES "vocabulary_terms"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_vocabulary_synonyms_term_id" ON "vocabulary_synonyms" ("term_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_vocabulary_synonym_key" ON "vocabulary_synonyms" ("vocabulary","key");
+
+CREATE TABLE IF NOT EXISTS "vocabulary_unknowns" (
+    "id" bigserial,
+    "vocabulary" varchar(50) NOT NULL,
+    "value" varchar(255) NOT NULL,
+    "key" varchar(255) NOT NULL,
+    "occurrences" bigint NOT NULL DEFAULT 0,
+    "first_seen_at" timestamptz,
+    "last_seen_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_vocabulary_unknown_key" ON "vocabulary_unknowns" ("vocabulary","key");
+
+-- Columns added after their tables were first created. AutoMigrate added them on boot, but a
+-- database last started on an older build may not have them yet.
+ALTER TABLE "wells" ADD COLUMN IF NOT EXISTS "crs" varchar(50);
+ALTER TABLE "import_jobs" ADD COLUMN IF NOT EXISTS "crs" varchar(50);
+ALTER TABLE "petrography_carbonate"
+    ADD COLUMN IF NOT EXISTS "well_id" bigint,
+    ADD COLUMN IF NOT EXISTS "coordinate_crs" varchar(50),
+    ADD COLUMN IF NOT EXISTS "raw_x" varchar(100),
+    ADD COLUMN IF NOT EXISTS "raw_y" varchar(100),
+    ADD COLUMN IF NOT EXISTS "age_start_ma" decimal(10,4),
+    ADD COLUMN IF NOT EXISTS "age_end_ma" decimal(10,4),
+    ADD COLUMN IF NOT EXISTS "formation_assignment" varchar(20);
+CREATE INDEX IF NOT EXISTS "idx_petrography_carbonate_age_end_ma" ON "petrography_carbonate" ("age_end_ma");
+CREATE INDEX IF NOT EXISTS "idx_petrography_carbonate_age_start_ma" ON "petrography_carbonate" ("age_start_ma");
+CREATE INDEX IF NOT EXISTS "idx_petrography_carbonate_formation_assignment" ON "petrography_carbonate" ("formation_assignment");
+CREATE INDEX IF NOT EXISTS "idx_petrography_carbonate_well_id" ON "petrography_carbonate" ("well_id");
+ALTER TABLE "petrography_clastic"
+    ADD COLUMN IF NOT EXISTS "well_id" bigint,
+    ADD COLUMN IF NOT EXISTS "coordinate_crs" varchar(50),
+    ADD COLUMN IF NOT EXISTS "raw_x" varchar(100),
+    ADD COLUMN IF NOT EXISTS "raw_y" varchar(100),
+    ADD COLUMN IF NOT EXISTS "age_start_ma" decimal(10,4),
+    ADD COLUMN IF NOT EXISTS "age_end_ma" decimal(10,4),
+    ADD COLUMN IF NOT EXISTS "formation_assignment" varchar(20);
+CREATE INDEX IF NOT EXISTS "idx_petrography_clastic_age_end_ma" ON "petrography_clastic" ("age_end_ma");
+CREATE INDEX IF NOT EXISTS "idx_petrography_clastic_age_start_ma" ON "petrography_clastic" ("age_start_ma");
+CREATE INDEX IF NOT EXISTS "idx_petrography_clastic_formation_assignment" ON "petrography_clastic" ("formation_assignment");
+CREATE INDEX IF NOT EXISTS "idx_petrography_clastic_well_id" ON "petrography_clastic" ("well_id");
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_petrography_carbonate_well') THEN
+        ALTER TABLE "petrography_carbonate" ADD CONSTRAINT "fk_petrography_carbonate_well"
+            FOREIGN KEY ("well_id") REFERENCES "wells"("id") ON DELETE RESTRICT ON UPDATE CASCADE;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_petrography_clastic_well') THEN
+        ALTER TABLE "petrography_clastic" ADD CONSTRAINT "fk_petrography_clastic_well"
+            FOREIGN KEY ("well_id") REFERENCES "wells"("id") ON DELETE RESTRICT ON UPDATE CASCADE;
+    END IF;
+END
+$$;
@@ -0,0 +1,85 @@
+-- Recreates the unused tables empty, as AutoMigrate left them
+CREATE TABLE IF NOT EXISTS "epbe_bases" (
+    "country" varchar(255),
+    "region" varchar(255),
+    "sub_region" varchar(255),
+    "business_regions" varchar(255),
+    "basin" varchar(255),
+    "sub_basin" varchar(255),
+    "well_name_field_name" varchar(255),
+    "uwi" varchar(255),
+    "latitude" decimal(10,7),
+    "longitude" decimal(10,7),
+    "formation_name" varchar(255),
+    "reservoir_name" varchar(255),
+    "period" varchar(255),
+    "epoch" varchar(255),
+    "age" varchar(255),
+    "onshore_offshore" varchar(255),
+    "water_depth_m" decimal(10,2),
+    "water_depth_ft" decimal(10,2)
+);
+CREATE TABLE IF NOT EXISTS "depth_infos" (
+    "depth_reference_type" varchar(255),
+    "depth_reference_elevation_m" decimal(10,2),
+    "depth_reference_elevation_ft" decimal(10,2),
+    "ground_level_elevation_m" decimal(10,2),
+    "ground_level_elevation_ft" decimal(10,2),
+    "top_depth_mmddf" decimal(10,2),
+    "top_depth_mtvddf" decimal(10,2),
+    "top_depth_mtvdss" decimal(10,2),
+    "top_depth_mbml" decimal(10,2),
+    "bottom_depth_mmddf" decimal(10,2),
+    "bottom_depth_mtvddf" decimal(10,2),
+    "bottom_depth_mtvdss" decimal(10,2),
+    "bottom_depth_mbml" decimal(10,2),
+    "top_depth_ftmddf" decimal(10,2),
+    "top_depth_fttvddf" decimal(10,2),
+    "top_depth_fttvdss" decimal(10,2),
+    "top_depth_ftbml" decimal(10,2),
+    "bottom_depth_ftmddf" decimal(10,2),
+    "bottom_depth_fttvddf" decimal(10,2),
+    "bottom_depth_fttvdss" decimal(10,2),
+    "bottom_depth_ftbml" decimal(10,2)
+);
+CREATE TABLE IF NOT EXISTS "metadata_infos" (
+    "data_source" varchar(255),
+    "analysis_type" varchar(255),
+    "owner" varchar(255),
+    "ownership" varchar(255),
+    "assurance" varchar(255),
+    "data_generator" varchar(255),
+    "data_generation_date" timestamptz,
+    "remark" text,
+    "data_entry_date" timestamptz,
+    "data_entry_mode" varchar(255),
+    "data_entry_focal" varchar(255),
+    "metadata_discipline_name" varchar(100),
+    "metadata_data_source_name" varchar(100),
+    "session_id" varchar(100),
+    "updated_timestamp" timestamptz DEFAULT CURRENT_TIMESTAMP,
+    "created_timestamp" timestamptz DEFAULT CURRENT_TIMESTAMP,
+    "id" bigserial,
+    "well_id" bigint,
+    "coordinate_crs" varchar(50),
+    "raw_x" varchar(100),
+    "raw_y" varchar(100),
+    "age_start_ma" decimal(10,4),
+    "age_end_ma" decimal(10,4),
+    "formation_assignment" varchar(20),
+    "duplicate_status" varchar(50),
+    "duplicate_resolution_action" varchar(50),
+    "master_record_id" bigint,
+    "review_queue_id" varchar(50),
+    "resolution_timestamp" timestamptz,
+    "resolution_reason" varchar(500),
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_metadata_infos_well" FOREIGN KEY ("well_id") REFERENCES "wells"("id") ON DELETE RESTRICT ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_metadata_infos_age_end_ma" ON "metadata_infos" ("age_end_ma");
+CREATE INDEX IF NOT EXISTS "idx_metadata_infos_age_start_ma" ON "metadata_infos" ("age_start_ma");
+CREATE INDEX IF NOT EXISTS "idx_metadata_infos_deleted_at" ON "metadata_infos" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_metadata_infos_formation_assignment" ON "metadata_infos" ("formation_assignment");
+CREATE INDEX IF NOT EXISTS "idx_metadata_infos_session_id" ON "metadata_infos" ("session_id");
+CREATE INDEX IF NOT EXISTS "idx_metadata_infos_well_id" ON "metadata_infos" ("well_id");
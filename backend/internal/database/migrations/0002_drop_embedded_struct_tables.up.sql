@@ -0,0 +1,5 @@
+-- AutoMigrate was also given the embedded EPBEBase, DepthInfo and MetadataInfo structs and created
+-- tables for them. Nothing reads or writes these tables; the sample tables hold the data.
+DROP TABLE IF EXISTS "epbe_bases";
+DROP TABLE IF EXISTS "depth_infos";
+DROP TABLE IF EXISTS "metadata_infos";
@@ -0,0 +1,15 @@
+-- PDF extractions, so their outcome survives the request and interrupted ones can be failed on start
+CREATE TABLE IF NOT EXISTS "extraction_jobs" (
+    "id" uuid,
+    "filename" varchar(255),
+    "original_filename" varchar(255),
+    "status" varchar(20),
+    "worker" varchar(255),
+    "error" text,
+    "started_at" timestamptz,
+    "finished_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_extraction_jobs_status" ON "extraction_jobs" ("status");
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "idx_extraction_jobs_document_id";
+ALTER TABLE "extraction_jobs" DROP COLUMN IF EXISTS "document_id";
+DROP TABLE IF EXISTS "documents";
@@ -0,0 +1,15 @@
+-- Uploaded PDFs, stored under their ID with the uploaded name kept as metadata
+CREATE TABLE IF NOT EXISTS "documents" (
+    "id" uuid,
+    "original_filename" varchar(255),
+    "size" bigint,
+    "pages" bigint,
+    "pdf_version" varchar(10),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+ALTER TABLE "extraction_jobs" ADD COLUMN IF NOT EXISTS "document_id" uuid
+    REFERENCES "documents"("id") ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS "idx_extraction_jobs_document_id" ON "extraction_jobs" ("document_id");
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "idx_petrography_clastic_document_id";
+ALTER TABLE "petrography_clastic" DROP COLUMN IF EXISTS "document_id";
+DROP INDEX IF EXISTS "idx_petrography_carbonate_document_id";
+ALTER TABLE "petrography_carbonate" DROP COLUMN IF EXISTS "document_id";
+DROP INDEX IF EXISTS "idx_documents_sha256";
+ALTER TABLE "documents" DROP COLUMN IF EXISTS "uploads";
+ALTER TABLE "documents" DROP COLUMN IF EXISTS "sha256";
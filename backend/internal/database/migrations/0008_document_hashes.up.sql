@@ -0,0 +1,13 @@
+-- Documents are stored under the SHA-256 of their content, so repeated uploads of a file find the
+-- same document; documents uploaded before keep a null hash
+ALTER TABLE "documents" ADD COLUMN IF NOT EXISTS "sha256" varchar(64);
+ALTER TABLE "documents" ADD COLUMN IF NOT EXISTS "uploads" bigint DEFAULT 1;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_documents_sha256" ON "documents" ("sha256") WHERE "sha256" IS NOT NULL;
+
+-- Records remember the document they were extracted from, so importing it twice can be detected
+ALTER TABLE "petrography_carbonate" ADD COLUMN IF NOT EXISTS "document_id" uuid
+    REFERENCES "documents"("id") ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS "idx_petrography_carbonate_document_id" ON "petrography_carbonate" ("document_id");
+ALTER TABLE "petrography_clastic" ADD COLUMN IF NOT EXISTS "document_id" uuid
+    REFERENCES "documents"("id") ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS "idx_petrography_clastic_document_id" ON "petrography_clastic" ("document_id");
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "idx_extraction_jobs_document_version";
+ALTER TABLE "extraction_jobs" DROP COLUMN IF EXISTS "rows_found";
+ALTER TABLE "extraction_jobs" DROP COLUMN IF EXISTS "tables_found";
+ALTER TABLE "extraction_jobs" DROP COLUMN IF EXISTS "options";
+ALTER TABLE "extraction_jobs" DROP COLUMN IF EXISTS "version";
+DROP INDEX IF EXISTS "idx_documents_created_at";
+ALTER TABLE "documents" DROP COLUMN IF EXISTS "uploaded_by";
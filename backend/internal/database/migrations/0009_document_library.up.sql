@@ -0,0 +1,24 @@
+-- Who uploaded a document, so the library can be searched by uploader
+ALTER TABLE "documents" ADD COLUMN IF NOT EXISTS "uploaded_by" varchar(255);
+CREATE INDEX IF NOT EXISTS "idx_documents_created_at" ON "documents" ("created_at");
+
+-- Extractions of a document are numbered, and a re-extraction with other pages, flavors or quality
+-- thresholds keeps its output next to the earlier ones.
+ALTER TABLE "extraction_jobs" ADD COLUMN IF NOT EXISTS "version" bigint DEFAULT 1;
+ALTER TABLE "extraction_jobs" ADD COLUMN IF NOT EXISTS "options" jsonb;
+ALTER TABLE "extraction_jobs" ADD COLUMN IF NOT EXISTS "tables_found" bigint DEFAULT 0;
+ALTER TABLE "extraction_jobs" ADD COLUMN IF NOT EXISTS "rows_found" bigint DEFAULT 0;
+
+-- Extractions run before all wrote to the same output, so the one whose output is kept becomes
+-- version 1 and the others version 0
+UPDATE "extraction_jobs" SET "version" = 0 WHERE "id" IN (
+    SELECT "id" FROM (
+        SELECT "id", row_number() OVER (
+            PARTITION BY "document_id"
+            ORDER BY ("status" = 'completed') DESC, "finished_at" DESC NULLS LAST, "created_at" DESC
+        ) AS "rank"
+        FROM "extraction_jobs" WHERE "document_id" IS NOT NULL
+    ) AS "ranked" WHERE "rank" > 1
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_extraction_jobs_document_version"
+    ON "extraction_jobs" ("document_id", "version") WHERE "version" > 0;
@@ -0,0 +1,569 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ColumnKind classifies a model column for filter value validation
+type ColumnKind string
+
+const (
+	KindString ColumnKind = "string"
+	KindNumber ColumnKind = "number"
+	KindTime   ColumnKind = "time"
+	KindBool   ColumnKind = "bool"
+)
+
+// Column describes a queryable column of a model
+type Column struct {
+	Name string     `json:"name"`
+	Kind ColumnKind `json:"kind"`
+}
+
+// ColumnSet is the whitelist of columns a query may reference, keyed by DB name
+type ColumnSet map[string]Column
+
+// ModelColumns returns the columns GORM knows for the model, excluding the given DB names.
+// It is the single source of truth for which identifiers may appear in filters, sorts and fieldsets.
+func ModelColumns(db *gorm.DB, model interface{}, exclude ...string) (ColumnSet, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, fmt.Errorf("failed to parse model schema: %w", err)
+	}
+
+	skip := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		skip[name] = true
+	}
+
+	columns := make(ColumnSet, len(stmt.Schema.DBNames))
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" || skip[field.DBName] {
+			continue
+		}
+
+		fieldType := field.FieldType
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		var kind ColumnKind
+		switch {
+		case fieldType == reflect.TypeOf(gorm.DeletedAt{}):
+			// Soft delete is handled by GORM scopes, never by user filters
+			continue
+		case fieldType == reflect.TypeOf(time.Time{}):
+			kind = KindTime
+		case fieldType.Kind() == reflect.Bool:
+			kind = KindBool
+		case fieldType.Kind() >= reflect.Int && fieldType.Kind() <= reflect.Float64:
+			kind = KindNumber
+		default:
+			kind = KindString
+		}
+
+		columns[field.DBName] = Column{Name: field.DBName, Kind: kind}
+	}
+
+	return columns, nil
+}
+
+// Filter is a node of a parsed filter expression: either an AND/OR group or a single condition
+type Filter struct {
+	And    []Filter      `json:"and,omitempty"`
+	Or     []Filter      `json:"or,omitempty"`
+	Field  string        `json:"field,omitempty"`
+	Op     string        `json:"op,omitempty"`
+	Values []interface{} `json:"values,omitempty"`
+}
+
+// SortField is a validated sort key
+type SortField struct {
+	Column string `json:"column"`
+	Desc   bool   `json:"desc"`
+}
+
+// ListQuery holds the validated filter, sort and fieldset of a list request
+type ListQuery struct {
+	Filter *Filter     `json:"filter,omitempty"`
+	Sort   []SortField `json:"sort,omitempty"`
+	Fields []string    `json:"fields,omitempty"`
+	Geo    *GeoFilter  `json:"geo,omitempty"`
+	// Interval is the geologic time filter of samples
+	Interval *IntervalFilter `json:"interval,omitempty"`
+
+	// columns is the whitelist the query was parsed against, used to check cursor values
+	columns ColumnSet
+}
+
+// Filter operators and the number of values each expects (-1 means one or more)
+var filterOps = map[string]int{
+	"eq":       1,
+	"ne":       1,
+	"gt":       1,
+	"gte":      1,
+	"lt":       1,
+	"lte":      1,
+	"like":     1,
+	"ilike":    1,
+	"between":  2,
+	"in":       -1,
+	"nin":      -1,
+	"is_null":  0,
+	"not_null": 0,
+}
+
+// maxFilterDepth bounds the nesting of and/or groups so a request cannot recurse without limit
+const maxFilterDepth = 8
+
+// ParseListQuery parses the filter, sort and fields query parameters against the column whitelist.
+//
+// Filter syntax (several filter parameters are ANDed together):
+//
+//	filter=field.op.value
+//	filter=and(basin.ilike.sarawak,top_depth_mmddf.between.(1500,2000),or(dolomite.gt.0,calcite.is_null))
+//
+// Values containing "," "(" or ")" may be double quoted. Sort is a comma separated list of
+// columns, prefixed with "-" for descending order. Fields is a comma separated list of columns.
+// Models with latitude and longitude columns also accept the spatial parameters of ParseGeoFilter,
+// and models with age_start_ma and age_end_ma the geologic time parameters of ParseIntervalFilter.
+func ParseListQuery(params url.Values, columns ColumnSet) (*ListQuery, error) {
+	query := &ListQuery{columns: columns}
+
+	var filters []Filter
+	for _, raw := range params["filter"] {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		p := &filterParser{input: raw, columns: columns}
+		filter, err := p.parse()
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+		filters = append(filters, *filter)
+	}
+	switch len(filters) {
+	case 0:
+	case 1:
+		query.Filter = &filters[0]
+	default:
+		query.Filter = &Filter{And: filters}
+	}
+
+	for _, key := range splitList(params.Get("sort")) {
+		desc := strings.HasPrefix(key, "-")
+		name := strings.TrimPrefix(strings.TrimPrefix(key, "-"), "+")
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("invalid sort: unknown column %q", name)
+		}
+		query.Sort = append(query.Sort, SortField{Column: name, Desc: desc})
+	}
+
+	for _, name := range splitList(params.Get("fields")) {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("invalid fields: unknown column %q", name)
+		}
+		query.Fields = append(query.Fields, name)
+	}
+	if len(query.Fields) > 0 && !containsString(query.Fields, "id") {
+		if _, ok := columns["id"]; ok {
+			query.Fields = append([]string{"id"}, query.Fields...)
+		}
+	}
+
+	geo, err := ParseGeoFilter(params)
+	if err != nil {
+		return nil, err
+	}
+	if geo != nil {
+		_, hasLat := columns["latitude"]
+		_, hasLon := columns["longitude"]
+		if !hasLat || !hasLon {
+			return nil, fmt.Errorf("spatial filters are not supported for this resource")
+		}
+		query.Geo = geo
+	}
+
+	interval, err := ParseIntervalFilter(params)
+	if err != nil {
+		return nil, err
+	}
+	if interval != nil {
+		_, hasStart := columns["age_start_ma"]
+		_, hasEnd := columns["age_end_ma"]
+		if !hasStart || !hasEnd {
+			return nil, fmt.Errorf("interval filters are not supported for this resource")
+		}
+		query.Interval = interval
+	}
+
+	return query, nil
+}
+
+// OrderClause renders the validated sort keys for OrderBy, always ending with a unique tie-breaker
+func (q *ListQuery) OrderClause(tieBreaker string) string {
+	keys := q.Keys(tieBreaker)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		direction := "ASC"
+		if k.Desc {
+			direction = "DESC"
+		}
+		parts = append(parts, fmt.Sprintf("%q %s", k.Column, direction))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Where returns a scope applying the parsed filter expression, spatial filter and interval filter
+func (q *ListQuery) Where() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if q == nil {
+			return db
+		}
+		if q.Filter != nil {
+			db = db.Where(q.Filter.Expression())
+		}
+		if q.Geo != nil {
+			db = db.Where(q.Geo.Expression())
+		}
+		if q.Interval != nil {
+			db = db.Where(q.Interval.Expression())
+		}
+		return db
+	}
+}
+
+// Select returns a scope restricting the selected columns to the requested fieldset
+func (q *ListQuery) Select() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if q == nil || len(q.Fields) == 0 {
+			return db
+		}
+
+		// Sort keys are always loaded so keyset cursors can be built from the last row
+		columns := append([]string{}, q.Fields...)
+		for _, s := range q.Sort {
+			if !containsString(columns, s.Column) {
+				columns = append(columns, s.Column)
+			}
+		}
+		return db.Select(columns)
+	}
+}
+
+// Expression converts the filter tree to a GORM clause. Column names are emitted as quoted
+// identifiers and values as bind variables, so nothing from the request is spliced into SQL.
+func (f Filter) Expression() clause.Expression {
+	if len(f.And) > 0 {
+		exprs := make([]clause.Expression, 0, len(f.And))
+		for _, child := range f.And {
+			exprs = append(exprs, child.Expression())
+		}
+		return clause.And(exprs...)
+	}
+	if len(f.Or) > 0 {
+		exprs := make([]clause.Expression, 0, len(f.Or))
+		for _, child := range f.Or {
+			exprs = append(exprs, child.Expression())
+		}
+		return clause.Or(exprs...)
+	}
+
+	column := clause.Column{Name: f.Field}
+	switch f.Op {
+	case "eq":
+		return clause.Eq{Column: column, Value: f.Values[0]}
+	case "ne":
+		return clause.Neq{Column: column, Value: f.Values[0]}
+	case "gt":
+		return clause.Gt{Column: column, Value: f.Values[0]}
+	case "gte":
+		return clause.Gte{Column: column, Value: f.Values[0]}
+	case "lt":
+		return clause.Lt{Column: column, Value: f.Values[0]}
+	case "lte":
+		return clause.Lte{Column: column, Value: f.Values[0]}
+	case "like":
+		return clause.Expr{SQL: `? LIKE ? ESCAPE '\'`, Vars: []interface{}{column, ContainsPattern(fmt.Sprint(f.Values[0]))}}
+	case "ilike":
+		return clause.Expr{SQL: `? ILIKE ? ESCAPE '\'`, Vars: []interface{}{column, ContainsPattern(fmt.Sprint(f.Values[0]))}}
+	case "between":
+		return clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []interface{}{column, f.Values[0], f.Values[1]}}
+	case "in":
+		return clause.IN{Column: column, Values: f.Values}
+	case "nin":
+		return clause.Not(clause.IN{Column: column, Values: f.Values})
+	case "is_null":
+		return clause.Expr{SQL: "? IS NULL", Vars: []interface{}{column}}
+	case "not_null":
+		return clause.Expr{SQL: "? IS NOT NULL", Vars: []interface{}{column}}
+	}
+
+	// Unreachable for filters produced by ParseListQuery
+	return clause.Expr{SQL: "1 = 0"}
+}
+
+// likeEscaper escapes the LIKE wildcards so like and ilike match the value literally as a substring
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// ContainsPattern returns the LIKE pattern matching s literally anywhere in a value. The condition
+// using it must declare the escape character with ESCAPE '\'.
+func ContainsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+// filterParser is a small recursive descent parser for the filter syntax
+type filterParser struct {
+	input   string
+	pos     int
+	columns ColumnSet
+}
+
+func (p *filterParser) parse() (*Filter, error) {
+	filter, err := p.parseExpr(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos != len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos:], p.pos)
+	}
+	return filter, nil
+}
+
+func (p *filterParser) parseExpr(depth int) (*Filter, error) {
+	p.skipSpaces()
+	word := p.readUntil(".(),")
+
+	if (word == "and" || word == "or") && p.peek() == '(' {
+		if depth >= maxFilterDepth {
+			return nil, fmt.Errorf("groups nested deeper than %d levels", maxFilterDepth)
+		}
+		p.pos++
+		var children []Filter
+		for {
+			child, err := p.parseExpr(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, *child)
+
+			p.skipSpaces()
+			switch p.peek() {
+			case ',':
+				p.pos++
+				continue
+			case ')':
+				p.pos++
+			default:
+				return nil, fmt.Errorf("expected ',' or ')' at position %d", p.pos)
+			}
+			break
+		}
+		if word == "and" {
+			return &Filter{And: children}, nil
+		}
+		return &Filter{Or: children}, nil
+	}
+
+	return p.parseCondition(word)
+}
+
+func (p *filterParser) parseCondition(field string) (*Filter, error) {
+	column, ok := p.columns[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown column %q", field)
+	}
+	if p.peek() != '.' {
+		return nil, fmt.Errorf("expected operator after %q", field)
+	}
+	p.pos++
+
+	op := p.readUntil(".(),")
+	arity, ok := filterOps[op]
+	if !ok {
+		return nil, fmt.Errorf("unknown operator %q", op)
+	}
+
+	var raw []string
+	if arity != 0 {
+		if p.peek() != '.' {
+			return nil, fmt.Errorf("operator %q on %q requires a value", op, field)
+		}
+		p.pos++
+
+		if arity == 1 {
+			value, err := p.readValue()
+			if err != nil {
+				return nil, err
+			}
+			raw = []string{value}
+		} else {
+			list, err := p.readList()
+			if err != nil {
+				return nil, err
+			}
+			if arity > 0 && len(list) != arity {
+				return nil, fmt.Errorf("operator %q expects %d values, got %d", op, arity, len(list))
+			}
+			if len(list) == 0 {
+				return nil, fmt.Errorf("operator %q expects at least one value", op)
+			}
+			raw = list
+		}
+	}
+
+	if err := checkOperator(column, op); err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, 0, len(raw))
+	for _, r := range raw {
+		value, err := convertValue(column, r)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return &Filter{Field: field, Op: op, Values: values}, nil
+}
+
+func (p *filterParser) readList() ([]string, error) {
+	if p.peek() != '(' {
+		return nil, fmt.Errorf("expected '(' at position %d", p.pos)
+	}
+	p.pos++
+
+	var list []string
+	for {
+		value, err := p.readValue()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ')':
+			p.pos++
+			return list, nil
+		default:
+			return nil, fmt.Errorf("unterminated list at position %d", p.pos)
+		}
+	}
+}
+
+func (p *filterParser) readValue() (string, error) {
+	if p.peek() != '"' {
+		return strings.TrimSpace(p.readUntil("(),")), nil
+	}
+
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.input) {
+		ch := p.input[p.pos]
+		p.pos++
+		switch {
+		case ch == '\\' && p.pos < len(p.input):
+			b.WriteByte(p.input[p.pos])
+			p.pos++
+		case ch == '"':
+			return b.String(), nil
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return "", fmt.Errorf("unterminated quoted value")
+}
+
+func (p *filterParser) readUntil(stops string) string {
+	start := p.pos
+	for p.pos < len(p.input) && !strings.ContainsRune(stops, rune(p.input[p.pos])) {
+		p.pos++
+	}
+	return strings.TrimSpace(p.input[start:p.pos])
+}
+
+func (p *filterParser) peek() byte {
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *filterParser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// checkOperator rejects operators that make no sense for the column kind
+func checkOperator(column Column, op string) error {
+	switch op {
+	case "like", "ilike":
+		if column.Kind != KindString {
+			return fmt.Errorf("operator %q is only valid on text columns, %q is %s", op, column.Name, column.Kind)
+		}
+	case "gt", "gte", "lt", "lte", "between":
+		if column.Kind != KindNumber && column.Kind != KindTime {
+			return fmt.Errorf("operator %q is only valid on numeric or date columns, %q is %s", op, column.Name, column.Kind)
+		}
+	}
+	return nil
+}
+
+// convertValue parses a raw filter value according to the column kind
+func convertValue(column Column, raw string) (interface{}, error) {
+	switch column.Kind {
+	case KindNumber:
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, fmt.Errorf("column %q expects a number, got %q", column.Name, raw)
+		}
+		return value, nil
+	case KindBool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("column %q expects true or false, got %q", column.Name, raw)
+		}
+		return value, nil
+	case KindTime:
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if value, err := time.Parse(layout, raw); err == nil {
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("column %q expects a date (YYYY-MM-DD or RFC3339), got %q", column.Name, raw)
+	}
+	return raw, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
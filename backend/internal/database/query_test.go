@@ -0,0 +1,315 @@
+package database
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var testColumns = ColumnSet{
+	"id":                {Name: "id", Kind: KindNumber},
+	"basin":             {Name: "basin", Kind: KindString},
+	"top_depth_mmddf":   {Name: "top_depth_mmddf", Kind: KindNumber},
+	"dolomite":          {Name: "dolomite", Kind: KindNumber},
+	"calcite":           {Name: "calcite", Kind: KindNumber},
+	"data_entry_date":   {Name: "data_entry_date", Kind: KindTime},
+	"verified":          {Name: "verified", Kind: KindBool},
+	"created_timestamp": {Name: "created_timestamp", Kind: KindTime},
+}
+
+// dryRunDB builds statements without connecting to a database
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}), &gorm.Config{
+		DisableAutomaticPing: true,
+		DryRun:               true,
+	})
+	if err != nil {
+		t.Fatalf("open dry run db: %v", err)
+	}
+	return db
+}
+
+func TestParseListQueryFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter []string
+		want   *Filter
+	}{
+		{
+			name:   "string condition",
+			filter: []string{"basin.eq.Sarawak"},
+			want:   &Filter{Field: "basin", Op: "eq", Values: []interface{}{"Sarawak"}},
+		},
+		{
+			name:   "number value",
+			filter: []string{"dolomite.gt.2.5"},
+			want:   &Filter{Field: "dolomite", Op: "gt", Values: []interface{}{2.5}},
+		},
+		{
+			name:   "between list",
+			filter: []string{"top_depth_mmddf.between.(1500,2000)"},
+			want:   &Filter{Field: "top_depth_mmddf", Op: "between", Values: []interface{}{1500.0, 2000.0}},
+		},
+		{
+			name:   "quoted value with separators",
+			filter: []string{`basin.in.("Malay, Basin","Sarawak (North)")`},
+			want:   &Filter{Field: "basin", Op: "in", Values: []interface{}{"Malay, Basin", "Sarawak (North)"}},
+		},
+		{
+			name:   "escaped quote",
+			filter: []string{`basin.eq."say \"hi\""`},
+			want:   &Filter{Field: "basin", Op: "eq", Values: []interface{}{`say "hi"`}},
+		},
+		{
+			name:   "date value",
+			filter: []string{"data_entry_date.gte.2024-03-01"},
+			want:   &Filter{Field: "data_entry_date", Op: "gte", Values: []interface{}{time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}},
+		},
+		{
+			name:   "null check",
+			filter: []string{"calcite.is_null"},
+			want:   &Filter{Field: "calcite", Op: "is_null", Values: []interface{}{}},
+		},
+		{
+			name:   "nested groups",
+			filter: []string{"and(basin.ilike.sarawak, or(dolomite.gt.0,calcite.is_null))"},
+			want: &Filter{And: []Filter{
+				{Field: "basin", Op: "ilike", Values: []interface{}{"sarawak"}},
+				{Or: []Filter{
+					{Field: "dolomite", Op: "gt", Values: []interface{}{0.0}},
+					{Field: "calcite", Op: "is_null", Values: []interface{}{}},
+				}},
+			}},
+		},
+		{
+			name:   "several parameters are ANDed",
+			filter: []string{"basin.eq.Malay", "verified.eq.true"},
+			want: &Filter{And: []Filter{
+				{Field: "basin", Op: "eq", Values: []interface{}{"Malay"}},
+				{Field: "verified", Op: "eq", Values: []interface{}{true}},
+			}},
+		},
+		{
+			name:   "blank parameter is ignored",
+			filter: []string{"  "},
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := ParseListQuery(url.Values{"filter": tt.filter}, testColumns)
+			if err != nil {
+				t.Fatalf("ParseListQuery: %v", err)
+			}
+			if !reflect.DeepEqual(query.Filter, tt.want) {
+				t.Errorf("filter = %#v, want %#v", query.Filter, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseListQueryRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantErr string
+	}{
+		{"unknown column", url.Values{"filter": {"password.eq.x"}}, `unknown column "password"`},
+		{"injected column", url.Values{"filter": {`basin";drop table wells;--.eq.x`}}, "unknown column"},
+		{"quoted column", url.Values{"filter": {`"basin".eq.x`}}, "unknown column"},
+		{"column with sql", url.Values{"filter": {"basin or 1=1.eq.x"}}, "unknown column"},
+		{"unknown operator", url.Values{"filter": {"basin.regex.x"}}, `unknown operator "regex"`},
+		{"missing operator", url.Values{"filter": {"basin"}}, "expected operator"},
+		{"missing value", url.Values{"filter": {"basin.eq"}}, "requires a value"},
+		{"like on number", url.Values{"filter": {"dolomite.like.1"}}, "only valid on text columns"},
+		{"range on text", url.Values{"filter": {"basin.gt.a"}}, "only valid on numeric or date columns"},
+		{"range on bool", url.Values{"filter": {"verified.lt.true"}}, "only valid on numeric or date columns"},
+		{"not a number", url.Values{"filter": {"dolomite.eq.1;drop"}}, "expects a number"},
+		{"NaN", url.Values{"filter": {"dolomite.gt.NaN"}}, "expects a number"},
+		{"infinity", url.Values{"filter": {"dolomite.lt.Inf"}}, "expects a number"},
+		{"negative infinity in list", url.Values{"filter": {"dolomite.in.(1,-Infinity)"}}, "expects a number"},
+		{"not a bool", url.Values{"filter": {"verified.eq.yes"}}, "expects true or false"},
+		{"not a date", url.Values{"filter": {"data_entry_date.eq.yesterday"}}, "expects a date"},
+		{"between arity", url.Values{"filter": {"dolomite.between.(1)"}}, "expects 2 values"},
+		{"empty number list", url.Values{"filter": {"dolomite.in.()"}}, "expects a number"},
+		{"list without parenthesis", url.Values{"filter": {"basin.in.a,b"}}, "expected '('"},
+		{"unterminated list", url.Values{"filter": {"basin.in.(a,b"}}, "unterminated list"},
+		{"unterminated quote", url.Values{"filter": {`basin.eq."abc`}}, "unterminated quoted value"},
+		{"trailing input", url.Values{"filter": {"basin.eq.a)"}}, "unexpected"},
+		{"unclosed group", url.Values{"filter": {"and(basin.eq.a"}}, "expected ',' or ')'"},
+		{"nested too deep", url.Values{"filter": {strings.Repeat("and(", maxFilterDepth+1) + "basin.eq.a" + strings.Repeat(")", maxFilterDepth+1)}}, "nested deeper"},
+		{"unknown sort", url.Values{"sort": {"-secret"}}, `invalid sort: unknown column "secret"`},
+		{"injected sort", url.Values{"sort": {`basin desc; drop table wells`}}, "invalid sort"},
+		{"unknown field", url.Values{"fields": {"basin,secret"}}, `invalid fields: unknown column "secret"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseListQuery(tt.params, testColumns)
+			if err == nil {
+				t.Fatalf("expected an error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseListQueryNestingLimit(t *testing.T) {
+	filter := strings.Repeat("and(", maxFilterDepth) + "basin.eq.a" + strings.Repeat(")", maxFilterDepth)
+	if _, err := ParseListQuery(url.Values{"filter": {filter}}, testColumns); err != nil {
+		t.Fatalf("%d nested groups should be accepted: %v", maxFilterDepth, err)
+	}
+}
+
+func TestParseListQuerySortAndFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		params     url.Values
+		wantSort   []SortField
+		wantFields []string
+	}{
+		{
+			name:     "sort directions",
+			params:   url.Values{"sort": {"-top_depth_mmddf, +basin,dolomite"}},
+			wantSort: []SortField{{Column: "top_depth_mmddf", Desc: true}, {Column: "basin"}, {Column: "dolomite"}},
+		},
+		{
+			name:       "fields include id",
+			params:     url.Values{"fields": {"basin,dolomite"}},
+			wantFields: []string{"id", "basin", "dolomite"},
+		},
+		{
+			name:       "id is not repeated",
+			params:     url.Values{"fields": {"basin,id"}},
+			wantFields: []string{"basin", "id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := ParseListQuery(tt.params, testColumns)
+			if err != nil {
+				t.Fatalf("ParseListQuery: %v", err)
+			}
+			if !reflect.DeepEqual(query.Sort, tt.wantSort) {
+				t.Errorf("sort = %#v, want %#v", query.Sort, tt.wantSort)
+			}
+			if !reflect.DeepEqual(query.Fields, tt.wantFields) {
+				t.Errorf("fields = %#v, want %#v", query.Fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestFilterExpression(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		wantSQL  string
+		wantVars []interface{}
+	}{
+		{
+			name:     "eq",
+			filter:   "basin.eq.Malay",
+			wantSQL:  `"basin" = $1`,
+			wantVars: []interface{}{"Malay"},
+		},
+		{
+			name:     "injection stays a bind variable",
+			filter:   `basin.eq."x' OR '1'='1"`,
+			wantSQL:  `"basin" = $1`,
+			wantVars: []interface{}{"x' OR '1'='1"},
+		},
+		{
+			name:     "like escapes wildcards",
+			filter:   `basin.like."50%_a\\b"`,
+			wantSQL:  `"basin" LIKE $1 ESCAPE '\'`,
+			wantVars: []interface{}{`%50\%\_a\\b%`},
+		},
+		{
+			name:     "ilike escapes wildcards",
+			filter:   "basin.ilike.%",
+			wantSQL:  `"basin" ILIKE $1 ESCAPE '\'`,
+			wantVars: []interface{}{`%\%%`},
+		},
+		{
+			name:     "between",
+			filter:   "top_depth_mmddf.between.(1500,2000)",
+			wantSQL:  `"top_depth_mmddf" BETWEEN $1 AND $2`,
+			wantVars: []interface{}{1500.0, 2000.0},
+		},
+		{
+			name:     "not in",
+			filter:   "basin.nin.(a,b)",
+			wantSQL:  `"basin" NOT IN ($1,$2)`,
+			wantVars: []interface{}{"a", "b"},
+		},
+		{
+			name:    "not null",
+			filter:  "calcite.not_null",
+			wantSQL: `"calcite" IS NOT NULL`,
+		},
+		{
+			name:     "groups",
+			filter:   "or(basin.eq.a,and(dolomite.gt.1,calcite.lte.2))",
+			wantSQL:  `("basin" = $1 OR ("dolomite" > $2 AND "calcite" <= $3))`,
+			wantVars: []interface{}{"a", 1.0, 2.0},
+		},
+	}
+
+	db := dryRunDB(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := ParseListQuery(url.Values{"filter": {tt.filter}}, testColumns)
+			if err != nil {
+				t.Fatalf("ParseListQuery: %v", err)
+			}
+
+			var rows []map[string]interface{}
+			stmt := db.Table("samples").Scopes(query.Where()).Find(&rows).Statement
+			sql := stmt.SQL.String()
+			if !strings.Contains(sql, "WHERE "+tt.wantSQL) {
+				t.Errorf("sql = %s, want WHERE %s", sql, tt.wantSQL)
+			}
+			if len(stmt.Vars) != len(tt.wantVars) || (len(tt.wantVars) > 0 && !reflect.DeepEqual(stmt.Vars, tt.wantVars)) {
+				t.Errorf("vars = %#v, want %#v", stmt.Vars, tt.wantVars)
+			}
+		})
+	}
+}
+
+func TestModelColumns(t *testing.T) {
+	type sample struct {
+		ID        uint
+		Basin     string
+		Depth     *float64
+		Verified  bool
+		Sampled   *time.Time
+		Secret    string
+		DeletedAt gorm.DeletedAt
+	}
+
+	columns, err := ModelColumns(dryRunDB(t), &sample{}, "secret")
+	if err != nil {
+		t.Fatalf("ModelColumns: %v", err)
+	}
+	want := ColumnSet{
+		"id":       {Name: "id", Kind: KindNumber},
+		"basin":    {Name: "basin", Kind: KindString},
+		"depth":    {Name: "depth", Kind: KindNumber},
+		"verified": {Name: "verified", Kind: KindBool},
+		"sampled":  {Name: "sampled", Kind: KindTime},
+	}
+	if !reflect.DeepEqual(columns, want) {
+		t.Errorf("columns = %#v, want %#v", columns, want)
+	}
+}
@@ -0,0 +1,31 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"workbench/internal/config"
+)
+
+// redisConnectTimeout bounds the ping that checks a new Redis connection
+const redisConnectTimeout = 5 * time.Second
+
+// ConnectRedis opens a Redis client and checks that the server answers
+func ConnectRedis(cfg *config.RedisConfig) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisConnectTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis at %s: %w", cfg.Addr(), err)
+	}
+	return client, nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"workbench/internal/core/models"
+	"workbench/internal/timescale"
+
+	"gorm.io/gorm"
+)
+
+// TimescaleReport summarizes a BackfillTimescale run
+type TimescaleReport struct {
+	SamplesDated int64 `json:"samples_dated"`
+	Inconsistent int64 `json:"inconsistent"`
+}
+
+// timescaleRow is the part of a sample read and written by BackfillTimescale
+type timescaleRow struct {
+	ID     uint
+	Period string
+	Epoch  string
+	Age    string
+}
+
+// BackfillTimescale dates the samples saved before their span in Ma was recorded: it fills empty
+// Period, Epoch and Age from the ICS chart and sets age_start_ma and age_end_ma. Samples whose fields
+// contradict each other are counted and left as they are.
+func BackfillTimescale(db *gorm.DB) (*TimescaleReport, error) {
+	report := &TimescaleReport{}
+	for _, table := range WellSampleTables {
+		var rows []timescaleRow
+		if err := db.Table(table).Select("id, period, epoch, age").
+			Where("age_start_ma IS NULL AND deleted_at IS NULL").
+			Where("TRIM(COALESCE(period, '')) <> '' OR TRIM(COALESCE(epoch, '')) <> '' OR TRIM(COALESCE(age, '')) <> ''").
+			Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", table, err)
+		}
+
+		for _, row := range rows {
+			base := models.EPBEBase{Period: row.Period, Epoch: row.Epoch, Age: row.Age}
+			meta := models.MetadataInfo{}
+			if err := models.ApplyTimescale(&base, &meta); err != nil {
+				if errors.Is(err, timescale.ErrInconsistent) {
+					report.Inconsistent++
+				}
+				continue
+			}
+			if meta.AgeStartMa == nil {
+				continue
+			}
+
+			// UpdateColumns skips the model hooks and timestamps; only the time columns change
+			if err := db.Table(table).Where("id = ?", row.ID).UpdateColumns(map[string]interface{}{
+				"period":       base.Period,
+				"epoch":        base.Epoch,
+				"age":          base.Age,
+				"age_start_ma": *meta.AgeStartMa,
+				"age_end_ma":   *meta.AgeEndMa,
+			}).Error; err != nil {
+				return nil, fmt.Errorf("failed to date %s %d: %w", table, row.ID, err)
+			}
+			report.SamplesDated++
+		}
+	}
+	return report, nil
+}
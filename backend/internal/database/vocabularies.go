@@ -0,0 +1,170 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"workbench/internal/core/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnknownVocabulary is returned for a vocabulary name that is not managed
+	ErrUnknownVocabulary = errors.New("unknown vocabulary")
+	// ErrSynonymTaken is returned when a spelling already belongs to another term of the vocabulary
+	ErrSynonymTaken = errors.New("spelling already belongs to another term")
+	// ErrInvalidParent is returned when a parent term is missing or in the wrong vocabulary
+	ErrInvalidParent = errors.New("invalid parent term")
+	// ErrTermValueRequired is returned for a term without a value
+	ErrTermValueRequired = errors.New("value is required")
+)
+
+// seedVocabularyTerms are created on migration when missing; other vocabularies are curated by users
+var seedVocabularyTerms = map[string]map[string][]string{
+	models.VocabularyOnshoreOffshore: {
+		"Onshore":  {"On-shore", "On shore", "Land"},
+		"Offshore": {"Off-shore", "Off shore", "Marine"},
+	},
+}
+
+// SeedVocabularies creates the built-in vocabulary terms that do not exist yet
+func SeedVocabularies(db *gorm.DB) error {
+	for vocabulary, terms := range seedVocabularyTerms {
+		for value, synonyms := range terms {
+			existing, err := models.LookupVocabulary(db, vocabulary, value)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				continue
+			}
+			term := models.VocabularyTerm{Vocabulary: vocabulary, Value: value}
+			if err := SaveVocabularyTerm(db, &term, synonyms); err != nil {
+				return fmt.Errorf("failed to seed %s %q: %w", vocabulary, value, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SaveVocabularyTerm creates or updates a term and replaces its synonyms. The term's own value is
+// always one of them.
+func SaveVocabularyTerm(db *gorm.DB, term *models.VocabularyTerm, synonyms []string) error {
+	if _, ok := models.VocabularyColumns[term.Vocabulary]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownVocabulary, term.Vocabulary)
+	}
+	term.Value = strings.TrimSpace(term.Value)
+	if models.VocabularyKey(term.Vocabulary, term.Value) == "" {
+		return ErrTermValueRequired
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if term.ParentID != nil {
+			parentVocabulary, ok := models.VocabularyParents[term.Vocabulary]
+			if !ok {
+				return fmt.Errorf("%w: %s terms have no parent", ErrInvalidParent, term.Vocabulary)
+			}
+			var parent models.VocabularyTerm
+			if err := tx.Where("id = ? AND vocabulary = ?", *term.ParentID, parentVocabulary).Take(&parent).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("%w: %d is not a %s", ErrInvalidParent, *term.ParentID, parentVocabulary)
+				}
+				return err
+			}
+		}
+
+		// Build the spellings, first one wins on duplicate keys
+		spellings := []models.VocabularySynonym{}
+		seen := map[string]bool{}
+		for _, value := range append([]string{term.Value}, synonyms...) {
+			value = strings.TrimSpace(value)
+			key := models.VocabularyKey(term.Vocabulary, value)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			var owner models.VocabularySynonym
+			err := tx.Where("vocabulary = ? AND key = ?", term.Vocabulary, key).Take(&owner).Error
+			if err == nil && (term.ID == 0 || owner.TermID != term.ID) {
+				return fmt.Errorf("%w: %q", ErrSynonymTaken, value)
+			}
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			spellings = append(spellings, models.VocabularySynonym{Vocabulary: term.Vocabulary, Value: value, Key: key})
+		}
+
+		if err := tx.Omit("Synonyms", "Parent").Save(term).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("term_id = ?", term.ID).Delete(&models.VocabularySynonym{}).Error; err != nil {
+			return err
+		}
+		for i := range spellings {
+			spellings[i].TermID = term.ID
+		}
+		if err := tx.Create(&spellings).Error; err != nil {
+			return err
+		}
+		term.Synonyms = spellings
+
+		// Spellings that are now known are no longer waiting for curation
+		return tx.Where("vocabulary = ? AND key IN ?", term.Vocabulary, keysOf(seen)).Delete(&models.VocabularyUnknown{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	models.InvalidateVocabularies()
+	return nil
+}
+
+// RewriteVocabularyValues replaces every stored spelling of a term by its canonical value in all
+// tables holding the vocabulary, and returns the number of rows changed
+func RewriteVocabularyValues(db *gorm.DB, term *models.VocabularyTerm) (int64, error) {
+	keys := map[string]bool{}
+	for _, synonym := range term.Synonyms {
+		keys[synonym.Key] = true
+	}
+
+	var changed int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for table, column := range models.VocabularyColumns[term.Vocabulary] {
+			var values []string
+			if err := tx.Table(table).Distinct(column).Where(column+" <> ''").Pluck(column, &values).Error; err != nil {
+				return err
+			}
+
+			var stale []string
+			for _, value := range values {
+				if value != term.Value && keys[models.VocabularyKey(term.Vocabulary, value)] {
+					stale = append(stale, value)
+				}
+			}
+			if len(stale) == 0 {
+				continue
+			}
+
+			result := tx.Table(table).Where(column+" IN ?", stale).Update(column, term.Value)
+			if result.Error != nil {
+				return result.Error
+			}
+			changed += result.RowsAffected
+		}
+		return nil
+	})
+	return changed, err
+}
+
+func keysOf(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
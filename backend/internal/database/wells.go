@@ -0,0 +1,190 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"workbench/internal/core/models"
+
+	"gorm.io/gorm"
+)
+
+// WellSampleTables are the sample tables that carry a copy of the well header and a well_id
+var WellSampleTables = []string{"petrography_carbonate", "petrography_clastic"}
+
+// WellConflict is a well header column whose value differs between the samples of one well
+type WellConflict struct {
+	UWI    string `json:"uwi"`
+	WellID uint   `json:"well_id"`
+	Column string `json:"column"`
+	// Chosen is the value kept on the well
+	Chosen string `json:"chosen"`
+	// Values counts the samples holding each distinct value
+	Values map[string]int `json:"values"`
+}
+
+// WellDedupReport summarizes a DeduplicateWells run
+type WellDedupReport struct {
+	WellsCreated      int            `json:"wells_created"`
+	SamplesLinked     int64          `json:"samples_linked"`
+	SamplesWithoutUWI int64          `json:"samples_without_uwi"`
+	Conflicts         []WellConflict `json:"conflicts"`
+}
+
+// DeduplicateWells creates a well for every UWI found on samples that are not linked yet and links them.
+// The well header takes the most common non-empty value of each column; columns where the samples
+// disagree are reported as conflicts. Sample rows are left as they are, so nothing is lost, and editing
+// the well afterwards rewrites them all. Running it again only touches samples that are still unlinked.
+func DeduplicateWells(db *gorm.DB) (*WellDedupReport, error) {
+	report := &WellDedupReport{Conflicts: []WellConflict{}}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&models.Well{}); err != nil {
+		return nil, fmt.Errorf("failed to parse well schema: %w", err)
+	}
+
+	// Collect the header of every unlinked sample, grouped by UWI
+	groups := map[string][]models.EPBEBase{}
+	for _, table := range WellSampleTables {
+		var samples []models.EPBEBase
+		if err := db.Table(table).Select(models.WellHeaderColumns).
+			Where("well_id IS NULL AND TRIM(COALESCE(uwi, '')) <> ''").
+			Find(&samples).Error; err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		for _, sample := range samples {
+			uwi := strings.TrimSpace(sample.UWI)
+			groups[uwi] = append(groups[uwi], sample)
+		}
+
+		var withoutUWI int64
+		if err := db.Table(table).Where("TRIM(COALESCE(uwi, '')) = ''").Count(&withoutUWI).Error; err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", table, err)
+		}
+		report.SamplesWithoutUWI += withoutUWI
+	}
+
+	uwis := make([]string, 0, len(groups))
+	for uwi := range groups {
+		uwis = append(uwis, uwi)
+	}
+	sort.Strings(uwis)
+
+	for _, uwi := range uwis {
+		samples := groups[uwi]
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var well models.Well
+			err := tx.Where("uwi = ?", uwi).Take(&well).Error
+			existing := err == nil
+			if err != nil && err != gorm.ErrRecordNotFound {
+				return err
+			}
+
+			wellValue := reflect.ValueOf(&well).Elem()
+			for _, column := range models.WellHeaderColumns {
+				if column == "uwi" {
+					continue
+				}
+				field := stmt.Schema.LookUpField(column)
+
+				// Tally the distinct non-empty values of this column
+				counts := map[string]int{}
+				originals := map[string]interface{}{}
+				var order []string
+				for i := range samples {
+					value, _ := field.ValueOf(context.Background(), reflect.ValueOf(models.NewWellFromBase(&samples[i])).Elem())
+					key := headerValueKey(value)
+					if key == "" {
+						continue
+					}
+					if _, seen := counts[key]; !seen {
+						order = append(order, key)
+						originals[key] = value
+					}
+					counts[key]++
+				}
+
+				chosen := ""
+				if existing {
+					current, _ := field.ValueOf(context.Background(), wellValue)
+					chosen = headerValueKey(current)
+				} else {
+					for _, key := range order {
+						if chosen == "" || counts[key] > counts[chosen] {
+							chosen = key
+						}
+					}
+					if chosen != "" {
+						if err := field.Set(context.Background(), wellValue, originals[chosen]); err != nil {
+							return err
+						}
+					}
+				}
+
+				_, agrees := counts[chosen]
+				if len(counts) > 1 || (len(counts) == 1 && chosen != "" && !agrees) {
+					report.Conflicts = append(report.Conflicts, WellConflict{
+						UWI:    uwi,
+						Column: column,
+						Chosen: chosen,
+						Values: counts,
+					})
+				}
+			}
+
+			if !existing {
+				well.UWI = uwi
+				if err := tx.Create(&well).Error; err != nil {
+					return err
+				}
+				report.WellsCreated++
+			}
+			for i := range report.Conflicts {
+				if report.Conflicts[i].UWI == uwi {
+					report.Conflicts[i].WellID = well.ID
+				}
+			}
+
+			for _, table := range WellSampleTables {
+				result := tx.Table(table).
+					Where("well_id IS NULL AND TRIM(uwi) = ?", uwi).
+					Update("well_id", well.ID)
+				if result.Error != nil {
+					return result.Error
+				}
+				report.SamplesLinked += result.RowsAffected
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to deduplicate well %s: %w", uwi, err)
+		}
+	}
+
+	return report, nil
+}
+
+// headerValueKey renders a header value for comparison; empty strings and NULLs give ""
+func headerValueKey(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case *float64:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		if value == nil {
+			return ""
+		}
+		return fmt.Sprint(value)
+	}
+}
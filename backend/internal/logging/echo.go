@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// Middleware assigns every request an ID, taken from the X-Request-ID header when the client sent
+// one, puts it in the request context so handlers' log lines carry it, and logs each request once
+// it completes: server errors at error level, client errors at warn, the rest at info.
+func Middleware() echo.MiddlewareFunc {
+	requestID := middleware.RequestID()
+	requestLogger := middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
+		LogStatus:       true,
+		LogMethod:       true,
+		LogURI:          true,
+		LogRoutePath:    true,
+		LogLatency:      true,
+		LogRemoteIP:     true,
+		LogResponseSize: true,
+		LogError:        true,
+		HandleError:     true,
+		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
+			level := slog.LevelInfo
+			switch {
+			case v.Status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case v.Status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+			attrs := []slog.Attr{
+				slog.String("method", v.Method),
+				slog.String("uri", v.URI),
+				slog.String("route", v.RoutePath),
+				slog.Int("status", v.Status),
+				slog.Float64("latency_ms", float64(v.Latency.Microseconds())/1000),
+				slog.Int64("bytes_out", v.ResponseSize),
+				slog.String("remote_ip", v.RemoteIP),
+			}
+			if v.Error != nil {
+				attrs = append(attrs, slog.String("error", v.Error.Error()))
+			}
+			slog.LogAttrs(c.Request().Context(), level, "request", attrs...)
+			return nil
+		},
+	})
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withContext := func(c echo.Context) error {
+			id := c.Response().Header().Get(echo.HeaderXRequestID)
+			req := c.Request()
+			c.SetRequest(req.WithContext(WithRequestID(req.Context(), id)))
+			return next(c)
+		}
+		return requestID(requestLogger(withContext))
+	}
+}
+
+// LogPanic logs a panic recovered by Echo's Recover middleware, with its stack
+func LogPanic(c echo.Context, err error, stack []byte) error {
+	slog.ErrorContext(c.Request().Context(), "💥 Panic recovered", "error", err.Error(), "stack", string(stack))
+	return err
+}
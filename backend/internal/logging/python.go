@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+)
+
+// pythonLine matches the extractor's logging format, '%(asctime)s - %(levelname)s - %(message)s'
+var pythonLine = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2},\d+ - (DEBUG|INFO|WARNING|ERROR|CRITICAL) - (.*)$`)
+
+var pythonLevels = map[string]slog.Level{
+	"DEBUG":    slog.LevelDebug,
+	"INFO":     slog.LevelInfo,
+	"WARNING":  slog.LevelWarn,
+	"ERROR":    slog.LevelError,
+	"CRITICAL": slog.LevelError,
+}
+
+// CopyPythonLog logs every line a Python process writes to r as a record of its own, at the level
+// Python logged it. Lines that are not log records, such as print output or the lines of a
+// traceback, keep the level of the record before them. Each line is also copied to w, if not nil.
+func CopyPythonLog(ctx context.Context, r io.Reader, stream string, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	level := slog.LevelInfo
+	for scanner.Scan() {
+		line := scanner.Text()
+		if w != nil {
+			io.WriteString(w, line+"\n")
+		}
+		if line == "" {
+			continue
+		}
+		message := line
+		if match := pythonLine.FindStringSubmatch(line); match != nil {
+			level, message = pythonLevels[match[1]], match[2]
+		}
+		slog.LogAttrs(ctx, level, message, slog.String("source", "python"), slog.String("stream", stream))
+	}
+	return scanner.Err()
+}
+
+// PythonLevel returns the most verbose Python logging level the default logger records, for
+// passing to the extractor so it does not produce lines that would be dropped anyway
+func PythonLevel(ctx context.Context) string {
+	logger := slog.Default()
+	switch {
+	case logger.Enabled(ctx, slog.LevelDebug):
+		return "DEBUG"
+	case logger.Enabled(ctx, slog.LevelInfo):
+		return "INFO"
+	case logger.Enabled(ctx, slog.LevelWarn):
+		return "WARNING"
+	}
+	return "ERROR"
+}
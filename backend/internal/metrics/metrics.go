@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "workbench"
+
+// Registry holds every metric the server exposes on /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestDuration is the latency of HTTP requests by route pattern, so IDs in paths do not
+	// create a series per record
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// ExtractionDuration is the run time of the Python extractor by outcome: success, failed or timeout
+	ExtractionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "extraction_duration_seconds",
+		Help:      "Duration of PDF extraction jobs by outcome.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 900},
+	}, []string{"outcome"})
+
+	// ExtractionQueueDepth is the number of extraction jobs waiting for a free worker
+	ExtractionQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "extraction_queue_depth",
+		Help:      "Extraction jobs waiting for a free worker.",
+	})
+
+	// ExtractionRunning is the number of extractor processes running
+	ExtractionRunning = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "extraction_running",
+		Help:      "Extractor processes running.",
+	})
+
+	// DocumentTables is the number of tables extracted per document
+	DocumentTables = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "extraction_document_tables",
+		Help:      "Tables extracted per PDF document.",
+		Buckets:   []float64{0, 1, 2, 5, 10, 20, 50, 100},
+	})
+
+	// DocumentRows is the number of table rows extracted per document
+	DocumentRows = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "extraction_document_rows",
+		Help:      "Table rows extracted per PDF document.",
+		Buckets:   []float64{0, 10, 50, 100, 250, 500, 1000, 5000},
+	})
+
+	// HeaderMappings counts mapped headers by how they matched: exact, epbe, fuzzy or miss
+	HeaderMappings = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mapping_headers_total",
+		Help:      "Table headers mapped to database fields, by match kind (exact, epbe, fuzzy, miss).",
+	}, []string{"match"})
+
+	// FuzzyScore is the similarity score of headers mapped by fuzzy matching
+	FuzzyScore = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mapping_fuzzy_score",
+		Help:      "Similarity score of headers mapped by fuzzy matching.",
+		Buckets:   []float64{0.65, 0.7, 0.75, 0.8, 0.85, 0.9, 0.95, 1},
+	})
+
+	// Rows counts saved source rows by target table and status: inserted, valid, skipped or failed
+	Rows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rows_total",
+		Help:      "Source rows saved from PDF tables and spreadsheets, by target table and status.",
+	}, []string{"table", "status"})
+
+	// DBQueryDuration is the duration of GORM statements by operation and table
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Duration of database statements issued through GORM, by operation and table.",
+		Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"operation", "table"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		ExtractionDuration,
+		ExtractionQueueDepth,
+		ExtractionRunning,
+		DocumentTables,
+		DocumentRows,
+		HeaderMappings,
+		FuzzyScore,
+		Rows,
+		DBQueryDuration,
+	)
+}
+
+// RegisterDBStats exposes the connection pool statistics of db. Registering a second pool is a no-op.
+func RegisterDBStats(db *sql.DB) error {
+	err := Registry.Register(collectors.NewDBStatsCollector(db, namespace))
+	var registered prometheus.AlreadyRegisteredError
+	if errors.As(err, &registered) {
+		return nil
+	}
+	return err
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() echo.HandlerFunc {
+	return echo.WrapHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+}
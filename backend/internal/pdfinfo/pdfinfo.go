@@ -0,0 +1,99 @@
+package pdfinfo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Validation errors; Inspect wraps them with details
+var (
+	ErrNotPDF            = errors.New("not a PDF file")
+	ErrMalformed         = errors.New("malformed PDF")
+	ErrEncrypted         = errors.New("encrypted PDFs are not supported, remove the encryption and upload it again")
+	ErrPasswordProtected = errors.New("password-protected PDFs are not supported, remove the password and upload it again")
+	ErrNoPages           = errors.New("PDF has no pages")
+)
+
+// Info describes a valid PDF
+type Info struct {
+	Version string `json:"version"`
+	Pages   int    `json:"pages"`
+}
+
+// tailSize is how far from the end of the file the %%EOF marker is looked for
+const tailSize = 1024
+
+var header = regexp.MustCompile(`^%PDF-(\d\.\d)`)
+
+// Inspect validates the PDF in r, of the given size: its header, its end-of-file marker and
+// cross-reference table, that it is not encrypted and that its page tree has pages.
+func Inspect(r io.ReaderAt, size int64) (info *Info, err error) {
+	head := make([]byte, 16)
+	if n, _ := r.ReadAt(head, 0); n < len("%PDF-1.0") {
+		return nil, ErrNotPDF
+	}
+	match := header.FindSubmatch(head)
+	if match == nil {
+		return nil, ErrNotPDF
+	}
+	version := string(match[1])
+
+	// The parser insists on %%EOF being the last line; anything appended after it is ignored
+	tailStart := max(size-tailSize, 0)
+	tail := make([]byte, size-tailStart)
+	if _, err := r.ReadAt(tail, tailStart); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	eof := bytes.LastIndex(tail, []byte("%%EOF"))
+	if eof < 0 {
+		return nil, fmt.Errorf("%w: missing %%%%EOF marker, the file may be truncated", ErrMalformed)
+	}
+	end := tailStart + int64(eof) + int64(len("%%EOF"))
+
+	// The parser panics on some malformed objects
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			info, err = nil, fmt.Errorf("%w: %v", ErrMalformed, recovered)
+		}
+	}()
+
+	reader, err := pdf.NewReader(versionShim{r}, end)
+	if errors.Is(err, pdf.ErrInvalidPassword) {
+		return nil, ErrPasswordProtected
+	}
+	if err != nil && bytes.Contains(tail, []byte("/Encrypt")) {
+		// The parser only handles some encryption schemes; any it fails on is still encryption
+		return nil, ErrEncrypted
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if !reader.Trailer().Key("Encrypt").IsNull() {
+		return nil, ErrEncrypted
+	}
+
+	pages := reader.NumPage()
+	if pages < 1 {
+		return nil, ErrNoPages
+	}
+	return &Info{Version: version, Pages: pages}, nil
+}
+
+// versionShim presents PDF 2.0 files with a 1.7 header, the newest the parser accepts; the structures
+// it reads are unchanged in 2.0
+type versionShim struct {
+	io.ReaderAt
+}
+
+func (v versionShim) ReadAt(p []byte, off int64) (int, error) {
+	n, err := v.ReaderAt.ReadAt(p, off)
+	if off <= 5 && off+int64(n) >= 8 && bytes.Equal(p[5-off:8-off], []byte("2.0")) {
+		copy(p[5-off:8-off], "1.7")
+	}
+	return n, err
+}
@@ -0,0 +1,115 @@
+package pdfinfo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF assembles a PDF from numbered objects with a correct cross-reference table. Object 1 must
+// be the catalog; extra is added to the trailer dictionary.
+func buildPDF(version string, objects []string, extra string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%%PDF-%s\n%%\xe2\xe3\xcf\xd3\n", version)
+
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R %s>>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, extra, xref)
+	return b.Bytes()
+}
+
+// pagesPDF is a PDF with n empty A4 pages
+func pagesPDF(version string, n int, extra string) []byte {
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	var kids []string
+	for i := 0; i < n; i++ {
+		kids = append(kids, fmt.Sprintf("%d 0 R", len(objects)+1))
+		objects = append(objects, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] >>")
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), n)
+	return buildPDF(version, objects, extra)
+}
+
+func TestInspect(t *testing.T) {
+	valid := pagesPDF("1.4", 1, "")
+	// Standard security handler whose keys match no password, the empty user password included
+	passwordProtected := "/Encrypt << /Filter /Standard /V 1 /R 2 /Length 40 /P -4 /O <" + strings.Repeat("00", 32) +
+		"> /U <" + strings.Repeat("00", 32) + "> >> /ID [<00112233445566778899aabbccddeeff> <00112233445566778899aabbccddeeff>] "
+
+	tests := []struct {
+		name        string
+		data        []byte
+		wantVersion string
+		wantPages   int
+		wantErr     error
+	}{
+		{name: "single page", data: valid, wantVersion: "1.4", wantPages: 1},
+		{name: "several pages", data: pagesPDF("1.7", 3, ""), wantVersion: "1.7", wantPages: 3},
+		{name: "PDF 2.0", data: pagesPDF("2.0", 2, ""), wantVersion: "2.0", wantPages: 2},
+		{name: "data after the end marker", data: append(append([]byte{}, valid...), "\x00\x00 trailing junk"...), wantVersion: "1.4", wantPages: 1},
+		{name: "empty file", data: nil, wantErr: ErrNotPDF},
+		{name: "short file", data: []byte("%PDF"), wantErr: ErrNotPDF},
+		{name: "zip archive", data: []byte("PK\x03\x04\x14\x00\x00\x00\x08\x00 not a pdf"), wantErr: ErrNotPDF},
+		{name: "header not at the start", data: append([]byte("junk"), valid...), wantErr: ErrNotPDF},
+		{name: "truncated", data: valid[:len(valid)-20], wantErr: ErrMalformed},
+		{name: "no cross-reference table", data: []byte("%PDF-1.4\n1 0 obj\n<< >>\nendobj\n%%EOF\n"), wantErr: ErrMalformed},
+		{name: "corrupt cross-reference offset", data: bytes.Replace(valid, []byte("startxref\n"), []byte("startxref\n9"), 1), wantErr: ErrMalformed},
+		{name: "no pages", data: pagesPDF("1.4", 0, ""), wantErr: ErrNoPages},
+		{name: "encrypted", data: pagesPDF("1.4", 1, "/Encrypt << /Filter /Custom /V 9 >> "), wantErr: ErrEncrypted},
+		{name: "password protected", data: pagesPDF("1.4", 1, passwordProtected), wantErr: ErrPasswordProtected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Inspect(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Inspect = %+v, %v, want %v", info, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Inspect: %v", err)
+			}
+			if info.Version != tt.wantVersion || info.Pages != tt.wantPages {
+				t.Errorf("Inspect = %+v, want version %s with %d pages", info, tt.wantVersion, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestVersionShim(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		offset int64
+		size   int
+		want   string
+	}{
+		{"whole header", "%PDF-2.0\n", 0, 9, "%PDF-1.7\n"},
+		{"version only", "%PDF-2.0\n", 5, 3, "1.7"},
+		{"other versions unchanged", "%PDF-1.5\n", 0, 9, "%PDF-1.5\n"},
+		{"2.0 elsewhere unchanged", "%PDF-1.7\n2.0", 9, 3, "2.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := make([]byte, tt.size)
+			n, _ := versionShim{strings.NewReader(tt.data)}.ReadAt(p, tt.offset)
+			if got := string(p[:n]); got != tt.want {
+				t.Errorf("ReadAt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package queue
+
+import (
+	"context"
+	"sync"
+)
+
+// Message is a job taken from a queue. It stays reserved for its consumer until acknowledged.
+type Message struct {
+	Queue string
+	Body  []byte
+}
+
+// Queue hands jobs to workers. Delivery is at least once: a message reserved by a consumer that
+// stops before acknowledging it may be delivered again, so jobs must tolerate being seen twice.
+type Queue interface {
+	Enqueue(ctx context.Context, queue string, body []byte) error
+	// Dequeue blocks until a message is available or ctx is done
+	Dequeue(ctx context.Context, queue string) (*Message, error)
+	// Ack releases a handled message
+	Ack(ctx context.Context, msg *Message) error
+	// Len returns the number of messages waiting in a queue
+	Len(ctx context.Context, queue string) (int64, error)
+	// Shared reports whether other processes consume the same queues
+	Shared() bool
+}
+
+// memoryQueue keeps messages in this process; they are lost when it stops
+type memoryQueue struct {
+	mu       sync.Mutex
+	messages map[string][][]byte
+	// ready is closed and replaced whenever a message is enqueued
+	ready map[string]chan struct{}
+}
+
+// NewMemory returns a queue held in process memory
+func NewMemory() Queue {
+	return &memoryQueue{messages: map[string][][]byte{}, ready: map[string]chan struct{}{}}
+}
+
+func (m *memoryQueue) Enqueue(_ context.Context, queue string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages[queue] = append(m.messages[queue], body)
+	if ready, ok := m.ready[queue]; ok {
+		close(ready)
+		delete(m.ready, queue)
+	}
+	return nil
+}
+
+func (m *memoryQueue) Dequeue(ctx context.Context, queue string) (*Message, error) {
+	for {
+		m.mu.Lock()
+		if pending := m.messages[queue]; len(pending) > 0 {
+			m.messages[queue] = pending[1:]
+			m.mu.Unlock()
+			return &Message{Queue: queue, Body: pending[0]}, nil
+		}
+		ready, ok := m.ready[queue]
+		if !ok {
+			ready = make(chan struct{})
+			m.ready[queue] = ready
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Ack has nothing to release, messages are removed when dequeued
+func (m *memoryQueue) Ack(context.Context, *Message) error {
+	return nil
+}
+
+func (m *memoryQueue) Len(_ context.Context, queue string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.messages[queue])), nil
+}
+
+func (m *memoryQueue) Shared() bool {
+	return false
+}
+
+var (
+	defaultMu sync.RWMutex
+	current   = NewMemory()
+)
+
+// Default returns the process-wide queue, in memory unless SetDefault installed another
+func Default() Queue {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return current
+}
+
+// SetDefault replaces the process-wide queue
+func SetDefault(q Queue) {
+	defaultMu.Lock()
+	current = q
+	defaultMu.Unlock()
+}
@@ -0,0 +1,159 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisPollInterval bounds each blocking read so Dequeue notices a cancelled context
+const redisPollInterval = 2 * time.Second
+
+// HeartbeatInterval is how often a consumer should call Heartbeat. A consumer whose heartbeat is
+// older than three intervals is taken for dead, and its reserved messages are requeued.
+const HeartbeatInterval = 10 * time.Second
+
+// redisQueue is a reliable queue on Redis lists: a dequeued message is moved atomically to a list
+// owned by its consumer and removed from there on Ack, so a consumer that dies leaves it behind to
+// be requeued by Recover, when it restarts, or by RecoverStale on any other consumer.
+type redisQueue struct {
+	client   *redis.Client
+	prefix   string
+	consumer string
+}
+
+// NewRedis returns a queue stored in Redis under keys starting with prefix. consumer names this
+// process's reservations; it should be stable across restarts so Recover finds them.
+func NewRedis(client *redis.Client, prefix, consumer string) Queue {
+	return &redisQueue{client: client, prefix: prefix + "queue:", consumer: consumer}
+}
+
+func (r *redisQueue) pendingKey(queue string) string {
+	return r.prefix + queue
+}
+
+func (r *redisQueue) reservedKey(queue string) string {
+	return r.reservedPrefix(queue) + r.consumer
+}
+
+func (r *redisQueue) reservedPrefix(queue string) string {
+	return r.prefix + queue + ":reserved:"
+}
+
+func (r *redisQueue) heartbeatKey(queue, consumer string) string {
+	return r.prefix + queue + ":heartbeat:" + consumer
+}
+
+func (r *redisQueue) Enqueue(ctx context.Context, queue string, body []byte) error {
+	return r.client.LPush(ctx, r.pendingKey(queue), body).Err()
+}
+
+func (r *redisQueue) Dequeue(ctx context.Context, queue string) (*Message, error) {
+	for {
+		body, err := r.client.BLMove(ctx, r.pendingKey(queue), r.reservedKey(queue), "RIGHT", "LEFT", redisPollInterval).Bytes()
+		if err == nil {
+			return &Message{Queue: queue, Body: body}, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+	}
+}
+
+func (r *redisQueue) Ack(ctx context.Context, msg *Message) error {
+	return r.client.LRem(ctx, r.reservedKey(msg.Queue), 1, msg.Body).Err()
+}
+
+func (r *redisQueue) Len(ctx context.Context, queue string) (int64, error) {
+	return r.client.LLen(ctx, r.pendingKey(queue)).Result()
+}
+
+func (r *redisQueue) Shared() bool {
+	return true
+}
+
+// Heartbeat tells the other consumers of a queue that this one is alive, so RecoverStale leaves its
+// reserved messages alone. It does nothing for queues that are not shared.
+func Heartbeat(ctx context.Context, q Queue, queue string) error {
+	r, ok := q.(*redisQueue)
+	if !ok {
+		return nil
+	}
+	return r.client.Set(ctx, r.heartbeatKey(queue, r.consumer), time.Now().Unix(), 3*HeartbeatInterval).Err()
+}
+
+// Recover requeues the messages this consumer reserved but never acknowledged, which a previous run
+// left behind when it stopped, along with those of every consumer that stopped sending heartbeats.
+// It returns the consumers whose messages it requeued and how many it moved.
+func Recover(ctx context.Context, q Queue, queue string) ([]string, int, error) {
+	return recoverReserved(ctx, q, queue, true)
+}
+
+// RecoverStale requeues the messages reserved by other consumers that stopped sending heartbeats, so
+// their jobs do not wait until those consumers restart. It returns the consumers whose messages it
+// requeued and how many it moved.
+func RecoverStale(ctx context.Context, q Queue, queue string) ([]string, int, error) {
+	return recoverReserved(ctx, q, queue, false)
+}
+
+// recoverReserved requeues the reserved messages of dead consumers, and this consumer's own with own
+func recoverReserved(ctx context.Context, q Queue, queue string, own bool) ([]string, int, error) {
+	r, ok := q.(*redisQueue)
+	if !ok {
+		return nil, 0, nil
+	}
+
+	var consumers []string
+	moved := 0
+	iter := r.client.Scan(ctx, 0, r.reservedPrefix(queue)+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		consumer := strings.TrimPrefix(iter.Val(), r.reservedPrefix(queue))
+		if consumer == r.consumer && !own {
+			continue
+		}
+		if consumer != r.consumer {
+			alive, err := r.client.Exists(ctx, r.heartbeatKey(queue, consumer)).Result()
+			if err != nil {
+				return consumers, moved, fmt.Errorf("failed to check consumer %s: %w", consumer, err)
+			}
+			if alive > 0 {
+				continue
+			}
+		}
+
+		n, err := r.requeue(ctx, iter.Val(), queue)
+		moved += n
+		if err != nil {
+			return consumers, moved, err
+		}
+		if n > 0 {
+			consumers = append(consumers, consumer)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return consumers, moved, fmt.Errorf("failed to list reserved messages: %w", err)
+	}
+	return consumers, moved, nil
+}
+
+// requeue moves every message of a reserved list back to the queue and returns how many it moved
+func (r *redisQueue) requeue(ctx context.Context, reserved, queue string) (int, error) {
+	moved := 0
+	for {
+		err := r.client.LMove(ctx, reserved, r.pendingKey(queue), "RIGHT", "RIGHT").Err()
+		if errors.Is(err, redis.Nil) {
+			return moved, nil
+		}
+		if err != nil {
+			return moved, fmt.Errorf("failed to requeue reserved messages: %w", err)
+		}
+		moved++
+	}
+}
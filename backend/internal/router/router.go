@@ -1,48 +1,109 @@
 package router
 
 import (
-	"net/http"
+	"context"
+	"errors"
+	"log/slog"
 
+	"workbench/internal/cache"
+	"workbench/internal/config"
 	"workbench/internal/core/handlers"
 	"workbench/internal/database"
+	"workbench/internal/logging"
+	"workbench/internal/metrics"
+	"workbench/internal/queue"
+	"workbench/internal/storage"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
+// Server is the Echo router together with the handlers that run background work
+type Server struct {
+	*echo.Echo
+	extraction *handlers.ExtractionHandler
+	health     *handlers.HealthHandler
+}
+
 // Setup creates and configures the Echo router
-func Setup() *echo.Echo {
+func Setup(cfg *config.Config) *Server {
 	e := echo.New()
 
-	// Middleware
-	e.Use(middleware.Logger())
-	e.Use(middleware.Recover())
-	e.Use(middleware.CORS())
+	// Middleware; the request logger comes first so every later line carries the request ID
+	e.Use(logging.Middleware())
+	e.Use(metrics.Middleware())
+	e.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{LogErrorFunc: logging.LogPanic}))
+	// PDF viewers on the frontend's origin send range and conditional requests and read the headers
+	// exposed here
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins:     cfg.Server.CORSOrigins,
+		AllowMethods:     []string{echo.GET, echo.HEAD, echo.POST, echo.PUT, echo.DELETE, echo.OPTIONS},
+		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "Range", echo.HeaderIfModifiedSince, "If-None-Match", "If-Range"},
+		ExposeHeaders:    []string{"Accept-Ranges", "Content-Range", echo.HeaderContentLength, echo.HeaderContentDisposition, "ETag"},
+		AllowCredentials: true,
+	}))
+
+	getDB := database.GetDB()
+
+	// Liveness and readiness probes
+	healthHandler := handlers.NewHealthHandler(getDB, cfg)
+	healthHandler.HealthRoutes(e)
 
-	// Health check endpoint
-	e.GET("/health", func(c echo.Context) error {
-		return c.JSON(http.StatusOK, map[string]string{
-			"status":  "ok",
-			"message": "Server is running",
-		})
-	})
+	// Prometheus metrics
+	e.GET("/metrics", metrics.Handler())
 
 	// API routes
 	api := e.Group("/api/v1")
 
-	getDB := database.GetDB()
-
 	// Initialize handlers here
 	userHandler := handlers.NewUserHandler(getDB)
 	petrographyClasticHandler := handlers.NewPetrographyClasticHandler(getDB)
 	petrographyCarbonateHandler := handlers.NewPetrographyCarbonateHandler(getDB)
-	extractionHandler := handlers.NewExtractionHandler(getDB)
+	extractionHandler := handlers.NewExtractionHandler(getDB, queue.Default(), cache.Default(), storage.Default(), cfg.Extraction, cfg.Upload, cfg.Storage)
+	wellHandler := handlers.NewWellHandler(getDB)
+	coordinateHandler := handlers.NewCoordinateHandler()
+	vocabularyHandler := handlers.NewVocabularyHandler(getDB)
+	timescaleHandler := handlers.NewTimescaleHandler()
 
 	// Add Routes here
 	userHandler.UserRoutes(api)
 	petrographyClasticHandler.PetrographyClasticRoutes(api)
 	petrographyCarbonateHandler.PetrographyCarbonateRoutes(api)
 	extractionHandler.ExtractionRoutes(api)
+	wellHandler.WellRoutes(api)
+	coordinateHandler.CoordinateRoutes(api)
+	vocabularyHandler.VocabularyRoutes(api)
+	timescaleHandler.TimescaleRoutes(api)
+	if local, ok := storage.Default().(*storage.Local); ok {
+		handlers.NewStorageHandler(local).StorageRoutes(api)
+	}
+
+	return &Server{Echo: e, extraction: extractionHandler, health: healthHandler}
+}
+
+// RecoverJobs marks the extractions interrupted by the previous run as failed
+func (s *Server) RecoverJobs(ctx context.Context) error {
+	failed, imports, err := s.extraction.RecoverJobs(ctx)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		slog.WarnContext(ctx, "⚠️ Marked interrupted extractions as failed", "count", failed)
+	}
+	if imports > 0 {
+		slog.InfoContext(ctx, "📥 Unfinished imports can be resumed", "count", imports)
+	}
+	return nil
+}
+
+// Shutdown stops accepting requests, extractions and imports, then waits until ctx is done for the
+// running requests and jobs. Extractions still running at the deadline are killed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	// Turn unready and refuse new jobs first, so requests arriving while connections drain get a 503
+	s.health.Drain()
+	jobs := make(chan error, 1)
+	go func() { jobs <- s.extraction.Shutdown(ctx) }()
 
-	return e
+	err := s.Echo.Shutdown(ctx)
+	return errors.Join(err, <-jobs)
 }
@@ -0,0 +1,67 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"workbench/internal/config"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestCORS(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.CORSOrigins = []string{"http://viewer.example"}
+	cfg.Extraction.Workers = 0
+	server := Setup(cfg)
+
+	t.Run("preflight allows range and conditional requests", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodOptions, "/api/v1/extraction/pdf/1", nil)
+		req.Header.Set(echo.HeaderOrigin, "http://viewer.example")
+		req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodGet)
+		req.Header.Set(echo.HeaderAccessControlRequestHeaders, "Range, If-None-Match, If-Range")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("status = %d, want 204", rec.Code)
+		}
+		if got := rec.Header().Get(echo.HeaderAccessControlAllowOrigin); got != "http://viewer.example" {
+			t.Errorf("allowed origin = %q", got)
+		}
+		allowed := rec.Header().Get(echo.HeaderAccessControlAllowHeaders)
+		for _, header := range []string{"Range", "If-None-Match", "If-Range"} {
+			if !strings.Contains(allowed, header) {
+				t.Errorf("allowed headers %q lack %s", allowed, header)
+			}
+		}
+	})
+
+	t.Run("responses expose the range headers", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/livez", nil)
+		req.Header.Set(echo.HeaderOrigin, "http://viewer.example")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		exposed := rec.Header().Get(echo.HeaderAccessControlExposeHeaders)
+		for _, header := range []string{"Accept-Ranges", "Content-Range", "ETag"} {
+			if !strings.Contains(exposed, header) {
+				t.Errorf("exposed headers %q lack %s", exposed, header)
+			}
+		}
+	})
+
+	t.Run("other origins are not allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodOptions, "/api/v1/extraction/pdf/1", nil)
+		req.Header.Set(echo.HeaderOrigin, "http://elsewhere.example")
+		req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodGet)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get(echo.HeaderAccessControlAllowOrigin); got != "" {
+			t.Errorf("allowed origin = %q, want none", got)
+		}
+	})
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalRoute is the path, under the API, that serves the local backend's signed URLs
+const LocalRoute = "/api/v1/storage/"
+
+// tempPrefix names the files an upload is written to before it is renamed into place
+const tempPrefix = ".tmp-"
+
+// Local stores objects as files under a root directory
+type Local struct {
+	root    string
+	secret  []byte
+	baseURL string
+}
+
+// NewLocal stores objects under root, created if needed. Signed URLs point to baseURL and are
+// signed with secret.
+func NewLocal(root string, secret []byte, baseURL string) (*Local, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %v", err)
+	}
+	return &Local{root: root, secret: secret, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// Root returns the directory objects are stored in
+func (l *Local) Root() string {
+	return l.root
+}
+
+// path returns the file of a key
+func (l *Local) path(key string) (string, error) {
+	if err := CheckKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the object through a temporary file, so readers never see a partial object
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	file, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func (l *Local) Get(ctx context.Context, key string) (Reader, *Object, error) {
+	file, err := l.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, &Object{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (*Object, error) {
+	file, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(file)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	file, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) List(ctx context.Context, prefix string) ([]Object, error) {
+	// Walk the deepest directory the prefix names
+	dir := l.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		var err error
+		if dir, err = l.path(prefix[:i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var objects []Object
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(l.root, file)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return objects, err
+}
+
+// SignedURL returns a URL to LocalRoute carrying the expiry and an HMAC of the key, expiry and
+// disposition, checked by Verify
+func (l *Local) SignedURL(ctx context.Context, key string, ttl time.Duration, disposition string) (string, error) {
+	if err := CheckKey(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	query := url.Values{
+		"expires":     {expires},
+		"disposition": {disposition},
+		"signature":   {l.sign(key, expires, disposition)},
+	}
+	return l.baseURL + LocalRoute + key + "?" + query.Encode(), nil
+}
+
+// Verify checks the signature and expiry of a signed URL's query for key
+func (l *Local) Verify(key string, query url.Values) error {
+	expires := query.Get("expires")
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return errors.New("invalid expiry")
+	}
+	signature, err := hex.DecodeString(query.Get("signature"))
+	if err != nil || !hmac.Equal(signature, l.mac(key, expires, query.Get("disposition"))) {
+		return errors.New("invalid signature")
+	}
+	if time.Now().Unix() > unix {
+		return errors.New("URL expired")
+	}
+	return nil
+}
+
+func (l *Local) sign(key, expires, disposition string) string {
+	return hex.EncodeToString(l.mac(key, expires, disposition))
+}
+
+func (l *Local) mac(key, expires, disposition string) []byte {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(key + "\n" + expires + "\n" + disposition))
+	return mac.Sum(nil)
+}
+
+// Ping checks that the root directory is writable
+func (l *Local) Ping(ctx context.Context) error {
+	probe, err := os.CreateTemp(l.root, tempPrefix+"ping-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %v", l.root, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
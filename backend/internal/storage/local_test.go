@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocal(t *testing.T) *Local {
+	t.Helper()
+	l, err := NewLocal(t.TempDir(), []byte("test secret"), "http://localhost:8080/")
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	return l
+}
+
+func TestLocal(t *testing.T) {
+	testBackend(t, newTestLocal(t))
+}
+
+func TestLocalNewCreatesRoot(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "a", "b")
+	if _, err := NewLocal(root, nil, ""); err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		t.Errorf("root not created: %v", err)
+	}
+}
+
+func TestLocalIgnoresPartialUploads(t *testing.T) {
+	l := newTestLocal(t)
+	ctx := context.Background()
+	if err := l.Put(ctx, "documents/a.pdf", strings.NewReader("data"), 4, ""); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	// An upload interrupted before its rename leaves a temporary file behind
+	if err := os.WriteFile(filepath.Join(l.Root(), "documents", tempPrefix+"123"), []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	objects, err := l.List(ctx, Documents)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Key != "documents/a.pdf" {
+		t.Errorf("List = %+v, want only documents/a.pdf", objects)
+	}
+}
+
+func TestLocalStatDirectory(t *testing.T) {
+	l := newTestLocal(t)
+	if err := l.Put(context.Background(), "pages/1/page-1.png", strings.NewReader("p"), 1, ""); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := l.Stat(context.Background(), "pages/1"); err != ErrNotFound {
+		t.Errorf("Stat of a directory = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalSignedURL(t *testing.T) {
+	l := newTestLocal(t)
+	const key = "documents/a.pdf"
+	const disposition = `attachment; filename="a.pdf"`
+
+	signed, err := l.SignedURL(context.Background(), key, time.Minute, disposition)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("invalid URL %q: %v", signed, err)
+	}
+	if want := "http://localhost:8080" + LocalRoute + key; u.Scheme+"://"+u.Host+u.Path != want {
+		t.Errorf("URL = %s, want %s?...", signed, want)
+	}
+	if got := u.Query().Get("disposition"); got != disposition {
+		t.Errorf("disposition = %q, want %q", got, disposition)
+	}
+
+	with := func(name, value string) url.Values {
+		query := u.Query()
+		query.Set(name, value)
+		return query
+	}
+	// A correctly signed URL whose expiry has passed
+	expired := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	expiredQuery := with("expires", expired)
+	expiredQuery.Set("signature", l.sign(key, expired, disposition))
+
+	tests := []struct {
+		name    string
+		key     string
+		query   url.Values
+		wantErr string
+	}{
+		{name: "valid", key: key, query: u.Query()},
+		{name: "other key", key: "documents/b.pdf", query: u.Query(), wantErr: "invalid signature"},
+		{name: "changed disposition", key: key, query: with("disposition", "inline"), wantErr: "invalid signature"},
+		{name: "extended expiry", key: key, query: with("expires", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)), wantErr: "invalid signature"},
+		{name: "tampered signature", key: key, query: with("signature", strings.Repeat("0", 64)), wantErr: "invalid signature"},
+		{name: "signature not hex", key: key, query: with("signature", "zz"), wantErr: "invalid signature"},
+		{name: "missing expiry", key: key, query: with("expires", ""), wantErr: "invalid expiry"},
+		{name: "expired", key: key, query: expiredQuery, wantErr: "URL expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.Verify(tt.key, tt.query)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Verify = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Verify = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("other secret", func(t *testing.T) {
+		other, err := NewLocal(l.Root(), []byte("other secret"), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := other.Verify(key, u.Query()); err == nil {
+			t.Error("Verify accepted a URL signed with another secret")
+		}
+	})
+}
+
+func TestLocalPingReadOnly(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root ignores directory permissions")
+	}
+	l := newTestLocal(t)
+	if err := os.Chmod(l.Root(), 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(l.Root(), 0755)
+	if err := l.Ping(context.Background()); err == nil {
+		t.Error("Ping succeeded on a read-only directory")
+	}
+}
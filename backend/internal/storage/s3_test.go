@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"workbench/internal/config"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// fakeS3 is an in-memory S3 service speaking the path-style subset of the API the S3 backend uses.
+// Signatures are not checked.
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+	etag        string
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{buckets: make(map[string]map[string]fakeObject)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	objects, exists := f.buckets[bucket]
+	if key == "" {
+		switch {
+		case r.Method == http.MethodPut:
+			if !exists {
+				f.buckets[bucket] = make(map[string]fakeObject)
+			}
+		case !exists:
+			s3ErrorResponse(w, r, http.StatusNotFound, "NoSuchBucket")
+		case r.Method == http.MethodHead:
+		case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+			listObjects(w, bucket, objects, r.URL.Query().Get("prefix"))
+		default:
+			s3ErrorResponse(w, r, http.StatusNotImplemented, "NotImplemented")
+		}
+		return
+	}
+	if !exists {
+		s3ErrorResponse(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		body := io.Reader(r.Body)
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			body = decodeChunked(r.Body)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			s3ErrorResponse(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		sum := md5.Sum(data)
+		object := fakeObject{
+			data:        data,
+			contentType: r.Header.Get("Content-Type"),
+			modTime:     time.Now().UTC().Truncate(time.Second),
+			etag:        `"` + hex.EncodeToString(sum[:]) + `"`,
+		}
+		objects[key] = object
+		w.Header().Set("ETag", object.etag)
+	case http.MethodGet, http.MethodHead:
+		object, ok := objects[key]
+		if !ok {
+			s3ErrorResponse(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", object.etag)
+		w.Header().Set("Content-Type", object.contentType)
+		http.ServeContent(w, r, key, object.modTime, bytes.NewReader(object.data))
+	case http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s3ErrorResponse(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// decodeChunked strips the aws-chunked framing of a streaming-signed upload: hex-sized chunks, each
+// followed by its signature, up to an empty chunk and optional trailers
+func decodeChunked(body io.Reader) io.Reader {
+	r := bufio.NewReader(body)
+	pr, pw := io.Pipe()
+	go func() {
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+			size, err := strconv.ParseInt(sizeHex, 16, 64)
+			if err != nil {
+				pw.CloseWithError(fmt.Errorf("invalid chunk size %q", sizeHex))
+				return
+			}
+			if size == 0 {
+				pw.Close()
+				return
+			}
+			if _, err := io.CopyN(pw, r, size); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := r.Discard(2); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
+
+func listObjects(w http.ResponseWriter, bucket string, objects map[string]fakeObject, prefix string) {
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int64
+		StorageClass string
+	}
+	result := struct {
+		XMLName     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		MaxKeys     int
+		IsTruncated bool
+		Contents    []content
+	}{Name: bucket, Prefix: prefix, MaxKeys: 1000}
+
+	for key, object := range objects {
+		if strings.HasPrefix(key, prefix) {
+			result.Contents = append(result.Contents, content{
+				Key:          key,
+				LastModified: object.modTime.Format("2006-01-02T15:04:05.000Z"),
+				ETag:         object.etag,
+				Size:         int64(len(object.data)),
+				StorageClass: "STANDARD",
+			})
+		}
+	}
+	sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func s3ErrorResponse(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	// Responses to HEAD have no body; the client derives the code from the status
+	if r.Method != http.MethodHead {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message><Resource>%s</Resource></Error>`,
+			code, code, r.URL.Path)
+	}
+}
+
+func newTestS3(t *testing.T) (*S3, *fakeS3) {
+	t.Helper()
+	fake, server := newFakeS3(t)
+	s, err := NewS3(context.Background(), config.S3Config{
+		// The scheme is accepted and dropped
+		Endpoint:  server.URL,
+		Region:    "us-east-1",
+		Bucket:    "workbench",
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewS3: %v", err)
+	}
+	return s, fake
+}
+
+func TestS3(t *testing.T) {
+	s, fake := newTestS3(t)
+	if _, ok := fake.buckets["workbench"]; !ok {
+		t.Fatal("NewS3 did not create the bucket")
+	}
+	testBackend(t, s)
+}
+
+func TestS3SignedURL(t *testing.T) {
+	s, _ := newTestS3(t)
+	ctx := context.Background()
+	if err := s.Put(ctx, "documents/a.pdf", strings.NewReader("%PDF"), 4, "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	const disposition = `attachment; filename="a.pdf"`
+	signed, err := s.SignedURL(ctx, "documents/a.pdf", time.Minute, disposition)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("invalid URL %q: %v", signed, err)
+	}
+	query := u.Query()
+	if u.Path != "/workbench/documents/a.pdf" {
+		t.Errorf("path = %q, want /workbench/documents/a.pdf", u.Path)
+	}
+	if query.Get("X-Amz-Signature") == "" || query.Get("X-Amz-Expires") != "60" {
+		t.Errorf("URL %s is not presigned for 60 seconds", signed)
+	}
+	if got := query.Get("response-content-disposition"); got != disposition {
+		t.Errorf("disposition = %q, want %q", got, disposition)
+	}
+
+	resp, err := http.Get(signed)
+	if err != nil {
+		t.Fatalf("GET %s: %v", signed, err)
+	}
+	defer resp.Body.Close()
+	if data, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusOK || string(data) != "%PDF" {
+		t.Errorf("GET = %d %q, want the object", resp.StatusCode, data)
+	}
+}
+
+func TestS3Ping(t *testing.T) {
+	s, fake := newTestS3(t)
+	if err := s.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	fake.mu.Lock()
+	delete(fake.buckets, "workbench")
+	fake.mu.Unlock()
+	if err := s.Ping(context.Background()); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Ping = %v, want a missing bucket error", err)
+	}
+}
+
+func TestS3Unreachable(t *testing.T) {
+	_, server := newFakeS3(t)
+	server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := NewS3(ctx, config.S3Config{Endpoint: server.Listener.Addr().String(), Region: "us-east-1", Bucket: "workbench"})
+	if err == nil || !strings.Contains(err.Error(), "failed to reach bucket workbench") {
+		t.Errorf("NewS3 = %v, want an unreachable bucket error", err)
+	}
+}
+
+// TestS3Service runs the backend suite against a real S3-compatible service when
+// WORKBENCH_TEST_S3_ENDPOINT is set, in a bucket of its own that is removed afterwards
+func TestS3Service(t *testing.T) {
+	endpoint := os.Getenv("WORKBENCH_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("WORKBENCH_TEST_S3_ENDPOINT is not set")
+	}
+	useSSL, _ := strconv.ParseBool(os.Getenv("WORKBENCH_TEST_S3_USE_SSL"))
+	bucket := fmt.Sprintf("workbench-test-%d", time.Now().UnixNano())
+
+	ctx := context.Background()
+	s, err := NewS3(ctx, config.S3Config{
+		Endpoint:  endpoint,
+		Region:    os.Getenv("WORKBENCH_TEST_S3_REGION"),
+		Bucket:    bucket,
+		AccessKey: os.Getenv("WORKBENCH_TEST_S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("WORKBENCH_TEST_S3_SECRET_KEY"),
+		UseSSL:    useSSL,
+	})
+	if err != nil {
+		t.Fatalf("NewS3: %v", err)
+	}
+	t.Cleanup(func() {
+		for info := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Recursive: true}) {
+			if info.Err == nil {
+				s.client.RemoveObject(ctx, bucket, info.Key, minio.RemoveObjectOptions{})
+			}
+		}
+		if err := s.client.RemoveBucket(ctx, bucket); err != nil {
+			t.Logf("failed to remove bucket %s: %v", bucket, err)
+		}
+	})
+
+	testBackend(t, s)
+}
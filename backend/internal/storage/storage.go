@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"workbench/internal/config"
+)
+
+// Key prefixes of the stored objects
+const (
+	// Documents holds the uploaded PDFs, named after their content hash
+	Documents = "documents/"
+	// Outputs holds the extractor's JSON and Markdown output, named after the PDF
+	Outputs = "outputs/"
+	// Pages holds single pages of documents, as PDFs and images, under the document's ID
+	Pages = "pages/"
+	// Imports holds uploaded spreadsheets until their import completes, under the import's ID
+	Imports = "imports/"
+)
+
+var (
+	// ErrNotFound is returned for a key with no object
+	ErrNotFound = errors.New("storage: object not found")
+	// ErrInvalidKey is returned for a key that is not a clean relative path
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// Object describes a stored object
+type Object struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modified"`
+}
+
+// Reader reads a stored object; it can seek, for range requests, and read at offsets, for parsers
+type Reader interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+}
+
+// Storage keeps uploaded documents and the extractor's output. Keys are slash-separated relative paths
+// chosen by the server, never by clients.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens an object; ErrNotFound when there is none
+	Get(ctx context.Context, key string) (Reader, *Object, error)
+	Stat(ctx context.Context, key string) (*Object, error)
+	// Delete removes an object; a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// List returns the objects whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// SignedURL returns a URL that downloads the object without credentials until ttl has passed,
+	// with the given Content-Disposition
+	SignedURL(ctx context.Context, key string, ttl time.Duration, disposition string) (string, error)
+	// Ping checks that the storage is reachable and writable
+	Ping(ctx context.Context) error
+}
+
+var (
+	defaultMu sync.RWMutex
+	current   Storage
+)
+
+// Default returns the process-wide storage installed by SetDefault
+func Default() Storage {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return current
+}
+
+// SetDefault replaces the process-wide storage
+func SetDefault(s Storage) {
+	defaultMu.Lock()
+	current = s
+	defaultMu.Unlock()
+}
+
+// New opens the storage backend selected by cfg. baseURL is the server's public URL, which signed URLs
+// of the local backend point to.
+func New(ctx context.Context, cfg config.StorageConfig, baseURL string) (Storage, error) {
+	switch cfg.Backend {
+	case config.StorageS3:
+		return NewS3(ctx, cfg.S3)
+	case config.StorageLocal:
+		secret := []byte(cfg.SigningKey)
+		if len(secret) == 0 {
+			// Signed URLs then stop working on restart and are not accepted by other replicas
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, err
+			}
+		}
+		return NewLocal(cfg.LocalDir, secret, baseURL)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
+
+// CheckKey rejects keys that are empty, absolute, name the storage root or climb out of it
+func CheckKey(key string) error {
+	if key == "" || key == "." || strings.HasPrefix(key, "/") || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// DocumentKey returns the key of a stored PDF
+func DocumentKey(name string) string {
+	return Documents + name
+}
+
+// OutputKey returns the key of an extractor output file
+func OutputKey(name string) string {
+	return Outputs + name
+}
+
+// ImportKey returns the key of an uploaded spreadsheet
+func ImportKey(name string) string {
+	return Imports + name
+}
+
+// PageKey returns the key of a rendered page of the document with the given ID
+func PageKey(documentID, name string) string {
+	return Pages + documentID + "/" + name
+}
@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"workbench/internal/config"
+)
+
+func TestCheckKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"documents/report.pdf", true},
+		{"pages/42/page-1.png", true},
+		{"report.pdf", true},
+		{"documents/..report.pdf", true},
+		{"", false},
+		{"/etc/passwd", false},
+		{"..", false},
+		{"../secret", false},
+		{"documents/../../secret", false},
+		{"documents//report.pdf", false},
+		{"documents/./report.pdf", false},
+		{"documents/", false},
+		{".", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			err := CheckKey(tt.key)
+			if tt.valid && err != nil {
+				t.Errorf("CheckKey(%q) = %v, want nil", tt.key, err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("CheckKey(%q) = %v, want ErrInvalidKey", tt.key, err)
+			}
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	tests := []struct {
+		got, want string
+	}{
+		{DocumentKey("abc.pdf"), "documents/abc.pdf"},
+		{OutputKey("abc.json"), "outputs/abc.json"},
+		{ImportKey("7.xlsx"), "imports/7.xlsx"},
+		{PageKey("42", "page-1.png"), "pages/42/page-1.png"},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("key = %q, want %q", tt.got, tt.want)
+		}
+		if err := CheckKey(tt.got); err != nil {
+			t.Errorf("CheckKey(%q) = %v", tt.got, err)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Run("local", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := New(context.Background(), config.StorageConfig{Backend: config.StorageLocal, LocalDir: dir}, "http://localhost")
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		local, ok := s.(*Local)
+		if !ok {
+			t.Fatalf("New = %T, want *Local", s)
+		}
+		if local.Root() != dir {
+			t.Errorf("root = %q, want %q", local.Root(), dir)
+		}
+		// Without a signing key one is generated, so URLs still verify within the process
+		if len(local.secret) == 0 {
+			t.Error("no signing key generated")
+		}
+	})
+
+	t.Run("unknown backend", func(t *testing.T) {
+		if _, err := New(context.Background(), config.StorageConfig{Backend: "ftp"}, ""); err == nil {
+			t.Error("New accepted an unknown backend")
+		}
+	})
+}
+
+// testBackend runs the behaviour every backend shares against s, which must be empty
+func testBackend(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	put := func(t *testing.T, key, data string) {
+		t.Helper()
+		if err := s.Put(ctx, key, strings.NewReader(data), int64(len(data)), "application/octet-stream"); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+	}
+	read := func(t *testing.T, key string) (string, *Object) {
+		t.Helper()
+		r, object, err := s.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("reading %q: %v", key, err)
+		}
+		return string(data), object
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		before := time.Now().Add(-time.Minute)
+		put(t, "documents/a.pdf", "%PDF-1.4 first")
+
+		data, object := read(t, "documents/a.pdf")
+		if data != "%PDF-1.4 first" {
+			t.Errorf("Get = %q", data)
+		}
+		if object.Key != "documents/a.pdf" || object.Size != int64(len(data)) || object.ModTime.Before(before) {
+			t.Errorf("Get object = %+v", object)
+		}
+
+		stat, err := s.Stat(ctx, "documents/a.pdf")
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		if stat.Key != "documents/a.pdf" || stat.Size != int64(len(data)) {
+			t.Errorf("Stat = %+v", stat)
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		put(t, "documents/b.pdf", "old content")
+		put(t, "documents/b.pdf", "new")
+		if data, object := read(t, "documents/b.pdf"); data != "new" || object.Size != 3 {
+			t.Errorf("Get = %q, %+v, want the new content", data, object)
+		}
+	})
+
+	t.Run("seek and read at", func(t *testing.T) {
+		put(t, "outputs/c.json", "0123456789")
+		r, _, err := s.Get(ctx, "outputs/c.json")
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		defer r.Close()
+
+		p := make([]byte, 3)
+		if _, err := r.ReadAt(p, 4); err != nil || string(p) != "456" {
+			t.Errorf("ReadAt = %q, %v, want 456", p, err)
+		}
+		if _, err := r.Seek(7, io.SeekStart); err != nil {
+			t.Fatalf("Seek: %v", err)
+		}
+		if rest, err := io.ReadAll(r); err != nil || string(rest) != "789" {
+			t.Errorf("read after Seek = %q, %v, want 789", rest, err)
+		}
+
+		// Reads at offsets stop at the end of the object
+		p = make([]byte, 5)
+		if n, err := r.ReadAt(p, 8); n != 2 || err != io.EOF || string(p[:n]) != "89" {
+			t.Errorf("ReadAt past the end = %q, %v, want 89, EOF", p[:n], err)
+		}
+		if n, err := r.ReadAt(p, 10); n != 0 || err != io.EOF {
+			t.Errorf("ReadAt at the end = %d, %v, want 0, EOF", n, err)
+		}
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+		if _, _, err := s.Get(ctx, "documents/missing.pdf"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get = %v, want ErrNotFound", err)
+		}
+		if _, err := s.Stat(ctx, "documents/missing.pdf"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat = %v, want ErrNotFound", err)
+		}
+		if err := s.Delete(ctx, "documents/missing.pdf"); err != nil {
+			t.Errorf("Delete = %v, want nil", err)
+		}
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		for _, key := range []string{"", "/abs.pdf", "../escape.pdf", "documents/../../escape.pdf"} {
+			if err := s.Put(ctx, key, strings.NewReader("x"), 1, ""); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Put(%q) = %v, want ErrInvalidKey", key, err)
+			}
+			if _, _, err := s.Get(ctx, key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Get(%q) = %v, want ErrInvalidKey", key, err)
+			}
+			if _, err := s.Stat(ctx, key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Stat(%q) = %v, want ErrInvalidKey", key, err)
+			}
+			if err := s.Delete(ctx, key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Delete(%q) = %v, want ErrInvalidKey", key, err)
+			}
+			if _, err := s.SignedURL(ctx, key, time.Minute, ""); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("SignedURL(%q) = %v, want ErrInvalidKey", key, err)
+			}
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		put(t, "pages/1/page-1.png", "p1")
+		put(t, "pages/1/page-2.png", "p2")
+		put(t, "pages/12/page-1.png", "p3")
+
+		tests := []struct {
+			prefix string
+			want   []string
+		}{
+			{"pages/1/", []string{"pages/1/page-1.png", "pages/1/page-2.png"}},
+			{"pages/1", []string{"pages/1/page-1.png", "pages/1/page-2.png", "pages/12/page-1.png"}},
+			{"pages/1/page-2", []string{"pages/1/page-2.png"}},
+			{"pages/9/", nil},
+			{"imports/", nil},
+		}
+		for _, tt := range tests {
+			objects, err := s.List(ctx, tt.prefix)
+			if err != nil {
+				t.Fatalf("List(%q): %v", tt.prefix, err)
+			}
+			var keys []string
+			for _, object := range objects {
+				keys = append(keys, object.Key)
+				if object.Size != 2 {
+					t.Errorf("List(%q): %s has size %d, want 2", tt.prefix, object.Key, object.Size)
+				}
+			}
+			sort.Strings(keys)
+			if strings.Join(keys, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("List(%q) = %v, want %v", tt.prefix, keys, tt.want)
+			}
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		put(t, "imports/7.xlsx", "sheet")
+		if err := s.Delete(ctx, "imports/7.xlsx"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := s.Stat(ctx, "imports/7.xlsx"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat after Delete = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("ping", func(t *testing.T) {
+		if err := s.Ping(ctx); err != nil {
+			t.Errorf("Ping: %v", err)
+		}
+	})
+}
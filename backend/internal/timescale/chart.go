@@ -0,0 +1,266 @@
+package timescale
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Rank is the level of a chronostratigraphic unit as stored in the Period, Epoch and Age columns
+type Rank string
+
+const (
+	RankPeriod Rank = "period"
+	RankEpoch  Rank = "epoch"
+	RankAge    Rank = "age"
+)
+
+// Unit is a unit of the ICS chronostratigraphic chart. Start is its base and End its top, in Ma.
+type Unit struct {
+	Name   string  `json:"name"`
+	Rank   Rank    `json:"rank"`
+	Start  float64 `json:"start_ma"`
+	End    float64 `json:"end_ma"`
+	Parent string  `json:"parent,omitempty"`
+}
+
+// chartData is the Phanerozoic part of the ICS International Chronostratigraphic Chart, one unit per
+// line as rank|name|base Ma|top Ma. Epochs belong to the period above them and ages to the epoch above.
+const chartData = `
+period|Quaternary|2.58|0
+epoch|Holocene|0.0117|0
+age|Meghalayan|0.0042|0
+age|Northgrippian|0.0082|0.0042
+age|Greenlandian|0.0117|0.0082
+epoch|Pleistocene|2.58|0.0117
+age|Upper Pleistocene|0.129|0.0117
+age|Chibanian|0.774|0.129
+age|Calabrian|1.80|0.774
+age|Gelasian|2.58|1.80
+period|Neogene|23.03|2.58
+epoch|Pliocene|5.333|2.58
+age|Piacenzian|3.600|2.58
+age|Zanclean|5.333|3.600
+epoch|Miocene|23.03|5.333
+age|Messinian|7.246|5.333
+age|Tortonian|11.63|7.246
+age|Serravallian|13.82|11.63
+age|Langhian|15.98|13.82
+age|Burdigalian|20.44|15.98
+age|Aquitanian|23.03|20.44
+period|Paleogene|66.0|23.03
+epoch|Oligocene|33.9|23.03
+age|Chattian|27.82|23.03
+age|Rupelian|33.9|27.82
+epoch|Eocene|56.0|33.9
+age|Priabonian|37.71|33.9
+age|Bartonian|41.2|37.71
+age|Lutetian|47.8|41.2
+age|Ypresian|56.0|47.8
+epoch|Paleocene|66.0|56.0
+age|Thanetian|59.2|56.0
+age|Selandian|61.6|59.2
+age|Danian|66.0|61.6
+period|Cretaceous|145.0|66.0
+epoch|Upper Cretaceous|100.5|66.0
+age|Maastrichtian|72.1|66.0
+age|Campanian|83.6|72.1
+age|Santonian|86.3|83.6
+age|Coniacian|89.8|86.3
+age|Turonian|93.9|89.8
+age|Cenomanian|100.5|93.9
+epoch|Lower Cretaceous|145.0|100.5
+age|Albian|113.0|100.5
+age|Aptian|121.4|113.0
+age|Barremian|125.77|121.4
+age|Hauterivian|132.6|125.77
+age|Valanginian|139.8|132.6
+age|Berriasian|145.0|139.8
+period|Jurassic|201.4|145.0
+epoch|Upper Jurassic|161.5|145.0
+age|Tithonian|149.2|145.0
+age|Kimmeridgian|154.8|149.2
+age|Oxfordian|161.5|154.8
+epoch|Middle Jurassic|174.7|161.5
+age|Callovian|165.3|161.5
+age|Bathonian|168.2|165.3
+age|Bajocian|170.9|168.2
+age|Aalenian|174.7|170.9
+epoch|Lower Jurassic|201.4|174.7
+age|Toarcian|184.2|174.7
+age|Pliensbachian|192.9|184.2
+age|Sinemurian|199.5|192.9
+age|Hettangian|201.4|199.5
+period|Triassic|251.902|201.4
+epoch|Upper Triassic|237.0|201.4
+age|Rhaetian|208.5|201.4
+age|Norian|227.0|208.5
+age|Carnian|237.0|227.0
+epoch|Middle Triassic|247.2|237.0
+age|Ladinian|242.0|237.0
+age|Anisian|247.2|242.0
+epoch|Lower Triassic|251.902|247.2
+age|Olenekian|251.2|247.2
+age|Induan|251.902|251.2
+period|Permian|298.9|251.902
+epoch|Lopingian|259.51|251.902
+age|Changhsingian|254.14|251.902
+age|Wuchiapingian|259.51|254.14
+epoch|Guadalupian|273.01|259.51
+age|Capitanian|264.28|259.51
+age|Wordian|266.9|264.28
+age|Roadian|273.01|266.9
+epoch|Cisuralian|298.9|273.01
+age|Kungurian|283.5|273.01
+age|Artinskian|290.1|283.5
+age|Sakmarian|293.52|290.1
+age|Asselian|298.9|293.52
+period|Carboniferous|358.9|298.9
+epoch|Upper Pennsylvanian|307.0|298.9
+age|Gzhelian|303.7|298.9
+age|Kasimovian|307.0|303.7
+epoch|Middle Pennsylvanian|315.2|307.0
+age|Moscovian|315.2|307.0
+epoch|Lower Pennsylvanian|323.2|315.2
+age|Bashkirian|323.2|315.2
+epoch|Upper Mississippian|330.9|323.2
+age|Serpukhovian|330.9|323.2
+epoch|Middle Mississippian|346.7|330.9
+age|Visean|346.7|330.9
+epoch|Lower Mississippian|358.9|346.7
+age|Tournaisian|358.9|346.7
+period|Devonian|419.2|358.9
+epoch|Upper Devonian|382.7|358.9
+age|Famennian|372.2|358.9
+age|Frasnian|382.7|372.2
+epoch|Middle Devonian|393.3|382.7
+age|Givetian|387.7|382.7
+age|Eifelian|393.3|387.7
+epoch|Lower Devonian|419.2|393.3
+age|Emsian|407.6|393.3
+age|Pragian|410.8|407.6
+age|Lochkovian|419.2|410.8
+period|Silurian|443.8|419.2
+epoch|Pridoli|423.0|419.2
+epoch|Ludlow|427.4|423.0
+age|Ludfordian|425.6|423.0
+age|Gorstian|427.4|425.6
+epoch|Wenlock|433.4|427.4
+age|Homerian|430.5|427.4
+age|Sheinwoodian|433.4|430.5
+epoch|Llandovery|443.8|433.4
+age|Telychian|438.5|433.4
+age|Aeronian|440.8|438.5
+age|Rhuddanian|443.8|440.8
+period|Ordovician|485.4|443.8
+epoch|Upper Ordovician|458.4|443.8
+age|Hirnantian|445.2|443.8
+age|Katian|453.0|445.2
+age|Sandbian|458.4|453.0
+epoch|Middle Ordovician|470.0|458.4
+age|Darriwilian|467.3|458.4
+age|Dapingian|470.0|467.3
+epoch|Lower Ordovician|485.4|470.0
+age|Floian|477.7|470.0
+age|Tremadocian|485.4|477.7
+period|Cambrian|538.8|485.4
+epoch|Furongian|497.0|485.4
+age|Cambrian Stage 10|489.5|485.4
+age|Jiangshanian|494.0|489.5
+age|Paibian|497.0|494.0
+epoch|Miaolingian|509.0|497.0
+age|Guzhangian|500.5|497.0
+age|Drumian|504.5|500.5
+age|Wuliuan|509.0|504.5
+epoch|Cambrian Series 2|521.0|509.0
+age|Cambrian Stage 4|514.0|509.0
+age|Cambrian Stage 3|521.0|514.0
+epoch|Terreneuvian|538.8|521.0
+age|Cambrian Stage 2|529.0|521.0
+age|Fortunian|538.8|529.0
+`
+
+// informalIntervals are widely used names that are not units of the three ranks: eras, the Tertiary,
+// Carboniferous subperiods and the early/middle/late subdivisions of Cenozoic epochs
+var informalIntervals = map[string][2]float64{
+	"cenozoic":           {66.0, 0},
+	"mesozoic":           {251.902, 66.0},
+	"paleozoic":          {538.8, 251.902},
+	"phanerozoic":        {538.8, 0},
+	"tertiary":           {66.0, 2.58},
+	"pennsylvanian":      {323.2, 298.9},
+	"mississippian":      {358.9, 323.2},
+	"early paleocene":    {66.0, 61.6},
+	"middle paleocene":   {61.6, 59.2},
+	"late paleocene":     {59.2, 56.0},
+	"early eocene":       {56.0, 47.8},
+	"middle eocene":      {47.8, 37.71},
+	"late eocene":        {37.71, 33.9},
+	"early oligocene":    {33.9, 27.82},
+	"late oligocene":     {27.82, 23.03},
+	"early miocene":      {23.03, 15.98},
+	"middle miocene":     {15.98, 11.63},
+	"late miocene":       {11.63, 5.333},
+	"early pliocene":     {5.333, 3.600},
+	"late pliocene":      {3.600, 2.58},
+	"early pleistocene":  {2.58, 0.774},
+	"middle pleistocene": {0.774, 0.129},
+	"late pleistocene":   {0.129, 0.0117},
+	"recent":             {0.0117, 0},
+	"plio pleistocene":   {5.333, 0.0117},
+	"oligo miocene":      {33.9, 5.333},
+}
+
+// Units holds the chart from youngest to oldest, as printed
+var Units []Unit
+
+// byName indexes units by normalized name, including "early"/"late" forms of "lower"/"upper" epochs
+var byName = map[string]*Unit{}
+
+func init() {
+	var period, epoch string
+	for _, line := range strings.Split(strings.TrimSpace(chartData), "\n") {
+		fields := strings.Split(line, "|")
+		start, err1 := strconv.ParseFloat(fields[2], 64)
+		end, err2 := strconv.ParseFloat(fields[3], 64)
+		if len(fields) != 4 || err1 != nil || err2 != nil {
+			panic("timescale: bad chart line " + line)
+		}
+
+		unit := Unit{Name: fields[1], Rank: Rank(fields[0]), Start: start, End: end}
+		switch unit.Rank {
+		case RankPeriod:
+			period = unit.Name
+		case RankEpoch:
+			epoch = unit.Name
+			unit.Parent = period
+		case RankAge:
+			unit.Parent = epoch
+		}
+		Units = append(Units, unit)
+	}
+
+	for i := range Units {
+		unit := &Units[i]
+		key := normalize(unit.Name)
+		byName[key] = unit
+		switch {
+		case strings.HasPrefix(key, "lower "):
+			byName["early "+strings.TrimPrefix(key, "lower ")] = unit
+		case strings.HasPrefix(key, "upper "):
+			byName["late "+strings.TrimPrefix(key, "upper ")] = unit
+		}
+	}
+}
+
+// Lookup returns the unit with a name, ignoring case and spelling variants such as "Palaeogene"
+func Lookup(name string) *Unit {
+	return byName[normalize(name)]
+}
+
+// ParentUnit returns the unit's parent, or nil for periods
+func (u *Unit) ParentUnit() *Unit {
+	if u.Parent == "" {
+		return nil
+	}
+	return byName[normalize(u.Parent)]
+}
@@ -0,0 +1,108 @@
+package timescale
+
+import "testing"
+
+func TestChartTilesParents(t *testing.T) {
+	for i := range Units {
+		unit := &Units[i]
+		if unit.Start <= unit.End {
+			t.Errorf("%s: base %v is not older than top %v", unit.Name, unit.Start, unit.End)
+		}
+		if unit.Rank != RankPeriod && unit.ParentUnit() == nil {
+			t.Errorf("%s: parent %q is not in the chart", unit.Name, unit.Parent)
+		}
+
+		// Children are youngest first and must cover the parent without gaps or overlaps
+		children := unit.Children()
+		if len(children) == 0 {
+			// The Pridoli is the one epoch the ICS leaves undivided
+			if unit.Rank == RankPeriod {
+				t.Errorf("%s: period has no epochs", unit.Name)
+			}
+			continue
+		}
+		if children[0].End != unit.End {
+			t.Errorf("%s: youngest child %s ends at %v, want %v", unit.Name, children[0].Name, children[0].End, unit.End)
+		}
+		if last := children[len(children)-1]; last.Start != unit.Start {
+			t.Errorf("%s: oldest child %s starts at %v, want %v", unit.Name, last.Name, last.Start, unit.Start)
+		}
+		for j := 1; j < len(children); j++ {
+			if children[j].End != children[j-1].Start {
+				t.Errorf("%s: %s ends at %v but %s starts at %v", unit.Name, children[j].Name, children[j].End, children[j-1].Name, children[j-1].Start)
+			}
+		}
+	}
+}
+
+func TestChartPeriodsAreContiguous(t *testing.T) {
+	var periods []*Unit
+	for i := range Units {
+		if Units[i].Rank == RankPeriod {
+			periods = append(periods, &Units[i])
+		}
+	}
+	if periods[0].End != 0 {
+		t.Errorf("youngest period %s ends at %v, want 0", periods[0].Name, periods[0].End)
+	}
+	for i := 1; i < len(periods); i++ {
+		if periods[i].End != periods[i-1].Start {
+			t.Errorf("%s ends at %v but %s starts at %v", periods[i].Name, periods[i].End, periods[i-1].Name, periods[i-1].Start)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Miocene", "Miocene"},
+		{"  miocene ", "Miocene"},
+		{"Palaeogene", "Paleogene"},
+		{"PALAEOCENE", "Paleocene"},
+		{"Early Cretaceous", "Lower Cretaceous"},
+		{"late jurassic", "Upper Jurassic"},
+		{"Cambrian Stage 10", "Cambrian Stage 10"},
+		{"Upper-Pleistocene", "Upper Pleistocene"},
+		{"Tertiary", ""},
+		{"Mio", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unit := Lookup(tt.name)
+			got := ""
+			if unit != nil {
+				got = unit.Name
+			}
+			if got != tt.want {
+				t.Errorf("Lookup(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParentUnit(t *testing.T) {
+	tests := []struct {
+		name, parent string
+	}{
+		{"Serravallian", "Miocene"},
+		{"Miocene", "Neogene"},
+		{"Lower Cretaceous", "Cretaceous"},
+		{"Neogene", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := Lookup(tt.name).ParentUnit()
+			got := ""
+			if parent != nil {
+				got = parent.Name
+			}
+			if got != tt.parent {
+				t.Errorf("ParentUnit of %s = %q, want %q", tt.name, got, tt.parent)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package timescale
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrInconsistent is returned by Derive when period, epoch and age describe different times
+var ErrInconsistent = errors.New("period, epoch and age are inconsistent")
+
+// epsilon absorbs rounding of boundaries stored as decimals
+const epsilon = 1e-4
+
+// Interval is a span of geologic time in Ma; Start is the older bound. A single age in Ma has Start == End.
+type Interval struct {
+	Start float64 `json:"start_ma"`
+	End   float64 `json:"end_ma"`
+}
+
+// numericAge matches ages written in years before present: "15 Ma", "c. 15.2 Ma", "23-5.3 Ma", "15 ± 1 Ma", "800 ka"
+var numericAge = regexp.MustCompile(`^(?:~|c\.?|ca\.?|approx\.?)?\s*(\d+(?:\.\d+)?)\s*(?:(?:-|–|to)\s*(\d+(?:\.\d+)?)|(?:±|\+/-)\s*(\d+(?:\.\d+)?))?\s*(ma|mya|my|m\.y\.|myr|ka|kyr)?\.?$`)
+
+// rangeSeparator splits ranges of named units such as "Oligocene-Miocene" or "Late Eocene to Early Oligocene"
+var rangeSeparator = regexp.MustCompile(`\s*(?:-|–|—|/|\bto\b)\s*`)
+
+// Parse reads a unit name, abbreviation, informal subdivision, range or numeric age, e.g. "Mio.",
+// "Middle Miocene", "Serravallian", "Palaeogene", "Oligo-Miocene" or "15 Ma"
+func Parse(text string) (Interval, error) {
+	value := strings.ToLower(strings.TrimSpace(text))
+	if value == "" {
+		return Interval{}, errors.New("empty value")
+	}
+
+	if m := numericAge.FindStringSubmatch(value); m != nil {
+		scale := 1.0
+		if strings.HasPrefix(m[4], "k") {
+			scale = 0.001
+		}
+		first, _ := strconv.ParseFloat(m[1], 64)
+		interval := Interval{Start: first * scale, End: first * scale}
+		if m[2] != "" {
+			second, _ := strconv.ParseFloat(m[2], 64)
+			interval = Interval{Start: math.Max(first, second) * scale, End: math.Min(first, second) * scale}
+		} else if m[3] != "" {
+			margin, _ := strconv.ParseFloat(m[3], 64)
+			interval = Interval{Start: (first + margin) * scale, End: math.Max(0, first-margin) * scale}
+		}
+		if interval.Start > Units[len(Units)-1].Start {
+			return Interval{}, fmt.Errorf("%q is older than the Phanerozoic", text)
+		}
+		return interval, nil
+	}
+
+	if interval, ok := informalIntervals[normalize(value)]; ok {
+		return Interval{Start: interval[0], End: interval[1]}, nil
+	}
+
+	parts := rangeSeparator.Split(value, -1)
+	var result *Interval
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		interval, err := parseName(part)
+		if err != nil {
+			return Interval{}, fmt.Errorf("%q: %w", text, err)
+		}
+		if result == nil {
+			result = &interval
+		} else {
+			result.Start = math.Max(result.Start, interval.Start)
+			result.End = math.Min(result.End, interval.End)
+		}
+	}
+	if result == nil {
+		return Interval{}, fmt.Errorf("%q is not a geologic time", text)
+	}
+	return *result, nil
+}
+
+// parseName reads a single unit name, optionally qualified by early/middle/late
+func parseName(text string) (Interval, error) {
+	key := normalize(text)
+	if interval, ok := informalIntervals[key]; ok {
+		return Interval{Start: interval[0], End: interval[1]}, nil
+	}
+	if unit, ok := byName[key]; ok {
+		return unit.Interval(), nil
+	}
+
+	words := strings.Fields(key)
+	qualifier := ""
+	if len(words) > 1 {
+		switch words[0] {
+		case "early", "lower", "earliest", "lowermost":
+			qualifier = "early"
+		case "middle", "mid":
+			qualifier = "middle"
+		case "late", "upper", "latest", "uppermost":
+			qualifier = "late"
+		}
+		if qualifier != "" {
+			words = words[1:]
+		}
+	}
+
+	name, err := findName(strings.Join(words, " "))
+	if err != nil {
+		return Interval{}, err
+	}
+	if interval, ok := informalIntervals[name]; ok && qualifier == "" {
+		return Interval{Start: interval[0], End: interval[1]}, nil
+	}
+	unit := byName[name]
+	if qualifier == "" || unit == nil {
+		if unit == nil {
+			interval := informalIntervals[name]
+			return Interval{Start: interval[0], End: interval[1]}, nil
+		}
+		return unit.Interval(), nil
+	}
+
+	// "Late Cretaceous", "Middle Miocene"
+	if qualified, ok := byName[qualifier+" "+name]; ok {
+		return qualified.Interval(), nil
+	}
+	if interval, ok := informalIntervals[qualifier+" "+name]; ok {
+		return Interval{Start: interval[0], End: interval[1]}, nil
+	}
+
+	// "Early Neogene": the oldest, middle or youngest child of the unit
+	children := unit.Children()
+	if len(children) > 1 {
+		switch {
+		case qualifier == "early":
+			return children[len(children)-1].Interval(), nil
+		case qualifier == "late":
+			return children[0].Interval(), nil
+		case len(children) == 3:
+			return children[1].Interval(), nil
+		}
+	}
+	return unit.Interval(), nil
+}
+
+// findName resolves a normalized name or an unambiguous abbreviation such as "mio" or "cret"
+func findName(key string) (string, error) {
+	if _, ok := byName[key]; ok {
+		return key, nil
+	}
+	if _, ok := informalIntervals[key]; ok {
+		return key, nil
+	}
+	if len(key) < 3 {
+		return "", fmt.Errorf("%q is not a geologic time", key)
+	}
+
+	candidates := map[string]bool{}
+	for name := range byName {
+		if strings.HasPrefix(name, key) {
+			candidates[name] = true
+		}
+	}
+	for name := range informalIntervals {
+		if strings.HasPrefix(name, key) && !strings.Contains(name, " ") {
+			candidates[name] = true
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return "", fmt.Errorf("%q is not a geologic time", key)
+	case 1:
+		for name := range candidates {
+			return name, nil
+		}
+	}
+	names := make([]string, 0, len(candidates))
+	for name := range candidates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("%q is ambiguous: %s", key, strings.Join(names, ", "))
+}
+
+// normalize lower-cases a name, folds British spellings and drops punctuation
+func normalize(name string) string {
+	value := strings.ToLower(name)
+	value = strings.ReplaceAll(value, "palaeo", "paleo")
+	value = strings.ReplaceAll(value, "æ", "e")
+	return strings.Join(strings.FieldsFunc(value, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// Interval returns the span of the unit
+func (u *Unit) Interval() Interval {
+	return Interval{Start: u.Start, End: u.End}
+}
+
+// Children returns the units one rank below, youngest first
+func (u *Unit) Children() []*Unit {
+	var children []*Unit
+	for i := range Units {
+		if Units[i].Parent == u.Name {
+			children = append(children, &Units[i])
+		}
+	}
+	return children
+}
+
+// IsPoint reports whether the interval is a single age
+func (i Interval) IsPoint() bool {
+	return math.Abs(i.Start-i.End) < epsilon
+}
+
+// Overlaps reports whether two intervals share time. A single age on a boundary belongs to the
+// younger unit's base, so 23.03 Ma is Miocene and not Oligocene.
+func (i Interval) Overlaps(other Interval) bool {
+	switch {
+	case i.IsPoint() && other.IsPoint():
+		return math.Abs(i.Start-other.Start) < epsilon
+	case i.IsPoint():
+		return other.containsAge(i.Start)
+	case other.IsPoint():
+		return i.containsAge(other.Start)
+	default:
+		return math.Min(i.Start, other.Start)-math.Max(i.End, other.End) > epsilon
+	}
+}
+
+// containsAge applies the base-inclusive boundary rule to a single age
+func (i Interval) containsAge(age float64) bool {
+	if age <= epsilon && i.End <= epsilon {
+		return true
+	}
+	return age > i.End+epsilon/2 && age <= i.Start+epsilon/2
+}
+
+// Within reports whether the interval lies inside another
+func (i Interval) Within(other Interval) bool {
+	if i.IsPoint() {
+		return other.containsAge(i.Start)
+	}
+	return i.Start <= other.Start+epsilon && i.End >= other.End-epsilon
+}
+
+// Intersect returns the time two overlapping intervals share
+func (i Interval) Intersect(other Interval) Interval {
+	if i.IsPoint() {
+		return i
+	}
+	if other.IsPoint() {
+		return other
+	}
+	return Interval{Start: math.Min(i.Start, other.Start), End: math.Max(i.End, other.End)}
+}
+
+// String renders the interval as "23.03–5.333 Ma" or "15 Ma"
+func (i Interval) String() string {
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	if i.IsPoint() {
+		return format(i.Start) + " Ma"
+	}
+	return format(i.Start) + "–" + format(i.End) + " Ma"
+}
+
+// Containing returns the unit of a rank that contains the whole interval, or nil
+func Containing(interval Interval, rank Rank) *Unit {
+	for i := range Units {
+		if Units[i].Rank == rank && interval.Within(Units[i].Interval()) {
+			return &Units[i]
+		}
+	}
+	return nil
+}
+
+// Derivation is what Derive found in a record's Period, Epoch and Age
+type Derivation struct {
+	// Interval is the time all parsed fields agree on; nil when none could be parsed
+	Interval *Interval `json:"interval"`
+	Period   *Unit     `json:"period"`
+	Epoch    *Unit     `json:"epoch"`
+	Age      *Unit     `json:"age"`
+	// Unparsed lists the fields whose text is not a recognizable geologic time
+	Unparsed []Rank `json:"unparsed,omitempty"`
+}
+
+// Derive parses the period, epoch and age texts, checks that they describe overlapping time and
+// returns the shared interval with the chart units containing it at each rank
+func Derive(period, epoch, age string) (*Derivation, error) {
+	derivation := &Derivation{}
+	type parsed struct {
+		rank     Rank
+		text     string
+		interval Interval
+	}
+	var fields []parsed
+
+	for _, field := range []struct {
+		rank Rank
+		text string
+	}{{RankPeriod, period}, {RankEpoch, epoch}, {RankAge, age}} {
+		if strings.TrimSpace(field.text) == "" {
+			continue
+		}
+		interval, err := Parse(field.text)
+		if err != nil {
+			derivation.Unparsed = append(derivation.Unparsed, field.rank)
+			continue
+		}
+		for _, other := range fields {
+			if !interval.Overlaps(other.interval) {
+				return nil, fmt.Errorf("%w: %s %q (%s) does not overlap %s %q (%s)", ErrInconsistent,
+					field.rank, field.text, interval, other.rank, other.text, other.interval)
+			}
+		}
+		fields = append(fields, parsed{rank: field.rank, text: field.text, interval: interval})
+	}
+	if len(fields) == 0 {
+		return derivation, nil
+	}
+
+	shared := fields[0].interval
+	for _, field := range fields[1:] {
+		shared = shared.Intersect(field.interval)
+	}
+	derivation.Interval = &shared
+	derivation.Period = Containing(shared, RankPeriod)
+	derivation.Epoch = Containing(shared, RankEpoch)
+	derivation.Age = Containing(shared, RankAge)
+	return derivation, nil
+}
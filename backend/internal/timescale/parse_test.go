@@ -0,0 +1,237 @@
+package timescale
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Interval
+		wantErr string
+	}{
+		// Chart units
+		{text: "Miocene", want: Interval{23.03, 5.333}},
+		{text: "Serravallian", want: Interval{13.82, 11.63}},
+		{text: "Palaeogene", want: Interval{66.0, 23.03}},
+		{text: "Late Cretaceous", want: Interval{100.5, 66.0}},
+		// Informal names and subdivisions
+		{text: "Tertiary", want: Interval{66.0, 2.58}},
+		{text: "Middle Miocene", want: Interval{15.98, 11.63}},
+		{text: "mid Miocene", want: Interval{15.98, 11.63}},
+		{text: "Early Eocene", want: Interval{56.0, 47.8}},
+		{text: "Recent", want: Interval{0.0117, 0}},
+		// Qualified units without an informal subdivision take the oldest, middle or youngest child
+		{text: "Early Neogene", want: Interval{23.03, 5.333}},
+		{text: "Late Paleogene", want: Interval{33.9, 23.03}},
+		{text: "Middle Jurassic", want: Interval{174.7, 161.5}},
+		// Abbreviations
+		{text: "Mio.", want: Interval{23.03, 5.333}},
+		{text: "Cret", want: Interval{145.0, 66.0}},
+		{text: "Upper Mio", want: Interval{11.63, 5.333}},
+		// Ranges
+		{text: "Oligocene-Miocene", want: Interval{33.9, 5.333}},
+		{text: "Oligo-Miocene", want: Interval{33.9, 5.333}},
+		{text: "Late Eocene to Early Oligocene", want: Interval{37.71, 27.82}},
+		{text: "Burdigalian / Langhian", want: Interval{20.44, 13.82}},
+		// Numeric ages
+		{text: "15 Ma", want: Interval{15, 15}},
+		{text: "c. 15.2 Ma", want: Interval{15.2, 15.2}},
+		{text: "5.3-23 Ma", want: Interval{23, 5.3}},
+		{text: "15 ± 1 Ma", want: Interval{16, 14}},
+		{text: "0.5 +/- 1 Ma", want: Interval{1.5, 0}},
+		{text: "800 ka", want: Interval{0.8, 0.8}},
+		// Rejected
+		{text: "", wantErr: "empty value"},
+		{text: "Jurassic Park", wantErr: "not a geologic time"},
+		{text: "Pa", wantErr: "not a geologic time"},
+		{text: "Lo", wantErr: "not a geologic time"},
+		{text: "Pal", wantErr: "ambiguous"},
+		{text: "600 Ma", wantErr: "older than the Phanerozoic"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := Parse(tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse(%q) = %v, %v, want error %q", tt.text, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.text, err)
+			}
+			if math.Abs(got.Start-tt.want.Start) > 1e-9 || math.Abs(got.End-tt.want.End) > 1e-9 {
+				t.Errorf("Parse(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntervalOverlaps(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Interval
+		want bool
+	}{
+		{"nested", Interval{23.03, 5.333}, Interval{15.98, 11.63}, true},
+		{"adjacent units do not overlap", Interval{33.9, 23.03}, Interval{23.03, 5.333}, false},
+		{"boundary age belongs to the younger unit's base", Interval{23.03, 23.03}, Interval{23.03, 5.333}, true},
+		{"boundary age is not in the older unit", Interval{23.03, 23.03}, Interval{33.9, 23.03}, false},
+		{"present day is in the youngest unit", Interval{0, 0}, Interval{0.0042, 0}, true},
+		{"equal points", Interval{15, 15}, Interval{15, 15}, true},
+		{"distinct points", Interval{15, 15}, Interval{16, 16}, false},
+		{"disjoint", Interval{145.0, 66.0}, Interval{23.03, 5.333}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Overlaps(tt.b); got != tt.want {
+				t.Errorf("%v.Overlaps(%v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got := tt.b.Overlaps(tt.a); got != tt.want {
+				t.Errorf("%v.Overlaps(%v) = %v, want %v", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContaining(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval Interval
+		rank     Rank
+		want     string
+	}{
+		{"age in a period", Interval{15, 15}, RankPeriod, "Neogene"},
+		{"age in an epoch", Interval{15, 15}, RankEpoch, "Miocene"},
+		{"age in an age", Interval{15, 15}, RankAge, "Langhian"},
+		{"boundary age in its younger age", Interval{23.03, 23.03}, RankAge, "Aquitanian"},
+		{"span across ages", Interval{15.98, 11.63}, RankAge, ""},
+		{"span in its epoch", Interval{15.98, 11.63}, RankEpoch, "Miocene"},
+		{"span across periods", Interval{33.9, 5.333}, RankPeriod, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unit := Containing(tt.interval, tt.rank)
+			got := ""
+			if unit != nil {
+				got = unit.Name
+			}
+			if got != tt.want {
+				t.Errorf("Containing(%v, %s) = %q, want %q", tt.interval, tt.rank, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDerive(t *testing.T) {
+	tests := []struct {
+		name                           string
+		period, epoch, age             string
+		wantInterval                   *Interval
+		wantPeriod, wantEpoch, wantAge string
+		wantUnparsed                   []Rank
+		wantErr                        error
+	}{
+		{
+			name:         "nothing given",
+			wantInterval: nil,
+		},
+		{
+			name:         "age fills epoch and period",
+			age:          "Serravallian",
+			wantInterval: &Interval{13.82, 11.63},
+			wantPeriod:   "Neogene", wantEpoch: "Miocene", wantAge: "Serravallian",
+		},
+		{
+			name:         "epoch fills period only",
+			epoch:        "Middle Miocene",
+			wantInterval: &Interval{15.98, 11.63},
+			wantPeriod:   "Neogene", wantEpoch: "Miocene",
+		},
+		{
+			name:         "consistent fields narrow each other",
+			period:       "Tertiary",
+			epoch:        "Miocene",
+			age:          "15 Ma",
+			wantInterval: &Interval{15, 15},
+			wantPeriod:   "Neogene", wantEpoch: "Miocene", wantAge: "Langhian",
+		},
+		{
+			name:         "range across epochs keeps the period",
+			epoch:        "Oligocene-Miocene",
+			wantInterval: &Interval{33.9, 5.333},
+		},
+		{
+			name:         "unparsed fields are reported and skipped",
+			period:       "Neogene",
+			epoch:        "Middle Earth",
+			wantInterval: &Interval{23.03, 2.58},
+			wantPeriod:   "Neogene",
+			wantUnparsed: []Rank{RankEpoch},
+		},
+		{
+			name:    "epoch outside the period",
+			period:  "Cretaceous",
+			epoch:   "Miocene",
+			wantErr: ErrInconsistent,
+		},
+		{
+			name:    "age outside the epoch",
+			epoch:   "Oligocene",
+			age:     "Langhian",
+			wantErr: ErrInconsistent,
+		},
+		{
+			name:    "adjacent units are inconsistent",
+			period:  "Paleogene",
+			age:     "Aquitanian",
+			wantErr: ErrInconsistent,
+		},
+	}
+
+	unitName := func(unit *Unit) string {
+		if unit == nil {
+			return ""
+		}
+		return unit.Name
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			derivation, err := Derive(tt.period, tt.epoch, tt.age)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Derive error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Derive: %v", err)
+			}
+
+			if !reflect.DeepEqual(derivation.Interval, tt.wantInterval) {
+				t.Errorf("interval = %v, want %v", derivation.Interval, tt.wantInterval)
+			}
+			if got := unitName(derivation.Period); got != tt.wantPeriod {
+				t.Errorf("period = %q, want %q", got, tt.wantPeriod)
+			}
+			if got := unitName(derivation.Epoch); got != tt.wantEpoch {
+				t.Errorf("epoch = %q, want %q", got, tt.wantEpoch)
+			}
+			if got := unitName(derivation.Age); got != tt.wantAge {
+				t.Errorf("age = %q, want %q", got, tt.wantAge)
+			}
+			if !reflect.DeepEqual(derivation.Unparsed, tt.wantUnparsed) {
+				t.Errorf("unparsed = %v, want %v", derivation.Unparsed, tt.wantUnparsed)
+			}
+		})
+	}
+}
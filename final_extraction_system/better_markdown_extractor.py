@@ -17,8 +17,14 @@ from datetime import datetime
 # -----------------------------
 # CONFIG
 # -----------------------------
-PDF_DIR = "./input_pdfs"
-OUTPUT_DIR = "./output"
+# The backend passes its configured directories; the defaults match a run from this directory
+PDF_DIR = os.environ.get("EXTRACTION_INPUT_DIR", "./input_pdfs")
+# When set, only this PDF of PDF_DIR is processed, so concurrent workers each handle their own upload
+PDF_FILE = os.environ.get("EXTRACTION_INPUT_FILE", "")
+OUTPUT_DIR = os.environ.get("EXTRACTION_OUTPUT_DIR", "./output")
+# A re-extraction narrows the pages ("1-3,7") and picks the flavors ("lattice", "stream" or both)
+PAGES = os.environ.get("EXTRACTION_PAGES", "")
+FLAVORS = [f.strip() for f in os.environ.get("EXTRACTION_FLAVORS", "stream").split(",") if f.strip()]
 
 # Camelot Settings
 camelot_config = {
@@ -32,18 +38,30 @@ quality_config = {
     "MIN_TABLE_CONFIDENCE": 50,
     "MIN_TABLE_ROWS": 3,
     "MIN_TABLE_COLS": 2,
+    "MIN_FILLED_RATIO": 0.4,
     "MAX_DUPLICATE_SIMILARITY": 0.8,
     "ENABLE_DUPLICATE_FILTERING": True,
 }
 
+# The backend passes the thresholds of a re-extraction
+for key, env, cast in [
+    ("MIN_TABLE_CONFIDENCE", "EXTRACTION_MIN_CONFIDENCE", float),
+    ("MIN_TABLE_ROWS", "EXTRACTION_MIN_ROWS", int),
+    ("MIN_TABLE_COLS", "EXTRACTION_MIN_COLS", int),
+    ("MIN_FILLED_RATIO", "EXTRACTION_MIN_FILLED", float),
+]:
+    if os.environ.get(env):
+        quality_config[key] = cast(os.environ[env])
+
 
 # -----------------------------
 # SETUP
 # -----------------------------
 os.makedirs(f"{OUTPUT_DIR}/markdown", exist_ok=True)
 
-# Setup logging
-logging.basicConfig(level=logging.INFO, format='%(asctime)s - %(levelname)s - %(message)s')
+# Setup logging; the backend parses this format line by line, and passes its level in LOG_LEVEL
+LOG_LEVEL = os.environ.get("LOG_LEVEL", "INFO").upper()
+logging.basicConfig(level=getattr(logging, LOG_LEVEL, logging.INFO), format='%(asctime)s - %(levelname)s - %(message)s')
 logger = logging.getLogger(__name__)
 
 # -----------------------------
@@ -133,10 +151,8 @@ class BetterCamelotExtractor:
             # Convert to list of lists
             table_data = [df.columns.tolist()] + df.values.tolist()
             
-            # Calculate confidence based on accuracy
-            confidence = getattr(table, 'accuracy', 0.8)
-            if confidence < 0.5:
-                confidence = 0.5
+            # Camelot reports accuracy as a percentage; confidence is kept between 0 and 1
+            confidence = min(max(getattr(table, 'accuracy', 80.0) / 100, 0.0), 1.0)
             
             return {
                 'source': 'camelot',
@@ -183,15 +199,15 @@ class BetterCamelotExtractor:
             data = table_data['data']
             metadata = table_data['metadata']
             
-            # Must have at least 3 rows and 2 columns
-            if metadata['num_rows'] < 3 or metadata['num_cols'] < 2:
+            # Must have enough rows and columns (3 and 2 by default)
+            if metadata['num_rows'] < quality_config["MIN_TABLE_ROWS"] or metadata['num_cols'] < quality_config["MIN_TABLE_COLS"]:
                 return False
             
             # Must have reasonable confidence
-            if table_data['confidence'] < 0.5:
+            if table_data['confidence'] * 100 < quality_config["MIN_TABLE_CONFIDENCE"]:
                 return False
             
-            # Must have substantial content (at least 40% non-empty cells)
+            # Must have substantial content (at least 40% non-empty cells by default)
             non_empty_cells = 0
             total_cells = 0
             
@@ -201,7 +217,7 @@ class BetterCamelotExtractor:
                     if str(cell).strip() != '':
                         non_empty_cells += 1
             
-            if total_cells == 0 or non_empty_cells / total_cells < 0.4:
+            if total_cells == 0 or non_empty_cells / total_cells < quality_config["MIN_FILLED_RATIO"]:
                 return False
             
             # Check if it's a real data table (not just headers or formatting)
@@ -222,6 +238,16 @@ class BetterCamelotExtractor:
             return False
     
 
+def parse_pages(spec: str) -> Optional[List[int]]:
+    """Expand a page list such as "1-3,7"; an empty list means every page"""
+    if not spec:
+        return None
+    pages = []
+    for part in spec.split(","):
+        start, _, end = part.strip().partition("-")
+        pages.extend(range(int(start), int(end or start) + 1))
+    return pages
+
 def remove_duplicates(tables: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
     """Remove duplicate tables based on content hash"""
     seen_hashes = set()
@@ -352,7 +378,7 @@ def save_better_markdown(tables: List[Dict[str, Any]], pdf_name: str, pdf_path:
 
 **Extraction Date:** {extraction_date}
 **File Size:** {file_size:,} bytes
-**Pages Processed:** all
+**Pages Processed:** {PAGES or 'all'}
 **Extractors Used:** camelot
 
 ## Extraction Summary
@@ -373,7 +399,7 @@ This document contains extracted tables from the PDF file. Each table has been p
             table_num = i + 1
             page = table['page']
             method = table['method']
-            confidence = table['confidence']
+            confidence = table['confidence'] * 100
             rows = table['metadata']['num_rows']
             cols = table['metadata']['num_cols']
             
@@ -405,7 +431,10 @@ def main():
     logger.info("=" * 60)
     
     # Find PDF files
-    pdf_files = list(Path(PDF_DIR).glob("*.pdf"))
+    if PDF_FILE:
+        pdf_files = [Path(PDF_DIR) / PDF_FILE]
+    else:
+        pdf_files = list(Path(PDF_DIR).glob("*.pdf"))
     if not pdf_files:
         logger.error(f"❌ No PDF files found in {PDF_DIR}")
         return
@@ -421,7 +450,7 @@ def main():
             logger.info(f"🚀 Processing PDF: {pdf_path.name}")
             
             # Extract quality tables
-            tables = extractor.extract_tables(str(pdf_path))
+            tables = extractor.extract_tables(str(pdf_path), pages=parse_pages(PAGES), flavors=FLAVORS)
             
             if not tables:
                 logger.info(f"  No quality tables found in {pdf_path.name}")
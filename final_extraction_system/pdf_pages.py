@@ -0,0 +1,70 @@
+#!/usr/bin/env python3
+"""
+PDF page tool - single pages of a PDF, as a PDF or an image
+The backend runs it on demand for the viewer and stores the result, so each page is made once
+"""
+import argparse
+import logging
+import os
+import sys
+
+# Setup logging; the backend parses this format line by line, and passes its level in LOG_LEVEL
+LOG_LEVEL = os.environ.get("LOG_LEVEL", "INFO").upper()
+logging.basicConfig(level=getattr(logging, LOG_LEVEL, logging.INFO), format='%(asctime)s - %(levelname)s - %(message)s')
+logger = logging.getLogger(__name__)
+
+IMAGE_FORMATS = {"png": "PNG", "webp": "WEBP"}
+
+
+def write_page_pdf(pdf_path: str, page: int, output: str):
+    """Write one page of the PDF as a PDF of its own"""
+    from pypdf import PdfReader, PdfWriter
+
+    reader = PdfReader(pdf_path)
+    writer = PdfWriter()
+    # Only the objects the page uses are copied, so fonts and images of other pages are left out
+    writer.add_page(reader.pages[page - 1])
+    with open(output, "wb") as f:
+        writer.write(f)
+
+
+def render_page(pdf_path: str, page: int, output: str, image_format: str, dpi: int, width: int):
+    """Render one page of the PDF as an image, at dpi or, when width is set, that many pixels wide"""
+    import pypdfium2 as pdfium
+
+    document = pdfium.PdfDocument(pdf_path)
+    try:
+        pdf_page = document[page - 1]
+        # PDF units are 1/72 inch
+        scale = width / pdf_page.get_width() if width else dpi / 72
+        image = pdf_page.render(scale=scale).to_pil()
+        options = {"quality": 80} if image_format == "webp" else {"optimize": True}
+        image.save(output, format=IMAGE_FORMATS[image_format], **options)
+    finally:
+        document.close()
+
+
+def main():
+    parser = argparse.ArgumentParser(description="Write one page of a PDF as a PDF or an image")
+    parser.add_argument("pdf", help="input PDF")
+    parser.add_argument("page", type=int, help="page number, from 1")
+    parser.add_argument("output", help="file to write")
+    parser.add_argument("--format", choices=["pdf", *IMAGE_FORMATS], default="pdf")
+    parser.add_argument("--dpi", type=int, default=150, help="resolution of an image")
+    parser.add_argument("--width", type=int, default=0, help="width of an image in pixels, instead of --dpi")
+    args = parser.parse_args()
+
+    try:
+        if args.format == "pdf":
+            write_page_pdf(args.pdf, args.page, args.output)
+        else:
+            render_page(args.pdf, args.page, args.output, args.format, args.dpi, args.width)
+    except Exception as e:
+        logger.error(f"Failed to write page {args.page} of {args.pdf}: {e}")
+        sys.exit(1)
+
+    logger.info(f"Wrote page {args.page} as {args.format}: {args.output}")
+
+
+if __name__ == "__main__":
+    main()